@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/handlers"
+	"github.com/schollz/progressbar/v3"
+)
+
+// CatchupDownload downloads a catchup programme of a channel to a .ts file.
+// target is the programme srno or its start and end epochs as "start-end".
+func CatchupDownload(channelID, target, quality string) error {
+	// Load the login credentials for the catchup API
+	handlers.Init()
+
+	srno, start, end := handlers.ParseCatchupTarget(target)
+	request := handlers.CatchupDownloadRequest{
+		ChannelID: channelID,
+		Srno:      srno,
+		Start:     start,
+		End:       end,
+		Quality:   quality,
+	}
+
+	fmt.Println("Fetching catchup stream")
+	var bar *progressbar.ProgressBar
+	result, err := handlers.DownloadCatchup(request, func(progress handlers.CatchupDownloadProgress) {
+		if progress.TotalSegments == 0 {
+			return
+		}
+		if bar == nil {
+			fmt.Printf("Downloading %s\n", progress.Title)
+			bar = progressbar.Default(int64(progress.TotalSegments))
+		}
+		bar.Set(progress.DoneSegments)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Downloaded %.1f MB to %s\n", float64(result.Bytes)/(1<<20), result.File)
+	return nil
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/handlers"
+	"github.com/schollz/progressbar/v3"
+)
+
+// ChannelsProbe fetches the live stream of every channel to learn which of
+// them stream with DRM. The running server picks up the result on restart.
+func ChannelsProbe(concurrency int) error {
+	// Load the login credentials for the live stream API
+	handlers.Init()
+
+	fmt.Println("Probing channels for DRM")
+	var bar *progressbar.ProgressBar
+	report, err := handlers.ProbeDRMChannels(concurrency, func(progress handlers.DRMProbeProgress) {
+		if bar == nil {
+			bar = progressbar.Default(int64(progress.Total))
+		}
+		bar.Set(progress.Done)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Probed %d channels: %d with DRM, %d failed, %d changed\n", report.Total, report.DRM, report.Failed, report.Changed)
+	return nil
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/internal/constants"
+	"github.com/jiotv-go/jiotv_go/v3/internal/handlers"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+// DoctorCommandNames are the name and aliases of the doctor command. It
+// loads the config, the logger and the store itself, so that their errors do
+// not stop it from starting.
+var DoctorCommandNames = []string{"doctor", "dr"}
+
+// DoctorReport is the result of the doctor command
+type DoctorReport struct {
+	Version string                 `json:"version"`
+	OS      string                 `json:"os"`
+	Arch    string                 `json:"arch"`
+	Checks  []handlers.DoctorCheck `json:"checks"`
+}
+
+// Doctor checks the configuration, the store, the login and playback of the
+// default profile, and prints a report with hints to fix what fails. With
+// jsonOutput the report is printed as JSON to attach to bug reports.
+// Returns an error if a check failed.
+func Doctor(configPath string, jsonOutput bool) error {
+	if jsonOutput {
+		// Keep log lines out of the JSON report
+		utils.Log = log.New(io.Discard, "", 0)
+	} else {
+		// Until the path prefix for the log file is checked
+		utils.Log = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	report := DoctorReport{
+		Version: strings.TrimSpace(constants.Version),
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		Checks:  doctorChecks(configPath, jsonOutput, time.Now()),
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printDoctorReport(os.Stdout, report)
+	}
+
+	if failed := countChecks(report.Checks, handlers.CheckFail); failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+// doctorChecks runs the checks in order. Checks that need an earlier check
+// to pass are skipped when it fails.
+func doctorChecks(configPath string, jsonOutput bool, now time.Time) []handlers.DoctorCheck {
+	checks := []handlers.DoctorCheck{configCheck(configPath)}
+	if checks[0].Status == handlers.CheckFail {
+		return append(checks, skippedChecks("Needs a valid config", "path prefix", "store", "tokens", "token refresh", "channels", "live stream", "manifest", "DRM")...)
+	}
+
+	pathPrefix := pathPrefixCheck()
+	checks = append(checks, pathPrefix)
+	if pathPrefix.Status == handlers.CheckFail {
+		return append(checks, skippedChecks("Needs a writable path prefix", "store", "tokens", "token refresh", "channels", "live stream", "manifest", "DRM")...)
+	}
+	if !jsonOutput {
+		// The log file is under the path prefix unless `log_path` is set
+		InitializeLogger()
+	}
+
+	storeResult := storeCheck()
+	checks = append(checks, storeResult)
+	if storeResult.Status == handlers.CheckFail {
+		return append(checks, skippedChecks("Needs a working store", "tokens", "token refresh", "channels", "live stream", "manifest", "DRM")...)
+	}
+
+	// Load the login credentials for the checks below
+	handlers.Init()
+
+	session := handlers.DoctorSessionChecks(utils.DefaultProfile, now)
+	checks = append(checks, session...)
+	if session[0].Status == handlers.CheckFail {
+		return append(checks, skippedChecks("Needs a valid login", "channels", "live stream", "manifest", "DRM")...)
+	}
+	return append(checks, handlers.DoctorPlaybackChecks(utils.DefaultProfile)...)
+}
+
+// configCheck loads the config file and reports the one in use
+func configCheck(configPath string) handlers.DoctorCheck {
+	check := handlers.DoctorCheck{Name: "config"}
+	if err := LoadConfig(configPath); err != nil {
+		check.Status, check.Message = handlers.CheckFail, err.Error()
+		check.Hint = "Fix the config file, or pass another one with `--config`."
+		return check
+	}
+
+	check.Status = handlers.CheckPass
+	if config.File == "" {
+		check.Message = "No config file found, using environment variables"
+		return check
+	}
+	path, err := filepath.Abs(config.File)
+	if err != nil {
+		path = config.File
+	}
+	check.Message = "Loaded " + path
+	return check
+}
+
+// pathPrefixCheck checks that files can be written under the path prefix
+func pathPrefixCheck() (check handlers.DoctorCheck) {
+	check.Name = "path prefix"
+	hint := "Make the directory writable by the user running JioTV Go, or set `path_prefix` to another directory."
+	defer func() {
+		// GetPathPrefix panics if it cannot create the directory
+		if r := recover(); r != nil {
+			check.Status, check.Message, check.Hint = handlers.CheckFail, fmt.Sprint(r), hint
+		}
+	}()
+
+	dir := store.GetPathPrefix()
+	file, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		check.Status, check.Message, check.Hint = handlers.CheckFail, fmt.Sprintf("Cannot write to %s: %v", dir, err), hint
+		return check
+	}
+	file.Close()
+	os.Remove(file.Name())
+	check.Status, check.Message = handlers.CheckPass, dir+" is writable"
+	return check
+}
+
+// storeCheck opens the store and checks that only its owner can read it
+func storeCheck() handlers.DoctorCheck {
+	check := handlers.DoctorCheck{Name: "store"}
+	filename := filepath.Join(store.GetPathPrefix(), store.TomlStoreFile)
+
+	if err := store.Init(); err != nil {
+		check.Status, check.Message = handlers.CheckFail, err.Error()
+		switch {
+		case errors.Is(err, store.ErrWrongStoreKey):
+			check.Hint = fmt.Sprintf("Set %s or `store_key_file` to the passphrase the store was encrypted with. If it is lost, delete %s and log in again.", store.StoreKeyEnv, filename)
+		case errors.Is(err, store.ErrStoreKeyMissing):
+			check.Hint = fmt.Sprintf("Set %s or `store_key_file`, or run the command in a terminal to enter the passphrase.", store.StoreKeyEnv)
+		case config.Cfg.StoreBackend == "" || config.Cfg.StoreBackend == store.BackendTOML:
+			check.Hint = fmt.Sprintf("%s may be damaged. Delete it and log in again.", filename)
+		default:
+			check.Hint = fmt.Sprintf("Set `store_backend` to %s, %s or %s.", store.BackendTOML, store.BackendMemory, store.BackendEnv)
+		}
+		return check
+	}
+
+	switch config.Cfg.StoreBackend {
+	case store.BackendMemory:
+		check.Status, check.Message = handlers.CheckWarn, "The store is kept in memory, so logins are lost on restart"
+		check.Hint = "Set `store_backend` to toml to keep logins."
+		return check
+	case store.BackendEnv:
+		check.Status, check.Message = handlers.CheckPass, fmt.Sprintf("Read %d keys from %s environment variables", len(store.Keys()), store.EnvStorePrefix)
+		return check
+	}
+
+	if info, err := os.Stat(filename); err == nil && info.Mode().Perm()&0o077 != 0 {
+		check.Status, check.Message = handlers.CheckWarn, fmt.Sprintf("%s can be read by other users (%s)", filename, info.Mode().Perm())
+		check.Hint = "Run `chmod 600 " + filename + "`, as the store holds your login."
+		return check
+	}
+	check.Status, check.Message = handlers.CheckPass, fmt.Sprintf("Loaded %s with %d keys", filename, len(store.Keys()))
+	if config.Cfg.StoreEncryption {
+		check.Message += ", encrypted"
+	}
+	return check
+}
+
+// skippedChecks returns the named checks as skipped
+func skippedChecks(reason string, names ...string) []handlers.DoctorCheck {
+	checks := make([]handlers.DoctorCheck, len(names))
+	for i, name := range names {
+		checks[i] = handlers.DoctorCheck{Name: name, Status: handlers.CheckSkip, Message: reason}
+	}
+	return checks
+}
+
+func countChecks(checks []handlers.DoctorCheck, status string) int {
+	count := 0
+	for _, check := range checks {
+		if check.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// printDoctorReport prints the report for people
+func printDoctorReport(w io.Writer, report DoctorReport) {
+	fmt.Fprintf(w, "JioTV Go %s doctor (%s/%s)\n\n", report.Version, report.OS, report.Arch)
+	for _, check := range report.Checks {
+		fmt.Fprintf(w, "[%s] %s: %s\n", strings.ToUpper(check.Status), check.Name, check.Message)
+		if check.Hint != "" {
+			fmt.Fprintf(w, "       Hint: %s\n", check.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		countChecks(report.Checks, handlers.CheckPass),
+		countChecks(report.Checks, handlers.CheckWarn),
+		countChecks(report.Checks, handlers.CheckFail),
+		countChecks(report.Checks, handlers.CheckSkip))
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/internal/handlers"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func TestDoctorLocalChecks(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousLog, previousCfg := utils.Log, config.Cfg
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log, config.Cfg = previousLog, previousCfg
+	})
+
+	if check := pathPrefixCheck(); check.Status != handlers.CheckPass {
+		t.Errorf("pathPrefixCheck() = %+v, want pass", check)
+	}
+
+	if check := storeCheck(); check.Status != handlers.CheckPass {
+		t.Errorf("storeCheck() = %+v, want pass", check)
+	}
+
+	filename := filepath.Join(store.GetPathPrefix(), store.TomlStoreFile)
+	if err := os.Chmod(filename, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if check := storeCheck(); check.Status != handlers.CheckWarn || !strings.Contains(check.Hint, "chmod 600") {
+		t.Errorf("storeCheck() of a readable store = %+v, want warn", check)
+	}
+
+	if err := os.WriteFile(filename, []byte("broken = ["), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if check := storeCheck(); check.Status != handlers.CheckFail || check.Hint == "" {
+		t.Errorf("storeCheck() of a damaged store = %+v, want fail with a hint", check)
+	}
+
+	config.Cfg.StoreBackend = store.BackendMemory
+	if check := storeCheck(); check.Status != handlers.CheckWarn {
+		t.Errorf("storeCheck() of the memory store = %+v, want warn", check)
+	}
+}
+
+func TestPrintDoctorReport(t *testing.T) {
+	report := DoctorReport{
+		Version: "v1.0.0",
+		OS:      "linux",
+		Arch:    "amd64",
+		Checks: []handlers.DoctorCheck{
+			{Name: "config", Status: handlers.CheckPass, Message: "Loaded config.toml"},
+			{Name: "tokens", Status: handlers.CheckFail, Message: "Not logged in", Hint: "Log in"},
+			{Name: "channels", Status: handlers.CheckSkip, Message: "Needs a valid login"},
+		},
+	}
+	var buf bytes.Buffer
+	printDoctorReport(&buf, report)
+	want := `JioTV Go v1.0.0 doctor (linux/amd64)
+
+[PASS] config: Loaded config.toml
+[FAIL] tokens: Not logged in
+       Hint: Log in
+[SKIP] channels: Needs a valid login
+
+1 passed, 0 warnings, 1 failed, 1 skipped
+`
+	if buf.String() != want {
+		t.Errorf("printDoctorReport() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestDoctorChecksConfig(t *testing.T) {
+	previousCfg, previousFile := config.Cfg, config.File
+	t.Cleanup(func() {
+		config.Cfg, config.File = previousCfg, previousFile
+	})
+
+	filename := filepath.Join(t.TempDir(), "jiotv_go.toml")
+	if err := os.WriteFile(filename, []byte("drm = ["), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checks := doctorChecks(filename, true, time.Now())
+	if checks[0].Name != "config" || checks[0].Status != handlers.CheckFail || checks[0].Hint == "" {
+		t.Errorf("config check of a damaged config = %+v, want fail with a hint", checks[0])
+	}
+	for _, check := range checks[1:] {
+		if check.Status != handlers.CheckSkip {
+			t.Errorf("check %s after a failed config = %+v, want skip", check.Name, check)
+		}
+	}
+
+	if err := os.WriteFile(filename, []byte("drm = false\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if check := configCheck(filename); check.Status != handlers.CheckPass || !strings.Contains(check.Message, filename) {
+		t.Errorf("configCheck() = %+v, want pass", check)
+	}
+}
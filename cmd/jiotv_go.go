@@ -11,12 +11,12 @@ import (
 	"github.com/jiotv-go/jiotv_go/v3/internal/middleware"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/scheduler"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
 	"github.com/jiotv-go/jiotv_go/v3/web"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/template/html/v2"
 )
@@ -86,11 +86,22 @@ func JioTVServer(jiotvServerConfig JioTVServerConfig) error {
 
 	app.Use(middleware.CORS())
 
-	app.Use(logger.New(logger.Config{
-		TimeZone: "Asia/Kolkata",
-		Format:   "[${time}] ${status} - ${latency} ${method} ${path} Params:[${queryParams}] ${error}\n",
-		Output:   utils.Log.Writer(),
-	}))
+	// Log requests with the credentials in their URLs redacted
+	app.Use(middleware.Logger(utils.Log.Writer()))
+
+	// Select the account profile, before authentication sees the stripped path
+	app.Use(middleware.Profile())
+
+	// Count requests by route family for /metrics
+	app.Use(middleware.Metrics())
+
+	// Authenticate requests when users are configured
+	app.Use(middleware.Auth())
+	// Reject unknown profiles, only once the request is authenticated
+	app.Use(middleware.CheckProfile())
+	if len(config.Cfg.AuthUsers) == 0 && jiotvServerConfig.Host != "localhost" && jiotvServerConfig.Host != "127.0.0.1" {
+		utils.Log.Println("WARNING: No auth_users are configured. Anyone who can reach the server can use your JioTV account.")
+	}
 
 	app.Use("/static", filesystem.New(filesystem.Config{
 		Root:       http.FS(web.GetStaticFiles()),
@@ -104,6 +115,19 @@ func JioTVServer(jiotvServerConfig JioTVServerConfig) error {
 	// Initialize the television object
 	handlers.Init()
 
+	// Resume persisted recordings and start the recording schedule
+	handlers.InitRecordings()
+
+	// Refresh tokens in the background before they expire
+	handlers.InitTokenRefresh()
+
+	// Fetch the channel list now, so that /readyz does not wait for the first viewer
+	go func() {
+		if _, err := television.Channels(); err != nil {
+			utils.Log.Printf("Failed to fetch channels: %v", err)
+		}
+	}()
+
 	app.Get("/", handlers.IndexHandler)
 	app.Post("/login/sendOTP", handlers.LoginSendOTPHandler)
 	app.Post("/login/verifyOTP", handlers.LoginVerifyOTPHandler)
@@ -112,6 +136,7 @@ func JioTVServer(jiotvServerConfig JioTVServerConfig) error {
 	app.Post("/live/key/:channelID", handlers.LiveManifestKeyHandler)
 	app.Get("/live/:id", handlers.LiveHandler)
 	app.Get("/live/:quality/:id", handlers.LiveQualityHandler)
+	app.Get("/stream/:id", handlers.StreamHandler)
 	app.Get("/render.m3u8", handlers.RenderHandler)
 	app.Get("/render.ts", handlers.RenderTSHandler)
 	app.Get("/render.key", handlers.RenderKeyHandler)
@@ -128,18 +153,55 @@ func JioTVServer(jiotvServerConfig JioTVServerConfig) error {
 	app.Get("/catchup/play/:id", handlers.CatchupPlayerHandler)
 	app.Get("/catchup/render/:id", handlers.CatchupRenderPlayerHandler)
 	app.Get("/catchup/stream/:id", handlers.CatchupStreamHandler)
+	app.Post("/api/catchup/download", handlers.CatchupDownloadHandler)
+	app.Get("/api/catchup/downloads", handlers.CatchupDownloadsHandler)
+	app.Get("/api/catchup/download/:id", handlers.CatchupDownloadStatusHandler)
 	app.Get("/favicon.ico", handlers.FaviconHandler)
 	app.Get("/jtvimage/:file", handlers.ImageHandler)
 	app.Get("/epg.xml.gz", handlers.EPGHandler)
 	app.Get("/epg/:channelID/:offset", handlers.WebEPGHandler)
+	app.Get("/api/epg/now", handlers.EPGNowHandler)
+	app.Get("/api/epg/next", handlers.EPGNextHandler)
+	app.Get("/api/epg/search", handlers.EPGSearchHandler)
+	app.Get("/api/epg/:channelID", handlers.EPGChannelHandler)
 	app.Get("/jtvposter/:date/:file", handlers.PosterHandler)
 	app.Get("/mpd/:channelID", handlers.LiveMpdHandler)
 	app.Post("/drm", handlers.DRMKeyHandler)
 	app.Get("/dashtime", handlers.DASHTimeHandler)
+	app.Get("/api/relay/stats", handlers.SegmentRelayStatsHandler)
+	app.Get("/metrics", handlers.MetricsHandler)
+	app.Get("/healthz", handlers.HealthzHandler)
+	app.Get("/readyz", handlers.ReadyzHandler)
+	app.Get("/api/diagnostics", handlers.DiagnosticsHandler)
+	app.Post("/api/channels/refresh", handlers.ChannelsRefreshHandler)
+	app.Get("/api/session", handlers.SessionHandler)
+	app.Get("/api/token/status", handlers.TokenRefreshStatusHandler)
+	app.Get("/api/recordings", handlers.RecordingsHandler)
+	app.Post("/api/recordings", handlers.AddRecordingHandler)
+	app.Delete("/api/recordings/:id", handlers.CancelRecordingHandler)
+	app.Get("/api/recordings/rules", handlers.RecordingRulesHandler)
+	app.Post("/api/recordings/rules", handlers.AddRecordingRuleHandler)
+	app.Get("/api/recordings/rules/preview", handlers.RecordingRulesPreviewHandler)
+	app.Delete("/api/recordings/rules/:id", handlers.DeleteRecordingRuleHandler)
 
 	app.Get("/render.mpd", handlers.MpdHandler)
 	app.Use("/render.dash", handlers.DashHandler)
 
+	// Xtream Codes API emulation for IPTV apps that only speak player_api.php
+	app.Get("/player_api.php", handlers.XtreamPlayerAPIHandler)
+	app.Post("/player_api.php", handlers.XtreamPlayerAPIHandler)
+	app.Get("/get.php", handlers.XtreamPlaylistHandler)
+	app.Get("/xmltv.php", handlers.XtreamXMLTVHandler)
+	app.Get("/live/:username/:password/:id", handlers.XtreamLiveHandler)
+	app.Get("/timeshift/:username/:password/:duration/:start/:id", handlers.XtreamTimeshiftHandler)
+	app.Get("/streaming/timeshift.php", handlers.XtreamTimeshiftHandler)
+
+	// HDHomeRun tuner emulation for Plex, Jellyfin and Emby Live TV
+	app.Get("/discover.json", handlers.HDHomeRunDiscoverHandler)
+	app.Get("/lineup.json", handlers.HDHomeRunLineupHandler)
+	app.Get("/lineup_status.json", handlers.HDHomeRunLineupStatusHandler)
+	app.Get("/device.xml", handlers.HDHomeRunDeviceXMLHandler)
+
 	if jiotvServerConfig.TLS {
 		if jiotvServerConfig.TLSCertPath == "" || jiotvServerConfig.TLSKeyPath == "" {
 			return fmt.Errorf("TLS cert and key paths are required for HTTPS. Please provide them using --tls-cert and --tls-key flags")
@@ -1,19 +1,27 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jiotv-go/jiotv_go/v3/internal/handlers"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
 )
 
-// Logout logs the user out by removing the saved login credentials file.
-// It checks if the file exists before removing to avoid errors.
+// Logout logs the user out by removing the saved login credentials of the
+// given profile. An empty profile is the default profile.
 // Logs messages to provide feedback to the user.
 // Returns any errors encountered.
-func Logout() error {
+func Logout(profile string) error {
+	profile = utils.NormalizeProfile(profile)
+	if profile != utils.DefaultProfile && !utils.ProfileExists(profile) {
+		return fmt.Errorf("profile %s not found", profile)
+	}
 	fmt.Println("Deleting existing login file if exists")
 
-	err := utils.Logout()
+	err := utils.LogoutProfile(profile)
 	if err != nil {
 		return err
 	}
@@ -26,8 +34,17 @@ func Logout() error {
 // LoginOTP handles the login flow using OTP.
 // It takes the mobile number as input, sends an OTP,
 // verifies the entered OTP by the user and logs in the user.
+// The credentials are saved to the given profile, the default one if empty.
 // Returns any error encountered.
-func LoginOTP() error {
+func LoginOTP(profile string) error {
+	profile = utils.NormalizeProfile(profile)
+	if profile != utils.DefaultProfile {
+		if err := utils.ValidateProfileName(profile); err != nil {
+			return err
+		}
+		fmt.Printf("Logging in to profile %s\n", profile)
+	}
+
 	fmt.Print("Enter your mobile number: +91 ")
 	var mobileNumber string
 	fmt.Scanln(&mobileNumber)
@@ -47,7 +64,7 @@ func LoginOTP() error {
 		var otp string
 		fmt.Scanln(&otp)
 
-		resultOTP, err := utils.LoginVerifyOTP(mobileNumber, otp)
+		resultOTP, err := utils.LoginVerifyOTPFor(profile, mobileNumber, otp)
 		if err != nil {
 			return err
 		}
@@ -61,3 +78,71 @@ func LoginOTP() error {
 
 	return nil
 }
+
+// LoginProfiles prints the profiles with saved login credentials.
+func LoginProfiles() error {
+	profiles := utils.ListProfiles()
+	if len(profiles) == 0 {
+		fmt.Println("No profiles are logged in")
+		return nil
+	}
+	for _, profile := range profiles {
+		fmt.Println(profile)
+	}
+	return nil
+}
+
+// LoginStatus prints the login session of the given profile: the masked
+// account IDs, token expiries, last refreshes, premium providers and whether
+// JioTV rejected the refresh token. It prints JSON if asJSON is set.
+func LoginStatus(profile string, asJSON bool) error {
+	status := handlers.SessionStatusFor(profile)
+	if asJSON {
+		output, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+
+	fmt.Printf("Profile: %s\n", status.Profile)
+	if !status.LoggedIn {
+		fmt.Println("Logged in: no")
+		return nil
+	}
+	fmt.Println("Logged in: yes")
+	fmt.Printf("CRM: %s\n", status.CRM)
+	fmt.Printf("Unique ID: %s\n", status.UniqueID)
+	fmt.Printf("Access token expires: %s\n", formatStatusTime(status.AccessTokenExpiry))
+	fmt.Printf("SSO token expires: %s\n", formatStatusTime(status.SSOTokenExpiry))
+	fmt.Printf("Last access token refresh: %s\n", formatStatusTime(status.LastTokenRefresh))
+	fmt.Printf("Last SSO token refresh: %s\n", formatStatusTime(status.LastSSOTokenRefresh))
+	if status.RefreshTokenRejected {
+		fmt.Printf("Refresh token rejected: yes, at %s\n", formatStatusTime(status.RefreshTokenRejectedAt))
+	} else {
+		fmt.Println("Refresh token rejected: no")
+	}
+
+	providers := make([]string, 0, len(status.PremiumProviders))
+	for _, provider := range status.PremiumProviders {
+		providers = append(providers, provider.Name)
+	}
+	if len(providers) == 0 {
+		providers = append(providers, "none")
+	}
+	fmt.Printf("Premium providers: %s\n", strings.Join(providers, ", "))
+
+	for _, warning := range status.Warnings {
+		fmt.Printf("WARNING: %s\n", warning)
+	}
+	return nil
+}
+
+// formatStatusTime formats a time of LoginStatus, or "unknown" for the zero time
+func formatStatusTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format(time.RFC1123)
+}
@@ -24,7 +24,7 @@ func TestLogout(t *testing.T) {
 				}
 			}()
 
-			if err := Logout(); (err != nil) != tt.wantErr {
+			if err := Logout(""); (err != nil) != tt.wantErr {
 				t.Errorf("Logout() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -52,7 +52,7 @@ func TestLoginOTP(t *testing.T) {
 			// Set a timeout to prevent hanging if user input is expected
 			done := make(chan error, 1)
 			go func() {
-				done <- LoginOTP()
+				done <- LoginOTP("")
 			}()
 
 			select {
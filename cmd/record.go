@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/handlers"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+)
+
+// RecordAdd schedules a recording. The running JioTV Go server picks it up
+// from the recordings file and records it in its window.
+func RecordAdd(request handlers.RecordingRequest) error {
+	recording, err := handlers.AddRecording(request)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Scheduled recording %s of channel %s from %s to %s\n",
+		recording.ID, recording.ChannelID, formatRecordingTime(recording.Start), formatRecordingTime(recording.Stop))
+	fmt.Println("Recording to", recording.File)
+	fmt.Println("Make sure JioTV Go server is running during the recording window.")
+	return nil
+}
+
+// RecordList prints all recordings as a table
+func RecordList() error {
+	recordings, err := handlers.ListRecordings()
+	if err != nil {
+		return err
+	}
+	if len(recordings) == 0 {
+		fmt.Println("No recordings")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCHANNEL\tTITLE\tSTART\tSTOP\tSTATUS\tFILE")
+	for _, recording := range recordings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", recording.ID, recording.ChannelID, recording.Title,
+			formatRecordingTime(recording.Start), formatRecordingTime(recording.Stop), recording.Status, recording.File)
+	}
+	return w.Flush()
+}
+
+// RecordCancel cancels a scheduled or running recording
+func RecordCancel(id string) error {
+	recording, err := handlers.CancelRecording(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Cancelled recording %s of channel %s\n", recording.ID, recording.ChannelID)
+	return nil
+}
+
+// formatRecordingTime formats a recording time in local time
+func formatRecordingTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// RecordRuleAdd stores a recording rule. The server applies it after the next
+// EPG update or when it starts.
+func RecordRuleAdd(rule handlers.RecordingRule) error {
+	rule, err := handlers.AddRecordingRule(rule)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added recording rule %s (%s)\n", rule.ID, rule.Name)
+	fmt.Println("It applies after the next EPG update or when JioTV Go server starts. Run \"jiotv_go record rules preview\" to see what it matches.")
+	return nil
+}
+
+// RecordRuleList prints all recording rules as a table
+func RecordRuleList() error {
+	rules, err := handlers.ListRecordingRules()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		fmt.Println("No recording rules")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTITLE\tCATEGORY\tCHANNELS\tCHANNEL CATEGORIES\tLANGUAGES\tREGEX\tACTION\tKEEP\tERROR")
+	for _, rule := range rules {
+		keep := "all"
+		if rule.KeepLast > 0 {
+			keep = strconv.Itoa(rule.KeepLast)
+		}
+		// Rules that fail to compile are skipped until they are fixed
+		ruleErr := ""
+		if err := handlers.RecordingRuleError(rule); err != nil {
+			ruleErr = err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\t%s\t%s\t%s\n", rule.ID, rule.Name, rule.Title, rule.Category,
+			strings.Join(rule.ChannelIDs, ","), strings.Join(rule.ChannelCategories, ","), strings.Join(rule.Languages, ","),
+			rule.Regex, rule.Action, keep, ruleErr)
+	}
+	return w.Flush()
+}
+
+// RecordRuleDelete removes a recording rule
+func RecordRuleDelete(id string) error {
+	if err := handlers.DeleteRecordingRule(id); err != nil {
+		return err
+	}
+	fmt.Println("Deleted recording rule", id)
+	return nil
+}
+
+// RecordRulePreview fetches the EPG of the channels the rules cover and
+// prints the recordings the rules would schedule, without scheduling them
+func RecordRulePreview() error {
+	rules, err := handlers.ListRecordingRules()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		fmt.Println("No recording rules")
+		return nil
+	}
+
+	channelIDs := handlers.RecordingRuleChannelIDs(rules)
+	if channelIDs != nil && len(channelIDs) == 0 {
+		fmt.Println("No channels match the channel categories and languages of the recording rules")
+		return nil
+	}
+	fmt.Println("Fetching EPG")
+	_, programmes, err := epg.FetchProgrammes(channelIDs)
+	if err != nil {
+		return err
+	}
+	planned, err := handlers.PreviewRecordingRules(programmes)
+	if err != nil {
+		return err
+	}
+	if len(planned) == 0 {
+		fmt.Println("No upcoming programmes match the recording rules")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tCHANNEL\tTITLE\tSTART\tSTOP\tSOURCE")
+	for _, recording := range planned {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", recording.RuleID, recording.ChannelID, recording.Title,
+			formatRecordingTime(recording.Start), formatRecordingTime(recording.Stop), recording.Source)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
+)
+
+// SecureURLRotate replaces the key used to encrypt URLs. URLs encrypted with
+// the previous key keep working for the grace period.
+func SecureURLRotate(grace time.Duration) error {
+	expiry, err := secureurl.RotateKey(grace)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Rotated the URL encryption key.")
+	if expiry.IsZero() {
+		fmt.Println("URLs encrypted with the previous key no longer work.")
+	} else {
+		fmt.Println("URLs encrypted with the previous key work until", expiry.Local().Format("2006-01-02 15:04:05"))
+	}
+	if config.Cfg.DisableURLEncryption {
+		fmt.Println("Note: URL encryption is disabled in the config, so the key is not used.")
+	}
+	fmt.Println("Restart JioTV Go server to start using the new key.")
+	return nil
+}
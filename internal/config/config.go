@@ -2,8 +2,11 @@ package config
 
 import (
 	"log"
+	"net/url"
 	"os"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -14,6 +17,12 @@ import (
 type JioTVConfig struct {
 	// Enable Or Disable EPG Generation. Default: false
 	EPG bool `yaml:"epg" env:"JIOTV_EPG" json:"epg" toml:"epg"`
+	// EPGPastDays is the number of days before today in the EPG, for catchup. At most 7. Default: 0
+	EPGPastDays int `yaml:"epg_past_days" env:"JIOTV_EPG_PAST_DAYS" json:"epg_past_days" toml:"epg_past_days"`
+	// EPGFutureDays is the number of days after today in the EPG. Set to -1 for today only. At most 7. Default: 1
+	EPGFutureDays int `yaml:"epg_future_days" env:"JIOTV_EPG_FUTURE_DAYS" json:"epg_future_days" toml:"epg_future_days"`
+	// EPGSources are XMLTV URLs or files, gzip or plain, whose programmes are merged into the EPG for custom channels with an epg_id. Default: []
+	EPGSources []string `yaml:"epg_sources" env:"JIOTV_EPG_SOURCES" json:"epg_sources" toml:"epg_sources"`
 	// Enable Or Disable Debug Mode. Default: false
 	Debug bool `yaml:"debug" env:"JIOTV_DEBUG" json:"debug" toml:"debug"`
 	// Enable Or Disable TS Handler. While TS Handler is enabled, the server will serve the TS files directly from JioTV API. Default: false
@@ -26,10 +35,24 @@ type JioTVConfig struct {
 	Title string `yaml:"title" env:"JIOTV_TITLE" json:"title" toml:"title"`
 	// Enable Or Disable URL Encryption. URL Encryption prevents hackers from injecting URLs into the server. Default: true
 	DisableURLEncryption bool `yaml:"disable_url_encryption" env:"JIOTV_DISABLE_URL_ENCRYPTION" json:"disable_url_encryption" toml:"disable_url_encryption"`
+	// URLExpiryHours is how long encrypted URLs in playlists stay valid. Set to -1 for URLs that never expire. Default: 24
+	URLExpiryHours int `yaml:"url_expiry_hours" env:"JIOTV_URL_EXPIRY_HOURS" json:"url_expiry_hours" toml:"url_expiry_hours"`
+	// URLClientBinding makes encrypted URLs only work from the IP address they were issued to. Default: false
+	URLClientBinding bool `yaml:"url_client_binding" env:"JIOTV_URL_CLIENT_BINDING" json:"url_client_binding" toml:"url_client_binding"`
+	// URLAllowedHosts are additional upstream hosts, with their subdomains, that encrypted URLs may point to. "*" allows any host. Default: []
+	URLAllowedHosts []string `yaml:"url_allowed_hosts" env:"JIOTV_URL_ALLOWED_HOSTS" json:"url_allowed_hosts" toml:"url_allowed_hosts"`
 	// Proxy URL. Proxy is useful to bypass geo-restrictions and ip-restrictions for JioTV API. Default: ""
 	Proxy string `yaml:"proxy" env:"JIOTV_PROXY" json:"proxy" toml:"proxy"`
 	// PathPrefix is the prefix for all file paths managed by JioTV Go. Default: "$HOME/.jiotv_go"
 	PathPrefix string `yaml:"path_prefix" env:"JIOTV_PATH_PREFIX" json:"path_prefix" toml:"path_prefix"`
+	// ChannelsCacheTTLMinutes is how long the channel list from JioTV is cached. An expired list is served while it is refreshed in the background. Set to -1 to fetch it on every request. Default: 30
+	ChannelsCacheTTLMinutes int `yaml:"channels_cache_ttl_minutes" env:"JIOTV_CHANNELS_CACHE_TTL_MINUTES" json:"channels_cache_ttl_minutes" toml:"channels_cache_ttl_minutes"`
+	// StoreBackend selects where credentials are stored: "toml" for the store file under PathPrefix, "memory" to keep them in memory only, or "env" to read them from JIOTV_STORE_DATA_* environment variables for read-only filesystems. Default: "toml"
+	StoreBackend string `yaml:"store_backend" env:"JIOTV_STORE_BACKEND" json:"store_backend" toml:"store_backend"`
+	// StoreEncryption encrypts the tokens and account IDs in the store file. The passphrase is read from the JIOTV_STORE_KEY environment variable, the StoreKeyFile or a prompt at start. Default: false
+	StoreEncryption bool `yaml:"store_encryption" env:"JIOTV_STORE_ENCRYPTION" json:"store_encryption" toml:"store_encryption"`
+	// StoreKeyFile is the path of a file holding the store passphrase. It is created with a random passphrase if it does not exist. Default: ""
+	StoreKeyFile string `yaml:"store_key_file" env:"JIOTV_STORE_KEY_FILE" json:"store_key_file" toml:"store_key_file"`
 	// LogPath is the directory for log files. Default: ""
 	LogPath string `yaml:"log_path" env:"JIOTV_LOG_PATH" json:"log_path" toml:"log_path"`
 	// LogToStdout controls logging to stdout/stderr. Default: true
@@ -40,11 +63,40 @@ type JioTVConfig struct {
 	DefaultCategories []int `yaml:"default_categories" env:"JIOTV_DEFAULT_CATEGORIES" json:"default_categories" toml:"default_categories"`
 	// DefaultLanguages is the list of language IDs to display on the default web page. Default: []
 	DefaultLanguages []int `yaml:"default_languages" env:"JIOTV_DEFAULT_LANGUAGES" json:"default_languages" toml:"default_languages"`
+	// XtreamUsername is the username Xtream Codes clients must log in with. Default: "" (any username is accepted)
+	XtreamUsername string `yaml:"xtream_username" env:"JIOTV_XTREAM_USERNAME" json:"xtream_username" toml:"xtream_username"`
+	// XtreamPassword is the password Xtream Codes clients must log in with. Default: "" (any password is accepted)
+	XtreamPassword string `yaml:"xtream_password" env:"JIOTV_XTREAM_PASSWORD" json:"xtream_password" toml:"xtream_password"`
+	// HDHomeRunTuners is the number of tuners advertised to HDHomeRun clients and the maximum number of concurrent HDHomeRun streams. Default: 2
+	HDHomeRunTuners int `yaml:"hdhomerun_tuners" env:"JIOTV_HDHOMERUN_TUNERS" json:"hdhomerun_tuners" toml:"hdhomerun_tuners"`
+	// SegmentCacheSizeMB is the memory in MB used to share segments between viewers of the same channel. Set to -1 to disable. Default: 64
+	SegmentCacheSizeMB int `yaml:"segment_cache_size_mb" env:"JIOTV_SEGMENT_CACHE_SIZE_MB" json:"segment_cache_size_mb" toml:"segment_cache_size_mb"`
+	// AuthUsers are the users allowed to use the server. When set, requests must authenticate as one of them. Default: [] (no authentication)
+	AuthUsers []AuthUser `yaml:"auth_users" json:"auth_users" toml:"auth_users"`
+	// AuthTrustedNetworks are CIDR ranges, such as 192.168.1.0/24, whose requests skip authentication. Default: []
+	AuthTrustedNetworks []string `yaml:"auth_trusted_networks" env:"JIOTV_AUTH_TRUSTED_NETWORKS" json:"auth_trusted_networks" toml:"auth_trusted_networks"`
+}
+
+// AuthUser is a user allowed to use the server. It authenticates with its
+// name and password using HTTP Basic authentication, or with its token.
+type AuthUser struct {
+	// Name is the username for HTTP Basic authentication and Xtream Codes apps
+	Name string `yaml:"name" json:"name" toml:"name"`
+	// Password is the password for HTTP Basic authentication and Xtream Codes apps. Empty disables password login.
+	Password string `yaml:"password" json:"password" toml:"password"`
+	// Token is sent as the X-API-Key header, a Bearer token or the token query parameter. Empty disables token login.
+	Token string `yaml:"token" json:"token" toml:"token"`
+	// Scopes the user has access to: watch, playlist, epg and admin. Default: [watch, playlist, epg]
+	Scopes []string `yaml:"scopes" json:"scopes" toml:"scopes"`
 }
 
 // Cfg is the global config variable
 var Cfg JioTVConfig
 
+// File is the config file Cfg was loaded from, empty if it was loaded from
+// environment variables only
+var File string
+
 // Load loads the JioTVConfig from a file.
 // It first checks if a filename is provided, otherwise tries to find a common config file.
 // If no file is found, it loads config from environment variables.
@@ -56,6 +108,7 @@ func (c *JioTVConfig) Load(filename string) error {
 	if filename == "" {
 		filename = commonFileExists()
 	}
+	File = filename
 	if filename == "" {
 		log.Println("INFO: No config file found, using environment variables")
 		return cleanenv.ReadEnv(c)
@@ -76,6 +129,77 @@ func (*JioTVConfig) Get(key string) interface{} {
 	return nil
 }
 
+// redactedValue replaces secrets in redacted configs
+const redactedValue = "REDACTED"
+
+// Redacted returns a copy of the config with passwords, tokens, and proxy
+// and EPG source credentials replaced, for showing it to users
+func (c JioTVConfig) Redacted() JioTVConfig {
+	if c.XtreamPassword != "" {
+		c.XtreamPassword = redactedValue
+	}
+	c.Proxy = RedactURL(c.Proxy)
+
+	sources := make([]string, len(c.EPGSources))
+	for i, source := range c.EPGSources {
+		sources[i] = RedactURL(source)
+	}
+	c.EPGSources = sources
+
+	users := make([]AuthUser, len(c.AuthUsers))
+	for i, user := range c.AuthUsers {
+		if user.Password != "" {
+			user.Password = redactedValue
+		}
+		if user.Token != "" {
+			user.Token = redactedValue
+		}
+		users[i] = user
+	}
+	c.AuthUsers = users
+	return c
+}
+
+// secretQueryParams are query parameters that carry credentials, such as in
+// the xmltv.php URLs of Xtream Codes providers
+var secretQueryParams = []string{"password", "pass", "token", "key", "api_key", "apikey", "secret", "auth"}
+
+// RedactURL replaces the password and secret query parameters in a URL, such
+// as a proxy URL. URLs that cannot be parsed are replaced whole if they may
+// contain credentials.
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		if strings.Contains(rawURL, "@") {
+			return redactedValue
+		}
+		return rawURL
+	}
+	redacted := false
+	if parsed.User != nil {
+		if _, ok := parsed.User.Password(); ok {
+			parsed.User = url.UserPassword(parsed.User.Username(), redactedValue)
+			redacted = true
+		}
+	}
+	if parsed.RawQuery != "" {
+		query := parsed.Query()
+		for name := range query {
+			if slices.Contains(secretQueryParams, strings.ToLower(name)) {
+				query.Set(name, redactedValue)
+				redacted = true
+			}
+		}
+		if redacted {
+			parsed.RawQuery = query.Encode()
+		}
+	}
+	if !redacted {
+		return rawURL
+	}
+	return parsed.String()
+}
+
 // commonFileExists checks for the existence of common config
 // file names and returns the first one found. It searches
 // for config files in the following formats:
@@ -9,4 +9,7 @@ const (
 
 	// EPG-related tasks
 	EPGTaskID = "jiotv_epg"
+
+	// Recording-related tasks
+	RecordingTaskID = "jiotv_recordings"
 )
@@ -16,6 +16,17 @@ const (
 	JioTVCatchupCDNDomain = "jiotv.catchup.cdn.jio.com"
 )
 
+// Domains are the JioTV domains above, for checking that they can be reached
+var Domains = []string{
+	JioTVAPIDomain,
+	TVMediaDomain,
+	JioTVCDNDomain,
+	AuthMediaDomain,
+	APIJioDomain,
+	JioTVDataCDNDomain,
+	JioTVCatchupCDNDomain,
+}
+
 // Complete URL endpoints
 const (
 	// Authentication URLs
@@ -39,6 +50,7 @@ const (
 	EPGURL            = "https://jiotv.data.cdn.jio.com/apis/v1.3/getepg/get?offset=%d&channel_id=%d"
 	EPGPosterURL      = "https://jiotv.catchup.cdn.jio.com/dare_images/shows"
 	EPGPosterURLSlash = "https://jiotv.catchup.cdn.jio.com/dare_images/shows/"
+	ChannelLogoURL    = "https://jiotv.catchup.cdn.jio.com/dare_images/images"
 )
 
 // URL path patterns (for string formatting)
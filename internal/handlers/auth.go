@@ -17,10 +17,30 @@ import (
 )
 
 var (
-	// tokenRefreshMutex prevents concurrent token refreshes
-	tokenRefreshMutex sync.Mutex
+	// tokenRefreshMutexes prevent concurrent token refreshes of a profile
+	tokenRefreshMutexes sync.Map
+
+	// refreshChannelList replaces the cached channel list. Tests replace it.
+	refreshChannelList = television.RefreshChannels
 )
 
+// refreshChannelsAfterLogin refreshes the cached channel list in the
+// background once the login state changed, so that the channels of the new
+// session are served instead of the cached ones
+func refreshChannelsAfterLogin() {
+	go func() {
+		if _, err := refreshChannelList(); err != nil {
+			utils.Log.Printf("Error refreshing channels after login change: %v", err)
+		}
+	}()
+}
+
+// tokenRefreshMutex returns the mutex serializing token refreshes of a profile
+func tokenRefreshMutex(profile string) *sync.Mutex {
+	mutex, _ := tokenRefreshMutexes.LoadOrStore(utils.NormalizeProfile(profile), &sync.Mutex{})
+	return mutex.(*sync.Mutex)
+}
+
 // IsAccessTokenExpired checks if the AccessToken needs refreshing
 // Returns true if the token is expired or will expire within the next 10 minutes
 func IsAccessTokenExpired(credentials *utils.JIOTV_CREDENTIALS) bool {
@@ -58,10 +78,16 @@ func IsSSOTokenExpired(credentials *utils.JIOTV_CREDENTIALS) bool {
 // EnsureFreshTokens checks and refreshes tokens if needed
 // This is the main function that should be called before making API requests
 func EnsureFreshTokens() error {
-	tokenRefreshMutex.Lock()
-	defer tokenRefreshMutex.Unlock()
+	return EnsureFreshTokensFor(utils.DefaultProfile)
+}
 
-	credentials, err := utils.GetJIOTVCredentials()
+// EnsureFreshTokensFor checks and refreshes the tokens of a profile if needed
+func EnsureFreshTokensFor(profile string) error {
+	mutex := tokenRefreshMutex(profile)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
 	if err != nil {
 		return fmt.Errorf("failed to get credentials: %v", err)
 	}
@@ -75,7 +101,7 @@ func EnsureFreshTokens() error {
 	if credentials.AccessToken != "" && credentials.RefreshToken != "" {
 		if IsAccessTokenExpired(credentials) {
 			utils.Log.Println("AccessToken is expired, refreshing...")
-			err := LoginRefreshAccessToken()
+			err := LoginRefreshAccessTokenFor(profile)
 			if err != nil {
 				utils.Log.Printf("AccessToken refresh failed: %v", err)
 				return err
@@ -88,7 +114,7 @@ func EnsureFreshTokens() error {
 	if credentials.SSOToken != "" && credentials.UniqueID != "" {
 		if IsSSOTokenExpired(credentials) {
 			utils.Log.Println("SSOToken is expired, refreshing...")
-			err := LoginRefreshSSOToken()
+			err := LoginRefreshSSOTokenFor(profile)
 			if err != nil {
 				utils.Log.Printf("SSOToken refresh failed: %v", err)
 				return err
@@ -99,11 +125,11 @@ func EnsureFreshTokens() error {
 
 	if refreshed {
 		// Update the TV object with fresh credentials
-		freshCreds, err := utils.GetJIOTVCredentials()
+		freshCreds, err := utils.GetJIOTVCredentialsFor(profile)
 		if err != nil {
 			return fmt.Errorf("failed to get fresh credentials: %v", err)
 		}
-		TV = television.New(freshCreds)
+		setTV(profile, television.NewForProfile(profile, freshCreds))
 	}
 
 	return nil
@@ -151,32 +177,55 @@ func LoginVerifyOTPHandler(c *fiber.Ctx) error {
 		return err
 	}
 
-	result, err := utils.LoginVerifyOTP(mobileNumber, otp)
+	profile := utils.NormalizeProfile(formBody.Profile)
+	if profile != utils.DefaultProfile {
+		if err := utils.ValidateProfileName(profile); err != nil {
+			return internalUtils.BadRequestError(c, err.Error())
+		}
+	}
+
+	result, err := utils.LoginVerifyOTPFor(profile, mobileNumber, otp)
 	if err != nil {
 		utils.Log.Println(err)
 		return internalUtils.InternalServerError(c, "Internal server error")
 	}
-	Init()
+	if profile == utils.DefaultProfile {
+		Init()
+	} else {
+		resetProfile(profile)
+	}
+	refreshChannelsAfterLogin()
 	return c.JSON(result)
 }
 
 // LogoutHandler is used to logout
 func LogoutHandler(c *fiber.Ctx) error {
 	if !isLogoutDisabled {
-		err := utils.Logout()
+		profile := requestProfile(c)
+		err := utils.LogoutProfile(profile)
 		if err != nil {
 			utils.Log.Println(err)
 			return internalUtils.InternalServerError(c, "Internal server error")
 		}
-		Init()
+		if profile == utils.DefaultProfile {
+			Init()
+		} else {
+			resetProfile(profile)
+		}
+		refreshChannelsAfterLogin()
 	}
 	return c.Redirect("/", fiber.StatusFound)
 }
 
 // LoginRefreshAccessToken Function is used to refresh AccessToken
 func LoginRefreshAccessToken() error {
+	return LoginRefreshAccessTokenFor(utils.DefaultProfile)
+}
+
+// LoginRefreshAccessTokenFor refreshes the AccessToken of a profile
+func LoginRefreshAccessTokenFor(profile string) error {
 	utils.Log.Println("Refreshing AccessToken...")
-	tokenData, err := utils.GetJIOTVCredentials()
+	tokenData, err := utils.GetJIOTVCredentialsFor(profile)
 	if err != nil {
 		utils.Log.Printf("Error getting credentials for AccessToken refresh: %v", err)
 		return err
@@ -192,7 +241,7 @@ func LoginRefreshAccessToken() error {
 	// Prepare the request body
 	requestBody := map[string]string{
 		"appName":      "RJIL_JioTV",
-		"deviceId":     utils.GetDeviceID(),
+		"deviceId":     utils.GetDeviceIDFor(profile),
 		"refreshToken": tokenData.RefreshToken,
 	}
 
@@ -229,6 +278,11 @@ func LoginRefreshAccessToken() error {
 	if resp.StatusCode() != fasthttp.StatusOK {
 		err := fmt.Errorf("AccessToken refresh failed with status code: %d, body: %s", resp.StatusCode(), string(resp.Body()))
 		utils.Log.Printf("Error: %v", err)
+		if isRefreshTokenRejection(resp.StatusCode()) {
+			if storeErr := utils.SetRefreshTokenRejectedFor(profile, time.Now()); storeErr != nil {
+				utils.Log.Printf("Error saving refresh token rejection: %v", storeErr)
+			}
+		}
 		return err
 	}
 
@@ -245,12 +299,15 @@ func LoginRefreshAccessToken() error {
 	if response.AccessToken != "" {
 		tokenData.AccessToken = response.AccessToken
 		tokenData.LastTokenRefreshTime = strconv.FormatInt(time.Now().Unix(), 10)
-		err := utils.WriteJIOTVCredentials(tokenData)
+		err := utils.WriteJIOTVCredentialsFor(profile, tokenData)
 		if err != nil {
 			utils.Log.Printf("Error saving refreshed credentials: %v", err)
 			return err
 		}
-		TV = television.New(tokenData)
+		setTV(profile, television.NewForProfile(profile, tokenData))
+		if err := utils.ClearRefreshTokenRejectedFor(profile); err != nil {
+			utils.Log.Printf("Error clearing refresh token rejection: %v", err)
+		}
 		utils.Log.Println("AccessToken refreshed successfully")
 		return nil
 	} else {
@@ -260,10 +317,22 @@ func LoginRefreshAccessToken() error {
 	}
 }
 
+// isRefreshTokenRejection reports whether a status code of the AccessToken
+// refresh API means that JioTV rejected the refresh token itself, so that the
+// session cannot recover without logging in again
+func isRefreshTokenRejection(statusCode int) bool {
+	return statusCode == fasthttp.StatusBadRequest || statusCode == fasthttp.StatusUnauthorized || statusCode == fasthttp.StatusForbidden
+}
+
 // LoginRefreshSSOToken Function is used to refresh SSOToken
 func LoginRefreshSSOToken() error {
+	return LoginRefreshSSOTokenFor(utils.DefaultProfile)
+}
+
+// LoginRefreshSSOTokenFor refreshes the SSOToken of a profile
+func LoginRefreshSSOTokenFor(profile string) error {
 	utils.Log.Println("Refreshing SsoToken...")
-	tokenData, err := utils.GetJIOTVCredentials()
+	tokenData, err := utils.GetJIOTVCredentialsFor(profile)
 	if err != nil {
 		utils.Log.Printf("Error getting credentials for SSOToken refresh: %v", err)
 		return err
@@ -281,7 +350,7 @@ func LoginRefreshSSOToken() error {
 		return err
 	}
 
-	deviceID := utils.GetDeviceID()
+	deviceID := utils.GetDeviceIDFor(profile)
 	if deviceID == "" {
 		err := fmt.Errorf("DeviceID is empty, cannot refresh SSOToken")
 		utils.Log.Printf("Error: %v", err)
@@ -331,12 +400,12 @@ func LoginRefreshSSOToken() error {
 	if response.SSOToken != "" {
 		tokenData.SSOToken = response.SSOToken
 		tokenData.LastSSOTokenRefreshTime = strconv.FormatInt(time.Now().Unix(), 10)
-		err := utils.WriteJIOTVCredentials(tokenData)
+		err := utils.WriteJIOTVCredentialsFor(profile, tokenData)
 		if err != nil {
 			utils.Log.Printf("Error saving refreshed SSOToken credentials: %v", err)
 			return err
 		}
-		TV = television.New(tokenData)
+		setTV(profile, television.NewForProfile(profile, tokenData))
 		utils.Log.Println("SSOToken refreshed successfully")
 		return nil
 	} else {
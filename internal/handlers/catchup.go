@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/middleware"
 	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
@@ -78,10 +79,7 @@ func CatchupHandler(c *fiber.Ctx) error {
 	}
 
 	currentTime := time.Now().UnixMilli()
-	loc, err := time.LoadLocation("Asia/Kolkata")
-	if err != nil {
-		loc = time.FixedZone("IST", 5*3600+30*60)
-	}
+	loc := indiaLocation()
 
 	var pastEpgData []map[string]interface{}
 	for _, p := range epgData {
@@ -134,7 +132,8 @@ func CatchupStreamHandler(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Missing start or end time")
 	}
 
-	if err := EnsureFreshTokens(); err != nil {
+	profile := channelProfile(c, id)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		pkgUtils.Log.Printf("Failed to ensure fresh tokens: %v", err)
 	}
 
@@ -151,7 +150,7 @@ func CatchupStreamHandler(c *fiber.Ctx) error {
 	}
 
 	pkgUtils.Log.Printf("Fetching catchup URL for channel %s, start: %s, end: %s, srno: %s", id, start, end, srno)
-	catchupResult, err := TV.GetCatchupURL(id, srno, start, end)
+	catchupResult, err := tvFor(profile).GetCatchupURL(id, srno, start, end)
 	if err != nil {
 		pkgUtils.Log.Printf("Error fetching catchup URL: %v", err)
 		return internalUtils.InternalServerError(c, err)
@@ -167,7 +166,7 @@ func CatchupStreamHandler(c *fiber.Ctx) error {
 		return internalUtils.InternalServerError(c, fmt.Errorf("failed to get catchup URL from API"))
 	}
 
-	codedUrl, err := secureurl.EncryptURL(targetURL)
+	codedUrl, err := secureurl.EncryptURLFor(targetURL, internalUtils.URLClient(c))
 	if err != nil {
 		return internalUtils.InternalServerError(c, err)
 	}
@@ -181,7 +180,7 @@ func CatchupStreamHandler(c *fiber.Ctx) error {
 	if catchupResult.Hdnea != "" && !strings.Contains(targetURL, "hdnea=") && !strings.Contains(targetURL, "__hdnea__=") {
 		redirectURL += "&hdnea=" + url.QueryEscape(catchupResult.Hdnea)
 	}
-	return c.Redirect(redirectURL, fiber.StatusFound)
+	return c.Redirect(middleware.WithURLQuery(c, redirectURL), fiber.StatusFound)
 }
 
 func CatchupPlayerHandler(c *fiber.Ctx) error {
@@ -203,7 +202,7 @@ func CatchupPlayerHandler(c *fiber.Ctx) error {
 		"Description":   description,
 		"EpisodePoster": episodePoster,
 		"ShowTime":      showTime,
-		"player_url":    playerURL,
+		"player_url":    middleware.WithURLQuery(c, playerURL),
 	})
 }
 
@@ -232,11 +231,12 @@ func CatchupRenderPlayerHandler(c *fiber.Ctx) error {
 		endFmt = time.UnixMilli(endInt).UTC().Format("20060102T150405")
 	}
 
-	if err := EnsureFreshTokens(); err != nil {
+	profile := channelProfile(c, id)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		pkgUtils.Log.Printf("Failed to ensure fresh tokens: %v", err)
 	}
 
-	catchupResult, err := TV.GetCatchupURL(id, srno, startFmt, endFmt)
+	catchupResult, err := tvFor(profile).GetCatchupURL(id, srno, startFmt, endFmt)
 	if err == nil && catchupResult != nil && catchupResult.IsDRM {
 		mpdURL := internalUtils.SelectQuality(qualityForDrm, catchupResult.Mpd.Bitrates.Auto, catchupResult.Mpd.Bitrates.High, catchupResult.Mpd.Bitrates.Medium, catchupResult.Mpd.Bitrates.Low)
 		if mpdURL == "" {
@@ -256,11 +256,11 @@ func CatchupRenderPlayerHandler(c *fiber.Ctx) error {
 		}
 
 		if mpdURL != "" {
-			encMpdUrl, encErr := secureurl.EncryptURL(mpdURL)
+			encMpdUrl, encErr := secureurl.EncryptURLFor(mpdURL, internalUtils.URLClient(c))
 			if encErr == nil {
 				licenseUrl := ""
 				if catchupResult.Mpd.Key != "" {
-					encKey, keyErr := secureurl.EncryptURL(catchupResult.Mpd.Key)
+					encKey, keyErr := secureurl.EncryptURLFor(catchupResult.Mpd.Key, internalUtils.URLClient(c))
 					if keyErr == nil {
 						licenseUrl = "/drm?auth=" + encKey + "&channel_id=" + id + "&channel=" + encMpdUrl
 					}
@@ -269,7 +269,7 @@ func CatchupRenderPlayerHandler(c *fiber.Ctx) error {
 				if catchupResult.AlgoName == "timesplay" {
 					return c.Render("views/player_drm", fiber.Map{
 						"play_url":     mpdURL,
-						"license_url":  licenseUrl,
+						"license_url":  middleware.WithURLQuery(c, licenseUrl),
 						"channel_host": "",
 						"channel_path": "",
 					})
@@ -279,12 +279,12 @@ func CatchupRenderPlayerHandler(c *fiber.Ctx) error {
 				if parseErr == nil {
 					tvUrlSplit := strings.Split(parsedTvUrl.Path, "/")
 					if len(tvUrlSplit) > 1 {
-						tvUrlPath, pathErr := secureurl.EncryptURL(strings.Join(tvUrlSplit[:len(tvUrlSplit)-1], "/") + "/")
-						tvUrlHost, hostErr := secureurl.EncryptURL(parsedTvUrl.Host)
+						tvUrlPath, pathErr := secureurl.EncryptURLFor(strings.Join(tvUrlSplit[:len(tvUrlSplit)-1], "/")+"/", internalUtils.URLClient(c))
+						tvUrlHost, hostErr := secureurl.EncryptURLFor(parsedTvUrl.Host, internalUtils.URLClient(c))
 						if pathErr == nil && hostErr == nil {
 							return c.Render("views/player_drm", fiber.Map{
-								"play_url":     "/render.mpd?auth=" + encMpdUrl,
-								"license_url":  licenseUrl,
+								"play_url":     middleware.WithURLQuery(c, "/render.mpd?auth="+encMpdUrl),
+								"license_url":  middleware.WithURLQuery(c, licenseUrl),
 								"channel_host": tvUrlHost,
 								"channel_path": tvUrlPath,
 							})
@@ -296,7 +296,7 @@ func CatchupRenderPlayerHandler(c *fiber.Ctx) error {
 	}
 
 	return c.Render("views/player_hls", fiber.Map{
-		"play_url":   playURL,
+		"play_url":   middleware.WithURLQuery(c, playURL),
 		"is_catchup": true,
 	})
 }
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// catchupDownloadWorkers bounds the segments of a download fetched at once
+	catchupDownloadWorkers = 4
+	// catchupEPGDays is how many past days of EPG are searched for a catchup srno
+	catchupEPGDays = 7
+	// catchupDownloadRetention is how long finished downloads stay listed
+	catchupDownloadRetention = time.Hour
+
+	CatchupDownloadRunning   = "downloading"
+	CatchupDownloadCompleted = "completed"
+	CatchupDownloadFailed    = "failed"
+)
+
+var (
+	errCatchupDownloadCancelled = errors.New("download cancelled")
+
+	// catchupDownloads tracks the downloads started over HTTP by ID
+	catchupDownloads   = make(map[string]*CatchupDownloadProgress)
+	catchupDownloadsMu sync.Mutex
+
+	// catchupFiles are the files being downloaded to, so that two downloads
+	// of a programme do not write the same file
+	catchupFiles   = make(map[string]bool)
+	catchupFilesMu sync.Mutex
+)
+
+// claimCatchupFile reserves filename for a download. It fails when the file
+// already exists or another download writes it. The returned func releases it.
+func claimCatchupFile(filename string) (func(), error) {
+	catchupFilesMu.Lock()
+	defer catchupFilesMu.Unlock()
+	if catchupFiles[filename] {
+		return nil, fmt.Errorf("%s is already being downloaded", filename)
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return nil, fmt.Errorf("%s already exists", filename)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	catchupFiles[filename] = true
+	return func() {
+		catchupFilesMu.Lock()
+		delete(catchupFiles, filename)
+		catchupFilesMu.Unlock()
+	}, nil
+}
+
+// pruneCatchupDownloads forgets the downloads that finished more than
+// catchupDownloadRetention before now. catchupDownloadsMu must be held.
+func pruneCatchupDownloads(now time.Time) {
+	for id, status := range catchupDownloads {
+		if !status.FinishedAt.IsZero() && now.Sub(status.FinishedAt) > catchupDownloadRetention {
+			delete(catchupDownloads, id)
+		}
+	}
+}
+
+// catchupProgramme is an aired programme to download from catchup
+type catchupProgramme struct {
+	ChannelID string
+	Srno      string
+	Title     string
+	Start     time.Time
+	Stop      time.Time
+}
+
+// parseCatchupEpoch parses a catchup epoch in seconds or milliseconds
+func parseCatchupEpoch(value string) (time.Time, error) {
+	epoch, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid epoch %q", value)
+	}
+	if epoch < epochThreshold {
+		return time.Unix(epoch, 0), nil
+	}
+	return time.UnixMilli(epoch), nil
+}
+
+// lookupCatchupProgramme looks up a programme of the last catchupEPGDays
+// days by srno, or the programme airing at start when srno is empty
+func lookupCatchupProgramme(channelID, srno string, start time.Time) (catchupProgramme, error) {
+	for offset := 0; offset >= -catchupEPGDays; offset-- {
+		entries, err := getCatchupEPG(channelID, offset)
+		if err != nil {
+			return catchupProgramme{}, err
+		}
+		var entry map[string]interface{}
+		if srno == "" {
+			entry = findCatchupProgramme(entries, start)
+		} else {
+			for _, candidate := range entries {
+				if candidateSrno, _ := candidate["srno"].(string); candidateSrno == srno {
+					entry = candidate
+					break
+				}
+			}
+		}
+		if entry == nil {
+			continue
+		}
+		entryStart, okStart := epgEpochMillis(entry, "startEpoch")
+		entryStop, okStop := epgEpochMillis(entry, "endEpoch")
+		if !okStart || !okStop {
+			continue
+		}
+		entrySrno, _ := entry["srno"].(string)
+		title, _ := entry["showname"].(string)
+		return catchupProgramme{
+			ChannelID: channelID,
+			Srno:      entrySrno,
+			Title:     title,
+			Start:     time.UnixMilli(entryStart),
+			Stop:      time.UnixMilli(entryStop),
+		}, nil
+	}
+	if srno != "" {
+		return catchupProgramme{}, fmt.Errorf("no programme with srno %s in the last %d days of channel %s", srno, catchupEPGDays, channelID)
+	}
+	return catchupProgramme{}, fmt.Errorf("no programme at %s in the last %d days of channel %s", start.Format(time.RFC3339), catchupEPGDays, channelID)
+}
+
+// resolveCatchupProgramme turns a CatchupDownloadRequest into the programme
+// to download. A srno is looked up in the catchup EPG. A start and end are
+// used as given, with the srno and title looked up when the EPG has them.
+func resolveCatchupProgramme(request CatchupDownloadRequest) (catchupProgramme, error) {
+	channelID := strings.TrimSpace(request.ChannelID)
+	if channelID == "" {
+		return catchupProgramme{}, fmt.Errorf("channel_id is required")
+	}
+	if request.Start == "" || request.End == "" {
+		if request.Srno == "" {
+			return catchupProgramme{}, fmt.Errorf("either srno or start and end is required")
+		}
+		return lookupCatchupProgramme(channelID, request.Srno, time.Time{})
+	}
+
+	start, err := parseCatchupEpoch(request.Start)
+	if err != nil {
+		return catchupProgramme{}, err
+	}
+	stop, err := parseCatchupEpoch(request.End)
+	if err != nil {
+		return catchupProgramme{}, err
+	}
+	if !stop.After(start) {
+		return catchupProgramme{}, fmt.Errorf("end must be after start")
+	}
+	programme := catchupProgramme{ChannelID: channelID, Srno: request.Srno, Title: request.Title, Start: start, Stop: stop}
+	if programme.Srno == "" {
+		if found, err := lookupCatchupProgramme(channelID, "", start); err == nil {
+			programme.Srno = found.Srno
+			if programme.Title == "" {
+				programme.Title = found.Title
+			}
+		} else {
+			utils.Log.Printf("Catchup download: %v", err)
+		}
+	}
+	return programme, nil
+}
+
+// ParseCatchupTarget parses the `<srno|start-end>` argument of the catchup download command
+func ParseCatchupTarget(target string) (srno, start, end string) {
+	if before, after, found := strings.Cut(target, "-"); found {
+		return "", before, after
+	}
+	return target, "", ""
+}
+
+// downloadSegments fetches segments with bounded concurrency and writes them
+// to w in playlist order. At most twice the number of workers are held in
+// memory while waiting for an earlier segment.
+func (s *tsStream) downloadSegments(segments []hlsSegment, w io.Writer, workers int, progress func(done int, bytes int64)) error {
+	type result struct {
+		data []byte
+		err  error
+	}
+	results := make([]chan result, len(segments))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	quit := make(chan struct{})
+	defer close(quit)
+	window := make(chan struct{}, workers*2)
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range segments {
+			select {
+			case window <- struct{}{}:
+			case <-quit:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	for n := 0; n < workers; n++ {
+		go func() {
+			for i := range jobs {
+				data, err := s.segment(segments[i])
+				results[i] <- result{data: data, err: err}
+			}
+		}()
+	}
+
+	var written int64
+	for i, segment := range segments {
+		var r result
+		select {
+		case r = <-results[i]:
+		case <-s.done:
+			return errCatchupDownloadCancelled
+		}
+		<-window
+		if r.err != nil {
+			return fmt.Errorf("segment %d: %w", segment.Sequence, r.err)
+		}
+		n, err := w.Write(r.data)
+		if err != nil {
+			return err
+		}
+		written += int64(n)
+		if progress != nil {
+			progress(i+1, written)
+		}
+	}
+	return nil
+}
+
+// downloadCatchup writes the catchup stream of a programme to w, played with
+// the account of profile. The stream is resolved through GetCatchupURL and
+// the quality picked like the live stream. progress is called with the
+// number of segments written, the total and the bytes written.
+func downloadCatchup(programme catchupProgramme, profile, quality string, w io.Writer, done <-chan struct{}, progress func(done, total int, bytes int64)) error {
+	if err := EnsureFreshTokensFor(profile); err != nil {
+		utils.Log.Printf("Failed to ensure fresh tokens: %v", err)
+	}
+	start := programme.Start.UTC().Format("20060102T150405")
+	end := programme.Stop.UTC().Format("20060102T150405")
+	catchupResult, err := tvFor(profile).GetCatchupURL(programme.ChannelID, programme.Srno, start, end)
+	if err != nil {
+		return err
+	}
+	bitrates := catchupResult.Bitrates
+	playlistURL := internalUtils.SelectQuality(quality, bitrates.Auto, bitrates.High, bitrates.Medium, bitrates.Low)
+	if playlistURL == "" {
+		playlistURL = catchupResult.Result
+	}
+	if playlistURL == "" {
+		if catchupResult.IsDRM {
+			return fmt.Errorf("catchup of channel %s is DRM protected and cannot be downloaded", programme.ChannelID)
+		}
+		return fmt.Errorf("no catchup stream found for channel %s", programme.ChannelID)
+	}
+
+	stream := newTSStream(programme.ChannelID, quality)
+	stream.profile = profile
+	// Catchup URLs carry their own token, a live token refresh does not apply
+	stream.refreshable = false
+	stream.bypassRelay = true
+	stream.done = done
+	stream.playlistURL = playlistURL
+	if catchupResult.Hdnea != "" {
+		setCachedHDNEA(stream.hdneaKey(), catchupResult.Hdnea)
+	}
+	playlist, err := stream.loadPlaylist()
+	if err != nil {
+		return err
+	}
+	if len(playlist.Segments) == 0 {
+		return fmt.Errorf("catchup playlist of channel %s has no segments", programme.ChannelID)
+	}
+
+	total := len(playlist.Segments)
+	if progress != nil {
+		progress(0, total, 0)
+	}
+	return stream.downloadSegments(playlist.Segments, w, catchupDownloadWorkers, func(done int, bytes int64) {
+		if progress != nil {
+			progress(done, total, bytes)
+		}
+	})
+}
+
+// DownloadCatchup downloads a catchup programme into a .ts file in the
+// recordings folder. The file only appears once the download is complete,
+// and downloads to a file that exists or is being downloaded fail. progress
+// is called whenever a segment was written.
+func DownloadCatchup(request CatchupDownloadRequest, progress func(CatchupDownloadProgress)) (CatchupDownloadProgress, error) {
+	status := CatchupDownloadProgress{
+		ChannelID: request.ChannelID,
+		Status:    CatchupDownloadRunning,
+		StartedAt: time.Now(),
+	}
+	fail := func(err error) (CatchupDownloadProgress, error) {
+		status.Status = CatchupDownloadFailed
+		status.Error = err.Error()
+		status.FinishedAt = time.Now()
+		if progress != nil {
+			progress(status)
+		}
+		return status, err
+	}
+
+	programme, err := resolveCatchupProgramme(request)
+	if err != nil {
+		return fail(err)
+	}
+	quality := request.Quality
+	if quality == "" {
+		quality = "auto"
+	}
+	dir, err := recordingsDir()
+	if err != nil {
+		return fail(err)
+	}
+	status.Title = programme.Title
+	status.File = filepath.Join(dir, recordingFileName(Recording{ChannelID: programme.ChannelID, Title: programme.Title, Start: programme.Start}))
+
+	release, err := claimCatchupFile(status.File)
+	if err != nil {
+		return fail(err)
+	}
+	defer release()
+
+	file, err := os.CreateTemp(dir, filepath.Base(status.File)+".*.part")
+	if err != nil {
+		return fail(err)
+	}
+	partFile := file.Name()
+	downloadErr := downloadCatchup(programme, request.Profile, quality, file, nil, func(done, total int, bytes int64) {
+		status.DoneSegments, status.TotalSegments, status.Bytes = done, total, bytes
+		if total > 0 {
+			status.Percent = float64(done) * 100 / float64(total)
+		}
+		if progress != nil {
+			progress(status)
+		}
+	})
+	if err := file.Close(); err != nil && downloadErr == nil {
+		downloadErr = err
+	}
+	if downloadErr != nil {
+		os.Remove(partFile)
+		return fail(downloadErr)
+	}
+	// Never replace a file that appeared while downloading
+	if _, err := os.Stat(status.File); err == nil {
+		os.Remove(partFile)
+		return fail(fmt.Errorf("%s already exists", status.File))
+	}
+	if err := os.Rename(partFile, status.File); err != nil {
+		os.Remove(partFile)
+		return fail(err)
+	}
+
+	status.Status = CatchupDownloadCompleted
+	status.FinishedAt = time.Now()
+	if progress != nil {
+		progress(status)
+	}
+	return status, nil
+}
+
+// CatchupDownloadHandler starts downloading a catchup programme in the
+// background with the profile of the request, and responds with the
+// download to poll for progress
+func CatchupDownloadHandler(c *fiber.Ctx) error {
+	var request CatchupDownloadRequest
+	if err := c.BodyParser(&request); err != nil {
+		return internalUtils.BadRequestError(c, "Invalid JSON")
+	}
+	if request.ChannelID == "" || (request.Srno == "" && (request.Start == "" || request.End == "")) {
+		return internalUtils.BadRequestError(c, "channel_id and either srno or start and end are required")
+	}
+	request.Profile = channelProfile(c, request.ChannelID)
+
+	id, err := newRecordingID()
+	if err != nil {
+		return internalUtils.InternalServerError(c, err)
+	}
+	status := &CatchupDownloadProgress{ID: id, ChannelID: request.ChannelID, Status: CatchupDownloadRunning, StartedAt: time.Now()}
+	catchupDownloadsMu.Lock()
+	pruneCatchupDownloads(status.StartedAt)
+	catchupDownloads[id] = status
+	catchupDownloadsMu.Unlock()
+
+	go func() {
+		_, err := DownloadCatchup(request, func(progress CatchupDownloadProgress) {
+			progress.ID = id
+			catchupDownloadsMu.Lock()
+			*status = progress
+			catchupDownloadsMu.Unlock()
+		})
+		if err != nil {
+			utils.Log.Printf("Catchup download %s failed: %v", id, err)
+		}
+	}()
+
+	catchupDownloadsMu.Lock()
+	defer catchupDownloadsMu.Unlock()
+	return c.Status(fiber.StatusAccepted).JSON(*status)
+}
+
+// CatchupDownloadsHandler lists the catchup downloads started since the server started
+func CatchupDownloadsHandler(c *fiber.Ctx) error {
+	catchupDownloadsMu.Lock()
+	defer catchupDownloadsMu.Unlock()
+
+	downloads := make([]CatchupDownloadProgress, 0, len(catchupDownloads))
+	for _, status := range catchupDownloads {
+		downloads = append(downloads, *status)
+	}
+	sort.Slice(downloads, func(i, j int) bool {
+		return downloads[i].StartedAt.Before(downloads[j].StartedAt)
+	})
+	return c.JSON(downloads)
+}
+
+// CatchupDownloadStatusHandler reports the progress of a catchup download
+func CatchupDownloadStatusHandler(c *fiber.Ctx) error {
+	catchupDownloadsMu.Lock()
+	defer catchupDownloadsMu.Unlock()
+
+	status, ok := catchupDownloads[c.Params("id")]
+	if !ok {
+		return internalUtils.NotFoundError(c, "Download not found")
+	}
+	return c.JSON(*status)
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/aes"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseCatchupTarget(t *testing.T) {
+	tests := []struct {
+		target    string
+		wantSrno  string
+		wantStart string
+		wantEnd   string
+	}{
+		{target: "240115210000", wantSrno: "240115210000"},
+		{target: "1705332600000-1705336200000", wantStart: "1705332600000", wantEnd: "1705336200000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			srno, start, end := ParseCatchupTarget(tt.target)
+			if srno != tt.wantSrno || start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("ParseCatchupTarget() = %q, %q, %q", srno, start, end)
+			}
+		})
+	}
+}
+
+func TestParseCatchupEpoch(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "Milliseconds", value: "1705332600000", want: time.UnixMilli(1705332600000)},
+		{name: "Seconds", value: "1705332600", want: time.Unix(1705332600, 0)},
+		{name: "Invalid", value: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCatchupEpoch(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCatchupEpoch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("parseCatchupEpoch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCatchupProgrammeValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		request CatchupDownloadRequest
+	}{
+		{name: "Missing channel", request: CatchupDownloadRequest{Srno: "1"}},
+		{name: "Missing programme", request: CatchupDownloadRequest{ChannelID: "143"}},
+		{name: "Invalid start", request: CatchupDownloadRequest{ChannelID: "143", Start: "x", End: "1705336200000"}},
+		{name: "End before start", request: CatchupDownloadRequest{ChannelID: "143", Start: "1705336200000", End: "1705332600000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := resolveCatchupProgramme(tt.request); err == nil {
+				t.Error("resolveCatchupProgramme() expected an error")
+			}
+		})
+	}
+}
+
+func TestTSStreamDownloadSegments(t *testing.T) {
+	previousTV := TV
+	previousLog := utils.Log
+	TV = &television.Television{Client: &fasthttp.Client{}, Headers: map[string]string{}}
+	utils.Log = log.New(os.Stderr, "", 0)
+	defer func() {
+		TV = previousTV
+		utils.Log = previousLog
+	}()
+
+	key := []byte("0123456789abcdef")
+	const segmentCount = 20
+	var inFlight, maxInFlight, keyRequests atomic.Int32
+	var flaky atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/key.key":
+			keyRequests.Add(1)
+			w.Write(key)
+			return
+		case "/missing.ts":
+			http.NotFound(w, r)
+			return
+		}
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := maxInFlight.Load()
+			if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		// Later segments finish first to check the output order
+		var index int
+		fmt.Sscanf(r.URL.Path, "/seg%d.ts", &index)
+		time.Sleep(time.Duration(segmentCount-index) * time.Millisecond)
+
+		if index == 7 && !flaky.Swap(true) {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		if index == 3 {
+			iv := make([]byte, aes.BlockSize)
+			iv[15] = 3
+			w.Write(encryptTestSegment(t, []byte("|3"), key, iv))
+			return
+		}
+		fmt.Fprintf(w, "|%d", index)
+	}))
+	defer server.Close()
+
+	var segments []hlsSegment
+	var want strings.Builder
+	for i := 0; i < segmentCount; i++ {
+		segment := hlsSegment{Sequence: int64(i), URI: fmt.Sprintf("%s/seg%d.ts", server.URL, i)}
+		if i == 3 {
+			segment.Key = &hlsKey{Method: "AES-128", URI: server.URL + "/key.key"}
+		}
+		segments = append(segments, segment)
+		fmt.Fprintf(&want, "|%d", i)
+	}
+
+	stream := newTSStream("custom_test", "auto")
+	stream.refreshable = false
+	stream.bypassRelay = true
+
+	var out bytes.Buffer
+	var lastDone int
+	err := stream.downloadSegments(segments, &out, 4, func(done int, bytes int64) {
+		if done != lastDone+1 {
+			t.Errorf("progress jumped from %d to %d", lastDone, done)
+		}
+		lastDone = done
+	})
+	if err != nil {
+		t.Fatalf("downloadSegments() error = %v", err)
+	}
+	if got := out.String(); got != want.String() {
+		t.Errorf("downloadSegments() output = %q, want %q", got, want.String())
+	}
+	if lastDone != segmentCount {
+		t.Errorf("progress reported %d segments, want %d", lastDone, segmentCount)
+	}
+	if got := maxInFlight.Load(); got > 4 {
+		t.Errorf("%d segments fetched at once, want at most 4", got)
+	}
+	if got := keyRequests.Load(); got != 1 {
+		t.Errorf("key requested %d times, want 1", got)
+	}
+
+	t.Run("Missing segment fails the download", func(t *testing.T) {
+		broken := append(append([]hlsSegment{}, segments[:2]...), hlsSegment{Sequence: 99, URI: server.URL + "/missing.ts"})
+		err := newTSStream("custom_test", "auto").downloadSegments(broken, &bytes.Buffer{}, 2, nil)
+		if err == nil {
+			t.Error("downloadSegments() expected an error")
+		}
+	})
+
+	t.Run("Cancelled download stops", func(t *testing.T) {
+		done := make(chan struct{})
+		close(done)
+		cancelled := newTSStream("custom_test", "auto")
+		cancelled.done = done
+		if err := cancelled.downloadSegments(segments, &bytes.Buffer{}, 2, nil); err == nil {
+			t.Error("downloadSegments() expected an error after cancellation")
+		}
+	})
+}
+
+func TestClaimCatchupFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "143_Show.ts")
+
+	release, err := claimCatchupFile(filename)
+	if err != nil {
+		t.Fatalf("claimCatchupFile() error = %v", err)
+	}
+	if _, err := claimCatchupFile(filename); err == nil {
+		t.Error("claimCatchupFile() of a file being downloaded succeeded")
+	}
+	release()
+
+	if err := os.WriteFile(filename, []byte("done"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := claimCatchupFile(filename); err == nil {
+		t.Error("claimCatchupFile() of a downloaded file succeeded")
+	}
+}
+
+func TestPruneCatchupDownloads(t *testing.T) {
+	now := time.Now()
+	catchupDownloadsMu.Lock()
+	previous := catchupDownloads
+	catchupDownloads = map[string]*CatchupDownloadProgress{
+		"running":  {Status: CatchupDownloadRunning, StartedAt: now.Add(-2 * catchupDownloadRetention)},
+		"recent":   {Status: CatchupDownloadCompleted, FinishedAt: now.Add(-time.Minute)},
+		"finished": {Status: CatchupDownloadFailed, FinishedAt: now.Add(-2 * catchupDownloadRetention)},
+	}
+	pruneCatchupDownloads(now)
+	got := catchupDownloads
+	catchupDownloads = previous
+	catchupDownloadsMu.Unlock()
+
+	if len(got) != 2 || got["running"] == nil || got["recent"] == nil {
+		t.Errorf("pruneCatchupDownloads() kept %v, want running and recent", slices.Collect(maps.Keys(got)))
+	}
+}
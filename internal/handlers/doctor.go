@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// loginHint is the hint of checks that need the user to log in again
+	loginHint = "Log in again with `jiotv_go login otp` or from the web interface."
+	// networkHint is the hint of checks that failed to reach JioTV
+	networkHint = "Check the internet connection. JioTV only streams to Indian IP addresses, so set `proxy` when running outside India."
+)
+
+// DoctorSessionChecks checks the login session of a profile: the expiry of
+// its tokens, and which tokens a refresh would renew now. The refresh is a dry
+// run that does not contact JioTV.
+func DoctorSessionChecks(profile string, now time.Time) []DoctorCheck {
+	status := SessionStatusFor(profile)
+	if !status.LoggedIn {
+		return []DoctorCheck{
+			{Name: "tokens", Status: CheckFail, Message: fmt.Sprintf("Profile %s is not logged in", status.Profile), Hint: loginHint},
+			{Name: "token refresh", Status: CheckSkip, Message: "Needs a login"},
+		}
+	}
+	return []DoctorCheck{tokenExpiryCheck(status), tokenRefreshDryRun(status.Profile, now)}
+}
+
+// tokenExpiryCheck checks the expiry of the tokens of a session
+func tokenExpiryCheck(status SessionStatus) DoctorCheck {
+	check := DoctorCheck{Name: "tokens"}
+	switch {
+	case status.SSOTokenExpired:
+		check.Status = CheckFail
+		check.Message = "The SSO token expired at " + status.SSOTokenExpiry.Format(time.RFC3339)
+		check.Hint = loginHint
+	case status.RefreshTokenRejected:
+		check.Status = CheckFail
+		check.Message = "JioTV rejected the refresh token at " + status.RefreshTokenRejectedAt.Format(time.RFC3339)
+		check.Hint = loginHint
+	case len(status.Warnings) > 0:
+		check.Status = CheckWarn
+		check.Message = strings.Join(status.Warnings, ". ")
+		if status.AccessTokenExpired {
+			check.Hint = "The access token is refreshed on the next request. Keep the server running so that it refreshes tokens in the background."
+		}
+	default:
+		check.Status = CheckPass
+		check.Message = "Access token " + describeExpiry(status.AccessTokenExpiry) + ", SSO token " + describeExpiry(status.SSOTokenExpiry)
+	}
+	return check
+}
+
+func describeExpiry(expiry time.Time) string {
+	if expiry.IsZero() {
+		return "has no expiry"
+	}
+	return "valid until " + expiry.Format(time.RFC3339)
+}
+
+// tokenRefreshDryRun reports which tokens the background refresh would renew
+// now, and whether the credentials it needs are stored
+func tokenRefreshDryRun(profile string, now time.Time) DoctorCheck {
+	check := DoctorCheck{Name: "token refresh"}
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
+	if err != nil || credentials == nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("Failed to read credentials: %v", err)
+		check.Hint = loginHint
+		return check
+	}
+
+	var missing []string
+	if credentials.AccessToken != "" && credentials.RefreshToken == "" {
+		missing = append(missing, "no refresh token to renew the access token")
+	}
+	if credentials.SSOToken != "" && credentials.UniqueID == "" {
+		missing = append(missing, "no unique ID to renew the SSO token")
+	}
+	if len(missing) > 0 {
+		check.Status = CheckWarn
+		check.Message = "Tokens cannot be refreshed: " + strings.Join(missing, ", ")
+		check.Hint = loginHint
+		return check
+	}
+
+	var due []string
+	refreshAccessToken, refreshSSOToken := tokensDueForRefresh(credentials, now)
+	if refreshAccessToken {
+		due = append(due, "access token")
+	}
+	if refreshSSOToken {
+		due = append(due, "SSO token")
+	}
+	check.Status = CheckPass
+	if len(due) == 0 {
+		check.Message = "No token needs a refresh now"
+	} else {
+		check.Message = "A refresh now would renew the " + strings.Join(due, " and ")
+	}
+	return check
+}
+
+// DoctorPlaybackChecks checks that a profile can play channels: it fetches
+// the channel list, the live stream and manifest of a channel without DRM,
+// and the manifest and license URL of a DRM channel
+func DoctorPlaybackChecks(profile string) []DoctorCheck {
+	channels, err := television.Channels()
+	if err != nil {
+		return []DoctorCheck{
+			{Name: "channels", Status: CheckFail, Message: fmt.Sprintf("Failed to fetch the channel list: %v", err), Hint: networkHint},
+			{Name: "live stream", Status: CheckSkip, Message: "Needs the channel list"},
+			{Name: "manifest", Status: CheckSkip, Message: "Needs the channel list"},
+			{Name: "DRM", Status: CheckSkip, Message: "Needs the channel list"},
+		}
+	}
+
+	var hlsChannel, drmChannel *television.Channel
+	for i, channel := range channels.Result {
+		if channel.RequiresSubscription || isCustomChannel(channel.ID) {
+			continue
+		}
+		if isDRMChannel(channel.ID) {
+			if drmChannel == nil {
+				drmChannel = &channels.Result[i]
+			}
+		} else if hlsChannel == nil {
+			hlsChannel = &channels.Result[i]
+		}
+	}
+
+	tv := tvFor(profile)
+	checks := []DoctorCheck{{Name: "channels", Status: CheckPass, Message: fmt.Sprintf("Fetched %d channels", len(channels.Result))}}
+	checks = append(checks, hlsPlaybackChecks(tv, hlsChannel)...)
+	return append(checks, drmPlaybackCheck(tv, drmChannel))
+}
+
+// hlsPlaybackChecks fetches the live stream and HLS manifest of a channel
+func hlsPlaybackChecks(tv *television.Television, channel *television.Channel) []DoctorCheck {
+	if channel == nil {
+		return []DoctorCheck{
+			{Name: "live stream", Status: CheckWarn, Message: "No channel without DRM or a separate subscription found"},
+			{Name: "manifest", Status: CheckSkip, Message: "Needs a live stream"},
+		}
+	}
+
+	live := DoctorCheck{Name: "live stream"}
+	liveResult, err := liveFor(tv, channel.ID)
+	streamURL := selectBestLiveHLSURL(liveResult, "auto")
+	switch {
+	case err != nil:
+		live.Status, live.Message, live.Hint = CheckFail, fmt.Sprintf("Failed to get the stream of %s (%s): %v", channel.Name, channel.ID, err), networkHint+" "+loginHint
+	case streamURL == "":
+		live.Status, live.Message, live.Hint = CheckFail, fmt.Sprintf("JioTV returned no HLS stream for %s (%s)", channel.Name, channel.ID), networkHint
+	default:
+		live.Status, live.Message = CheckPass, fmt.Sprintf("Got the stream of %s (%s)", channel.Name, channel.ID)
+	}
+	if live.Status != CheckPass {
+		return []DoctorCheck{live, {Name: "manifest", Status: CheckSkip, Message: "Needs a live stream"}}
+	}
+
+	manifest := DoctorCheck{Name: "manifest"}
+	body, status, _ := tv.Render(streamURL, liveResult.Hdnea)
+	switch {
+	case status != 200:
+		manifest.Status, manifest.Message, manifest.Hint = CheckFail, fmt.Sprintf("The HLS manifest of %s returned status %d", channel.ID, status), networkHint
+	case !bytes.HasPrefix(bytes.TrimSpace(body), []byte("#EXTM3U")):
+		manifest.Status, manifest.Message, manifest.Hint = CheckFail, fmt.Sprintf("The HLS manifest of %s is not a playlist", channel.ID), networkHint
+	default:
+		manifest.Status, manifest.Message = CheckPass, fmt.Sprintf("Fetched the HLS manifest of %s", channel.ID)
+	}
+	return []DoctorCheck{live, manifest}
+}
+
+// drmPlaybackCheck resolves the DASH manifest and license URL of a DRM
+// channel and fetches the manifest
+func drmPlaybackCheck(tv *television.Television, channel *television.Channel) DoctorCheck {
+	check := DoctorCheck{Name: "DRM"}
+	if channel == nil {
+		check.Status, check.Message = CheckWarn, "No DRM channel known"
+		check.Hint = "Run `jiotv_go channels probe` to find the channels that stream with DRM."
+		return check
+	}
+
+	liveResult, err := liveFor(tv, channel.ID)
+	if err == nil && liveResult == nil {
+		err = errors.New("no response")
+	}
+	if err != nil {
+		check.Status, check.Message, check.Hint = CheckFail, fmt.Sprintf("Failed to get the stream of %s (%s): %v", channel.Name, channel.ID, err), networkHint
+		return check
+	}
+	bitrates := liveResult.Mpd.ResolvedBitrates()
+	mpdURL := cmp.Or(bitrates.Auto, bitrates.High, bitrates.Medium, bitrates.Low, liveResult.Mpd.Result)
+	licenseURL := liveResult.ResolvedLicenseURL()
+	if mpdURL == "" || licenseURL == "" {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("JioTV returned no DASH manifest or license URL for %s (%s)", channel.Name, channel.ID)
+		check.Hint = "The channel may have stopped using DRM. Run `jiotv_go channels probe` to update the DRM channels."
+		return check
+	}
+
+	body, status, _ := tv.Render(mpdURL, liveResult.Hdnea)
+	if status != 200 || !bytes.Contains(body, []byte("<MPD")) {
+		check.Status, check.Message, check.Hint = CheckFail, fmt.Sprintf("The DASH manifest of %s returned status %d", channel.ID, status), networkHint
+		return check
+	}
+	check.Status, check.Message = CheckPass, fmt.Sprintf("Fetched the DASH manifest and resolved the license URL of %s (%s)", channel.Name, channel.ID)
+	return check
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func TestTokenExpiryCheck(t *testing.T) {
+	expiry := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		status   SessionStatus
+		want     string
+		wantHint bool
+	}{
+		{name: "valid", status: SessionStatus{AccessTokenExpiry: expiry, SSOTokenExpiry: expiry}, want: CheckPass},
+		{name: "sso expired", status: SessionStatus{SSOTokenExpired: true, SSOTokenExpiry: expiry}, want: CheckFail, wantHint: true},
+		{name: "refresh token rejected", status: SessionStatus{RefreshTokenRejected: true}, want: CheckFail, wantHint: true},
+		{name: "access expired", status: SessionStatus{AccessTokenExpired: true, Warnings: []string{"The access token expired"}}, want: CheckWarn, wantHint: true},
+		{name: "warning", status: SessionStatus{Warnings: []string{"The SSO token expires soon"}}, want: CheckWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := tokenExpiryCheck(tt.status)
+			if check.Status != tt.want || check.Message == "" || (check.Hint != "") != tt.wantHint {
+				t.Errorf("tokenExpiryCheck() = %+v, want status %s, hint %v", check, tt.want, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestDoctorSessionChecks(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	previousLog := utils.Log
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log = previousLog
+	})
+
+	now := time.Now()
+	checks := DoctorSessionChecks("family", now)
+	if len(checks) != 2 || checks[0].Status != CheckFail || checks[1].Status != CheckSkip {
+		t.Fatalf("DoctorSessionChecks() without login = %+v", checks)
+	}
+
+	lastRefresh := strconv.FormatInt(now.Unix(), 10)
+	credentials := &utils.JIOTV_CREDENTIALS{
+		SSOToken:                buildUnsignedJWT(now.Add(30 * 24 * time.Hour).Unix()),
+		UniqueID:                "unique",
+		AccessToken:             buildUnsignedJWT(now.Add(time.Minute).Unix()),
+		RefreshToken:            "refresh",
+		LastTokenRefreshTime:    lastRefresh,
+		LastSSOTokenRefreshTime: lastRefresh,
+	}
+	if err := utils.WriteJIOTVCredentialsFor("family", credentials); err != nil {
+		t.Fatal(err)
+	}
+	checks = DoctorSessionChecks("family", now)
+	if checks[0].Status != CheckPass {
+		t.Errorf("tokens check = %+v, want pass", checks[0])
+	}
+	if checks[1].Status != CheckPass || !strings.Contains(checks[1].Message, "access token") || strings.Contains(checks[1].Message, "SSO token") {
+		t.Errorf("token refresh check = %+v, want only the access token due", checks[1])
+	}
+
+	credentials.RefreshToken = ""
+	if err := utils.WriteJIOTVCredentialsFor("family", credentials); err != nil {
+		t.Fatal(err)
+	}
+	if check := tokenRefreshDryRun("family", now); check.Status != CheckWarn || check.Hint == "" {
+		t.Errorf("tokenRefreshDryRun() without refresh token = %+v, want warn", check)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/proxy"
 	"github.com/jiotv-go/jiotv_go/v3/internal/constants/headers"
+	"github.com/jiotv-go/jiotv_go/v3/internal/middleware"
 	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
@@ -52,11 +53,8 @@ func buildCookieHeaderFromSetCookieHeaders(setCookieHeaders [][]byte) string {
 }
 
 var (
-	// tokenRefreshLock prevents concurrent TV object modifications from race conditions
-	tokenRefreshLock sync.Mutex
-	// nextCredentialValidationTime tracks when token validity should be checked next.
-	// This prevents rechecking/re-refreshing on every request.
-	nextCredentialValidationTime time.Time
+	// credentialValidations holds the credentialValidation of each profile
+	credentialValidations sync.Map
 
 	// drmMpdCache caches the DrmMpdOutput for a short duration to prevent double API calls
 	// when IPTV apps request both the MPD and Key sequentially.
@@ -64,12 +62,33 @@ var (
 	drmMpdCacheTTL = 30 * time.Second
 )
 
+// credentialValidation tracks the token validity checks of a profile
+type credentialValidation struct {
+	// mu prevents concurrent TV object modifications from race conditions
+	mu sync.Mutex
+	// next tracks when token validity should be checked next.
+	// This prevents rechecking/re-refreshing on every request.
+	next time.Time
+}
+
+// credentialValidationFor returns the credentialValidation of a profile
+func credentialValidationFor(profile string) *credentialValidation {
+	validation, _ := credentialValidations.LoadOrStore(utils.NormalizeProfile(profile), &credentialValidation{})
+	return validation.(*credentialValidation)
+}
+
 type drmMpdCacheEntry struct {
 	Output    *DrmMpdOutput
 	UpdatedAt time.Time
 }
 
 func getCachedDrmMpd(key string) *DrmMpdOutput {
+	output := loadCachedDrmMpd(key)
+	countCacheLookup(drmMpdCacheRequests, output != nil)
+	return output
+}
+
+func loadCachedDrmMpd(key string) *DrmMpdOutput {
 	entryRaw, ok := drmMpdCache.Load(key)
 	if !ok {
 		return nil
@@ -94,17 +113,23 @@ func setCachedDrmMpd(key string, output *DrmMpdOutput) {
 // This function prevents 403 errors by keeping credentials always fresh
 // Returns true if tokens are fresh (either just refreshed or cached)
 func EnsureFreshCredentials() bool {
-	tokenRefreshLock.Lock()
-	defer tokenRefreshLock.Unlock()
+	return EnsureFreshCredentialsFor(utils.DefaultProfile)
+}
+
+// EnsureFreshCredentialsFor refreshes the tokens of a profile before they expire
+func EnsureFreshCredentialsFor(profile string) bool {
+	validation := credentialValidationFor(profile)
+	validation.mu.Lock()
+	defer validation.mu.Unlock()
 
 	now := time.Now()
-	if !nextCredentialValidationTime.IsZero() && now.Before(nextCredentialValidationTime) {
+	if !validation.next.IsZero() && now.Before(validation.next) {
 		return true
 	}
 
-	credentials, err := utils.GetJIOTVCredentials()
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
 	if err != nil || credentials == nil {
-		nextCredentialValidationTime = now.Add(credentialRefreshRetryBackoff)
+		validation.next = now.Add(credentialRefreshRetryBackoff)
 		if os.Getenv("JIOTV_DEBUG") == "true" && err != nil {
 			utils.Log.Printf("[DEBUG] EnsureFreshCredentials: failed to load credentials: %v", err)
 		}
@@ -130,27 +155,33 @@ func EnsureFreshCredentials() bool {
 	)
 
 	if !refreshAccessToken && !refreshSSOToken {
-		nextCredentialValidationTime = calculateNextCredentialValidationTime(credentials, now)
+		validation.next = calculateNextCredentialValidationTime(credentials, now)
 		return true
 	}
 
-	return performTokenRefresh(refreshAccessToken, refreshSSOToken, now)
+	return performTokenRefresh(profile, validation, refreshAccessToken, refreshSSOToken, now)
 }
 
 // ForceRefreshCredentials bypasses proactive validity checks and forces immediate refresh
 // Use this only in error recovery paths when we know tokens have failed
 func ForceRefreshCredentials() bool {
-	tokenRefreshLock.Lock()
-	defer tokenRefreshLock.Unlock()
+	return ForceRefreshCredentialsFor(utils.DefaultProfile)
+}
+
+// ForceRefreshCredentialsFor forces an immediate token refresh of a profile
+func ForceRefreshCredentialsFor(profile string) bool {
+	validation := credentialValidationFor(profile)
+	validation.mu.Lock()
+	defer validation.mu.Unlock()
 	now := time.Now()
 
 	if os.Getenv("JIOTV_DEBUG") == "true" {
 		utils.Log.Printf("[DEBUG] FORCED token refresh (bypassing expiry checks)")
 	}
 
-	credentials, err := utils.GetJIOTVCredentials()
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
 	if err != nil || credentials == nil {
-		nextCredentialValidationTime = now.Add(credentialRefreshRetryBackoff)
+		validation.next = now.Add(credentialRefreshRetryBackoff)
 		if os.Getenv("JIOTV_DEBUG") == "true" && err != nil {
 			utils.Log.Printf("[DEBUG] ForceRefreshCredentials: failed to load credentials: %v", err)
 		}
@@ -161,22 +192,23 @@ func ForceRefreshCredentials() bool {
 	refreshSSOToken := credentials.SSOToken != "" && credentials.UniqueID != ""
 
 	if !refreshAccessToken && !refreshSSOToken {
-		nextCredentialValidationTime = now.Add(credentialRefreshRetryBackoff)
+		validation.next = now.Add(credentialRefreshRetryBackoff)
 		return false
 	}
 
-	return performTokenRefresh(refreshAccessToken, refreshSSOToken, now)
+	return performTokenRefresh(profile, validation, refreshAccessToken, refreshSSOToken, now)
 }
 
-// performTokenRefresh does the actual token refresh work (must be called with lock held)
-func performTokenRefresh(refreshAccessToken, refreshSSOToken bool, now time.Time) bool {
+// performTokenRefresh does the actual token refresh work (must be called with validation.mu held)
+func performTokenRefresh(profile string, validation *credentialValidation, refreshAccessToken, refreshSSOToken bool, now time.Time) bool {
 	var accessTokenErr error
 	var ssoTokenErr error
 	var refreshed bool
 
 	// CRITICAL REFRESH #1: Refresh AccessToken
 	if refreshAccessToken {
-		accessTokenErr = LoginRefreshAccessToken()
+		accessTokenErr = LoginRefreshAccessTokenFor(profile)
+		countTokenRefresh("access", "request", accessTokenErr)
 		if accessTokenErr != nil {
 			if os.Getenv("JIOTV_DEBUG") == "true" {
 				utils.Log.Printf("[DEBUG] AccessToken refresh error: %v", accessTokenErr)
@@ -191,7 +223,8 @@ func performTokenRefresh(refreshAccessToken, refreshSSOToken bool, now time.Time
 
 	// CRITICAL REFRESH #2: Refresh SSOToken
 	if refreshSSOToken {
-		ssoTokenErr = LoginRefreshSSOToken()
+		ssoTokenErr = LoginRefreshSSOTokenFor(profile)
+		countTokenRefresh("sso", "request", ssoTokenErr)
 		if ssoTokenErr != nil {
 			if os.Getenv("JIOTV_DEBUG") == "true" {
 				utils.Log.Printf("[DEBUG] SSOToken refresh error: %v", ssoTokenErr)
@@ -205,19 +238,19 @@ func performTokenRefresh(refreshAccessToken, refreshSSOToken bool, now time.Time
 	}
 
 	if refreshed {
-		freshCreds, freshErr := utils.GetJIOTVCredentials()
+		freshCreds, freshErr := utils.GetJIOTVCredentialsFor(profile)
 		if freshErr == nil && freshCreds != nil {
-			nextCredentialValidationTime = calculateNextCredentialValidationTime(freshCreds, now)
+			validation.next = calculateNextCredentialValidationTime(freshCreds, now)
 		} else {
-			nextCredentialValidationTime = now.Add(minCredentialValidationInterval)
+			validation.next = now.Add(minCredentialValidationInterval)
 		}
 		if os.Getenv("JIOTV_DEBUG") == "true" {
-			utils.Log.Printf("[DEBUG] Token refresh cycle completed. Next validation at %s", nextCredentialValidationTime.Format(time.RFC3339))
+			utils.Log.Printf("[DEBUG] Token refresh cycle completed. Next validation at %s", validation.next.Format(time.RFC3339))
 		}
 		return true
 	}
 
-	nextCredentialValidationTime = now.Add(credentialRefreshRetryBackoff)
+	validation.next = now.Add(credentialRefreshRetryBackoff)
 
 	// Both refreshes failed - log comprehensive error
 	if os.Getenv("JIOTV_DEBUG") == "true" {
@@ -266,29 +299,41 @@ func calculateNextCredentialValidationTime(credentials *utils.JIOTV_CREDENTIALS,
 	return closest
 }
 
-// getDrmMpd returns required properties for rendering DRM MPD
-func getDrmMpd(channelID, quality string) (*DrmMpdOutput, error) {
-	cacheKey := channelID + "_" + quality
+// getDrmMpd returns required properties for rendering DRM MPD, with the URLs
+// encrypted for client as returned by internalUtils.URLClient
+func getDrmMpd(profile, channelID, quality, client string) (*DrmMpdOutput, error) {
+	cacheKey := drmMpdCacheKey(profile, channelID, quality, client)
 	if cached := getCachedDrmMpd(cacheKey); cached != nil {
 		return cached, nil
 	}
 
 	// Get live stream URL from JioTV API
-	liveResult, err := TV.Live(channelID)
+	liveResult, err := liveFor(tvFor(profile), channelID)
 	if err != nil {
 		return nil, err
 	}
-	if refreshedResult, refreshErr := refreshLiveResultIfNeeded(channelID, liveResult); refreshErr == nil && refreshedResult != nil {
+	if refreshedResult, refreshErr := refreshLiveResultIfNeeded(profile, channelID, liveResult); refreshErr == nil && refreshedResult != nil {
 		liveResult = refreshedResult
 	}
-	return buildDrmMpdOutput(liveResult, channelID, quality)
+	return buildDrmMpdOutput(liveResult, cacheKey, channelID, quality, client)
+}
+
+// drmMpdCacheKey returns the key under which the DRM MPD properties of a
+// channel are cached. Each client gets its own entry, as the URLs are
+// encrypted for it.
+func drmMpdCacheKey(profile, channelID, quality, client string) string {
+	key := profileCacheKey(profile, channelID+"_"+quality)
+	if client != "" {
+		key += "_" + client
+	}
+	return key
 }
 
 // buildDrmMpdOutput turns a playback response into the properties needed to
-// render the DRM player. It is shared by live channels and premium provider
-// content, which return the same payload shape.
-func buildDrmMpdOutput(liveResult *television.LiveURLOutput, channelID, quality string) (*DrmMpdOutput, error) {
-	cacheKey := channelID + "_" + quality
+// render the DRM player and caches them under cacheKey. It is shared by live
+// channels and premium provider content, which return the same payload shape.
+// The URLs are encrypted for client.
+func buildDrmMpdOutput(liveResult *television.LiveURLOutput, cacheKey, channelID, quality, client string) (*DrmMpdOutput, error) {
 
 	// ResolvedBitrates covers both shapes: live channels nest URLs under
 	// mpd.bitrates, premium content returns a single mpd.auto.
@@ -324,7 +369,7 @@ func buildDrmMpdOutput(liveResult *television.LiveURLOutput, channelID, quality
 		return output, nil
 	}
 
-	channel_enc_url, err := secureurl.EncryptURL(tv_url)
+	channel_enc_url, err := secureurl.EncryptURLFor(tv_url, client)
 	if err != nil {
 		utils.Log.Panicln(err)
 		return nil, err
@@ -334,7 +379,7 @@ func buildDrmMpdOutput(liveResult *television.LiveURLOutput, channelID, quality
 	// mpd.key, premium provider content returns a top-level keyUrl.
 	licenseUrl := ""
 	if resolvedLicenseURL := liveResult.ResolvedLicenseURL(); resolvedLicenseURL != "" {
-		enc_key, encErr := secureurl.EncryptURL(resolvedLicenseURL)
+		enc_key, encErr := secureurl.EncryptURLFor(resolvedLicenseURL, client)
 		if encErr != nil {
 			utils.Log.Panicln(encErr)
 			return nil, encErr
@@ -361,13 +406,13 @@ func buildDrmMpdOutput(liveResult *television.LiveURLOutput, channelID, quality
 		return nil, err
 	}
 	tv_url_split := strings.Split(parsedTvUrl.Path, "/")
-	tv_url_path, err := secureurl.EncryptURL(strings.Join(tv_url_split[:len(tv_url_split)-1], "/") + "/")
+	tv_url_path, err := secureurl.EncryptURLFor(strings.Join(tv_url_split[:len(tv_url_split)-1], "/")+"/", client)
 	if err != nil {
 		utils.Log.Panicln(err)
 		return nil, err
 	}
 
-	tv_url_host, err := secureurl.EncryptURL(parsedTvUrl.Host)
+	tv_url_host, err := secureurl.EncryptURLFor(parsedTvUrl.Host, client)
 	if err != nil {
 		utils.Log.Panicln(err)
 		return nil, err
@@ -388,6 +433,7 @@ func buildDrmMpdOutput(liveResult *television.LiveURLOutput, channelID, quality
 func LiveMpdHandler(c *fiber.Ctx) error {
 	// Get channel ID from URL
 	channelID := c.Params("channelID")
+	activeStreams.touch(channelID, c.IP(), time.Now())
 	quality := c.Query("q")
 	playerMode := c.Query("pm") // "hd" (force Shaka) or "auto" (try Shaka, fallback HLS)
 	if quality == "" {
@@ -410,18 +456,19 @@ func LiveMpdHandler(c *fiber.Ctx) error {
 	}
 
 	// Ensure tokens are fresh before requesting MPD
-	EnsureFreshCredentials()
+	profile := channelProfile(c, channelID)
+	EnsureFreshCredentialsFor(profile)
 
-	drmMpdOutput, err := getDrmMpd(channelID, quality)
+	drmMpdOutput, err := getDrmMpd(profile, channelID, quality, internalUtils.URLClient(c))
 
 	// If getting DRM MPD failed, try refreshing tokens forcefully and retry with multiple attempts
 	if err != nil {
 		utils.Log.Printf("First attempt to get DRM MPD failed: %v. Attempting recovery with forced credentials refresh...", err)
 
 		// Force refresh credentials (bypasses 30-second interval for error recovery)
-		if ForceRefreshCredentials() {
+		if ForceRefreshCredentialsFor(profile) {
 			// Retry getDrmMpd with fresh tokens
-			drmMpdOutput, err = getDrmMpd(channelID, quality)
+			drmMpdOutput, err = getDrmMpd(profile, channelID, quality, internalUtils.URLClient(c))
 			if err == nil {
 				utils.Log.Println("Retry successful after forced token refresh")
 			}
@@ -446,19 +493,19 @@ func LiveMpdHandler(c *fiber.Ctx) error {
 
 	if err != nil || drmMpdOutput == nil || drmMpdOutput.PlayUrl == "" {
 		// Use requested quality (default high) for HLS fallback to ensure best available quality first
-		play_url := utils.BuildHLSPlayURL(quality, channelID)
+		play_url := middleware.WithURLQuery(c, utils.BuildHLSPlayURL(quality, channelID))
 		internalUtils.SetCacheHeader(c, 3600)
 		return c.Render("views/player_hls", fiber.Map{
 			"play_url": play_url,
 		})
 	}
 
-	hlsFallbackURL := utils.BuildHLSPlayURL(quality, channelID)
-	hlsPlayerFallbackURL := "/player/" + channelID + "?q=" + quality + "&af=1"
+	hlsFallbackURL := middleware.WithURLQuery(c, utils.BuildHLSPlayURL(quality, channelID))
+	hlsPlayerFallbackURL := middleware.WithURLQuery(c, "/player/"+channelID+"?q="+quality+"&af=1")
 
 	return c.Render("views/player_drm", fiber.Map{
-		"play_url":                drmMpdOutput.PlayUrl,
-		"license_url":             drmMpdOutput.LicenseUrl,
+		"play_url":                middleware.WithURLQuery(c, drmMpdOutput.PlayUrl),
+		"license_url":             middleware.WithURLQuery(c, drmMpdOutput.LicenseUrl),
 		"channel_host":            drmMpdOutput.Tv_url_host,
 		"channel_path":            drmMpdOutput.Tv_url_path,
 		"hls_fallback_url":        hlsFallbackURL,
@@ -483,10 +530,9 @@ func DRMKeyHandler(c *fiber.Ctx) error {
 	channel := c.Query("channel")
 	channel_id := c.Query("channel_id")
 
-	decoded_channel, err := internalUtils.DecryptURLParam("channel", channel)
+	decoded_channel, err := internalUtils.DecryptURLParam("channel", channel, internalUtils.URLClient(c))
 	if err != nil {
-		utils.Log.Panicln(err)
-		return internalUtils.ForbiddenError(c, err)
+		return internalUtils.URLTokenError(c, err)
 	}
 
 	// Make a HEAD request to the decoded_channel to get the cookies
@@ -511,38 +557,39 @@ func DRMKeyHandler(c *fiber.Ctx) error {
 		c.Request().Header.Set("Cookie", cookieHeader)
 	}
 
-	decoded_url, err := internalUtils.DecryptURLParam("auth", auth)
+	decoded_url, err := internalUtils.DecryptURLParam("auth", auth, internalUtils.URLClient(c))
 	if err != nil {
-		utils.Log.Panicln(err)
-		return internalUtils.ForbiddenError(c, err)
+		return internalUtils.URLTokenError(c, err)
 	}
 
 	// Add headers to the request
-	c.Request().Header.Set("accesstoken", TV.AccessToken)
+	profile := requestProfile(c)
+	tv := tvFor(profile)
+	c.Request().Header.Set("accesstoken", tv.AccessToken)
 	c.Request().Header.Set("Connection", "keep-alive")
 	c.Request().Header.Set("os", "android")
 	c.Request().Header.Set("appName", "RJIL_JioTV")
-	c.Request().Header.Set("subscriberId", TV.Crm)
+	c.Request().Header.Set("subscriberId", tv.Crm)
 	c.Request().Header.Set("User-Agent", PLAYER_USER_AGENT)
-	c.Request().Header.Set("ssotoken", TV.SsoToken)
+	c.Request().Header.Set("ssotoken", tv.SsoToken)
 	c.Request().Header.Set("x-platform", "android")
 	c.Request().Header.Set("srno", generateDateTime())
-	c.Request().Header.Set("crmid", TV.Crm)
+	c.Request().Header.Set("crmid", tv.Crm)
 	c.Request().Header.Set("channelid", channel_id)
-	c.Request().Header.Set("uniqueId", TV.UniqueID)
+	c.Request().Header.Set("uniqueId", tv.UniqueID)
 	c.Request().Header.Set("versionCode", headers.VersionCode389)
 	c.Request().Header.Set("usergroup", "tvYR7NSNn7rymo3F")
 	c.Request().Header.Set("devicetype", "phone")
 	c.Request().Header.Set("Accept-Encoding", "gzip, deflate")
 	c.Request().Header.Set("osVersion", "13")
-	c.Request().Header.Set("deviceId", utils.GetDeviceID())
+	c.Request().Header.Set("deviceId", utils.GetDeviceIDFor(profile))
 	c.Request().Header.Set("Content-Type", "application/octet-stream")
 
 	// Remove headers
 	c.Request().Header.Del("Accept")
 	c.Request().Header.Del("Origin")
 
-	if err := proxy.Do(c, decoded_url, TV.Client); err != nil {
+	if err := proxy.Do(c, decoded_url, tv.Client); err != nil {
 		return err
 	}
 
@@ -553,7 +600,9 @@ func DRMKeyHandler(c *fiber.Ctx) error {
 // MpdHandler handles BPK proxy routes /bpk/:channelID
 func MpdHandler(c *fiber.Ctx) error {
 	// CRITICAL: Refresh credentials before proxying MPD
-	EnsureFreshCredentials()
+	profile := requestProfile(c)
+	tv := tvFor(profile)
+	EnsureFreshCredentialsFor(profile)
 
 	channelID := c.Query("channel_id")
 	quality := c.Query("q")
@@ -563,10 +612,16 @@ func MpdHandler(c *fiber.Ctx) error {
 		return fmt.Errorf("auth query param is required")
 	}
 
-	decryptedUrl, err := secureurl.DecryptURL(proxyUrl)
+	client := internalUtils.URLClient(c)
+	decryptedUrl, err := secureurl.DecryptURLFor(proxyUrl, client)
 	if err != nil {
-		utils.Log.Panicln(err)
-		return err
+		utils.Log.Println(err)
+		return internalUtils.URLTokenError(c, err)
+	}
+	// channel_id is a label of the active streams metric, so only channels
+	// of the channel list are counted
+	if _, ok := autoChannel(channelID); ok {
+		activeStreams.touch(channelID, c.IP(), time.Now())
 	}
 	parsedUrl, err := url.Parse(decryptedUrl)
 	if err != nil {
@@ -575,7 +630,7 @@ func MpdHandler(c *fiber.Ctx) error {
 	}
 
 	if channelID != "" {
-		if liveResult, liveErr := TV.Live(channelID); liveErr == nil && liveResult != nil {
+		if liveResult, liveErr := liveFor(tv, channelID); liveErr == nil && liveResult != nil {
 			if freshUrl := selectBestLiveMPDURL(liveResult, quality); freshUrl != "" {
 				decryptedUrl = freshUrl
 				parsedUrl, err = url.Parse(decryptedUrl)
@@ -590,12 +645,12 @@ func MpdHandler(c *fiber.Ctx) error {
 	proxyHost := parsedUrl.Host
 	pathParts := strings.Split(parsedUrl.Path, "/")
 	basePath := strings.Join(pathParts[:len(pathParts)-1], "/") + "/"
-	encProxyHost, err := secureurl.EncryptURL(proxyHost)
+	encProxyHost, err := secureurl.EncryptURLFor(proxyHost, client)
 	if err != nil {
 		utils.Log.Panicln(err)
 		return err
 	}
-	encProxyPath, err := secureurl.EncryptURL(basePath)
+	encProxyPath, err := secureurl.EncryptURLFor(basePath, client)
 	if err != nil {
 		utils.Log.Panicln(err)
 		return err
@@ -608,11 +663,12 @@ func MpdHandler(c *fiber.Ctx) error {
 		cachedHDNEA = chd
 	}
 
-	dashBaseURL := fmt.Sprintf("/render.dash/host/%s/path/%s", encProxyHost, encProxyPath)
+	dashBasePath := middleware.DASHBasePath(c)
+	dashBaseURL := fmt.Sprintf("%s/host/%s/path/%s", dashBasePath, encProxyHost, encProxyPath)
 	if cachedHDNEA != "" {
-		encHDNEA, encErr := secureurl.EncryptURL("__hdnea__=" + cachedHDNEA)
+		encHDNEA, encErr := secureurl.EncryptURLFor("__hdnea__="+cachedHDNEA, client)
 		if encErr == nil {
-			dashBaseURL = fmt.Sprintf("/render.dash/host/%s/path/%s/hdnea/%s", encProxyHost, encProxyPath, encHDNEA)
+			dashBaseURL = fmt.Sprintf("%s/host/%s/path/%s/hdnea/%s", dashBasePath, encProxyHost, encProxyPath, encHDNEA)
 		}
 	}
 
@@ -629,7 +685,7 @@ func MpdHandler(c *fiber.Ctx) error {
 	c.Request().Header.Del("Accept-Encoding")
 
 	// AGGRESSIVE REFRESH: Make initial proxy request
-	if err := proxy.Do(c, requestUrl, TV.Client); err != nil {
+	if err := proxy.Do(c, requestUrl, tv.Client); err != nil {
 		return err
 	}
 
@@ -642,7 +698,7 @@ func MpdHandler(c *fiber.Ctx) error {
 
 		// Reset response to allow retry
 		c.Response().Reset()
-		ForceRefreshCredentials()
+		ForceRefreshCredentialsFor(profile)
 
 		// Strip HDNEA token and retry - CDN will provide fresh auth
 		// HDNEA tokens are CDN-managed and expire, so requesting without them
@@ -657,7 +713,7 @@ func MpdHandler(c *fiber.Ctx) error {
 			}
 		}
 
-		if err := proxy.Do(c, strippedUrl, TV.Client); err != nil {
+		if err := proxy.Do(c, strippedUrl, tv.Client); err != nil {
 			if os.Getenv("JIOTV_DEBUG") == "true" {
 				utils.Log.Printf("[DEBUG] MpdHandler retry failed: %v", err)
 			}
@@ -679,9 +735,9 @@ func MpdHandler(c *fiber.Ctx) error {
 
 	// If we got a fresh __hdnea__ from upstream, update dashBaseURL with it
 	if upstreamHDNEA != "" {
-		encHDNEA, encErr := secureurl.EncryptURL("__hdnea__=" + upstreamHDNEA)
+		encHDNEA, encErr := secureurl.EncryptURLFor("__hdnea__="+upstreamHDNEA, client)
 		if encErr == nil {
-			dashBaseURL = fmt.Sprintf("/render.dash/host/%s/path/%s/hdnea/%s", encProxyHost, encProxyPath, encHDNEA)
+			dashBaseURL = fmt.Sprintf("%s/host/%s/path/%s/hdnea/%s", dashBasePath, encProxyHost, encProxyPath, encHDNEA)
 		}
 	}
 
@@ -749,7 +805,7 @@ func DashHandler(c *fiber.Ctx) error {
 					encHdnea := restParts[0]
 
 					// Decrypt HDNEA
-					decHdnea, decErr := secureurl.DecryptURL(encHdnea)
+					decHdnea, decErr := secureurl.DecryptURLFor(encHdnea, internalUtils.URLClient(c))
 					if decErr == nil && strings.HasPrefix(decHdnea, "__hdnea__=") {
 						hdneaToken = strings.TrimPrefix(decHdnea, "__hdnea__=")
 					}
@@ -780,15 +836,16 @@ func DashHandler(c *fiber.Ctx) error {
 	}
 
 	// decode the URL
-	proxyHost, err := secureurl.DecryptURL(proxyHost)
+	client := internalUtils.URLClient(c)
+	proxyHost, err := secureurl.DecryptURLFor(proxyHost, client)
 	if err != nil {
-		utils.Log.Panicln(err)
-		return err
+		utils.Log.Println(err)
+		return internalUtils.URLTokenError(c, err)
 	}
-	proxyPath, err = secureurl.DecryptURL(proxyPath)
+	proxyPath, err = secureurl.DecryptURLFor(proxyPath, client)
 	if err != nil {
-		utils.Log.Panicln(err)
-		return err
+		utils.Log.Println(err)
+		return internalUtils.URLTokenError(c, err)
 	}
 
 	if strings.HasPrefix(requestPath, "/render.dash") {
@@ -813,60 +870,66 @@ func DashHandler(c *fiber.Ctx) error {
 	}
 
 	// CRITICAL: Refresh credentials before proxying segments
-	EnsureFreshCredentials()
+	profile := requestProfile(c)
+	tv := tvFor(profile)
+	EnsureFreshCredentialsFor(profile)
+
+	// Viewers of the same channel share one upstream fetch per segment
+	return relaySegmentResponse(c, proxyUrl, func() error {
+		// AGGRESSIVE REFRESH: Make initial proxy request
+		if err := proxy.Do(c, proxyUrl, tv.Client); err != nil {
+			return err
+		}
 
-	// AGGRESSIVE REFRESH: Make initial proxy request
-	if err := proxy.Do(c, proxyUrl, TV.Client); err != nil {
-		return err
-	}
+		// Handle 403/401 auth failures with retry mechanism (AGGRESSIVE REFRESH)
+		statusCode := c.Response().StatusCode()
+		if statusCode == fiber.StatusForbidden || statusCode == fiber.StatusUnauthorized {
+			if os.Getenv("JIOTV_DEBUG") == "true" {
+				utils.Log.Printf("[DEBUG] DashHandler got %d response - clearing HDNEA cookie and retrying", statusCode)
+			}
 
-	// Handle 403/401 auth failures with retry mechanism (AGGRESSIVE REFRESH)
-	statusCode := c.Response().StatusCode()
-	if statusCode == fiber.StatusForbidden || statusCode == fiber.StatusUnauthorized {
-		if os.Getenv("JIOTV_DEBUG") == "true" {
-			utils.Log.Printf("[DEBUG] DashHandler got %d response - clearing HDNEA cookie and retrying", statusCode)
-		}
+			// Reset response to allow retry
+			c.Response().Reset()
+			ForceRefreshCredentialsFor(profile)
 
-		// Reset response to allow retry
-		c.Response().Reset()
-		ForceRefreshCredentials()
+			// Clear HDNEA cookie - expired token causes 403
+			// CDN will provide fresh HDNEA in the response
+			c.Request().Header.DelCookie("__hdnea__")
 
-		// Clear HDNEA cookie - expired token causes 403
-		// CDN will provide fresh HDNEA in the response
-		c.Request().Header.DelCookie("__hdnea__")
+			if err := proxy.Do(c, proxyUrl, tv.Client); err != nil {
+				if os.Getenv("JIOTV_DEBUG") == "true" {
+					utils.Log.Printf("[DEBUG] DashHandler retry failed: %v", err)
+				}
+				return err
+			}
 
-		if err := proxy.Do(c, proxyUrl, TV.Client); err != nil {
 			if os.Getenv("JIOTV_DEBUG") == "true" {
-				utils.Log.Printf("[DEBUG] DashHandler retry failed: %v", err)
+				utils.Log.Printf("[DEBUG] DashHandler retry - new status: %d", c.Response().StatusCode())
 			}
-			return err
-		}
 
-		if os.Getenv("JIOTV_DEBUG") == "true" {
-			utils.Log.Printf("[DEBUG] DashHandler retry - new status: %d", c.Response().StatusCode())
+			if os.Getenv("JIOTV_DEBUG") == "true" {
+				utils.Log.Printf("[DEBUG] DashHandler retry successful - new status: %d", c.Response().StatusCode())
+			}
 		}
 
-		if os.Getenv("JIOTV_DEBUG") == "true" {
-			utils.Log.Printf("[DEBUG] DashHandler retry successful - new status: %d", c.Response().StatusCode())
-		}
-	}
-
-	c.Response().Header.Del(fiber.HeaderServer)
-
-	return nil
+		c.Response().Header.Del(fiber.HeaderServer)
+		return nil
+	})
 }
 
 // LiveManifestMpdHandler handles the IPTV M3U route for MPD manifests: /live/mpd/:channelID
 func LiveManifestMpdHandler(c *fiber.Ctx) error {
 	channelID := c.Params("channelID")
+	activeStreams.touch(channelID, c.IP(), time.Now())
 	quality := c.Query("q")
 	if quality == "" {
 		quality = "auto"
 	}
 
-	EnsureFreshCredentials()
+	profile := channelProfile(c, channelID)
+	EnsureFreshCredentialsFor(profile)
 
-	drmMpdOutput, err := getDrmMpd(channelID, quality)
+	drmMpdOutput, err := getDrmMpd(profile, channelID, quality, internalUtils.URLClient(c))
 	if err != nil {
 		utils.Log.Printf("Error getting DRM MPD: %v", err)
 		return internalUtils.InternalServerError(c, err.Error())
@@ -876,7 +939,7 @@ func LiveManifestMpdHandler(c *fiber.Ctx) error {
 		return internalUtils.NotFoundError(c, "No MPD URL found for channel "+channelID)
 	}
 
-	return c.Redirect(drmMpdOutput.PlayUrl, fiber.StatusFound)
+	return c.Redirect(middleware.WithURLQuery(c, drmMpdOutput.PlayUrl), fiber.StatusFound)
 }
 
 // LiveManifestKeyHandler acts as a proxy for the Widevine license request for IPTV clients: /live/key/:channelID
@@ -889,7 +952,7 @@ func LiveManifestKeyHandler(c *fiber.Ctx) error {
 
 	// The MPD handler was likely called just milliseconds ago,
 	// so getDrmMpd will instantly return the cached result.
-	drmMpdOutput, err := getDrmMpd(channelID, quality)
+	drmMpdOutput, err := getDrmMpd(channelProfile(c, channelID), channelID, quality, internalUtils.URLClient(c))
 	if err != nil {
 		utils.Log.Printf("Error getting DRM Key info: %v", err)
 		return internalUtils.InternalServerError(c, err.Error())
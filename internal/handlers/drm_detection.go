@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// drmChannelsFile is the file under the path prefix holding the DRM status
+	// observed for each channel
+	drmChannelsFile = "drm_channels.json"
+	// drmObservationMaxAge is how old an unchanged observation gets before it
+	// is saved again with a new timestamp
+	drmObservationMaxAge = 24 * time.Hour
+	// defaultDRMProbeConcurrency is how many channels ProbeDRMChannels
+	// fetches at the same time unless told otherwise
+	defaultDRMProbeConcurrency = 4
+)
+
+var (
+	// drmObservations holds the DRM status observed for each channel. It is
+	// loaded from drmChannelsFile on first use.
+	drmObservations       map[string]DRMObservation
+	drmObservationsLoaded bool
+	drmObservationsMu     sync.Mutex
+)
+
+// DRMObservation is the DRM status of a channel as JioTV last reported it
+type DRMObservation struct {
+	DRM        bool      `json:"drm"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// DRMProbeProgress reports the progress of ProbeDRMChannels
+type DRMProbeProgress struct {
+	Total  int `json:"total"`
+	Done   int `json:"done"`
+	DRM    int `json:"drm"`
+	Failed int `json:"failed"`
+	// Changed counts channels whose DRM status differs from what was known
+	// before the probe
+	Changed int `json:"changed"`
+}
+
+// isDRMChannel reports whether a channel streams with DRM: as last observed
+// from JioTV, or as listed in drmList if it was never observed
+func isDRMChannel(channelID string) bool {
+	drmObservationsMu.Lock()
+	loadDRMObservationsLocked()
+	observation, ok := drmObservations[channelID]
+	drmObservationsMu.Unlock()
+	if ok {
+		return observation.DRM
+	}
+	return utils.ContainsString(channelID, drmList)
+}
+
+// drmChannelCount returns the number of channels known to stream with DRM
+func drmChannelCount() int {
+	drmObservationsMu.Lock()
+	defer drmObservationsMu.Unlock()
+	loadDRMObservationsLocked()
+
+	count := 0
+	for _, id := range drmList {
+		if _, observed := drmObservations[id]; !observed {
+			count++
+		}
+	}
+	for _, observation := range drmObservations {
+		if observation.DRM {
+			count++
+		}
+	}
+	return count
+}
+
+// liveFor fetches the live stream of a channel and records whether it
+// streams with DRM
+func liveFor(tv *television.Television, channelID string) (*television.LiveURLOutput, error) {
+	liveResult, err := tv.Live(channelID)
+	if err == nil && liveResult != nil {
+		recordDRMStatus(channelID, liveResult.IsDRM || liveResult.HasDRMStream(), time.Now(), true)
+	}
+	return liveResult, err
+}
+
+// recordDRMStatus records the DRM status of a channel. It reports whether the
+// status differs from what was known. The observations are saved if save is
+// set and the status changed or its last observation is old.
+func recordDRMStatus(channelID string, drm bool, now time.Time, save bool) bool {
+	if channelID == "" || isCustomChannel(channelID) {
+		return false
+	}
+	known := isDRMChannel(channelID)
+
+	drmObservationsMu.Lock()
+	defer drmObservationsMu.Unlock()
+
+	previous, observed := drmObservations[channelID]
+	if observed && previous.DRM == drm && now.Sub(previous.ObservedAt) < drmObservationMaxAge {
+		return false
+	}
+	drmObservations[channelID] = DRMObservation{DRM: drm, ObservedAt: now}
+	if drm != known {
+		utils.Log.Printf("DRM status of channel %s changed to %t", channelID, drm)
+	}
+	if save {
+		if err := saveDRMObservationsLocked(); err != nil {
+			utils.Log.Printf("Error saving DRM channels: %v", err)
+		}
+	}
+	return drm != known
+}
+
+// DRMObservations returns the DRM status observed for each channel
+func DRMObservations() map[string]DRMObservation {
+	drmObservationsMu.Lock()
+	defer drmObservationsMu.Unlock()
+	loadDRMObservationsLocked()
+
+	observations := make(map[string]DRMObservation, len(drmObservations))
+	for id, observation := range drmObservations {
+		observations[id] = observation
+	}
+	return observations
+}
+
+// ProbeDRMChannels fetches the live stream of every JioTV channel, at most
+// concurrency at a time, to learn which of them stream with DRM. progress is
+// called after each channel. It plays with the default profile only: DRM is
+// a property of the channel, and channels the default profile can not play
+// are counted as failed.
+func ProbeDRMChannels(concurrency int, progress func(DRMProbeProgress)) (DRMProbeProgress, error) {
+	if concurrency <= 0 {
+		concurrency = defaultDRMProbeConcurrency
+	}
+	channels, err := television.Channels()
+	if err != nil {
+		return DRMProbeProgress{}, err
+	}
+
+	var ids []string
+	for _, channel := range channels.Result {
+		if !isCustomChannel(channel.ID) {
+			ids = append(ids, channel.ID)
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		report   = DRMProbeProgress{Total: len(ids)}
+		next     atomic.Int64
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	for range min(concurrency, len(ids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(ids) {
+					return
+				}
+				liveResult, err := TV.Live(ids[i])
+				var drm, changed bool
+				if err == nil && liveResult != nil {
+					drm = liveResult.IsDRM || liveResult.HasDRMStream()
+					changed = recordDRMStatus(ids[i], drm, time.Now(), false)
+				}
+
+				mu.Lock()
+				report.Done++
+				switch {
+				case err != nil || liveResult == nil:
+					report.Failed++
+					if firstErr == nil && err != nil {
+						firstErr = err
+					}
+				case drm:
+					report.DRM++
+				}
+				if changed {
+					report.Changed++
+				}
+				if progress != nil {
+					progress(report)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	drmObservationsMu.Lock()
+	err = saveDRMObservationsLocked()
+	drmObservationsMu.Unlock()
+	if err != nil {
+		return report, err
+	}
+	if report.Total > 0 && report.Failed == report.Total {
+		return report, firstErr
+	}
+	return report, nil
+}
+
+func drmChannelsPath() string {
+	return filepath.Join(store.GetPathPrefix(), drmChannelsFile)
+}
+
+// loadDRMObservationsLocked loads the observations from disk once.
+// drmObservationsMu must be held.
+func loadDRMObservationsLocked() {
+	if drmObservationsLoaded {
+		return
+	}
+	drmObservationsLoaded = true
+
+	observations, err := readDRMObservations()
+	if err != nil {
+		utils.Log.Printf("Error loading DRM channels: %v", err)
+	}
+	drmObservations = observations
+}
+
+// readDRMObservations reads the observations saved on disk
+func readDRMObservations() (map[string]DRMObservation, error) {
+	observations := make(map[string]DRMObservation)
+	data, err := os.ReadFile(drmChannelsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return observations, nil
+	}
+	if err != nil {
+		return observations, err
+	}
+	if err := json.Unmarshal(data, &observations); err != nil {
+		return make(map[string]DRMObservation), err
+	}
+	return observations, nil
+}
+
+// saveDRMObservationsLocked saves the observations to disk. Newer
+// observations saved meanwhile by another process, such as a probe while the
+// server runs, are kept. drmObservationsMu must be held.
+func saveDRMObservationsLocked() error {
+	if saved, err := readDRMObservations(); err == nil {
+		for id, observation := range saved {
+			if current, ok := drmObservations[id]; !ok || observation.ObservedAt.After(current.ObservedAt) {
+				drmObservations[id] = observation
+			}
+		}
+	}
+	return store.WriteFileAtomic(drmChannelsPath(), 0644, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(drmObservations)
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+// resetDRMObservations forgets the observations loaded from disk
+func resetDRMObservations() {
+	drmObservationsMu.Lock()
+	drmObservations = nil
+	drmObservationsLoaded = false
+	drmObservationsMu.Unlock()
+}
+
+func TestRecordDRMStatus(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousLog := utils.Log
+	utils.Log = log.New(os.Stderr, "", 0)
+	resetDRMObservations()
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log = previousLog
+		resetDRMObservations()
+	})
+
+	const seeded, unseeded = "143", "999999"
+	if !isDRMChannel(seeded) || isDRMChannel(unseeded) {
+		t.Fatalf("isDRMChannel() does not fall back to drmList")
+	}
+	seedCount := drmChannelCount()
+
+	now := time.Now()
+	tests := []struct {
+		name        string
+		channelID   string
+		drm         bool
+		at          time.Time
+		wantChanged bool
+		wantDRM     bool
+		wantSavedAt time.Time
+	}{
+		{name: "new DRM channel", channelID: unseeded, drm: true, at: now, wantChanged: true, wantDRM: true, wantSavedAt: now},
+		{name: "unchanged observation is not saved again", channelID: unseeded, drm: true, at: now.Add(time.Hour), wantDRM: true, wantSavedAt: now},
+		{name: "old observation is saved again", channelID: unseeded, drm: true, at: now.Add(drmObservationMaxAge), wantDRM: true, wantSavedAt: now.Add(drmObservationMaxAge)},
+		{name: "seeded channel drops DRM", channelID: seeded, drm: false, at: now, wantChanged: true, wantDRM: false, wantSavedAt: now},
+		{name: "seeded channel matches the seed", channelID: "144", drm: true, at: now, wantDRM: true, wantSavedAt: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if changed := recordDRMStatus(tt.channelID, tt.drm, tt.at, true); changed != tt.wantChanged {
+				t.Errorf("recordDRMStatus() = %v, want %v", changed, tt.wantChanged)
+			}
+			if got := isDRMChannel(tt.channelID); got != tt.wantDRM {
+				t.Errorf("isDRMChannel() = %v, want %v", got, tt.wantDRM)
+			}
+
+			// The observation survives a restart
+			resetDRMObservations()
+			saved := DRMObservations()[tt.channelID]
+			if saved.DRM != tt.wantDRM || !saved.ObservedAt.Equal(tt.wantSavedAt) {
+				t.Errorf("saved observation = %+v, want DRM %v at %v", saved, tt.wantDRM, tt.wantSavedAt)
+			}
+		})
+	}
+
+	// One channel joined and one left the seeded list
+	if got := drmChannelCount(); got != seedCount {
+		t.Errorf("drmChannelCount() = %d, want %d", got, seedCount)
+	}
+}
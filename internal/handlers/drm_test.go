@@ -1,13 +1,20 @@
 package handlers
 
 import (
+	"log"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	fiberrecover "github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
 )
 
 func TestGetDrmMpd(t *testing.T) {
@@ -49,7 +56,7 @@ func TestGetDrmMpd(t *testing.T) {
 				}
 			}()
 
-			got, err := getDrmMpd(tt.args.channelID, tt.args.quality)
+			got, err := getDrmMpd("", tt.args.channelID, tt.args.quality, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getDrmMpd() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -162,6 +169,63 @@ func TestMpdHandler(t *testing.T) {
 	}
 }
 
+func TestMpdHandlerActiveStreams(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	previousLog := utils.Log
+	utils.Log = log.New(os.Stderr, "", 0)
+	autoChannelsMu.Lock()
+	autoChannels = map[string]television.Channel{"143": {ID: "143", Name: "News Channel"}, "144": {ID: "144", Name: "Music Channel"}}
+	autoChannelsUpdatedAt = time.Now()
+	autoChannelsMu.Unlock()
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log = previousLog
+		autoChannelsMu.Lock()
+		autoChannels = nil
+		autoChannelsMu.Unlock()
+	})
+
+	secureurl.Init()
+	auth, err := secureurl.EncryptURLFor("https://jiotvmblive.cdn.jio.com/bpk-tv/News/index.mpd", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	// The handler goes on to the uninitialized TV after counting
+	app.Use(fiberrecover.New())
+	app.Get("/render.mpd", MpdHandler)
+	for _, channelID := range []string{"143", "bogus"} {
+		if _, err := app.Test(httptest.NewRequest("GET", "/render.mpd?channel_id="+channelID+"&auth="+url.QueryEscape(auth), nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only channels of the channel list are labels of the metric
+	if counts := activeStreams.counts(time.Now()); counts["143"] != 1 || counts["bogus"] != 0 {
+		t.Errorf("active streams = %v, want one stream of channel 143", counts)
+	}
+
+	// Requests with a URL the server did not sign are not counted
+	for _, channelID := range []string{"144", "bogus"} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/render.mpd?channel_id="+channelID+"&auth=forged", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == fiber.StatusOK {
+			t.Errorf("MpdHandler() with a forged auth of channel %s = 200", channelID)
+		}
+		if count := activeStreams.counts(time.Now())[channelID]; count != 0 {
+			t.Errorf("MpdHandler() with a forged auth counted %d streams of channel %s", count, channelID)
+		}
+	}
+}
+
 func TestDashHandler(t *testing.T) {
 	type args struct {
 		c *fiber.Ctx
@@ -252,3 +316,25 @@ func TestLiveManifestHandlers(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildDrmMpdOutputBindsClient(t *testing.T) {
+	secureurl.Init()
+	cacheKey := drmMpdCacheKey("", "143", "auto", "10.0.0.1")
+	if cacheKey == drmMpdCacheKey("", "143", "auto", "10.0.0.2") {
+		t.Fatal("clients share a cache key")
+	}
+	t.Cleanup(func() { drmMpdCache.Delete(cacheKey) })
+
+	liveResult := &television.LiveURLOutput{IsDRM: true}
+	liveResult.Mpd.Result = "https://jiotvmblive.cdn.jio.com/bpk-tv/News/index.mpd"
+	output, err := buildDrmMpdOutput(liveResult, cacheKey, "143", "auto", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, err := secureurl.DecryptURLFor(output.Tv_url_host, "10.0.0.1"); err != nil || host != "jiotvmblive.cdn.jio.com" {
+		t.Errorf("DecryptURLFor(host) = %q, %v, want the stream host", host, err)
+	}
+	if _, err := secureurl.DecryptURLFor(output.Tv_url_path, "10.0.0.2"); err == nil {
+		t.Error("another client decrypted the stream path")
+	}
+}
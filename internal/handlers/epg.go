@@ -19,7 +19,7 @@ const (
 
 // EPGHandler handles EPG requests
 func EPGHandler(c *fiber.Ctx) error {
-	epgFilePath := utils.GetPathPrefix() + "epg.xml.gz"
+	epgFilePath := epg.FilePath()
 	// if epg.xml.gz exists, return it
 	if _, err := os.Stat(epgFilePath); err == nil {
 		return c.SendFile(epgFilePath, true)
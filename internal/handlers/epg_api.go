@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/middleware"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// epgScheduleWindow is the schedule of a channel returned when no end is given
+	epgScheduleWindow = 24 * time.Hour
+	// defaultEPGSearchLimit and maxEPGSearchLimit bound the results of a search
+	defaultEPGSearchLimit = 100
+	maxEPGSearchLimit     = 1000
+)
+
+// epgCatchupChannels returns the channels offering catchup. Tests replace it.
+var epgCatchupChannels = catchupChannels
+
+// epgIndexMissing responds while no EPG has been generated or loaded
+func epgIndexMissing(c *fiber.Ctx) error {
+	return internalUtils.ErrorResponse(c, fiber.StatusServiceUnavailable, "EPG is not available yet. Set epg to true in the config and wait for it to be generated.")
+}
+
+// EPGNowHandler returns the programme airing now on every channel
+func EPGNowHandler(c *fiber.Ctx) error {
+	index := epg.CurrentIndex()
+	if index == nil {
+		return epgIndexMissing(c)
+	}
+	now := time.Now()
+	return c.JSON(epgAPIResponse(c, index, index.Now(now), now))
+}
+
+// EPGNextHandler returns the programme airing next on every channel
+func EPGNextHandler(c *fiber.Ctx) error {
+	index := epg.CurrentIndex()
+	if index == nil {
+		return epgIndexMissing(c)
+	}
+	now := time.Now()
+	return c.JSON(epgAPIResponse(c, index, index.Next(now), now))
+}
+
+// EPGChannelHandler returns the schedule of a channel between the from and to
+// query parameters, by default the next 24 hours
+func EPGChannelHandler(c *fiber.Ctx) error {
+	index := epg.CurrentIndex()
+	if index == nil {
+		return epgIndexMissing(c)
+	}
+	channelID := c.Params("channelID")
+	now := time.Now()
+	from, to, err := epgTimeRange(c, now)
+	if err != nil {
+		return internalUtils.BadRequestError(c, err.Error())
+	}
+	if from.IsZero() {
+		from = now
+	}
+	if to.IsZero() {
+		to = from.Add(epgScheduleWindow)
+	}
+
+	programmes, ok := index.Channel(channelID, from, to)
+	if !ok {
+		return internalUtils.NotFoundError(c, fmt.Sprintf("No EPG for channel %s", channelID))
+	}
+	return c.JSON(epgAPIResponse(c, index, programmes, now))
+}
+
+// EPGSearchHandler searches the titles and descriptions of the programmes
+// for q, optionally limited to a category and to the from and to times
+func EPGSearchHandler(c *fiber.Ctx) error {
+	index := epg.CurrentIndex()
+	if index == nil {
+		return epgIndexMissing(c)
+	}
+	query := epg.SearchQuery{Text: c.Query("q"), Category: c.Query("category")}
+	if query.Text == "" && query.Category == "" {
+		return internalUtils.BadRequestError(c, "Missing q or category")
+	}
+	now := time.Now()
+	var err error
+	if query.From, query.To, err = epgTimeRange(c, now); err != nil {
+		return internalUtils.BadRequestError(c, err.Error())
+	}
+	query.Limit = c.QueryInt("limit", defaultEPGSearchLimit)
+	if query.Limit <= 0 || query.Limit > maxEPGSearchLimit {
+		return internalUtils.BadRequestError(c, fmt.Sprintf("limit must be between 1 and %d", maxEPGSearchLimit))
+	}
+	return c.JSON(epgAPIResponse(c, index, index.Search(query), now))
+}
+
+// epgTimeRange parses the optional from and to query parameters, which take
+// the same formats as recording times. Missing times are zero.
+func epgTimeRange(c *fiber.Ctx, now time.Time) (from, to time.Time, err error) {
+	if value := c.Query("from"); value != "" {
+		if from, err = parseRecordingTime(value, now); err != nil {
+			return from, to, err
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = parseRecordingTime(value, now); err != nil {
+			return from, to, err
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return from, to, fmt.Errorf("to must be after from")
+	}
+	return from, to, nil
+}
+
+// epgAPIResponse adds playback links to programmes
+func epgAPIResponse(c *fiber.Ctx, index *epg.Index, programmes []epg.IndexedProgramme, now time.Time) EPGAPIResponse {
+	hostURL := c.Protocol() + "://" + c.Hostname()
+	catchup := epgCatchupChannels()
+	response := EPGAPIResponse{Updated: index.Updated(), Programmes: make([]EPGAPIProgramme, len(programmes))}
+	for i, programme := range programmes {
+		links := epgProgrammeLinks(programme, hostURL, catchup, now)
+		links.Play = middleware.WithURLQuery(c, links.Play)
+		links.Stream = middleware.WithURLQuery(c, links.Stream)
+		links.Catchup = middleware.WithURLQuery(c, links.Catchup)
+		links.CatchupStream = middleware.WithURLQuery(c, links.CatchupStream)
+		response.Programmes[i] = EPGAPIProgramme{IndexedProgramme: programme, Links: links}
+	}
+	return response
+}
+
+// catchupChannels returns the IDs of the channels offering catchup, or nil
+// when the channel list is unavailable
+func catchupChannels() map[string]bool {
+	channels, err := television.Channels()
+	if err != nil {
+		utils.Log.Printf("Unable to check catchup availability: %v", err)
+		return nil
+	}
+	catchup := make(map[string]bool)
+	for _, channel := range channels.Result {
+		if channel.IsCatchupAvailable {
+			catchup[channel.ID] = true
+		}
+	}
+	return catchup
+}
+
+// epgProgrammeLinks returns the links to watch a programme at now: live while
+// it airs, and from catchup once it has started on a channel offering it.
+// When catchup is nil, any programme with a catchup ID is linked.
+func epgProgrammeLinks(programme epg.IndexedProgramme, hostURL string, catchup map[string]bool, now time.Time) EPGLinks {
+	var links EPGLinks
+	id := programme.ChannelID
+	if !programme.Start.After(now) && programme.Stop.After(now) {
+		links.Play = fmt.Sprintf("%s/play/%s", hostURL, id)
+		if EnableDRM && isDRMChannel(id) {
+			links.Stream = fmt.Sprintf("%s/live/mpd/%s", hostURL, id)
+		} else {
+			links.Stream = fmt.Sprintf("%s/live/%s.m3u8", hostURL, id)
+		}
+	}
+
+	started := !programme.Start.After(now)
+	inWindow := programme.Start.After(now.AddDate(0, 0, -catchupEPGDays))
+	if programme.CatchupID == "" || !started || !inWindow || (catchup != nil && !catchup[id]) {
+		return links
+	}
+	query := url.Values{}
+	query.Set("start", strconv.FormatInt(programme.Start.UnixMilli(), 10))
+	query.Set("end", strconv.FormatInt(programme.Stop.UnixMilli(), 10))
+	query.Set("srno", programme.CatchupID)
+	links.CatchupStream = fmt.Sprintf("%s/catchup/stream/%s.m3u8?%s", hostURL, id, query.Encode())
+	query.Set("showname", programme.Title)
+	query.Set("description", programme.Description)
+	links.Catchup = fmt.Sprintf("%s/catchup/play/%s?%s", hostURL, id, query.Encode())
+	return links
+}
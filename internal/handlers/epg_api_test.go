@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func TestEPGProgrammeLinks(t *testing.T) {
+	now := time.Date(2024, 1, 15, 18, 30, 0, 0, time.UTC)
+	programme := func(start time.Time, catchupID string) epg.IndexedProgramme {
+		return epg.IndexedProgramme{ChannelID: "143", Title: "News & Views", Start: start, Stop: start.Add(time.Hour), CatchupID: catchupID}
+	}
+	tests := []struct {
+		name              string
+		programme         epg.IndexedProgramme
+		catchup           map[string]bool
+		wantLive          bool
+		wantCatchupStream bool
+	}{
+		{name: "Airing", programme: programme(now.Add(-30*time.Minute), "1"), wantLive: true, wantCatchupStream: true},
+		{name: "Upcoming", programme: programme(now.Add(time.Hour), "2")},
+		{name: "Ended", programme: programme(now.Add(-2*time.Hour), "3"), wantCatchupStream: true},
+		{name: "Without catchup ID", programme: programme(now.Add(-2*time.Hour), "")},
+		{name: "Older than catchup", programme: programme(now.AddDate(0, 0, -8), "4")},
+		{name: "Channel without catchup", programme: programme(now.Add(-2*time.Hour), "5"), catchup: map[string]bool{"144": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := epgProgrammeLinks(tt.programme, "http://host", tt.catchup, now)
+			if tt.wantLive != (links.Play == "http://host/play/143") || tt.wantLive != (links.Stream == "http://host/live/143.m3u8") {
+				t.Errorf("epgProgrammeLinks() live links = %q, %q, want live %v", links.Play, links.Stream, tt.wantLive)
+			}
+			wantStream := ""
+			if tt.wantCatchupStream {
+				wantStream = fmt.Sprintf("http://host/catchup/stream/143.m3u8?end=%d&srno=%s&start=%d",
+					tt.programme.Stop.UnixMilli(), tt.programme.CatchupID, tt.programme.Start.UnixMilli())
+			}
+			if links.CatchupStream != wantStream {
+				t.Errorf("epgProgrammeLinks() catchup stream = %q, want %q", links.CatchupStream, wantStream)
+			}
+			if (links.Catchup == "") != (wantStream == "") {
+				t.Errorf("epgProgrammeLinks() catchup = %q", links.Catchup)
+			}
+			if links.Catchup != "" && !strings.Contains(links.Catchup, "showname=News+%26+Views") {
+				t.Errorf("epgProgrammeLinks() catchup %q does not escape the show name", links.Catchup)
+			}
+		})
+	}
+}
+
+// writeTestEPG writes an EPG file with a programme airing now and a later one
+func writeTestEPG(t *testing.T, now time.Time) string {
+	t.Helper()
+	format := func(t time.Time) string { return t.Format("20060102150405 -0700") }
+	document := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<tv>
+<channel id="143"><display-name>News One</display-name></channel>
+<programme channel="143" start="%s" stop="%s"><title lang="en">Headlines</title><desc lang="en">Top stories</desc><category lang="en">News</category><icon src=""></icon></programme>
+<programme channel="143" start="%s" stop="%s"><title lang="en">Debate</title><desc lang="en">Evening debate</desc><category lang="en">News</category><icon src=""></icon></programme>
+</tv>`, format(now.Add(-10*time.Minute)), format(now.Add(20*time.Minute)), format(now.Add(20*time.Minute)), format(now.Add(80*time.Minute)))
+
+	filename := filepath.Join(t.TempDir(), "epg.xml.gz")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	if _, err := gz.Write([]byte(document)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestEPGAPIHandlers(t *testing.T) {
+	previousLog, previousCatchup := utils.Log, epgCatchupChannels
+	utils.Log = log.New(os.Stderr, "", 0)
+	epgCatchupChannels = func() map[string]bool { return map[string]bool{"143": true} }
+	t.Cleanup(func() { utils.Log, epgCatchupChannels = previousLog, previousCatchup })
+
+	now := time.Now().Truncate(time.Second)
+	if err := epg.LoadIndex(writeTestEPG(t, now)); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/api/epg/now", EPGNowHandler)
+	app.Get("/api/epg/next", EPGNextHandler)
+	app.Get("/api/epg/search", EPGSearchHandler)
+	app.Get("/api/epg/:channelID", EPGChannelHandler)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantTitles []string
+	}{
+		{name: "Now", path: "/api/epg/now", wantStatus: 200, wantTitles: []string{"Headlines"}},
+		{name: "Next", path: "/api/epg/next", wantStatus: 200, wantTitles: []string{"Debate"}},
+		{name: "Channel", path: "/api/epg/143", wantStatus: 200, wantTitles: []string{"Headlines", "Debate"}},
+		{name: "Channel from", path: fmt.Sprintf("/api/epg/143?from=%d", now.Add(30*time.Minute).Unix()), wantStatus: 200, wantTitles: []string{"Debate"}},
+		{name: "Unknown channel", path: "/api/epg/144", wantStatus: 404},
+		{name: "Invalid range", path: "/api/epg/143?from=now&to=now", wantStatus: 400},
+		{name: "Search", path: "/api/epg/search?q=evening", wantStatus: 200, wantTitles: []string{"Debate"}},
+		{name: "Search category", path: "/api/epg/search?category=news&limit=1", wantStatus: 200, wantTitles: []string{"Headlines"}},
+		{name: "Search without query", path: "/api/epg/search", wantStatus: 400},
+		{name: "Search limit", path: "/api/epg/search?q=a&limit=0", wantStatus: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+			var response EPGAPIResponse
+			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, programme := range response.Programmes {
+				got = append(got, programme.Title)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantTitles, ",") {
+				t.Errorf("got programmes %v, want %v", got, tt.wantTitles)
+			}
+			if len(response.Programmes) > 0 && response.Programmes[0].ChannelName != "News One" {
+				t.Errorf("got channel name %q, want News One", response.Programmes[0].ChannelName)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/jiotv-go/jiotv_go/v3/internal/config"
 	"github.com/jiotv-go/jiotv_go/v3/internal/constants/headers"
 	"github.com/jiotv-go/jiotv_go/v3/internal/constants/urls"
+	"github.com/jiotv-go/jiotv_go/v3/internal/middleware"
 	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
@@ -78,7 +79,7 @@ func Init() {
 	if !EnableDRM {
 		utils.Log.Println("If you're not using IPTV Client. We strongly recommend enabling DRM for accessing channels without any issues! Either enable by setting environment variable JIOTV_DRM=true or by setting DRM: true in config. For more info Read https://telegram.me/jiotv_go/128")
 	} else {
-		utils.Log.Printf("Successfully loaded %d DRM channels", len(drmList))
+		utils.Log.Printf("Successfully loaded %d DRM channels", drmChannelCount())
 	}
 	// Generate a new device ID if not present
 	utils.GetDeviceID()
@@ -101,6 +102,9 @@ func Init() {
 		TV = television.New(credentials)
 	}
 
+	// Size the shared segment relay from the config
+	segmentRelay = newSegmentRelay(segmentRelayMaxBytes())
+
 	// Initialize custom channels at startup if configured
 	television.InitCustomChannels()
 }
@@ -136,7 +140,7 @@ func IndexHandler(c *fiber.Ctx) error {
 		return ErrorMessageHandler(c, err)
 	}
 
-	premiumProviders, premiumErr := television.PremiumProviders()
+	premiumProviders, premiumErr := television.PremiumProvidersFor(requestProfile(c))
 	if premiumErr != nil {
 		utils.SafeLogf("Unable to fetch premium providers: %v", premiumErr)
 	}
@@ -385,14 +389,14 @@ func liveResultNeedsRefresh(liveResult *television.LiveURLOutput) bool {
 
 // refreshChannelToken safely fetches a fresh stream using singleflight to prevent multiple
 // concurrent API requests for the same channel ID when a token expires (thundering herd).
-func refreshChannelToken(channelID string) (*television.LiveURLOutput, error) {
+func refreshChannelToken(profile, channelID string) (*television.LiveURLOutput, error) {
 	if channelID == "" {
 		return nil, fmt.Errorf("empty channel ID")
 	}
 
 	// Use singleflight to ensure only one concurrent TV.Live request per channelID
-	v, err, _ := tokenRefreshGroup.Do(channelID, func() (interface{}, error) {
-		return TV.Live(channelID)
+	v, err, _ := tokenRefreshGroup.Do(profileCacheKey(profile, channelID), func() (interface{}, error) {
+		return liveFor(tvFor(profile), channelID)
 	})
 
 	if err != nil {
@@ -407,13 +411,13 @@ func refreshChannelToken(channelID string) (*television.LiveURLOutput, error) {
 	return result, nil
 }
 
-func refreshLiveResultIfNeeded(channelID string, liveResult *television.LiveURLOutput) (*television.LiveURLOutput, error) {
+func refreshLiveResultIfNeeded(profile, channelID string, liveResult *television.LiveURLOutput) (*television.LiveURLOutput, error) {
 	if channelID == "" || liveResult == nil || !liveResultNeedsRefresh(liveResult) {
 		return liveResult, nil
 	}
 
 	utils.Log.Printf("HDNEA token is near expiry for channel %s; refreshing live URL", channelID)
-	refreshedResult, err := refreshChannelToken(channelID)
+	refreshedResult, err := refreshChannelToken(profile, channelID)
 	if err != nil {
 		return liveResult, err
 	}
@@ -462,6 +466,12 @@ func getCachedHDNEA(channelID string) string {
 	if channelID == "" {
 		return ""
 	}
+	token := loadCachedHDNEA(channelID)
+	countCacheLookup(hdneaCacheRequests, token != "")
+	return token
+}
+
+func loadCachedHDNEA(channelID string) string {
 	entryRaw, ok := renderHDNEACache.Load(channelID)
 	if !ok {
 		return ""
@@ -553,12 +563,13 @@ func LiveHandler(c *fiber.Ctx) error {
 	}
 
 	// For regular JioTV channels, ensure tokens are fresh before making API call
-	if err := EnsureFreshTokens(); err != nil {
+	profile := channelProfile(c, id)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		utils.Log.Printf("Failed to ensure fresh tokens: %v", err)
 		// Continue with the request - tokens might still work
 	}
 
-	liveResult, err := TV.Live(id)
+	liveResult, err := liveFor(tvFor(profile), id)
 	if err != nil {
 		utils.Log.Println(err)
 		return internalUtils.InternalServerError(c, err)
@@ -573,18 +584,18 @@ func LiveHandler(c *fiber.Ctx) error {
 	}
 	liveURL = toAbsoluteStreamURL(liveURL, liveResult)
 	if liveResult.Hdnea != "" {
-		setCachedHDNEA(id, liveResult.Hdnea)
+		setCachedHDNEA(profileCacheKey(profile, id), liveResult.Hdnea)
 	}
 	// quote url as it will be passed as a query parameter
 	// It is required to quote the url as it may contain special characters like ? and &
 
-	coded_url, err := secureurl.EncryptURL(liveURL)
+	coded_url, err := secureurl.EncryptURLFor(liveURL, internalUtils.URLClient(c))
 	if err != nil {
 		utils.Log.Println(err)
 		return internalUtils.ForbiddenError(c, err)
 	}
 	redirectURL := "/render.m3u8?auth=" + coded_url + "&channel_key_id=" + id
-	return c.Redirect(redirectURL, fiber.StatusFound)
+	return c.Redirect(middleware.WithURLQuery(c, redirectURL), fiber.StatusFound)
 }
 
 // LiveQualityHandler handles the live channel stream route `/live/:quality/:id.m3u8`.
@@ -593,28 +604,54 @@ func LiveQualityHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
 	// remove suffix .m3u8 if exists
 	id = strings.Replace(id, ".m3u8", "", 1)
+	redirectURL, err := liveQualityURL(c, quality, id)
+	if redirectURL == "" {
+		return err
+	}
+	// The tuner is only reserved for streams that can be played
+	if ok, err := reserveHDHomeRunTuner(c, id); !ok {
+		return err
+	}
+	return c.Redirect(redirectURL, fiber.StatusFound)
+}
 
+// liveQualityRedirect resolves the live stream for channel id at the given
+// quality and redirects to the render pipeline. It backs the Xtream Codes
+// live route.
+func liveQualityRedirect(c *fiber.Ctx, quality, id string) error {
+	redirectURL, err := liveQualityURL(c, quality, id)
+	if redirectURL == "" {
+		return err
+	}
+	return c.Redirect(redirectURL, fiber.StatusFound)
+}
+
+// liveQualityURL resolves the live stream for channel id at the given quality
+// and returns the URL of the render pipeline to redirect to. It returns an
+// empty URL after responding when the stream cannot be resolved.
+func liveQualityURL(c *fiber.Ctx, quality, id string) (string, error) {
 	// Check if this is a custom channel - serve directly for custom channels
 	if isCustomChannel(id) {
 		channel, exists := television.GetCustomChannelByID(id)
 		if !exists {
 			utils.Log.Printf("Custom channel with ID %s not found", id)
-			return internalUtils.NotFoundError(c, fmt.Sprintf("Custom channel with ID %s not found", id))
+			return "", internalUtils.NotFoundError(c, fmt.Sprintf("Custom channel with ID %s not found", id))
 		}
 		// For custom channels, redirect directly to the m3u8 URL (no render pipeline needed)
-		return c.Redirect(channel.URL, fiber.StatusFound)
+		return channel.URL, nil
 	}
 
 	// For regular JioTV channels, ensure tokens are fresh before making API call
-	if err := EnsureFreshTokens(); err != nil {
+	profile := channelProfile(c, id)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		utils.Log.Printf("Failed to ensure fresh tokens: %v", err)
 		// Continue with the request - tokens might still work
 	}
 
-	liveResult, err := TV.Live(id)
+	liveResult, err := liveFor(tvFor(profile), id)
 	if err != nil {
 		utils.Log.Println(err)
-		return internalUtils.InternalServerError(c, err)
+		return "", internalUtils.InternalServerError(c, err)
 	}
 	// Channels with following IDs output audio only m3u8 when quality level is enforced
 	if id == "1349" || id == "1322" {
@@ -627,21 +664,21 @@ func LiveQualityHandler(c *fiber.Ctx) error {
 		error_message := "No stream found for channel id: " + id + "Status: " + liveResult.Message
 		utils.Log.Println(error_message)
 		utils.Log.Println(liveResult)
-		return internalUtils.NotFoundError(c, error_message)
+		return "", internalUtils.NotFoundError(c, error_message)
 	}
 	liveURL = toAbsoluteStreamURL(liveURL, liveResult)
 	if liveResult.Hdnea != "" {
-		setCachedHDNEA(id, liveResult.Hdnea)
+		setCachedHDNEA(profileCacheKey(profile, id), liveResult.Hdnea)
 	}
 
 	// quote url as it will be passed as a query parameter
-	coded_url, err := secureurl.EncryptURL(liveURL)
+	coded_url, err := secureurl.EncryptURLFor(liveURL, internalUtils.URLClient(c))
 	if err != nil {
 		utils.Log.Println(err)
-		return internalUtils.ForbiddenError(c, err)
+		return "", internalUtils.ForbiddenError(c, err)
 	}
 	redirectURL := "/render.m3u8?auth=" + coded_url + "&channel_key_id=" + id + "&q=" + quality
-	return c.Redirect(redirectURL, fiber.StatusFound)
+	return middleware.WithURLQuery(c, redirectURL), nil
 }
 
 // RenderHandler handles M3U8 file for modification
@@ -657,16 +694,22 @@ func RenderHandler(c *fiber.Ctx) error {
 	if err := internalUtils.ValidateRequiredParam("channel_key_id", channel_id); err != nil {
 		return err
 	}
+	// Keep the HDHomeRun tuner of this stream reserved while the client polls the playlist
+	hdhomerunTuners.touch(c.IP(), channel_id, time.Now())
+	activeStreams.touch(channel_id, c.IP(), time.Now())
 	// decrypt url
-	decoded_url, err := secureurl.DecryptURL(auth)
+	profile := requestProfile(c)
+	tv := tvFor(profile)
+	rewrite := television.RewriteOptions{Client: internalUtils.URLClient(c), Query: middleware.URLQuery(c)}
+	decoded_url, err := secureurl.DecryptURLFor(auth, rewrite.Client)
 	if err != nil {
 		utils.Log.Println(err)
-		return err
+		return internalUtils.URLTokenError(c, err)
 	}
 
 	decoded_url = toAbsoluteStreamURL(decoded_url, nil)
 
-	hdneaKey := hdneaCacheKey(channel_id, decoded_url)
+	hdneaKey := profileCacheKey(profile, hdneaCacheKey(channel_id, decoded_url))
 
 	// Always prefer a freshly cached HDNEA token if available to prevent 403s on expired URL tokens
 	cachedHDNEA := getCachedHDNEA(hdneaKey)
@@ -691,7 +734,7 @@ func RenderHandler(c *fiber.Ctx) error {
 		utils.Log.Printf("[DEBUG] Token selection - URL token: %s | Cached token: %s | Using: %s (source: %s)",
 			truncateToken(urlToken), truncateToken(getCachedHDNEA(hdneaKey)), truncateToken(cachedHDNEA), sourceStr)
 	}
-	renderResult, statusCode, newHdnea := TV.Render(renderURL, cachedHDNEA)
+	renderResult, statusCode, newHdnea := tv.Render(renderURL, cachedHDNEA)
 
 	// DEBUG: Log token extraction and response
 	if os.Getenv("JIOTV_DEBUG") == "true" {
@@ -716,7 +759,7 @@ func RenderHandler(c *fiber.Ctx) error {
 		}
 
 		if channel_id != "" {
-			if refreshedLiveResult, refreshErr := refreshChannelToken(channel_id); refreshErr == nil && refreshedLiveResult != nil {
+			if refreshedLiveResult, refreshErr := refreshChannelToken(profile, channel_id); refreshErr == nil && refreshedLiveResult != nil {
 				if freshToken := extractLiveResultHDNEA(refreshedLiveResult); freshToken != "" {
 					setCachedHDNEA(hdneaKey, freshToken)
 					cachedHDNEA = freshToken
@@ -730,7 +773,7 @@ func RenderHandler(c *fiber.Ctx) error {
 				// using the freshly harvested cachedHDNEA token we just acquired.
 				// This preserves the player's requested timeline sequence.
 				renderURL = stripHDNEAFromURL(decoded_url)
-				renderResult, statusCode, newHdnea = TV.Render(renderURL, cachedHDNEA)
+				renderResult, statusCode, newHdnea = tv.Render(renderURL, cachedHDNEA)
 				if newHdnea != "" {
 					setCachedHDNEA(hdneaKey, newHdnea)
 					cachedHDNEA = newHdnea
@@ -759,7 +802,7 @@ func RenderHandler(c *fiber.Ctx) error {
 						}
 
 						renderURL = candidateURL
-						renderResult, statusCode, newHdnea = TV.Render(renderURL, cachedHDNEA)
+						renderResult, statusCode, newHdnea = tv.Render(renderURL, cachedHDNEA)
 						if newHdnea != "" {
 							setCachedHDNEA(hdneaKey, newHdnea)
 							cachedHDNEA = newHdnea
@@ -811,11 +854,11 @@ func RenderHandler(c *fiber.Ctx) error {
 	replacer := func(match []byte) []byte {
 		switch mediaURIExtension(match) {
 		case ".m3u8":
-			return television.ReplaceM3U8(baseUrl, match, params, channel_id, c.Query("q"))
+			return television.ReplaceM3U8(baseUrl, match, params, channel_id, c.Query("q"), rewrite)
 		case ".ts":
-			return television.ReplaceTS(baseUrl, match, params, channel_id)
+			return television.ReplaceTS(baseUrl, match, params, channel_id, rewrite)
 		case ".aac":
-			return television.ReplaceAAC(baseUrl, match, params, channel_id)
+			return television.ReplaceAAC(baseUrl, match, params, channel_id, rewrite)
 		default:
 			return match
 		}
@@ -832,7 +875,7 @@ func RenderHandler(c *fiber.Ctx) error {
 	replacer_key := func(match []byte) []byte {
 		switch {
 		case bytes.HasSuffix(match, []byte(".key")) || bytes.HasSuffix(match, []byte(".pkey")):
-			return television.ReplaceKey(match, params, channel_id)
+			return television.ReplaceKey(match, params, channel_id, rewrite)
 		default:
 			return match
 		}
@@ -883,9 +926,9 @@ func RenderKeyHandler(c *fiber.Ctx) error {
 		c.Request().Header.SetCookie("__hdnea__", hdnea)
 	}
 	// decode url
-	decoded_url, err := internalUtils.DecryptURLParam("auth", auth)
+	decoded_url, err := internalUtils.DecryptURLParam("auth", auth, internalUtils.URLClient(c))
 	if err != nil {
-		return err
+		return internalUtils.URLTokenError(c, err)
 	}
 
 	parsedURL, parseErr := url.Parse(decoded_url)
@@ -904,18 +947,19 @@ func RenderKeyHandler(c *fiber.Ctx) error {
 	}
 
 	// Copy headers from the Television headers map to the request
-	for key, value := range TV.Headers {
+	tv := tvFor(requestProfile(c))
+	for key, value := range tv.Headers {
 		c.Request().Header.Set(key, value) // Assuming only one value for each header
 	}
 	c.Request().Header.Set("srno", "230203144000")
-	c.Request().Header.Set("ssotoken", TV.SsoToken)
+	c.Request().Header.Set("ssotoken", tv.SsoToken)
 	c.Request().Header.Set("channelId", channel_id)
 	// Strip browser-added headers before proxying upstream. The key endpoint
 	// rejects requests carrying an Origin header with 403, which breaks
 	// AES-128 channels for any browser-based player served from a different
 	// origin (for example Jellyfin on :8096 requesting keys from :5001).
 	internalUtils.SetPlayerHeaders(c, PLAYER_USER_AGENT)
-	if err := proxy.Do(c, decoded_url, TV.Client); err != nil {
+	if err := proxy.Do(c, decoded_url, tv.Client); err != nil {
 		return err
 	}
 	c.Response().Header.Del(fiber.HeaderServer)
@@ -925,7 +969,9 @@ func RenderKeyHandler(c *fiber.Ctx) error {
 // RenderTSHandler loads TS file from JioTV server
 func RenderTSHandler(c *fiber.Ctx) error {
 	// Ensure tokens are fresh before proxying TS segments
-	if err := EnsureFreshTokens(); err != nil {
+	profile := requestProfile(c)
+	tv := tvFor(profile)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		utils.Log.Printf("Failed to ensure fresh tokens before TS proxy: %v", err)
 	}
 
@@ -933,20 +979,21 @@ func RenderTSHandler(c *fiber.Ctx) error {
 	if err := internalUtils.ValidateRequiredParam("channel_key_id", channelID); err != nil {
 		return err
 	}
+	hdhomerunTuners.touch(c.IP(), channelID, time.Now())
+	activeStreams.touch(channelID, c.IP(), time.Now())
 	auth := c.Query("auth")
 	// parse incoming hdnea query and set as request cookie only for upstream call (no client cookie)
 	if hdnea := c.Query("hdnea"); hdnea != "" {
 		c.Request().Header.SetCookie("__hdnea__", hdnea)
 	}
 	// decode url
-	decoded_url, err := internalUtils.DecryptURLParam("auth", auth)
+	decoded_url, err := internalUtils.DecryptURLParam("auth", auth, internalUtils.URLClient(c))
 	if err != nil {
-		utils.Log.Panicln(err)
-		return err
+		return internalUtils.URLTokenError(c, err)
 	}
 
 	// Cache tokens by stream kind: catchup and live ACLs are incompatible.
-	hdneaKey := hdneaCacheKey(channelID, decoded_url)
+	hdneaKey := profileCacheKey(profile, hdneaCacheKey(channelID, decoded_url))
 	cachedHDNEA := getCachedHDNEA(hdneaKey)
 	if cachedHDNEA != "" {
 		c.Request().Header.SetCookie("__hdnea__", cachedHDNEA)
@@ -971,40 +1018,42 @@ func RenderTSHandler(c *fiber.Ctx) error {
 		}
 	}
 
-	if err := internalUtils.ProxyRequest(c, decoded_url, TV.Client, PLAYER_USER_AGENT); err != nil {
-		return err
-	}
-
-	statusCode := c.Response().StatusCode()
-	if statusCode == fiber.StatusForbidden || statusCode == fiber.StatusUnauthorized {
-		if os.Getenv("JIOTV_DEBUG") == "true" {
-			utils.Log.Printf("[DEBUG] RenderTSHandler got %d response - forcing refresh and retrying", statusCode)
+	// Viewers of the same channel share one upstream fetch per segment
+	return relaySegmentResponse(c, decoded_url, func() error {
+		if err := internalUtils.ProxyRequest(c, decoded_url, tv.Client, PLAYER_USER_AGENT); err != nil {
+			return err
 		}
 
-		c.Response().Reset()
-		c.Request().Header.DelCookie("__hdnea__")
+		statusCode := c.Response().StatusCode()
+		if statusCode == fiber.StatusForbidden || statusCode == fiber.StatusUnauthorized {
+			if os.Getenv("JIOTV_DEBUG") == "true" {
+				utils.Log.Printf("[DEBUG] RenderTSHandler got %d response - forcing refresh and retrying", statusCode)
+			}
+
+			c.Response().Reset()
+			c.Request().Header.DelCookie("__hdnea__")
 
-		retryUrl := stripHDNEAFromURL(decoded_url)
-		if channelID != "" {
-			if refreshedResult, refreshErr := refreshChannelToken(channelID); refreshErr == nil && refreshedResult != nil {
-				if refreshedHDNEA := extractLiveResultHDNEA(refreshedResult); refreshedHDNEA != "" {
-					setCachedHDNEA(channelID, refreshedHDNEA)
-					c.Request().Header.SetCookie("__hdnea__", refreshedHDNEA)
+			retryUrl := stripHDNEAFromURL(decoded_url)
+			if channelID != "" {
+				if refreshedResult, refreshErr := refreshChannelToken(profile, channelID); refreshErr == nil && refreshedResult != nil {
+					if refreshedHDNEA := extractLiveResultHDNEA(refreshedResult); refreshedHDNEA != "" {
+						setCachedHDNEA(profileCacheKey(profile, channelID), refreshedHDNEA)
+						c.Request().Header.SetCookie("__hdnea__", refreshedHDNEA)
+					}
 				}
 			}
-		}
 
-		if err := internalUtils.ProxyRequest(c, retryUrl, TV.Client, PLAYER_USER_AGENT); err != nil {
-			return err
+			if err := internalUtils.ProxyRequest(c, retryUrl, tv.Client, PLAYER_USER_AGENT); err != nil {
+				return err
+			}
 		}
-	}
-
-	return nil
+		return nil
+	})
 }
 
 func setChannelPlaybackURLs(channels []television.Channel, hostURL string) {
 	for i := range channels {
-		if EnableDRM && utils.ContainsString(channels[i].ID, drmList) {
+		if EnableDRM && isDRMChannel(channels[i].ID) {
 			channels[i].URL = fmt.Sprintf("%s/live/mpd/%s", hostURL, channels[i].ID)
 			channels[i].KeyURL = fmt.Sprintf("%s/live/key/%s", hostURL, channels[i].ID)
 			continue
@@ -1015,6 +1064,17 @@ func setChannelPlaybackURLs(channels []television.Channel, hostURL string) {
 	}
 }
 
+// ChannelsRefreshHandler fetches the channel list from JioTV now, replacing
+// the cached list: `POST /api/channels/refresh`
+func ChannelsRefreshHandler(c *fiber.Ctx) error {
+	status, err := television.RefreshChannels()
+	if err != nil {
+		utils.Log.Printf("Error refreshing channels: %v", err)
+		return internalUtils.ErrorResponse(c, fiber.StatusBadGateway, "Failed to refresh channels: "+err.Error())
+	}
+	return c.JSON(status)
+}
+
 // ChannelsHandler fetch all channels from JioTV API
 // Also to generate M3U playlist
 func ChannelsHandler(c *fiber.Ctx) error {
@@ -1033,7 +1093,7 @@ func ChannelsHandler(c *fiber.Ctx) error {
 	// Check if the query parameter "type" is set to "m3u"
 	if c.Query("type") == "m3u" {
 		// Create an M3U playlist
-		m3uContent := GenerateM3UPlaylist(apiResponse.Result, hostURL, quality, splitCategory, languages, skipGenres)
+		m3uContent := GenerateM3UPlaylist(apiResponse.Result, hostURL, quality, splitCategory, languages, skipGenres, middleware.URLQuery(c))
 
 		// Set the Content-Disposition header for file download
 		c.Set("Content-Disposition", "attachment; filename=jiotv_playlist.m3u")
@@ -1048,11 +1108,12 @@ func ChannelsHandler(c *fiber.Ctx) error {
 
 // PremiumProvidersHandler lists premium providers detected on the account.
 func PremiumProvidersHandler(c *fiber.Ctx) error {
-	if err := EnsureFreshTokens(); err != nil {
+	profile := requestProfile(c)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		utils.Log.Printf("Failed to ensure fresh tokens for premium providers: %v", err)
 	}
 
-	premiumProviders, err := television.PremiumProviders()
+	premiumProviders, err := television.PremiumProvidersFor(profile)
 	if err != nil {
 		return ErrorMessageHandler(c, err)
 	}
@@ -1064,7 +1125,8 @@ func PremiumProvidersHandler(c *fiber.Ctx) error {
 
 // PremiumProviderCatalogHandler returns in-app catalog entries for a premium provider.
 func PremiumProviderCatalogHandler(c *fiber.Ctx) error {
-	if err := EnsureFreshTokens(); err != nil {
+	profile := requestProfile(c)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		utils.Log.Printf("Failed to ensure fresh tokens for premium catalog: %v", err)
 	}
 
@@ -1072,7 +1134,7 @@ func PremiumProviderCatalogHandler(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "0"))
 	limit, _ := strconv.Atoi(c.Query("limit", "60"))
 
-	catalogResult, err := television.PremiumProviderCatalog(providerIdentifier, page, limit)
+	catalogResult, err := television.PremiumProviderCatalogFor(profile, providerIdentifier, page, limit)
 	if err != nil {
 		return ErrorMessageHandler(c, err)
 	}
@@ -1082,7 +1144,8 @@ func PremiumProviderCatalogHandler(c *fiber.Ctx) error {
 
 // PremiumProviderWatchHandler renders a premium provider page with playable catalog cards.
 func PremiumProviderWatchHandler(c *fiber.Ctx) error {
-	if err := EnsureFreshTokens(); err != nil {
+	profile := requestProfile(c)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		utils.Log.Printf("Failed to ensure fresh tokens for premium provider page: %v", err)
 	}
 
@@ -1090,14 +1153,14 @@ func PremiumProviderWatchHandler(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "0"))
 	limit, _ := strconv.Atoi(c.Query("limit", "60"))
 
-	catalogResult, err := television.PremiumProviderCatalog(providerIdentifier, page, limit)
+	catalogResult, err := television.PremiumProviderCatalogFor(profile, providerIdentifier, page, limit)
 	if err != nil {
 		return ErrorMessageHandler(c, err)
 	}
 
 	providerName := providerIdentifier
 	providerURL := ""
-	premiumProviders, providersErr := television.PremiumProviders()
+	premiumProviders, providersErr := television.PremiumProvidersFor(profile)
 	if providersErr == nil {
 		for _, provider := range premiumProviders {
 			if strings.EqualFold(provider.ProviderID, catalogResult.ProviderID) || strings.EqualFold(provider.ID, providerIdentifier) {
@@ -1118,12 +1181,14 @@ func PremiumProviderWatchHandler(c *fiber.Ctx) error {
 		"Code":         catalogResult.Code,
 		"Message":      catalogResult.Message,
 		"Items":        catalogResult.Result,
+		"URLQuery":     template.URL(middleware.URLQuery(c)),
 	})
 }
 
 // PremiumProviderPlayHandler resolves a premium stream and redirects to the in-app player.
 func PremiumProviderPlayHandler(c *fiber.Ctx) error {
-	if err := EnsureFreshTokens(); err != nil {
+	profile := requestProfile(c)
+	if err := EnsureFreshTokensFor(profile); err != nil {
 		utils.Log.Printf("Failed to ensure fresh tokens for premium play: %v", err)
 	}
 
@@ -1134,7 +1199,7 @@ func PremiumProviderPlayHandler(c *fiber.Ctx) error {
 		SubCategoryID: c.Query("subCategoryId"),
 	}
 
-	playbackResult, err := television.PremiumProviderPlayback(c.Params("id"), playRequest)
+	playbackResult, err := television.PremiumProviderPlaybackFor(profile, c.Params("id"), playRequest)
 	if err != nil {
 		if errors.Is(err, television.ErrPremiumNotSubscribed) {
 			// Pass the message as a string: ErrorResponse marshals the value, and
@@ -1150,14 +1215,16 @@ func PremiumProviderPlayHandler(c *fiber.Ctx) error {
 	// Premium provider content is usually DASH protected by Widevine, so it
 	// has to be rendered by the DRM player rather than the HLS player.
 	if playbackResult.HasDRMStream() {
-		drmMpdOutput, drmErr := buildDrmMpdOutput(playbackResult, c.Params("id"), c.Query("q"))
+		client := internalUtils.URLClient(c)
+		cacheKey := drmMpdCacheKey(profile, c.Params("id"), c.Query("q"), client)
+		drmMpdOutput, drmErr := buildDrmMpdOutput(playbackResult, cacheKey, c.Params("id"), c.Query("q"), client)
 		if drmErr != nil {
 			return internalUtils.InternalServerError(c, drmErr)
 		}
 		if drmMpdOutput.IsDRM {
 			return c.Render("views/player_drm", fiber.Map{
-				"play_url":     drmMpdOutput.PlayUrl,
-				"license_url":  drmMpdOutput.LicenseUrl,
+				"play_url":     middleware.WithURLQuery(c, drmMpdOutput.PlayUrl),
+				"license_url":  middleware.WithURLQuery(c, drmMpdOutput.LicenseUrl),
 				"channel_host": drmMpdOutput.Tv_url_host,
 				"channel_path": drmMpdOutput.Tv_url_path,
 			})
@@ -1169,7 +1236,7 @@ func PremiumProviderPlayHandler(c *fiber.Ctx) error {
 		return internalUtils.NotFoundError(c, "No playable stream found for this premium item")
 	}
 
-	encryptedURL, err := secureurl.EncryptURL(playbackURL)
+	encryptedURL, err := secureurl.EncryptURLFor(playbackURL, internalUtils.URLClient(c))
 	if err != nil {
 		return internalUtils.ForbiddenError(c, err)
 	}
@@ -1178,7 +1245,7 @@ func PremiumProviderPlayHandler(c *fiber.Ctx) error {
 	if playbackResult.Hdnea != "" {
 		redirectURL += "&hdnea=" + url.QueryEscape(playbackResult.Hdnea)
 	}
-	return c.Redirect(redirectURL, fiber.StatusFound)
+	return c.Redirect(middleware.WithURLQuery(c, redirectURL), fiber.StatusFound)
 }
 
 // PremiumPlayerHandler serves the HLS player for premium provider streams.
@@ -1196,7 +1263,7 @@ func PremiumPlayerHandler(c *fiber.Ctx) error {
 
 	internalUtils.SetCacheHeader(c, 3600)
 	return c.Render("views/player_hls", fiber.Map{
-		"play_url": playURL,
+		"play_url": middleware.WithURLQuery(c, playURL),
 	})
 }
 
@@ -1210,7 +1277,7 @@ func PlayHandler(c *fiber.Ctx) error {
 	}
 
 	// Ensure tokens are fresh before making API call for DRM channels
-	if err := EnsureFreshTokens(); err != nil {
+	if err := EnsureFreshTokensFor(channelProfile(c, id)); err != nil {
 		utils.Log.Printf("Failed to ensure fresh tokens: %v", err)
 		// Continue with the request - tokens might still work or it might be a custom channel
 	}
@@ -1221,7 +1288,7 @@ func PlayHandler(c *fiber.Ctx) error {
 		// This avoids routing issues and 403 errors from mixed player usage
 		// While SONY_LIST was deprecated and its contents merged with drmList,
 		// we keep the check in case this needs to be reverted
-		if utils.ContainsString(id, drmList) {
+		if isDRMChannel(id) {
 			player_url = "/mpd/" + id + "?q=" + quality
 		} else if isCustomChannel(id) {
 			player_url = "/player/" + id + "?q=" + quality
@@ -1231,6 +1298,7 @@ func PlayHandler(c *fiber.Ctx) error {
 	} else {
 		player_url = "/player/" + id + "?q=" + quality
 	}
+	player_url = middleware.WithURLQuery(c, player_url)
 	playerURLJSON, _ := json.Marshal(player_url)
 	internalUtils.SetCacheHeader(c, 3600)
 	return c.Render("views/play", fiber.Map{
@@ -1245,7 +1313,7 @@ func PlayHandler(c *fiber.Ctx) error {
 func PlayerHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
 	quality := c.Query("q")
-	play_url := utils.BuildHLSPlayURL(quality, id)
+	play_url := middleware.WithURLQuery(c, utils.BuildHLSPlayURL(quality, id))
 	internalUtils.SetCacheHeader(c, 3600)
 	return c.Render("views/player_hls", fiber.Map{
 		"play_url": play_url,
@@ -1264,7 +1332,7 @@ func PlaylistHandler(c *fiber.Ctx) error {
 	splitCategory := c.Query("c")
 	languages := c.Query("l")
 	skipGenres := c.Query("sg")
-	return c.Redirect("/channels?type=m3u&q="+quality+"&c="+splitCategory+"&l="+languages+"&sg="+skipGenres, fiber.StatusMovedPermanently)
+	return c.Redirect(middleware.WithURLQuery(c, "/channels?type=m3u&q="+quality+"&c="+splitCategory+"&l="+languages+"&sg="+skipGenres), fiber.StatusMovedPermanently)
 }
 
 // ImageHandler loads image from JioTV server
@@ -1277,12 +1345,14 @@ func DASHTimeHandler(c *fiber.Ctx) error {
 	return c.SendString(time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
 }
 
-// GenerateM3UPlaylist generates an M3U playlist string from a list of channels
-func GenerateM3UPlaylist(channels []television.Channel, hostURL, quality, splitCategory, languages, skipGenres string) string {
+// GenerateM3UPlaylist generates an M3U playlist string from a list of channels.
+// The encoded query is added to every URL, so that players that can not send
+// headers keep the token and profile of the playlist request.
+func GenerateM3UPlaylist(channels []television.Channel, hostURL, quality, splitCategory, languages, skipGenres, query string) string {
 	var m3uContent strings.Builder
 	m3uContent.WriteString("#EXTM3U x-tvg-url=\"")
-	m3uContent.WriteString(hostURL)
-	m3uContent.WriteString("/epg.xml.gz\"\n")
+	m3uContent.WriteString(middleware.AppendQuery(hostURL+"/epg.xml.gz", query))
+	m3uContent.WriteString("\"\n")
 	logoURL := hostURL + "/jtvimage"
 
 	for _, channel := range channels {
@@ -1297,7 +1367,7 @@ func GenerateM3UPlaylist(channels []television.Channel, hostURL, quality, splitC
 		var channelURL string
 		var kodiProps string
 
-		if EnableDRM && utils.ContainsString(channel.ID, drmList) {
+		if EnableDRM && isDRMChannel(channel.ID) {
 			if quality != "" {
 				channelURL = fmt.Sprintf("%s/live/mpd/%s?q=%s", hostURL, channel.ID, quality)
 			} else {
@@ -1305,11 +1375,11 @@ func GenerateM3UPlaylist(channels []television.Channel, hostURL, quality, splitC
 			}
 
 			// Generate KODIPROP tags for Widevine DRM
-			kodiProps = fmt.Sprintf("#KODIPROP:inputstream=inputstream.adaptive\n#KODIPROP:inputstream.adaptive.manifest_type=mpd\n#KODIPROP:inputstream.adaptive.license_type=com.widevine.alpha\n#KODIPROP:inputstream.adaptive.license_key=%s/live/key/%s", hostURL, channel.ID)
+			licenseURL := fmt.Sprintf("%s/live/key/%s", hostURL, channel.ID)
 			if quality != "" {
-				kodiProps += "?q=" + quality
+				licenseURL += "?q=" + quality
 			}
-			kodiProps += "\n"
+			kodiProps = fmt.Sprintf("#KODIPROP:inputstream=inputstream.adaptive\n#KODIPROP:inputstream.adaptive.manifest_type=mpd\n#KODIPROP:inputstream.adaptive.license_type=com.widevine.alpha\n#KODIPROP:inputstream.adaptive.license_key=%s\n", middleware.AppendQuery(licenseURL, query))
 		} else {
 			if quality != "" {
 				channelURL = fmt.Sprintf("%s/live/%s/%s.m3u8", hostURL, quality, channel.ID)
@@ -1318,6 +1388,8 @@ func GenerateM3UPlaylist(channels []television.Channel, hostURL, quality, splitC
 			}
 		}
 
+		channelURL = middleware.AppendQuery(channelURL, query)
+
 		var channelLogoURL string
 		if strings.HasPrefix(channel.LogoURL, "http://") || strings.HasPrefix(channel.LogoURL, "https://") {
 			// Custom channel with full URL
@@ -745,7 +745,7 @@ func TestChannelsHandlerM3UDRM(t *testing.T) {
 			}
 
 			// Generate playlist
-			playlist := GenerateM3UPlaylist(mockChannels, hostURL, tc.quality, "", "", "")
+			playlist := GenerateM3UPlaylist(mockChannels, hostURL, tc.quality, "", "", "", "")
 
 			// Verify the output contains the expected elements
 			if !strings.Contains(playlist, tc.expectedURL) {
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/internal/middleware"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	hdhomerunDefaultTuners   = 2
+	hdhomerunFirmwareVersion = "20200101"
+	// hdhomerunTunerIdleTimeout is how long a tuner stays reserved after the
+	// client last requested the playlist or a segment of its channel
+	hdhomerunTunerIdleTimeout = 30 * time.Second
+	// hdhomerunQueryParam marks live URLs handed out in the HDHomeRun lineup
+	hdhomerunQueryParam = "hdhr"
+)
+
+// hdhomerunChecksumTable is the lookup table HDHomeRun clients use to validate device IDs
+var hdhomerunChecksumTable = [16]uint32{0xA, 0x5, 0xF, 0x6, 0x7, 0xC, 0x1, 0xB, 0x9, 0x2, 0x8, 0xD, 0x4, 0x3, 0xE, 0x0}
+
+// hdhomerunTuners tracks the tuners in use by HDHomeRun clients
+var hdhomerunTuners = newTunerPool()
+
+// tunerPool caps concurrent streams. HLS has no long-lived connection, so a
+// tuner is leased to a client address and channel pair and released once the
+// client stops polling that channel for hdhomerunTunerIdleTimeout.
+type tunerPool struct {
+	mu     sync.Mutex
+	leases map[string]time.Time
+}
+
+func newTunerPool() *tunerPool {
+	return &tunerPool{leases: make(map[string]time.Time)}
+}
+
+func tunerLeaseKey(clientIP, channelID string) string {
+	return clientIP + "|" + channelID
+}
+
+// expire drops idle leases. Callers must hold p.mu.
+func (p *tunerPool) expire(now time.Time) {
+	for key, lastSeen := range p.leases {
+		if now.Sub(lastSeen) > hdhomerunTunerIdleTimeout {
+			delete(p.leases, key)
+		}
+	}
+}
+
+// acquire reserves a tuner for the client and channel. A client that already
+// holds a tuner for the channel keeps it. Returns false when all tuners are busy.
+func (p *tunerPool) acquire(clientIP, channelID string, capacity int, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire(now)
+	key := tunerLeaseKey(clientIP, channelID)
+	if _, ok := p.leases[key]; !ok && len(p.leases) >= capacity {
+		return false
+	}
+	p.leases[key] = now
+	return true
+}
+
+// touch keeps an existing lease alive. Streams that never acquired a tuner are ignored.
+func (p *tunerPool) touch(clientIP, channelID string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := tunerLeaseKey(clientIP, channelID)
+	if _, ok := p.leases[key]; ok {
+		p.leases[key] = now
+	}
+}
+
+// hdhomerunTunerCount returns the configured number of HDHomeRun tuners
+func hdhomerunTunerCount() int {
+	if config.Cfg.HDHomeRunTuners > 0 {
+		return config.Cfg.HDHomeRunTuners
+	}
+	return hdhomerunDefaultTuners
+}
+
+// hdhomerunDeviceID derives a stable HDHomeRun device ID from the JioTV device ID.
+// The last hex digit is a checksum so that clients validating IDs accept it.
+func hdhomerunDeviceID(deviceID string) string {
+	sum := sha256.Sum256([]byte(deviceID))
+	id := binary.BigEndian.Uint32(sum[:4]) &^ 0xF
+	return fmt.Sprintf("%08X", id|hdhomerunDeviceIDChecksum(id))
+}
+
+// hdhomerunDeviceIDChecksum XORs the nibbles of id the way HDHomeRun clients
+// do when validating a device ID. A valid ID has a checksum of zero.
+func hdhomerunDeviceIDChecksum(id uint32) uint32 {
+	var checksum uint32
+	for shift := 28; shift >= 4; shift -= 8 {
+		checksum ^= hdhomerunChecksumTable[(id>>uint(shift))&0xF]
+		checksum ^= (id >> uint(shift-4)) & 0xF
+	}
+	return checksum
+}
+
+// hdhomerunFriendlyName returns the device name shown by media servers
+func hdhomerunFriendlyName() string {
+	if Title != "" {
+		return Title
+	}
+	return "JioTV Go"
+}
+
+// hdhomerunDiscovery builds the discover.json response for the given server URL
+func hdhomerunDiscovery(hostURL, deviceID string) HDHomeRunDiscovery {
+	return HDHomeRunDiscovery{
+		FriendlyName:    hdhomerunFriendlyName(),
+		Manufacturer:    "Silicondust",
+		ModelNumber:     "HDTC-2US",
+		FirmwareName:    "hdhomeruntc_atsc",
+		FirmwareVersion: hdhomerunFirmwareVersion,
+		DeviceID:        deviceID,
+		DeviceAuth:      deviceID,
+		TunerCount:      hdhomerunTunerCount(),
+		BaseURL:         hostURL,
+		LineupURL:       hostURL + "/lineup.json",
+	}
+}
+
+// hdhomerunLineup maps channels to HDHomeRun lineup entries pointing at the
+// live quality route. Channels the route cannot play are left out: DRM
+// channels have no HLS stream and subscription channels are refused by JioTV.
+func hdhomerunLineup(channels []television.Channel, hostURL, quality string) []HDHomeRunLineupItem {
+	lineup := make([]HDHomeRunLineupItem, 0, len(channels))
+	for _, channel := range channels {
+		if channel.RequiresSubscription || isDRMChannel(channel.ID) {
+			continue
+		}
+		item := HDHomeRunLineupItem{
+			GuideNumber: channel.ID,
+			GuideName:   channel.Name,
+			URL:         fmt.Sprintf("%s/live/%s/%s.m3u8?%s=1", hostURL, quality, channel.ID, hdhomerunQueryParam),
+		}
+		if channel.IsHD {
+			item.HD = 1
+		}
+		lineup = append(lineup, item)
+	}
+	return lineup
+}
+
+// reserveHDHomeRunTuner reserves a tuner for live requests coming from the
+// HDHomeRun lineup once their stream is resolved. It returns false after
+// responding when all tuners are busy.
+func reserveHDHomeRunTuner(c *fiber.Ctx, channelID string) (bool, error) {
+	if c.Query(hdhomerunQueryParam) == "" {
+		return true, nil
+	}
+	if hdhomerunTuners.acquire(c.IP(), channelID, hdhomerunTunerCount(), time.Now()) {
+		return true, nil
+	}
+	utils.Log.Printf("All %d HDHomeRun tuners are busy, rejecting channel %s for %s", hdhomerunTunerCount(), channelID, c.IP())
+	return false, internalUtils.ErrorResponse(c, fiber.StatusServiceUnavailable, "All tuners are busy")
+}
+
+// HDHomeRunDiscoverHandler handles the HDHomeRun `/discover.json` route
+func HDHomeRunDiscoverHandler(c *fiber.Ctx) error {
+	hostURL := strings.ToLower(c.Protocol()) + "://" + c.Hostname()
+	discovery := hdhomerunDiscovery(hostURL, hdhomerunDeviceID(utils.GetDeviceID()))
+	discovery.LineupURL = middleware.WithURLQuery(c, discovery.LineupURL)
+	return c.JSON(discovery)
+}
+
+// HDHomeRunLineupStatusHandler handles the HDHomeRun `/lineup_status.json` route
+func HDHomeRunLineupStatusHandler(c *fiber.Ctx) error {
+	return c.JSON(HDHomeRunLineupStatus{
+		ScanInProgress: 0,
+		ScanPossible:   1,
+		Source:         "Cable",
+		SourceList:     []string{"Cable"},
+	})
+}
+
+// HDHomeRunLineupHandler handles the HDHomeRun `/lineup.json` route.
+// Channels are filtered by the default categories and languages from the config.
+// The stream quality can be chosen with the `q` query parameter.
+func HDHomeRunLineupHandler(c *fiber.Ctx) error {
+	apiResponse, err := television.Channels()
+	if err != nil {
+		return ErrorMessageHandler(c, err)
+	}
+	channels := television.FilterChannelsByDefaults(apiResponse.Result, config.Cfg.DefaultCategories, config.Cfg.DefaultLanguages)
+
+	quality := strings.TrimSpace(c.Query("q"))
+	if quality == "" {
+		quality = "auto"
+	}
+	hostURL := strings.ToLower(c.Protocol()) + "://" + c.Hostname()
+	lineup := hdhomerunLineup(channels, hostURL, quality)
+	for i := range lineup {
+		lineup[i].URL = middleware.WithURLQuery(c, lineup[i].URL)
+	}
+	return c.JSON(lineup)
+}
+
+// HDHomeRunDeviceXMLHandler handles the HDHomeRun `/device.xml` UPnP description route
+func HDHomeRunDeviceXMLHandler(c *fiber.Ctx) error {
+	hostURL := strings.ToLower(c.Protocol()) + "://" + c.Hostname()
+	deviceID := hdhomerunDeviceID(utils.GetDeviceID())
+	return c.XML(HDHomeRunDeviceXML{
+		Xmlns:       "urn:schemas-upnp-org:device-1-0",
+		URLBase:     hostURL,
+		SpecVersion: HDHomeRunSpecVersion{Major: 1, Minor: 0},
+		Device: HDHomeRunDeviceDetail{
+			DeviceType:   "urn:schemas-upnp-org:device:MediaServer:1",
+			FriendlyName: hdhomerunFriendlyName(),
+			Manufacturer: "Silicondust",
+			ModelName:    "HDTC-2US",
+			ModelNumber:  "HDTC-2US",
+			SerialNumber: deviceID,
+			UDN:          "uuid:" + deviceID,
+		},
+	})
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHDHomeRunDeviceID(t *testing.T) {
+	tests := []struct {
+		name     string
+		deviceID string
+	}{
+		{name: "Regular device ID", deviceID: "a1b2c3d4e5f60718"},
+		{name: "Empty device ID", deviceID: ""},
+		{name: "Other device ID", deviceID: "0000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hdhomerunDeviceID(tt.deviceID)
+			if len(got) != 8 {
+				t.Fatalf("hdhomerunDeviceID() = %q, want 8 hex digits", got)
+			}
+			if again := hdhomerunDeviceID(tt.deviceID); again != got {
+				t.Errorf("hdhomerunDeviceID() not stable: %q then %q", got, again)
+			}
+			id, err := strconv.ParseUint(got, 16, 32)
+			if err != nil {
+				t.Fatalf("hdhomerunDeviceID() = %q is not hex: %v", got, err)
+			}
+			if checksum := hdhomerunDeviceIDChecksum(uint32(id)); checksum != 0 {
+				t.Errorf("hdhomerunDeviceID() = %q has checksum %d, want 0", got, checksum)
+			}
+		})
+	}
+
+	if hdhomerunDeviceID("device-one") == hdhomerunDeviceID("device-two") {
+		t.Error("hdhomerunDeviceID() returned the same ID for different devices")
+	}
+}
+
+func TestHDHomeRunDeviceIDChecksum(t *testing.T) {
+	if checksum := hdhomerunDeviceIDChecksum(0x12345678); checksum == 0 {
+		t.Error("hdhomerunDeviceIDChecksum(0x12345678) = 0, want an invalid ID")
+	}
+}
+
+func TestHDHomeRunTunerCount(t *testing.T) {
+	originalCfg := config.Cfg
+	defer func() { config.Cfg = originalCfg }()
+
+	tests := []struct {
+		name       string
+		configured int
+		want       int
+	}{
+		{name: "Default", configured: 0, want: hdhomerunDefaultTuners},
+		{name: "Negative falls back to default", configured: -1, want: hdhomerunDefaultTuners},
+		{name: "Configured", configured: 4, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg.HDHomeRunTuners = tt.configured
+			if got := hdhomerunTunerCount(); got != tt.want {
+				t.Errorf("hdhomerunTunerCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHDHomeRunLineup(t *testing.T) {
+	origDRMList := drmList
+	defer func() { drmList = origDRMList }()
+	drmList = []string{"990001"}
+
+	channels := []television.Channel{
+		{ID: "143", Name: "News One", IsHD: true},
+		{ID: "990001", Name: "DRM Only"},
+		{ID: "990002", Name: "Premium", RequiresSubscription: true},
+		{ID: "custom_1", Name: "Custom"},
+	}
+	lineup := hdhomerunLineup(channels, "http://localhost:5001", "high")
+
+	want := []HDHomeRunLineupItem{
+		{GuideNumber: "143", GuideName: "News One", URL: "http://localhost:5001/live/high/143.m3u8?hdhr=1", HD: 1},
+		{GuideNumber: "custom_1", GuideName: "Custom", URL: "http://localhost:5001/live/high/custom_1.m3u8?hdhr=1"},
+	}
+	if len(lineup) != len(want) {
+		t.Fatalf("hdhomerunLineup() returned %d items, want %d", len(lineup), len(want))
+	}
+	for i := range want {
+		if lineup[i] != want[i] {
+			t.Errorf("hdhomerunLineup()[%d] = %+v, want %+v", i, lineup[i], want[i])
+		}
+	}
+}
+
+func TestTunerPool(t *testing.T) {
+	now := time.Now()
+	pool := newTunerPool()
+
+	if !pool.acquire("10.0.0.1", "143", 2, now) {
+		t.Fatal("first tuner should be free")
+	}
+	if !pool.acquire("10.0.0.2", "144", 2, now) {
+		t.Fatal("second tuner should be free")
+	}
+	if pool.acquire("10.0.0.3", "145", 2, now) {
+		t.Fatal("third stream should be rejected when both tuners are busy")
+	}
+	if !pool.acquire("10.0.0.1", "143", 2, now) {
+		t.Fatal("a client should keep the tuner it already holds")
+	}
+
+	// Keep the first lease alive and let the second one go idle
+	later := now.Add(hdhomerunTunerIdleTimeout)
+	pool.touch("10.0.0.1", "143", later)
+	pool.touch("10.0.0.9", "999", later) // never acquired, must not take a tuner
+
+	afterIdle := later.Add(time.Second)
+	if !pool.acquire("10.0.0.3", "145", 2, afterIdle) {
+		t.Fatal("an idle tuner should be released")
+	}
+	if pool.acquire("10.0.0.4", "146", 2, afterIdle) {
+		t.Fatal("the touched lease should still hold its tuner")
+	}
+}
+
+func TestLiveQualityHandler_Tuners(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	originalCfg := config.Cfg
+	originalTuners := hdhomerunTuners
+	previousLog, previousTV := utils.Log, TV
+	utils.Log = log.New(os.Stderr, "", 0)
+	// JioTV cannot be reached, so its channels never resolve
+	TV = &television.Television{Client: &fasthttp.Client{Dial: func(string) (net.Conn, error) {
+		return nil, errors.New("offline")
+	}}, Headers: map[string]string{}}
+	defer func() {
+		cleanup()
+		config.Cfg = originalCfg
+		hdhomerunTuners = originalTuners
+		utils.Log, TV = previousLog, previousTV
+		television.InitCustomChannels()
+	}()
+
+	customChannelsFile := filepath.Join(t.TempDir(), "custom_channels.json")
+	data := `{"channels":[{"id":"news","name":"News","url":"https://example.com/news.m3u8"}]}`
+	if err := os.WriteFile(customChannelsFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.Cfg.CustomChannelsFile = customChannelsFile
+	television.InitCustomChannels()
+	config.Cfg.HDHomeRunTuners = 1
+	hdhomerunTuners = newTunerPool()
+
+	app := fiber.New()
+	app.Get("/live/:quality/:id", LiveQualityHandler)
+	get := func(path string) int {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("app.Test() error = %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	// A stream that cannot be resolved does not take the tuner
+	if status := get("/live/high/143.m3u8?hdhr=1"); status != fiber.StatusInternalServerError {
+		t.Errorf("unreachable channel status = %d, want %d", status, fiber.StatusInternalServerError)
+	}
+	if status := get("/live/high/cc_news.m3u8?hdhr=1"); status != fiber.StatusFound {
+		t.Errorf("status = %d, want %d", status, fiber.StatusFound)
+	}
+
+	// The only tuner is now held by the first request's client and channel
+	hdhomerunTuners.leases = map[string]time.Time{tunerLeaseKey("192.0.2.1", "cc_news"): time.Now()}
+	if status := get("/live/high/cc_news.m3u8?hdhr=1"); status != fiber.StatusServiceUnavailable {
+		t.Errorf("busy status = %d, want %d", status, fiber.StatusServiceUnavailable)
+	}
+}
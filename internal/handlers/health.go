@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/internal/constants"
+	"github.com/jiotv-go/jiotv_go/v3/internal/constants/urls"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/scheduler"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+// upstreamProbeTimeout is how long an upstream probe waits for a response
+const upstreamProbeTimeout = 5 * time.Second
+
+var (
+	// startedAt is when the server started
+	startedAt = time.Now()
+
+	// probeUpstream checks that a domain can be reached. Tests replace it.
+	probeUpstream = probeUpstreamDomain
+)
+
+// CheckReadiness checks that the store is initialised, the default profile is
+// logged in, the channel list is cached and, if EPG is enabled, the EPG file
+// was generated
+func CheckReadiness() Readiness {
+	var checks []ReadinessCheck
+	check := func(name string, ok bool, message string) {
+		if ok {
+			message = ""
+		}
+		checks = append(checks, ReadinessCheck{Name: name, OK: ok, Message: message})
+	}
+
+	check("store", store.KVS != nil, "The store is not initialised")
+
+	credentials, err := utils.GetJIOTVCredentials()
+	loggedIn := err == nil && credentials != nil && (credentials.SSOToken != "" || credentials.AccessToken != "")
+	check("credentials", loggedIn, "Not logged in")
+
+	channels := television.CachedChannelsStatus()
+	check("channels", channels.Channels > 0, "The channel list was not fetched yet")
+
+	if config.Cfg.EPG {
+		_, err := os.Stat(epg.FilePath())
+		check("epg", err == nil, "The EPG file was not generated yet")
+	}
+
+	readiness := Readiness{Ready: true, Checks: checks}
+	for _, c := range checks {
+		readiness.Ready = readiness.Ready && c.OK
+	}
+	return readiness
+}
+
+// GetDiagnostics describes the server, probing every JioTV domain
+func GetDiagnostics() Diagnostics {
+	kind, proxyURL := utils.RequestProxy()
+	return Diagnostics{
+		Version:      constants.Version,
+		GoVersion:    runtime.Version(),
+		StartedAt:    startedAt,
+		Config:       config.Cfg.Redacted(),
+		Proxy:        DiagnosticsProxy{Kind: kind, URL: proxyURL},
+		Upstreams:    probeUpstreams(urls.Domains),
+		Tasks:        scheduler.Tasks(),
+		Readiness:    CheckReadiness(),
+		Channels:     television.CachedChannelsStatus(),
+		TokenRefresh: TokenRefreshStatuses(),
+		DRMChannels:  drmChannelCount(),
+	}
+}
+
+// probeUpstreams probes the domains concurrently
+func probeUpstreams(domains []string) []UpstreamProbe {
+	probes := make([]UpstreamProbe, len(domains))
+	var wg sync.WaitGroup
+	for i, domain := range domains {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probes[i] = probeUpstream(domain)
+		}()
+	}
+	wg.Wait()
+	return probes
+}
+
+// probeUpstreamDomain sends a HEAD request to a domain through the proxy in
+// use. Any HTTP response means the domain can be reached.
+func probeUpstreamDomain(domain string) UpstreamProbe {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("https://" + domain + "/")
+	req.Header.SetMethod(fasthttp.MethodHead)
+
+	probe := UpstreamProbe{Domain: domain}
+	start := time.Now()
+	err := utils.GetRequestClient().DoTimeout(req, resp, upstreamProbeTimeout)
+	probe.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	probe.Reachable = true
+	probe.Status = resp.StatusCode()
+	return probe
+}
+
+// HealthzHandler reports that the server is up: `GET /healthz`
+func HealthzHandler(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// ReadyzHandler reports whether the server is ready to serve channels, with
+// status 503 if it is not: `GET /readyz`
+func ReadyzHandler(c *fiber.Ctx) error {
+	readiness := CheckReadiness()
+	if !readiness.Ready {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.JSON(readiness)
+}
+
+// DiagnosticsHandler describes the server for troubleshooting: `GET /api/diagnostics`
+func DiagnosticsHandler(c *fiber.Ctx) error {
+	return c.JSON(GetDiagnostics())
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/metrics"
+)
+
+// activeStreamTimeout is how long a client counts as watching a channel after
+// its last playlist, manifest or segment request
+const activeStreamTimeout = 30 * time.Second
+
+var (
+	tokenRefreshes = metrics.NewCounter("jiotv_token_refresh_total",
+		"Token refresh attempts by token, trigger and outcome.", "token", "trigger", "outcome")
+	hdneaCacheRequests = metrics.NewCounter("jiotv_hdnea_cache_requests_total",
+		"Lookups of cached HDNEA tokens by result.", "result")
+	drmMpdCacheRequests = metrics.NewCounter("jiotv_drm_mpd_cache_requests_total",
+		"Lookups of cached DRM MPD URLs by result.", "result")
+
+	// activeStreams tracks the clients watching each channel
+	activeStreams = newStreamActivity()
+)
+
+func init() {
+	metrics.NewGaugeFunc("jiotv_active_streams",
+		"Clients that requested a channel in the last 30 seconds, by channel.", []string{"channel"},
+		func(emit func(float64, ...string)) {
+			for channelID, count := range activeStreams.counts(time.Now()) {
+				emit(float64(count), channelID)
+			}
+		})
+	metrics.NewCounterFunc("jiotv_segment_relay_requests_total",
+		"Segment requests of the shared segment relay by result.", []string{"result"},
+		func(emit func(float64, ...string)) {
+			stats := segmentRelay.stats()
+			emit(float64(stats.Hits), "hit")
+			emit(float64(stats.Misses), "miss")
+		})
+	metrics.NewGaugeFunc("jiotv_segment_relay_bytes",
+		"Bytes of segments held by the shared segment relay.", nil,
+		func(emit func(float64, ...string)) {
+			emit(float64(segmentRelay.stats().Bytes))
+		})
+}
+
+// streamActivity tracks when each client last requested each channel
+type streamActivity struct {
+	mu       sync.Mutex
+	lastSeen map[string]map[string]time.Time
+}
+
+func newStreamActivity() *streamActivity {
+	return &streamActivity{lastSeen: make(map[string]map[string]time.Time)}
+}
+
+// touch records that a client requested a channel
+func (a *streamActivity) touch(channelID, clientIP string, now time.Time) {
+	if channelID == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	clients, ok := a.lastSeen[channelID]
+	if !ok {
+		clients = make(map[string]time.Time)
+		// Values of fiber requests are only valid during the request
+		a.lastSeen[strings.Clone(channelID)] = clients
+	}
+	if _, ok := clients[clientIP]; !ok {
+		clientIP = strings.Clone(clientIP)
+	}
+	clients[clientIP] = now
+}
+
+// counts returns the number of clients watching each channel, dropping idle ones
+func (a *streamActivity) counts(now time.Time) map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	counts := make(map[string]int, len(a.lastSeen))
+	for channelID, clients := range a.lastSeen {
+		for clientIP, lastSeen := range clients {
+			if now.Sub(lastSeen) > activeStreamTimeout {
+				delete(clients, clientIP)
+			}
+		}
+		if len(clients) == 0 {
+			delete(a.lastSeen, channelID)
+			continue
+		}
+		counts[channelID] = len(clients)
+	}
+	return counts
+}
+
+// countTokenRefresh counts a token refresh attempt
+func countTokenRefresh(token, trigger string, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	tokenRefreshes.Inc(token, trigger, outcome)
+}
+
+// countCacheLookup counts a cache lookup as a hit or a miss
+func countCacheLookup(counter *metrics.Counter, hit bool) {
+	if hit {
+		counter.Inc("hit")
+	} else {
+		counter.Inc("miss")
+	}
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format
+func MetricsHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, metrics.ContentType)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return metrics.WriteTo(c)
+}
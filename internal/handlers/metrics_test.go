@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestStreamActivity(t *testing.T) {
+	activity := newStreamActivity()
+	now := time.Now()
+
+	activity.touch("143", "192.0.2.1", now)
+	activity.touch("143", "192.0.2.2", now.Add(20*time.Second))
+	activity.touch("143", "192.0.2.2", now.Add(25*time.Second))
+	activity.touch("144", "192.0.2.1", now)
+	activity.touch("", "192.0.2.1", now)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want map[string]int
+	}{
+		{name: "All clients active", at: now.Add(10 * time.Second), want: map[string]int{"143": 2, "144": 1}},
+		{name: "Idle clients dropped", at: now.Add(40 * time.Second), want: map[string]int{"143": 1}},
+		{name: "All clients idle", at: now.Add(time.Minute), want: map[string]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activity.counts(tt.at); !maps.Equal(got, tt.want) {
+				t.Errorf("counts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
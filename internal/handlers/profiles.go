@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/middleware"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+// autoProfileCacheTTL is how long the channel list and premium providers used
+// to route auto profile requests are cached
+const autoProfileCacheTTL = time.Hour
+
+var (
+	// profileTVs holds the Television instances of named profiles. The
+	// default profile uses TV.
+	profileTVs sync.Map
+
+	// autoChannels caches the channels by ID for auto profile routing
+	autoChannels          map[string]television.Channel
+	autoChannelsUpdatedAt time.Time
+	autoChannelsMu        sync.Mutex
+
+	// profileProviders caches the premium providers of each profile for auto
+	// profile routing
+	profileProviders sync.Map
+)
+
+type profileProvidersEntry struct {
+	Providers []television.PremiumProvider
+	UpdatedAt time.Time
+}
+
+// tvFor returns the Television instance of a profile, creating it from the
+// stored credentials on first use
+func tvFor(profile string) *television.Television {
+	profile = utils.NormalizeProfile(profile)
+	if profile == utils.DefaultProfile || profile == utils.AutoProfile {
+		return TV
+	}
+	if tv, ok := profileTVs.Load(profile); ok {
+		return tv.(*television.Television)
+	}
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
+	if err != nil {
+		utils.Log.Printf("Login error for profile %s: %v", profile, err)
+	}
+	tv, _ := profileTVs.LoadOrStore(profile, television.NewForProfile(profile, credentials))
+	return tv.(*television.Television)
+}
+
+// setTV replaces the Television instance of a profile
+func setTV(profile string, tv *television.Television) {
+	profile = utils.NormalizeProfile(profile)
+	if profile == utils.DefaultProfile {
+		TV = tv
+		return
+	}
+	profileTVs.Store(profile, tv)
+}
+
+// resetProfile drops the cached state of a profile after it logged in or out
+func resetProfile(profile string) {
+	profileTVs.Delete(profile)
+	profileProviders.Delete(profile)
+}
+
+// requestProfile returns the profile of a request. Auto requests name no
+// channel to route by, so they use the default profile.
+func requestProfile(c *fiber.Ctx) string {
+	profile := middleware.ProfileName(c)
+	if profile == utils.AutoProfile {
+		return utils.DefaultProfile
+	}
+	return profile
+}
+
+// channelProfile returns the profile to play a channel with. Auto requests are
+// resolved to a profile entitled to the channel, which URLs generated for the
+// request then carry.
+func channelProfile(c *fiber.Ctx, channelID string) string {
+	profile := middleware.ProfileName(c)
+	if profile != utils.AutoProfile {
+		return profile
+	}
+	profile = autoProfile(channelID)
+	middleware.SetProfile(c, profile)
+	return profile
+}
+
+// profileCacheKey namespaces the cache keys of named profiles, as stream
+// tokens issued to one account are rejected for another
+func profileCacheKey(profile, key string) string {
+	profile = utils.NormalizeProfile(profile)
+	if key == "" || profile == utils.DefaultProfile {
+		return key
+	}
+	return profile + "|" + key
+}
+
+// autoProfile picks the profile to play a channel with. Channels without a
+// separate subscription play on the first profile. Premium channels play on a
+// profile with a premium provider named like the channel, or else on the first
+// profile with any premium provider.
+func autoProfile(channelID string) string {
+	profiles := utils.ListProfiles()
+	if len(profiles) == 0 {
+		return utils.DefaultProfile
+	}
+
+	channel, ok := autoChannel(channelID)
+	if !ok || !channel.RequiresSubscription {
+		return profiles[0]
+	}
+
+	entitled := ""
+	for _, profile := range profiles {
+		providers := premiumProvidersOf(profile)
+		if len(providers) == 0 {
+			continue
+		}
+		if providerMatchesChannel(providers, channel) {
+			return profile
+		}
+		if entitled == "" {
+			entitled = profile
+		}
+	}
+	if entitled != "" {
+		return entitled
+	}
+	return profiles[0]
+}
+
+// autoChannel looks a channel up in the cached channel list
+func autoChannel(channelID string) (television.Channel, bool) {
+	autoChannelsMu.Lock()
+	defer autoChannelsMu.Unlock()
+
+	if autoChannels == nil || time.Since(autoChannelsUpdatedAt) > autoProfileCacheTTL {
+		apiResponse, err := television.Channels()
+		if err != nil {
+			utils.Log.Printf("Unable to load channels for auto profile: %v", err)
+			return television.Channel{}, false
+		}
+		autoChannels = make(map[string]television.Channel, len(apiResponse.Result))
+		for _, channel := range apiResponse.Result {
+			autoChannels[channel.ID] = channel
+		}
+		autoChannelsUpdatedAt = time.Now()
+	}
+	channel, ok := autoChannels[channelID]
+	return channel, ok
+}
+
+// premiumProvidersOf returns the cached premium providers of a profile
+func premiumProvidersOf(profile string) []television.PremiumProvider {
+	if entryRaw, ok := profileProviders.Load(profile); ok {
+		if entry := entryRaw.(profileProvidersEntry); time.Since(entry.UpdatedAt) <= autoProfileCacheTTL {
+			return entry.Providers
+		}
+	}
+	providers, err := television.PremiumProvidersFor(profile)
+	if err != nil {
+		utils.Log.Printf("Unable to load premium providers of profile %s: %v", profile, err)
+		return nil
+	}
+	profileProviders.Store(profile, profileProvidersEntry{Providers: providers, UpdatedAt: time.Now()})
+	return providers
+}
+
+// providerMatchesChannel reports whether a premium provider is named in the
+// name of a channel
+func providerMatchesChannel(providers []television.PremiumProvider, channel television.Channel) bool {
+	channelName := compactName(channel.Name)
+	for _, provider := range providers {
+		if name := compactName(provider.Name); name != "" && strings.Contains(channelName, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compactName lowercases a name and removes its spaces
+func compactName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
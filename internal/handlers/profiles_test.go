@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func TestProfileCacheKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		key     string
+		want    string
+	}{
+		{name: "Default profile", profile: utils.DefaultProfile, key: "143", want: "143"},
+		{name: "Empty profile", profile: "", key: "143", want: "143"},
+		{name: "Named profile", profile: "family", key: "143", want: "family|143"},
+		{name: "Empty key", profile: "family", key: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := profileCacheKey(tt.profile, tt.key); got != tt.want {
+				t.Errorf("profileCacheKey(%q, %q) = %q, want %q", tt.profile, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoProfile(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	previousLog := utils.Log
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log = previousLog
+		autoChannelsMu.Lock()
+		autoChannels = nil
+		autoChannelsMu.Unlock()
+		profileProviders.Clear()
+	})
+
+	for _, profile := range []string{utils.DefaultProfile, "movies", "sports"} {
+		if err := utils.WriteJIOTVCredentialsFor(profile, &utils.JIOTV_CREDENTIALS{SSOToken: "sso-" + profile}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	autoChannelsMu.Lock()
+	autoChannels = map[string]television.Channel{
+		"143":  {ID: "143", Name: "News Channel"},
+		"1001": {ID: "1001", Name: "Sony LIV Sports HD", RequiresSubscription: true},
+		"1002": {ID: "1002", Name: "Zee5 Action", RequiresSubscription: true},
+		"1003": {ID: "1003", Name: "Hoichoi Bangla", RequiresSubscription: true},
+	}
+	autoChannelsUpdatedAt = time.Now()
+	autoChannelsMu.Unlock()
+
+	profileProviders.Store(utils.DefaultProfile, profileProvidersEntry{UpdatedAt: time.Now()})
+	profileProviders.Store("movies", profileProvidersEntry{Providers: []television.PremiumProvider{{Name: "ZEE5"}}, UpdatedAt: time.Now()})
+	profileProviders.Store("sports", profileProvidersEntry{Providers: []television.PremiumProvider{{Name: "SonyLIV"}}, UpdatedAt: time.Now()})
+
+	tests := []struct {
+		name      string
+		channelID string
+		want      string
+	}{
+		{name: "Free channel uses the first profile", channelID: "143", want: utils.DefaultProfile},
+		{name: "Unknown channel uses the first profile", channelID: "9999", want: utils.DefaultProfile},
+		{name: "Premium channel matching a provider", channelID: "1001", want: "sports"},
+		{name: "Premium channel matching another provider", channelID: "1002", want: "movies"},
+		{name: "Premium channel without a matching provider", channelID: "1003", want: "movies"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoProfile(tt.channelID); got != tt.want {
+				t.Errorf("autoProfile(%q) = %q, want %q", tt.channelID, got, tt.want)
+			}
+		})
+	}
+}
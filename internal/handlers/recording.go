@@ -0,0 +1,647 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/constants/tasks"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/scheduler"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	RecordingScheduled RecordingStatus = "scheduled"
+	RecordingRunning   RecordingStatus = "recording"
+	RecordingCompleted RecordingStatus = "completed"
+	RecordingFailed    RecordingStatus = "failed"
+	RecordingCancelled RecordingStatus = "cancelled"
+	RecordingMissed    RecordingStatus = "missed"
+	// RecordingDeleted marks recordings removed by the retention of their rule
+	RecordingDeleted RecordingStatus = "deleted"
+
+	RecordingSourceLive    RecordingSource = "live"
+	RecordingSourceCatchup RecordingSource = "catchup"
+
+	recordingsDirName  = "recordings"
+	recordingsFileName = "recordings.json"
+	// recordingCheckInterval is how often due, cancelled and missed recordings are looked for
+	recordingCheckInterval = 15 * time.Second
+	// recordingRetryDelay is the pause before reconnecting a recording whose stream ended early
+	recordingRetryDelay = 10 * time.Second
+	// catchupRecordingDelay gives JioTV time to publish a programme to catchup after it aired
+	catchupRecordingDelay = 5 * time.Minute
+	// catchupRetention is how long JioTV keeps programmes available for catchup
+	catchupRetention = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrRecordingNotFound is returned for unknown recording IDs
+	ErrRecordingNotFound = errors.New("recording not found")
+	// ErrRecordingFinished is returned when cancelling a recording that already ended
+	ErrRecordingFinished = errors.New("recording already finished")
+
+	// recordingsMu serialises read-modify-write cycles of the recordings file
+	// within this process. The file lock taken by lockRecordingsFile does the
+	// same between processes.
+	recordingsMu sync.Mutex
+
+	// activeRecordings holds the recordings running in this process by ID
+	activeRecordings   = make(map[string]*activeRecording)
+	activeRecordingsMu sync.Mutex
+
+	// recordingRunner records a due recording. It is replaced in tests.
+	recordingRunner = runRecording
+	// findProgramme looks up EPG programmes. It is replaced in tests.
+	findProgramme = epg.FindProgramme
+
+	unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// InvalidRequestError reports a recording or recording rule request that
+// cannot be accepted as given, as opposed to a failure of the server
+type InvalidRequestError struct {
+	Err error
+}
+
+func (e *InvalidRequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidRequestError) Unwrap() error {
+	return e.Err
+}
+
+// invalidRequest returns an InvalidRequestError with a formatted message
+func invalidRequest(format string, args ...interface{}) error {
+	return &InvalidRequestError{Err: fmt.Errorf(format, args...)}
+}
+
+// requestErrorResponse answers with 400 for invalid requests and 500 otherwise
+func requestErrorResponse(c *fiber.Ctx, err error) error {
+	var invalid *InvalidRequestError
+	if errors.As(err, &invalid) {
+		return internalUtils.BadRequestError(c, err.Error())
+	}
+	utils.Log.Println(err)
+	return internalUtils.InternalServerError(c, err)
+}
+
+// activeRecording is a recording running in this process
+type activeRecording struct {
+	done chan struct{}
+	once sync.Once
+}
+
+// stop ends the recording. It is safe to call more than once.
+func (a *activeRecording) stop() {
+	a.once.Do(func() { close(a.done) })
+}
+
+// recordingsDir returns the directory holding the recordings and their job file
+func recordingsDir() (string, error) {
+	dir := filepath.Join(utils.GetPathPrefix(), recordingsDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// readRecordingsFile decodes a JSON file of the recordings directory into v.
+// A missing file leaves v untouched.
+func readRecordingsFile(name string, v interface{}) error {
+	dir, err := recordingsDir()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeRecordingsFile writes v as JSON to the recordings directory
+func writeRecordingsFile(name string, v interface{}) error {
+	dir, err := recordingsDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, name), 0644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// lockRecordingsFile holds mu and an exclusive lock on the named file of the
+// recordings directory, so that the server and the CLI never overwrite each
+// other's changes. The returned function releases both.
+func lockRecordingsFile(mu *sync.Mutex, name string) (func(), error) {
+	mu.Lock()
+	dir, err := recordingsDir()
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	unlock, err := utils.LockFile(filepath.Join(dir, name+".lock"))
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		mu.Unlock()
+	}, nil
+}
+
+// loadRecordings reads the persisted recordings
+func loadRecordings() ([]Recording, error) {
+	recordings := []Recording{}
+	if err := readRecordingsFile(recordingsFileName, &recordings); err != nil {
+		return nil, err
+	}
+	return recordings, nil
+}
+
+// saveRecordings persists the recordings
+func saveRecordings(recordings []Recording) error {
+	return writeRecordingsFile(recordingsFileName, recordings)
+}
+
+// updateRecordings applies update to the persisted recordings and saves the result
+func updateRecordings(update func(recordings []Recording) ([]Recording, error)) error {
+	unlock, err := lockRecordingsFile(&recordingsMu, recordingsFileName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	recordings, err := loadRecordings()
+	if err != nil {
+		return err
+	}
+	recordings, err = update(recordings)
+	if err != nil {
+		return err
+	}
+	return saveRecordings(recordings)
+}
+
+// parseRecordingTime parses a time given on the command line or in the API.
+// Times without a zone are in IST, and "15:04" means today.
+func parseRecordingTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "now" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	loc := indiaLocation()
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("15:04", value, loc); err == nil {
+		today := now.In(loc)
+		return time.Date(today.Year(), today.Month(), today.Day(), t.Hour(), t.Minute(), 0, 0, loc), nil
+	}
+	return time.Time{}, invalidRequest("invalid time %q", value)
+}
+
+// programmeWindow returns the EPG programme of a channel identified by
+// programme, which is "now", "next" or a time during the programme
+func programmeWindow(channelID, programme string, now time.Time) (*epg.EPGObject, error) {
+	id, err := strconv.Atoi(channelID)
+	if err != nil {
+		return nil, invalidRequest("programme lookup needs a JioTV channel ID: %w", err)
+	}
+	at := now
+	if programme == "next" {
+		current, err := lookupProgramme(id, now)
+		if err != nil {
+			return nil, err
+		}
+		at = time.UnixMilli(current.EndEpoch)
+	} else if at, err = parseRecordingTime(programme, now); err != nil {
+		return nil, err
+	}
+	return lookupProgramme(id, at)
+}
+
+// lookupProgramme calls findProgramme and reports times without a programme
+// as invalid requests
+func lookupProgramme(channelID int, at time.Time) (*epg.EPGObject, error) {
+	programme, err := findProgramme(channelID, at)
+	if errors.Is(err, epg.ErrProgrammeNotFound) {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	return programme, err
+}
+
+// newRecordingID returns a random recording ID
+func newRecordingID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// recordingFileName returns the file name of a recording, such as 143_20240101-2100_News.ts
+func recordingFileName(recording Recording) string {
+	name := fmt.Sprintf("%s_%s", recording.ChannelID, recording.Start.In(indiaLocation()).Format("20060102-1504"))
+	if title := strings.Trim(unsafeFileNameChars.ReplaceAllString(recording.Title, "_"), "_"); title != "" {
+		name += "_" + title
+	}
+	return unsafeFileNameChars.ReplaceAllString(name, "_") + ".ts"
+}
+
+// NewRecording validates a recording request and builds the recording it describes
+func NewRecording(request RecordingRequest, now time.Time) (Recording, error) {
+	recording := Recording{
+		ChannelID: strings.TrimSpace(request.ChannelID),
+		Title:     strings.TrimSpace(request.Title),
+		Quality:   request.Quality,
+		Status:    RecordingScheduled,
+		Source:    RecordingSourceLive,
+		CreatedAt: now,
+	}
+	if recording.ChannelID == "" {
+		return recording, invalidRequest("channel_id is required")
+	}
+	if recording.Quality == "" {
+		recording.Quality = "auto"
+	}
+
+	switch {
+	case request.Programme != "":
+		programme, err := programmeWindow(recording.ChannelID, request.Programme, now)
+		if err != nil {
+			return recording, err
+		}
+		recording.Start = time.UnixMilli(programme.StartEpoch)
+		recording.Stop = time.UnixMilli(programme.EndEpoch)
+		if recording.Title == "" {
+			recording.Title = programme.Title
+		}
+	case request.Start != "" && request.Stop != "":
+		var err error
+		if recording.Start, err = parseRecordingTime(request.Start, now); err != nil {
+			return recording, err
+		}
+		if recording.Stop, err = parseRecordingTime(request.Stop, now); err != nil {
+			return recording, err
+		}
+	default:
+		return recording, invalidRequest("either start and stop or programme is required")
+	}
+
+	if !recording.Stop.After(recording.Start) {
+		return recording, invalidRequest("stop must be after start")
+	}
+	if !recording.Stop.After(now) {
+		return recording, invalidRequest("the recording window has already passed")
+	}
+
+	return recording, assignRecordingFile(&recording)
+}
+
+// assignRecordingFile gives a new recording its ID and output file
+func assignRecordingFile(recording *Recording) error {
+	id, err := newRecordingID()
+	if err != nil {
+		return err
+	}
+	recording.ID = id
+	dir, err := recordingsDir()
+	if err != nil {
+		return err
+	}
+	recording.File = filepath.Join(dir, recordingFileName(*recording))
+	return nil
+}
+
+// AddRecording schedules the recording described by request
+func AddRecording(request RecordingRequest) (Recording, error) {
+	recording, err := NewRecording(request, time.Now())
+	if err != nil {
+		return recording, err
+	}
+	err = updateRecordings(func(recordings []Recording) ([]Recording, error) {
+		return append(recordings, recording), nil
+	})
+	return recording, err
+}
+
+// ListRecordings returns all recordings ordered by start time
+func ListRecordings() ([]Recording, error) {
+	unlock, err := lockRecordingsFile(&recordingsMu, recordingsFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	recordings, err := loadRecordings()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(recordings, func(i, j int) bool {
+		return recordings[i].Start.Before(recordings[j].Start)
+	})
+	return recordings, nil
+}
+
+// CancelRecording cancels a scheduled or running recording. A recording
+// running in another process, such as the server when called from the CLI,
+// is stopped on that process' next recording check.
+func CancelRecording(id string) (Recording, error) {
+	var cancelled Recording
+	err := updateRecordings(func(recordings []Recording) ([]Recording, error) {
+		for i := range recordings {
+			if recordings[i].ID != id {
+				continue
+			}
+			if recordings[i].Status != RecordingScheduled && recordings[i].Status != RecordingRunning {
+				return nil, fmt.Errorf("%w: %s", ErrRecordingFinished, recordings[i].Status)
+			}
+			recordings[i].Status = RecordingCancelled
+			cancelled = recordings[i]
+			return recordings, nil
+		}
+		return nil, ErrRecordingNotFound
+	})
+	if err != nil {
+		return cancelled, err
+	}
+
+	activeRecordingsMu.Lock()
+	if active, ok := activeRecordings[id]; ok {
+		active.stop()
+	}
+	activeRecordingsMu.Unlock()
+	return cancelled, nil
+}
+
+// InitRecordings resumes persisted recordings, schedules the recording
+// check and applies the recording rules after every EPG update. Recordings whose window passed while the server was down are marked
+// missed, and recordings interrupted within their window continue in the same file.
+func InitRecordings() {
+	if err := checkRecordings(time.Now()); err != nil {
+		utils.Log.Printf("Failed to check recordings: %v", err)
+	}
+	scheduler.Add(tasks.RecordingTaskID, recordingCheckInterval, func() error {
+		return checkRecordings(time.Now())
+	})
+	initRecordingRules()
+}
+
+// recordingWindow returns when a recording is due and when it is missed.
+// Live recordings run during the programme, catchup recordings once the
+// programme is available from catchup until JioTV drops it.
+func recordingWindow(recording Recording) (startAt, missedAt time.Time) {
+	if recording.Source == RecordingSourceCatchup {
+		return recording.Stop.Add(catchupRecordingDelay), recording.Start.Add(catchupRetention)
+	}
+	return recording.Start, recording.Stop
+}
+
+// checkRecordings starts due recordings, stops cancelled ones and marks missed ones
+func checkRecordings(now time.Time) error {
+	var due []Recording
+	err := updateRecordings(func(recordings []Recording) ([]Recording, error) {
+		activeRecordingsMu.Lock()
+		defer activeRecordingsMu.Unlock()
+
+		for i := range recordings {
+			recording := &recordings[i]
+			active, running := activeRecordings[recording.ID]
+			switch recording.Status {
+			case RecordingCancelled:
+				if running {
+					active.stop()
+				}
+			case RecordingScheduled, RecordingRunning:
+				if running {
+					continue
+				}
+				startAt, missedAt := recordingWindow(*recording)
+				if !now.Before(missedAt) {
+					recording.Status = RecordingMissed
+					recording.Error = "JioTV Go was not running during the recording window"
+					continue
+				}
+				if !now.Before(startAt) {
+					recording.Status = RecordingRunning
+					activeRecordings[recording.ID] = &activeRecording{done: make(chan struct{})}
+					due = append(due, *recording)
+				}
+			}
+		}
+		return recordings, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, recording := range due {
+		activeRecordingsMu.Lock()
+		active := activeRecordings[recording.ID]
+		activeRecordingsMu.Unlock()
+		go recordingRunner(recording, active)
+	}
+	return nil
+}
+
+// runRecording records a recording into its file until it is done or cancelled
+func runRecording(recording Recording, active *activeRecording) {
+	utils.Log.Printf("Recording %s: recording channel %s to %s", recording.ID, recording.ChannelID, recording.File)
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if recording.Source == RecordingSourceCatchup {
+		// A catchup download always starts from the first segment
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+	file, err := os.OpenFile(recording.File, flags, 0644)
+	if err != nil {
+		finishRecording(recording, err)
+	} else {
+		w := bufio.NewWriter(file)
+		var recordErr error
+		if recording.Source == RecordingSourceCatchup {
+			recordErr = recordCatchup(recording, active, w)
+		} else {
+			recordErr = recordLive(recording, active, w)
+		}
+		if err := w.Flush(); err != nil {
+			recordErr = err
+		}
+		if err := file.Close(); err != nil && recordErr == nil {
+			recordErr = err
+		}
+		finishRecording(recording, recordErr)
+	}
+
+	activeRecordingsMu.Lock()
+	delete(activeRecordings, recording.ID)
+	activeRecordingsMu.Unlock()
+}
+
+// recordLive writes the live stream of the channel to w until the stop time
+// or cancellation. Streams that end early are reconnected while the
+// recording window lasts.
+func recordLive(recording Recording, active *activeRecording, w *bufio.Writer) error {
+	stopTimer := time.AfterFunc(time.Until(recording.Stop), active.stop)
+	defer stopTimer.Stop()
+
+	var recordErr error
+	stream := newTSStream(recording.ChannelID, recording.Quality)
+	stream.done = active.done
+	for !stream.stopped() {
+		recordErr = nil
+		if err := stream.connect(); err != nil {
+			recordErr = err
+		} else if playlist, err := stream.loadPlaylist(); err != nil {
+			recordErr = err
+		} else {
+			stream.run(w, playlist)
+		}
+		if recordErr != nil {
+			utils.Log.Printf("Recording %s: %v", recording.ID, recordErr)
+		}
+		select {
+		case <-active.done:
+		case <-time.After(recordingRetryDelay):
+		}
+	}
+	return recordErr
+}
+
+// recordCatchup downloads the catchup stream of an aired programme to w
+func recordCatchup(recording Recording, active *activeRecording, w *bufio.Writer) error {
+	programme := catchupProgramme{
+		ChannelID: recording.ChannelID,
+		Srno:      recording.Srno,
+		Title:     recording.Title,
+		Start:     recording.Start,
+		Stop:      recording.Stop,
+	}
+	return downloadCatchup(programme, utils.DefaultProfile, recording.Quality, w, active.done, nil)
+}
+
+// finishRecording stores the outcome of a recording and applies the
+// retention of its rule. A recording is completed when anything was written
+// and failed otherwise, catchup downloads also fail when they were cut short.
+// Cancelled recordings stay cancelled.
+func finishRecording(recording Recording, recordErr error) {
+	status := RecordingCompleted
+	message := ""
+	info, statErr := os.Stat(recording.File)
+	incomplete := recording.Source == RecordingSourceCatchup && recordErr != nil
+	if statErr != nil || info.Size() == 0 || incomplete {
+		status = RecordingFailed
+		message = "no segments were recorded"
+		if recordErr != nil {
+			message = recordErr.Error()
+		}
+	}
+
+	var rules []RecordingRule
+	if recording.RuleID != "" {
+		var err error
+		if rules, err = ListRecordingRules(); err != nil {
+			utils.Log.Printf("Recording %s: failed to load recording rules: %v", recording.ID, err)
+		}
+	}
+
+	err := updateRecordings(func(recordings []Recording) ([]Recording, error) {
+		for i := range recordings {
+			if recordings[i].ID != recording.ID || recordings[i].Status == RecordingCancelled {
+				continue
+			}
+			recordings[i].Status = status
+			recordings[i].Error = message
+		}
+		applyRuleRetention(recordings, rules)
+		return recordings, nil
+	})
+	if err != nil {
+		utils.Log.Printf("Recording %s: failed to save status: %v", recording.ID, err)
+		return
+	}
+	utils.Log.Printf("Recording %s: %s", recording.ID, status)
+}
+
+// RecordingsHandler lists all recordings
+func RecordingsHandler(c *fiber.Ctx) error {
+	recordings, err := ListRecordings()
+	if err != nil {
+		utils.Log.Println(err)
+		return internalUtils.InternalServerError(c, err)
+	}
+	return c.JSON(recordings)
+}
+
+// AddRecordingHandler schedules a recording from a RecordingRequest body
+func AddRecordingHandler(c *fiber.Ctx) error {
+	var request RecordingRequest
+	if err := c.BodyParser(&request); err != nil {
+		return internalUtils.BadRequestError(c, "Invalid JSON")
+	}
+	recording, err := AddRecording(request)
+	if err != nil {
+		return requestErrorResponse(c, err)
+	}
+	// Start right away instead of waiting for the next check if it is already due
+	if !time.Now().Before(recording.Start) {
+		if err := checkRecordings(time.Now()); err != nil {
+			utils.Log.Printf("Failed to check recordings: %v", err)
+		}
+	}
+	return c.Status(fiber.StatusCreated).JSON(recording)
+}
+
+// CancelRecordingHandler cancels a scheduled or running recording
+func CancelRecordingHandler(c *fiber.Ctx) error {
+	recording, err := CancelRecording(c.Params("id"))
+	switch {
+	case errors.Is(err, ErrRecordingNotFound):
+		return internalUtils.NotFoundError(c, err.Error())
+	case errors.Is(err, ErrRecordingFinished):
+		return internalUtils.ErrorResponse(c, fiber.StatusConflict, err.Error())
+	case err != nil:
+		utils.Log.Println(err)
+		return internalUtils.InternalServerError(c, err)
+	}
+	return c.JSON(recording)
+}
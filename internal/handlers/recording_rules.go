@@ -0,0 +1,581 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	recordingRulesFileName = "rules.json"
+
+	RecordingRuleActionRecord  = "record"
+	RecordingRuleActionCatchup = "catchup"
+)
+
+var (
+	// ErrRecordingRuleNotFound is returned for unknown recording rule IDs
+	ErrRecordingRuleNotFound = errors.New("recording rule not found")
+
+	// recordingRulesMu serialises changes to the recording rules file within this
+	// process, next to the file lock taken by lockRecordingsFile
+	recordingRulesMu sync.Mutex
+
+	registerRulesHook sync.Once
+
+	// ruleChannels returns the channel list that channel categories and
+	// languages of rules are resolved with. Tests replace it.
+	ruleChannels = func() ([]television.Channel, error) {
+		channels, err := television.Channels()
+		return channels.Result, err
+	}
+)
+
+// ruleMatcher is a compiled RecordingRule
+type ruleMatcher struct {
+	rule     RecordingRule
+	title    *regexp.Regexp
+	category *regexp.Regexp
+	channels map[string]bool
+	// lineup is the channels of the channel categories and languages of the
+	// rule, nil when it has none
+	lineup map[string]bool
+}
+
+// lookupName returns the ID of a name in a map of television, ignoring case
+func lookupName(names map[int]string, name string) (int, bool) {
+	for id, candidate := range names {
+		if strings.EqualFold(candidate, strings.TrimSpace(name)) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// resolveLineup returns the channels of the given channel categories and
+// languages. A channel needs one of the categories and one of the languages
+// when both are given.
+func resolveLineup(categoryNames, languageNames []string) (map[string]bool, error) {
+	categories := make(map[int]bool)
+	for _, name := range categoryNames {
+		id, ok := lookupName(television.CategoryMap, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown channel category %q", name)
+		}
+		categories[id] = true
+	}
+	languages := make(map[int]bool)
+	for _, name := range languageNames {
+		id, ok := lookupName(television.LanguageMap, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown language %q", name)
+		}
+		languages[id] = true
+	}
+
+	channels, err := ruleChannels()
+	if err != nil {
+		return nil, fmt.Errorf("fetching channels for the channel categories and languages: %w", err)
+	}
+	lineup := make(map[string]bool)
+	for _, channel := range channels {
+		if len(categories) > 0 && !categories[channel.Category] {
+			continue
+		}
+		if len(languages) > 0 && !languages[channel.Language] {
+			continue
+		}
+		lineup[channel.ID] = true
+	}
+	return lineup, nil
+}
+
+// compilePattern turns a rule pattern into a case-insensitive regular expression
+func compilePattern(pattern string, isRegex bool) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if !isRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// compileRecordingRule validates a rule and prepares it for matching
+func compileRecordingRule(rule RecordingRule) (*ruleMatcher, error) {
+	if rule.Title == "" && rule.Category == "" && len(rule.ChannelIDs) == 0 && len(rule.ChannelCategories) == 0 && len(rule.Languages) == 0 {
+		return nil, invalidRequest("a rule needs a title, category, channel, channel category or language to match")
+	}
+	switch rule.Action {
+	case RecordingRuleActionRecord, RecordingRuleActionCatchup:
+	default:
+		return nil, invalidRequest("invalid action %q, use %q or %q", rule.Action, RecordingRuleActionRecord, RecordingRuleActionCatchup)
+	}
+	if rule.KeepLast < 0 {
+		return nil, invalidRequest("keep_last must not be negative")
+	}
+
+	matcher := &ruleMatcher{rule: rule, channels: make(map[string]bool)}
+	var err error
+	if matcher.title, err = compilePattern(rule.Title, rule.Regex); err != nil {
+		return nil, invalidRequest("invalid title pattern: %w", err)
+	}
+	if matcher.category, err = compilePattern(rule.Category, rule.Regex); err != nil {
+		return nil, invalidRequest("invalid category pattern: %w", err)
+	}
+	for _, id := range rule.ChannelIDs {
+		matcher.channels[strings.TrimSpace(id)] = true
+	}
+	if len(rule.ChannelCategories) > 0 || len(rule.Languages) > 0 {
+		if matcher.lineup, err = resolveLineup(rule.ChannelCategories, rule.Languages); err != nil {
+			return nil, err
+		}
+	}
+	return matcher, nil
+}
+
+// matches reports whether a programme matches every criterion of the rule
+func (m *ruleMatcher) matches(programme epg.EPGObject) bool {
+	channelID := strconv.Itoa(int(programme.ChannelID))
+	if len(m.channels) > 0 && !m.channels[channelID] {
+		return false
+	}
+	if m.lineup != nil && !m.lineup[channelID] {
+		return false
+	}
+	if m.title != nil && !m.title.MatchString(programme.Title) {
+		return false
+	}
+	if m.category != nil && !m.category.MatchString(programme.ShowCategory) {
+		return false
+	}
+	return true
+}
+
+// programmeKey identifies an airing for deduplication by its srno, or by its start when it has none
+func programmeKey(channelID, srno string, start time.Time) string {
+	if srno != "" {
+		return channelID + "/" + srno
+	}
+	return channelID + "@" + strconv.FormatInt(start.UnixMilli(), 10)
+}
+
+// planRuleRecordings returns the recordings the rules schedule for the
+// programmes. Programmes that already have a recording, by srno or start,
+// are skipped, as are live recordings of programmes that already ended.
+// When several rules match a programme, the first one wins.
+func planRuleRecordings(rules []RecordingRule, programmes []epg.EPGObject, recordings []Recording, now time.Time) []Recording {
+	var matchers []*ruleMatcher
+	for _, rule := range rules {
+		matcher, err := compileRecordingRule(rule)
+		if err != nil {
+			utils.Log.Printf("Skipping recording rule %s: %v", rule.ID, err)
+			continue
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	scheduled := make(map[string]bool, len(recordings))
+	for _, recording := range recordings {
+		scheduled[programmeKey(recording.ChannelID, recording.Srno, recording.Start)] = true
+		scheduled[programmeKey(recording.ChannelID, "", recording.Start)] = true
+	}
+
+	sorted := make([]epg.EPGObject, len(programmes))
+	copy(sorted, programmes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartEpoch < sorted[j].StartEpoch
+	})
+
+	var planned []Recording
+	for _, programme := range sorted {
+		channelID := strconv.Itoa(int(programme.ChannelID))
+		start := time.UnixMilli(programme.StartEpoch)
+		stop := time.UnixMilli(programme.EndEpoch)
+		keys := []string{programmeKey(channelID, string(programme.Srno), start), programmeKey(channelID, "", start)}
+		if scheduled[keys[0]] || scheduled[keys[1]] {
+			continue
+		}
+
+		for _, matcher := range matchers {
+			if !matcher.matches(programme) {
+				continue
+			}
+			source := RecordingSourceLive
+			if matcher.rule.Action == RecordingRuleActionCatchup {
+				source = RecordingSourceCatchup
+			}
+			recording := Recording{
+				ChannelID: channelID,
+				Title:     programme.Title,
+				Quality:   matcher.rule.Quality,
+				Start:     start,
+				Stop:      stop,
+				Status:    RecordingScheduled,
+				Source:    source,
+				Srno:      string(programme.Srno),
+				RuleID:    matcher.rule.ID,
+				CreatedAt: now,
+			}
+			if recording.Quality == "" {
+				recording.Quality = "auto"
+			}
+			if _, missedAt := recordingWindow(recording); !now.Before(missedAt) {
+				break
+			}
+			planned = append(planned, recording)
+			scheduled[keys[0]], scheduled[keys[1]] = true, true
+			break
+		}
+	}
+	return planned
+}
+
+// applyRuleRetention keeps the newest KeepLast completed recordings of each
+// rule and deletes the files of older ones. Deleted recordings stay in the
+// list so that they are not scheduled again.
+func applyRuleRetention(recordings []Recording, rules []RecordingRule) {
+	for _, rule := range rules {
+		if rule.KeepLast <= 0 {
+			continue
+		}
+		var completed []int
+		for i, recording := range recordings {
+			if recording.RuleID == rule.ID && recording.Status == RecordingCompleted {
+				completed = append(completed, i)
+			}
+		}
+		sort.SliceStable(completed, func(a, b int) bool {
+			return recordings[completed[a]].Start.After(recordings[completed[b]].Start)
+		})
+		for _, i := range completed[min(rule.KeepLast, len(completed)):] {
+			if err := os.Remove(recordings[i].File); err != nil && !os.IsNotExist(err) {
+				utils.Log.Printf("Recording %s: failed to delete: %v", recordings[i].ID, err)
+				continue
+			}
+			recordings[i].Status = RecordingDeleted
+			utils.Log.Printf("Recording %s: deleted by the retention of rule %s", recordings[i].ID, rule.ID)
+		}
+	}
+}
+
+// loadRecordingRules reads the persisted recording rules
+func loadRecordingRules() ([]RecordingRule, error) {
+	rules := []RecordingRule{}
+	if err := readRecordingsFile(recordingRulesFileName, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ListRecordingRules returns all recording rules
+func ListRecordingRules() ([]RecordingRule, error) {
+	unlock, err := lockRecordingsFile(&recordingRulesMu, recordingRulesFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return loadRecordingRules()
+}
+
+// AddRecordingRule validates and stores a recording rule. The rule applies
+// from the next EPG update, or right away when the server has EPG data.
+func AddRecordingRule(rule RecordingRule) (RecordingRule, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Action == "" {
+		rule.Action = RecordingRuleActionRecord
+	}
+	if _, err := compileRecordingRule(rule); err != nil {
+		return rule, err
+	}
+	id, err := newRecordingID()
+	if err != nil {
+		return rule, err
+	}
+	rule.ID = id
+	rule.CreatedAt = time.Now()
+	if rule.Name == "" {
+		rule.Name = strings.Join(strings.Fields(strings.Join([]string{rule.Title, rule.Category, strings.Join(rule.ChannelIDs, ","),
+			strings.Join(rule.ChannelCategories, ","), strings.Join(rule.Languages, ",")}, " ")), " ")
+	}
+
+	unlock, err := lockRecordingsFile(&recordingRulesMu, recordingRulesFileName)
+	if err != nil {
+		return rule, err
+	}
+	defer unlock()
+	rules, err := loadRecordingRules()
+	if err != nil {
+		return rule, err
+	}
+	return rule, writeRecordingsFile(recordingRulesFileName, append(rules, rule))
+}
+
+// DeleteRecordingRule removes a recording rule. Recordings it already scheduled are kept.
+func DeleteRecordingRule(id string) error {
+	unlock, err := lockRecordingsFile(&recordingRulesMu, recordingRulesFileName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	rules, err := loadRecordingRules()
+	if err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		if rule.ID == id {
+			return writeRecordingsFile(recordingRulesFileName, append(rules[:i], rules[i+1:]...))
+		}
+	}
+	return ErrRecordingRuleNotFound
+}
+
+// PreviewRecordingRules lists the recordings the rules would schedule for
+// the programmes, without scheduling them
+func PreviewRecordingRules(programmes []epg.EPGObject) ([]Recording, error) {
+	rules, err := ListRecordingRules()
+	if err != nil {
+		return nil, err
+	}
+	recordings, err := ListRecordings()
+	if err != nil {
+		return nil, err
+	}
+	return planRuleRecordings(rules, programmes, recordings, time.Now()), nil
+}
+
+// EvaluateRecordingRules schedules the recordings the rules match in the programmes
+func EvaluateRecordingRules(programmes []epg.EPGObject) ([]Recording, error) {
+	rules, err := ListRecordingRules()
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	var planned []Recording
+	err = updateRecordings(func(recordings []Recording) ([]Recording, error) {
+		planned = planRuleRecordings(rules, programmes, recordings, time.Now())
+		for i := range planned {
+			if err := assignRecordingFile(&planned[i]); err != nil {
+				return nil, err
+			}
+		}
+		recordings = append(recordings, planned...)
+		applyRuleRetention(recordings, rules)
+		return recordings, nil
+	})
+	return planned, err
+}
+
+// RecordingRuleError returns why a stored rule matches no programme, like a
+// channel category that JioTV no longer has, or nil if it is valid
+func RecordingRuleError(rule RecordingRule) error {
+	_, err := compileRecordingRule(rule)
+	return err
+}
+
+// RecordingRuleChannelIDs returns the channels the rules are limited to, by
+// channel ID or by channel category and language, or nil when any rule
+// matches every channel. Invalid rules are left out.
+func RecordingRuleChannelIDs(rules []RecordingRule) []int {
+	ids := []int{}
+	for _, rule := range rules {
+		matcher, err := compileRecordingRule(rule)
+		if err != nil {
+			continue
+		}
+		channels := matcher.channels
+		switch {
+		case len(channels) > 0:
+		case matcher.lineup != nil:
+			channels = matcher.lineup
+		default:
+			return nil
+		}
+		for channelID := range channels {
+			if id, err := strconv.Atoi(channelID); err == nil && !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// ruleSelector selects the programmes any of the rules match, or returns
+// nil when there are no valid rules
+func ruleSelector(rules []RecordingRule) func(epg.EPGObject) bool {
+	var matchers []*ruleMatcher
+	for _, rule := range rules {
+		matcher, err := compileRecordingRule(rule)
+		if err != nil {
+			utils.Log.Printf("Skipping recording rule %s: %v", rule.ID, err)
+			continue
+		}
+		matchers = append(matchers, matcher)
+	}
+	if len(matchers) == 0 {
+		return nil
+	}
+	return func(programme epg.EPGObject) bool {
+		return slices.ContainsFunc(matchers, func(matcher *ruleMatcher) bool { return matcher.matches(programme) })
+	}
+}
+
+// ruleProgrammeSelector selects the programmes the rules match for the next
+// EPG update, so that only those are kept in memory for the rules
+func ruleProgrammeSelector() func(epg.EPGObject) bool {
+	rules, err := ListRecordingRules()
+	if err != nil {
+		utils.Log.Printf("Failed to load recording rules: %v", err)
+		return nil
+	}
+	return ruleSelector(rules)
+}
+
+// cachedRuleProgrammes returns the programmes the rules match in the EPG
+// cache, without fetching the EPG. It returns epg.ErrNoCachedProgrammes
+// when the EPG was not fetched yet.
+func cachedRuleProgrammes(rules []RecordingRule) ([]epg.EPGObject, error) {
+	selector := ruleSelector(rules)
+	channelIDs := RecordingRuleChannelIDs(rules)
+	// Rules limited to channel categories or languages without channels
+	if selector == nil || (channelIDs != nil && len(channelIDs) == 0) {
+		return nil, nil
+	}
+	return epg.CachedProgrammes(channelIDs, selector)
+}
+
+// evaluateCachedRecordingRules schedules the recordings the rules match in
+// the EPG cache
+func evaluateCachedRecordingRules() {
+	rules, err := ListRecordingRules()
+	if err != nil {
+		utils.Log.Printf("Failed to load recording rules: %v", err)
+		return
+	}
+	programmes, err := cachedRuleProgrammes(rules)
+	if errors.Is(err, epg.ErrNoCachedProgrammes) {
+		utils.Log.Println("Recording rules apply from the next EPG update")
+		return
+	}
+	if err != nil {
+		utils.Log.Printf("Failed to read programmes for recording rules: %v", err)
+		return
+	}
+	evaluateRecordingRulesAfterEPG(programmes)
+}
+
+// evaluateRecordingRulesAfterEPG is called after every EPG update with the
+// programmes the rules match
+func evaluateRecordingRulesAfterEPG(programmes []epg.EPGObject) {
+	planned, err := EvaluateRecordingRules(programmes)
+	if err != nil {
+		utils.Log.Printf("Failed to evaluate recording rules: %v", err)
+		return
+	}
+	if len(planned) > 0 {
+		utils.Log.Printf("Recording rules scheduled %d recordings", len(planned))
+	}
+}
+
+// initRecordingRules evaluates the rules after every EPG update. The EPG
+// file may be up to date at startup, so the rules are evaluated once with
+// the programmes in the EPG cache.
+func initRecordingRules() {
+	registerRulesHook.Do(func() {
+		epg.OnGenerated(ruleProgrammeSelector, evaluateRecordingRulesAfterEPG)
+	})
+
+	rules, err := ListRecordingRules()
+	if err != nil {
+		utils.Log.Printf("Failed to load recording rules: %v", err)
+		return
+	}
+	if len(rules) > 0 {
+		go evaluateCachedRecordingRules()
+	}
+}
+
+// RecordingRulesHandler lists all recording rules, with the error of the
+// ones that are skipped
+func RecordingRulesHandler(c *fiber.Ctx) error {
+	rules, err := ListRecordingRules()
+	if err != nil {
+		utils.Log.Println(err)
+		return internalUtils.InternalServerError(c, err)
+	}
+	statuses := make([]RecordingRuleStatus, len(rules))
+	for i, rule := range rules {
+		statuses[i].RecordingRule = rule
+		if err := RecordingRuleError(rule); err != nil {
+			statuses[i].Error = err.Error()
+		}
+	}
+	return c.JSON(statuses)
+}
+
+// AddRecordingRuleHandler stores a recording rule from a RecordingRule body
+// and applies it to the programmes in the EPG cache
+func AddRecordingRuleHandler(c *fiber.Ctx) error {
+	var rule RecordingRule
+	if err := c.BodyParser(&rule); err != nil {
+		return internalUtils.BadRequestError(c, "Invalid JSON")
+	}
+	rule, err := AddRecordingRule(rule)
+	if err != nil {
+		return requestErrorResponse(c, err)
+	}
+	go evaluateCachedRecordingRules()
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// DeleteRecordingRuleHandler removes a recording rule
+func DeleteRecordingRuleHandler(c *fiber.Ctx) error {
+	err := DeleteRecordingRule(c.Params("id"))
+	if errors.Is(err, ErrRecordingRuleNotFound) {
+		return internalUtils.NotFoundError(c, err.Error())
+	}
+	if err != nil {
+		utils.Log.Println(err)
+		return internalUtils.InternalServerError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RecordingRulesPreviewHandler lists the upcoming programmes the rules would
+// record, based on the programmes in the EPG cache
+func RecordingRulesPreviewHandler(c *fiber.Ctx) error {
+	rules, err := ListRecordingRules()
+	if err != nil {
+		utils.Log.Println(err)
+		return internalUtils.InternalServerError(c, err)
+	}
+	programmes, err := cachedRuleProgrammes(rules)
+	if errors.Is(err, epg.ErrNoCachedProgrammes) {
+		return internalUtils.ErrorResponse(c, fiber.StatusServiceUnavailable, "No EPG data yet. Enable EPG or wait for the next EPG update.")
+	}
+	if err != nil {
+		utils.Log.Println(err)
+		return internalUtils.InternalServerError(c, err)
+	}
+	planned, err := PreviewRecordingRules(programmes)
+	if err != nil {
+		utils.Log.Println(err)
+		return internalUtils.InternalServerError(c, err)
+	}
+	return c.JSON(planned)
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+)
+
+// setupRuleChannels serves a lineup of a Hindi and an English sports
+// channel and a Hindi news channel to rules
+func setupRuleChannels(t *testing.T) {
+	t.Helper()
+	previous := ruleChannels
+	ruleChannels = func() ([]television.Channel, error) {
+		return []television.Channel{
+			{ID: "154", Category: 8, Language: 1},
+			{ID: "155", Category: 8, Language: 6},
+			{ID: "143", Category: 12, Language: 1},
+		}, nil
+	}
+	t.Cleanup(func() { ruleChannels = previous })
+}
+
+func TestCompileRecordingRule(t *testing.T) {
+	setupRuleChannels(t)
+	tests := []struct {
+		name    string
+		rule    RecordingRule
+		wantErr bool
+	}{
+		{name: "Title", rule: RecordingRule{Title: "news", Action: RecordingRuleActionRecord}},
+		{name: "Category regex", rule: RecordingRule{Category: "^(Sports|Cricket)$", Regex: true, Action: RecordingRuleActionCatchup}},
+		{name: "Channel only", rule: RecordingRule{ChannelIDs: []string{"154"}, Action: RecordingRuleActionRecord}},
+		{name: "Channel category only", rule: RecordingRule{ChannelCategories: []string{"sports"}, Action: RecordingRuleActionRecord}},
+		{name: "Language only", rule: RecordingRule{Languages: []string{"Hindi"}, Action: RecordingRuleActionRecord}},
+		{name: "Unknown channel category", rule: RecordingRule{ChannelCategories: []string{"Cricket"}, Action: RecordingRuleActionRecord}, wantErr: true},
+		{name: "Unknown language", rule: RecordingRule{Languages: []string{"Klingon"}, Action: RecordingRuleActionRecord}, wantErr: true},
+		{name: "No criteria", rule: RecordingRule{Action: RecordingRuleActionRecord}, wantErr: true},
+		{name: "Invalid action", rule: RecordingRule{Title: "news", Action: "download"}, wantErr: true},
+		{name: "Invalid regex", rule: RecordingRule{Title: "(", Regex: true, Action: RecordingRuleActionRecord}, wantErr: true},
+		{name: "Negative retention", rule: RecordingRule{Title: "news", Action: RecordingRuleActionRecord, KeepLast: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compileRecordingRule(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("compileRecordingRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleMatcherMatches(t *testing.T) {
+	setupRuleChannels(t)
+	programme := epg.EPGObject{ChannelID: 154, Title: "The Big News (Repeat)", ShowCategory: "News"}
+
+	tests := []struct {
+		name string
+		rule RecordingRule
+		want bool
+	}{
+		{name: "Title substring ignores case", rule: RecordingRule{Title: "big news"}, want: true},
+		{name: "Title is not a regex by default", rule: RecordingRule{Title: "(Repeat)"}, want: true},
+		{name: "Title regex", rule: RecordingRule{Title: `^The .* News`, Regex: true}, want: true},
+		{name: "Category", rule: RecordingRule{Category: "news"}, want: true},
+		{name: "Other category", rule: RecordingRule{Category: "Sports"}, want: false},
+		{name: "Channel", rule: RecordingRule{ChannelIDs: []string{"143", "154"}}, want: true},
+		{name: "Other channel", rule: RecordingRule{Title: "news", ChannelIDs: []string{"143"}}, want: false},
+		{name: "All criteria must match", rule: RecordingRule{Title: "news", Category: "Sports"}, want: false},
+		{name: "Regex on sports channels", rule: RecordingRule{Title: `big\s+news`, Regex: true, ChannelCategories: []string{"Sports"}}, want: true},
+		{name: "Channel category", rule: RecordingRule{Title: "news", ChannelCategories: []string{"News"}}, want: false},
+		{name: "Channel category and language", rule: RecordingRule{ChannelCategories: []string{"Sports"}, Languages: []string{"hindi"}}, want: true},
+		{name: "Other language", rule: RecordingRule{ChannelCategories: []string{"Sports"}, Languages: []string{"English"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Action = RecordingRuleActionRecord
+			matcher, err := compileRecordingRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matcher.matches(programme); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanRuleRecordings(t *testing.T) {
+	setupRecordingTest(t)
+	now := time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC)
+	programme := func(channelID uint16, title string, start time.Time, srno string) epg.EPGObject {
+		return epg.EPGObject{
+			ChannelID:    channelID,
+			Title:        title,
+			ShowCategory: "Entertainment",
+			StartEpoch:   start.UnixMilli(),
+			EndEpoch:     start.Add(30 * time.Minute).UnixMilli(),
+			Srno:         epg.SerialNumber(srno),
+		}
+	}
+
+	rules := []RecordingRule{
+		{ID: "live", Title: "Serial", ChannelIDs: []string{"154"}, Action: RecordingRuleActionRecord},
+		{ID: "catchup", Title: "Serial", Action: RecordingRuleActionCatchup, Quality: "low"},
+	}
+	programmes := []epg.EPGObject{
+		programme(154, "Serial", now.Add(2*time.Hour), "3"),
+		programme(154, "Serial", now.Add(time.Hour), "2"),
+		// Already aired, too late to record live
+		programme(154, "Serial", now.Add(-time.Hour), "1"),
+		// Already scheduled by srno
+		programme(154, "Serial", now.Add(3*time.Hour), "4"),
+		// The same airing listed twice
+		programme(154, "Serial", now.Add(2*time.Hour), "3"),
+		// Other channels fall through to the catchup rule, even when already aired
+		programme(200, "Serial", now.Add(-time.Hour), "7"),
+		programme(200, "Movie", now.Add(time.Hour), "8"),
+	}
+	recordings := []Recording{{ChannelID: "154", Srno: "4", Start: now.Add(3 * time.Hour)}}
+
+	planned := planRuleRecordings(rules, programmes, recordings, now)
+
+	type plan struct {
+		ruleID string
+		srno   string
+		source RecordingSource
+	}
+	want := []plan{
+		{ruleID: "catchup", srno: "7", source: RecordingSourceCatchup},
+		{ruleID: "live", srno: "2", source: RecordingSourceLive},
+		{ruleID: "live", srno: "3", source: RecordingSourceLive},
+	}
+	if len(planned) != len(want) {
+		t.Fatalf("planRuleRecordings() planned %d recordings, want %d: %+v", len(planned), len(want), planned)
+	}
+	for i, recording := range planned {
+		got := plan{ruleID: recording.RuleID, srno: recording.Srno, source: recording.Source}
+		if got != want[i] {
+			t.Errorf("recording %d = %+v, want %+v", i, got, want[i])
+		}
+		if recording.Status != RecordingScheduled || recording.Title != "Serial" {
+			t.Errorf("recording %d = %+v", i, recording)
+		}
+	}
+	if planned[0].Quality != "low" || planned[1].Quality != "auto" {
+		t.Errorf("qualities = %q, %q, want low and auto", planned[0].Quality, planned[1].Quality)
+	}
+}
+
+func TestApplyRuleRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	var recordings []Recording
+	for i := 0; i < 4; i++ {
+		file := filepath.Join(dir, string(rune('a'+i))+".ts")
+		if err := os.WriteFile(file, []byte("ts"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		recordings = append(recordings, Recording{
+			ID:     string(rune('a' + i)),
+			RuleID: "rule",
+			Start:  now.Add(time.Duration(i) * time.Hour),
+			Status: RecordingCompleted,
+			File:   file,
+		})
+	}
+	// Recordings that did not complete do not count
+	recordings[3].Status = RecordingFailed
+
+	setupRecordingTest(t)
+	applyRuleRetention(recordings, []RecordingRule{{ID: "rule", KeepLast: 2}})
+
+	wantStatus := []RecordingStatus{RecordingDeleted, RecordingCompleted, RecordingCompleted, RecordingFailed}
+	for i, recording := range recordings {
+		if recording.Status != wantStatus[i] {
+			t.Errorf("recording %s status = %s, want %s", recording.ID, recording.Status, wantStatus[i])
+		}
+		_, err := os.Stat(recording.File)
+		if deleted := os.IsNotExist(err); deleted != (wantStatus[i] == RecordingDeleted) {
+			t.Errorf("recording %s file deleted = %v", recording.ID, deleted)
+		}
+	}
+}
+
+func TestRecordingRules(t *testing.T) {
+	setupRecordingTest(t)
+
+	if _, err := AddRecordingRule(RecordingRule{Action: RecordingRuleActionRecord}); err == nil {
+		t.Error("AddRecordingRule() accepted a rule without criteria")
+	}
+	rule, err := AddRecordingRule(RecordingRule{Title: "Serial", ChannelIDs: []string{"154"}})
+	if err != nil {
+		t.Fatalf("AddRecordingRule() error = %v", err)
+	}
+	if rule.ID == "" || rule.Action != RecordingRuleActionRecord || rule.Name == "" {
+		t.Errorf("AddRecordingRule() = %+v", rule)
+	}
+
+	start := time.Now().Add(time.Hour)
+	programmes := []epg.EPGObject{{
+		ChannelID:  154,
+		Title:      "Serial",
+		StartEpoch: start.UnixMilli(),
+		EndEpoch:   start.Add(30 * time.Minute).UnixMilli(),
+		Srno:       "42",
+	}}
+
+	preview, err := PreviewRecordingRules(programmes)
+	if err != nil || len(preview) != 1 {
+		t.Fatalf("PreviewRecordingRules() = %v, %v, want one recording", preview, err)
+	}
+	if recordings, _ := ListRecordings(); len(recordings) != 0 {
+		t.Errorf("PreviewRecordingRules() scheduled %d recordings", len(recordings))
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := EvaluateRecordingRules(programmes); err != nil {
+			t.Fatalf("EvaluateRecordingRules() error = %v", err)
+		}
+	}
+	recordings, err := ListRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 1 || recordings[0].RuleID != rule.ID || recordings[0].ID == "" || recordings[0].File == "" {
+		t.Errorf("EvaluateRecordingRules() scheduled %+v, want one recording of the rule", recordings)
+	}
+
+	if got := RecordingRuleChannelIDs([]RecordingRule{rule}); len(got) != 1 || got[0] != 154 {
+		t.Errorf("RecordingRuleChannelIDs() = %v, want [154]", got)
+	}
+	if got := RecordingRuleChannelIDs([]RecordingRule{rule, {Title: "Any channel", Action: RecordingRuleActionRecord}}); got != nil {
+		t.Errorf("RecordingRuleChannelIDs() = %v, want nil", got)
+	}
+	setupRuleChannels(t)
+	sports := RecordingRule{Title: "Final", ChannelCategories: []string{"Sports"}, Action: RecordingRuleActionRecord}
+	if got := RecordingRuleChannelIDs([]RecordingRule{rule, sports}); !slices.Equal(got, []int{154, 155}) {
+		t.Errorf("RecordingRuleChannelIDs() = %v, want [154 155]", got)
+	}
+	shopping := RecordingRule{ChannelCategories: []string{"Shopping"}, Action: RecordingRuleActionRecord}
+	if got := RecordingRuleChannelIDs([]RecordingRule{shopping}); got == nil || len(got) != 0 {
+		t.Errorf("RecordingRuleChannelIDs() = %v, want no channels", got)
+	}
+
+	// Only the programmes the rules match are kept from EPG updates
+	selector := ruleProgrammeSelector()
+	if selector == nil || !selector(programmes[0]) || selector(epg.EPGObject{ChannelID: 154, Title: "Movie"}) {
+		t.Error("ruleProgrammeSelector() does not select the programmes of the rule")
+	}
+
+	if err := DeleteRecordingRule(rule.ID); err != nil {
+		t.Fatalf("DeleteRecordingRule() error = %v", err)
+	}
+	if ruleProgrammeSelector() != nil {
+		t.Error("ruleProgrammeSelector() without rules selects programmes")
+	}
+	if err := DeleteRecordingRule(rule.ID); !errors.Is(err, ErrRecordingRuleNotFound) {
+		t.Errorf("DeleteRecordingRule() error = %v, want %v", err, ErrRecordingRuleNotFound)
+	}
+}
+
+func TestRecordingRulesHandlerReportsErrors(t *testing.T) {
+	setupRecordingTest(t)
+	setupRuleChannels(t)
+
+	// A channel category JioTV dropped after the rule was added
+	rules := []RecordingRule{
+		{ID: "valid", Title: "Final", ChannelCategories: []string{"Sports"}, Action: RecordingRuleActionRecord},
+		{ID: "stale", Title: "Final", ChannelCategories: []string{"Cricket"}, Action: RecordingRuleActionRecord},
+	}
+	if err := writeRecordingsFile(recordingRulesFileName, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/api/recordings/rules", RecordingRulesHandler)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/recordings/rules", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var statuses []RecordingRuleStatus
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].ID != "valid" || statuses[0].Error != "" || statuses[1].Error == "" {
+		t.Errorf("RecordingRulesHandler() = %+v, want an error for the stale rule only", statuses)
+	}
+
+	// The stale rule is skipped, and the valid one still selects programmes
+	selector := ruleSelector(rules)
+	if selector == nil || !selector(epg.EPGObject{ChannelID: 154, Title: "Final"}) {
+		t.Error("ruleSelector() does not select the programmes of the valid rule")
+	}
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func TestParseRecordingTime(t *testing.T) {
+	loc := indiaLocation()
+	now := time.Date(2024, 1, 15, 18, 0, 0, 0, loc)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "Now", value: "now", want: now},
+		{name: "RFC 3339", value: "2024-01-15T21:00:00Z", want: time.Date(2024, 1, 15, 21, 0, 0, 0, time.UTC)},
+		{name: "Unix seconds", value: "1705336200", want: time.Unix(1705336200, 0)},
+		{name: "Date and time in IST", value: "2024-01-16 06:30", want: time.Date(2024, 1, 16, 6, 30, 0, 0, loc)},
+		{name: "Time today in IST", value: "21:15", want: time.Date(2024, 1, 15, 21, 15, 0, 0, loc)},
+		{name: "Invalid", value: "tomorrow evening", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRecordingTime(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRecordingTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("parseRecordingTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordingFileName(t *testing.T) {
+	recording := Recording{
+		ChannelID: "143",
+		Title:     "News @ 9: Live/Special",
+		Start:     time.Date(2024, 1, 15, 21, 0, 0, 0, indiaLocation()),
+	}
+	if got, want := recordingFileName(recording), "143_20240115-2100_News_9_Live_Special.ts"; got != want {
+		t.Errorf("recordingFileName() = %q, want %q", got, want)
+	}
+}
+
+func setupRecordingTest(t *testing.T) {
+	t.Helper()
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousLog, previousRunner, previousFind := utils.Log, recordingRunner, findProgramme
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log, recordingRunner, findProgramme = previousLog, previousRunner, previousFind
+		activeRecordingsMu.Lock()
+		activeRecordings = make(map[string]*activeRecording)
+		activeRecordingsMu.Unlock()
+	})
+}
+
+func TestNewRecording(t *testing.T) {
+	setupRecordingTest(t)
+	loc := indiaLocation()
+	now := time.Date(2024, 1, 15, 18, 10, 0, 0, loc)
+
+	// Two half hour programmes from 18:00
+	findProgramme = func(channelID int, at time.Time) (*epg.EPGObject, error) {
+		slot := time.Date(2024, 1, 15, 18, 0, 0, 0, loc)
+		for at.Sub(slot) >= 30*time.Minute {
+			slot = slot.Add(30 * time.Minute)
+		}
+		return &epg.EPGObject{
+			StartEpoch: slot.UnixMilli(),
+			EndEpoch:   slot.Add(30 * time.Minute).UnixMilli(),
+			Title:      "Show at " + slot.Format("15:04"),
+		}, nil
+	}
+
+	tests := []struct {
+		name      string
+		request   RecordingRequest
+		wantStart time.Time
+		wantStop  time.Time
+		wantTitle string
+		wantErr   bool
+	}{
+		{
+			name:      "Start and stop",
+			request:   RecordingRequest{ChannelID: "143", Start: "19:00", Stop: "20:00", Title: "Evening"},
+			wantStart: time.Date(2024, 1, 15, 19, 0, 0, 0, loc),
+			wantStop:  time.Date(2024, 1, 15, 20, 0, 0, 0, loc),
+			wantTitle: "Evening",
+		},
+		{
+			name:      "Current programme",
+			request:   RecordingRequest{ChannelID: "143", Programme: "now"},
+			wantStart: time.Date(2024, 1, 15, 18, 0, 0, 0, loc),
+			wantStop:  time.Date(2024, 1, 15, 18, 30, 0, 0, loc),
+			wantTitle: "Show at 18:00",
+		},
+		{
+			name:      "Next programme",
+			request:   RecordingRequest{ChannelID: "143", Programme: "next"},
+			wantStart: time.Date(2024, 1, 15, 18, 30, 0, 0, loc),
+			wantStop:  time.Date(2024, 1, 15, 19, 0, 0, 0, loc),
+			wantTitle: "Show at 18:30",
+		},
+		{name: "Missing channel", request: RecordingRequest{Start: "19:00", Stop: "20:00"}, wantErr: true},
+		{name: "Missing window", request: RecordingRequest{ChannelID: "143"}, wantErr: true},
+		{name: "Stop before start", request: RecordingRequest{ChannelID: "143", Start: "20:00", Stop: "19:00"}, wantErr: true},
+		{name: "Window passed", request: RecordingRequest{ChannelID: "143", Start: "16:00", Stop: "17:00"}, wantErr: true},
+		{name: "Programme of a custom channel", request: RecordingRequest{ChannelID: "custom_news", Programme: "now"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRecording(tt.request, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRecording() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Start.Equal(tt.wantStart) || !got.Stop.Equal(tt.wantStop) {
+				t.Errorf("NewRecording() window = %v - %v, want %v - %v", got.Start, got.Stop, tt.wantStart, tt.wantStop)
+			}
+			if got.Title != tt.wantTitle {
+				t.Errorf("NewRecording() title = %q, want %q", got.Title, tt.wantTitle)
+			}
+			if got.ID == "" || got.File == "" || got.Status != RecordingScheduled || got.Quality != "auto" {
+				t.Errorf("NewRecording() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestAddRecordingHandlerStatus(t *testing.T) {
+	setupRecordingTest(t)
+	findProgramme = func(channelID int, at time.Time) (*epg.EPGObject, error) {
+		if channelID == 143 {
+			return nil, fmt.Errorf("%w on channel %d", epg.ErrProgrammeNotFound, channelID)
+		}
+		return nil, errors.New("EPG request failed")
+	}
+	app := fiber.New()
+	app.Post("/api/recordings", AddRecordingHandler)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "Invalid JSON", body: "{", wantStatus: fiber.StatusBadRequest},
+		{name: "Missing channel", body: `{"start":"19:00","stop":"20:00"}`, wantStatus: fiber.StatusBadRequest},
+		{name: "Invalid time", body: `{"channel_id":"143","start":"soon","stop":"20:00"}`, wantStatus: fiber.StatusBadRequest},
+		{name: "No programme at that time", body: `{"channel_id":"143","programme":"now"}`, wantStatus: fiber.StatusBadRequest},
+		{name: "EPG failure", body: `{"channel_id":"144","programme":"now"}`, wantStatus: fiber.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/recordings", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCheckRecordings(t *testing.T) {
+	setupRecordingTest(t)
+	now := time.Now()
+
+	started := make(chan string, 4)
+	recordingRunner = func(recording Recording, active *activeRecording) {
+		started <- recording.ID
+	}
+
+	recordings := []Recording{
+		{ID: "passed", Start: now.Add(-2 * time.Hour), Stop: now.Add(-time.Hour), Status: RecordingScheduled},
+		{ID: "interrupted", Start: now.Add(-time.Hour), Stop: now.Add(time.Hour), Status: RecordingRunning},
+		{ID: "due", Start: now.Add(-time.Second), Stop: now.Add(time.Hour), Status: RecordingScheduled},
+		{ID: "later", Start: now.Add(time.Hour), Stop: now.Add(2 * time.Hour), Status: RecordingScheduled},
+		{ID: "done", Start: now.Add(-2 * time.Hour), Stop: now.Add(-time.Hour), Status: RecordingCompleted},
+	}
+	if err := saveRecordings(recordings); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkRecordings(now); err != nil {
+		t.Fatalf("checkRecordings() error = %v", err)
+	}
+
+	startedIDs := map[string]bool{<-started: true, <-started: true}
+	if !startedIDs["interrupted"] || !startedIDs["due"] {
+		t.Errorf("started %v, want the interrupted and the due recording", startedIDs)
+	}
+
+	wantStatus := map[string]RecordingStatus{
+		"passed":      RecordingMissed,
+		"interrupted": RecordingRunning,
+		"due":         RecordingRunning,
+		"later":       RecordingScheduled,
+		"done":        RecordingCompleted,
+	}
+	got, err := ListRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, recording := range got {
+		if recording.Status != wantStatus[recording.ID] {
+			t.Errorf("recording %s status = %s, want %s", recording.ID, recording.Status, wantStatus[recording.ID])
+		}
+	}
+
+	// Running recordings are not started twice
+	if err := checkRecordings(now); err != nil {
+		t.Fatalf("checkRecordings() error = %v", err)
+	}
+	select {
+	case id := <-started:
+		t.Errorf("recording %s started twice", id)
+	default:
+	}
+}
+
+func TestCancelRecording(t *testing.T) {
+	setupRecordingTest(t)
+	now := time.Now()
+
+	recordings := []Recording{
+		{ID: "running", Start: now.Add(-time.Minute), Stop: now.Add(time.Hour), Status: RecordingRunning},
+		{ID: "done", Start: now.Add(-2 * time.Hour), Stop: now.Add(-time.Hour), Status: RecordingCompleted},
+	}
+	if err := saveRecordings(recordings); err != nil {
+		t.Fatal(err)
+	}
+	active := &activeRecording{done: make(chan struct{})}
+	activeRecordings["running"] = active
+
+	cancelled, err := CancelRecording("running")
+	if err != nil {
+		t.Fatalf("CancelRecording() error = %v", err)
+	}
+	if cancelled.Status != RecordingCancelled {
+		t.Errorf("CancelRecording() status = %s, want %s", cancelled.Status, RecordingCancelled)
+	}
+	select {
+	case <-active.done:
+	default:
+		t.Error("CancelRecording() did not stop the running recording")
+	}
+
+	if _, err := CancelRecording("done"); !errors.Is(err, ErrRecordingFinished) {
+		t.Errorf("CancelRecording() of a finished recording error = %v, want %v", err, ErrRecordingFinished)
+	}
+	if _, err := CancelRecording("unknown"); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("CancelRecording() of an unknown recording error = %v, want %v", err, ErrRecordingNotFound)
+	}
+
+	// The outcome of a cancelled recording does not overwrite the cancellation
+	finishRecording(Recording{ID: "running", File: "missing.ts"}, nil)
+	got, err := ListRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, recording := range got {
+		if recording.ID == "running" && recording.Status != RecordingCancelled {
+			t.Errorf("recording status = %s after finishing, want %s", recording.Status, RecordingCancelled)
+		}
+	}
+}
+
+func TestRecordingWindow(t *testing.T) {
+	start := time.Date(2024, 1, 15, 21, 0, 0, 0, time.UTC)
+	stop := start.Add(time.Hour)
+
+	startAt, missedAt := recordingWindow(Recording{Start: start, Stop: stop, Source: RecordingSourceLive})
+	if !startAt.Equal(start) || !missedAt.Equal(stop) {
+		t.Errorf("live recordingWindow() = %v, %v, want the programme window", startAt, missedAt)
+	}
+
+	startAt, missedAt = recordingWindow(Recording{Start: start, Stop: stop, Source: RecordingSourceCatchup})
+	if !startAt.Equal(stop.Add(catchupRecordingDelay)) || !missedAt.Equal(start.Add(catchupRetention)) {
+		t.Errorf("catchup recordingWindow() = %v, %v, want after the programme aired", startAt, missedAt)
+	}
+}
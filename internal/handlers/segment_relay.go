@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"container/list"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	segmentRelayDefaultSizeMB = 64
+	// segmentRelayTTL keeps segments long enough for viewers of the same live
+	// channel to catch up with each other while the playlist window moves on
+	segmentRelayTTL = 2 * time.Minute
+)
+
+// segmentRelay is shared by all segment proxies. It is replaced in Init once the config is loaded.
+var segmentRelay = newSegmentRelay(segmentRelayDefaultSizeMB << 20)
+
+// relayedSegment is an upstream segment response shared between viewers
+type relayedSegment struct {
+	key         string
+	body        []byte
+	contentType string
+	statusCode  int
+	storedAt    time.Time
+}
+
+// SegmentRelayStats reports how effective the shared segment relay is
+type SegmentRelayStats struct {
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+	Entries  int     `json:"entries"`
+	Bytes    int64   `json:"bytes"`
+	MaxBytes int64   `json:"max_bytes"`
+}
+
+// relay shares upstream segment responses between concurrent and
+// near-simultaneous viewers. Concurrent requests for the same segment are
+// collapsed with singleflight, like tokenRefreshGroup does for token refreshes,
+// and completed responses are kept in an LRU bounded by total body size.
+type relay struct {
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+	bytes    int64
+	maxBytes int64
+
+	group  singleflight.Group
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func newSegmentRelay(maxBytes int64) *relay {
+	return &relay{
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		maxBytes: maxBytes,
+	}
+}
+
+// segmentRelayMaxBytes returns the configured relay size. A negative size disables caching.
+func segmentRelayMaxBytes() int64 {
+	sizeMB := config.Cfg.SegmentCacheSizeMB
+	if sizeMB == 0 {
+		sizeMB = segmentRelayDefaultSizeMB
+	}
+	if sizeMB < 0 {
+		return 0
+	}
+	return int64(sizeMB) << 20
+}
+
+// segmentRelayKey returns the relay key of a decrypted upstream URL, or ""
+// for URLs that are not media segments. The hdnea token differs between
+// viewers and refreshes but not the segment, so it is left out of the key.
+func segmentRelayKey(upstreamURL string) string {
+	parsed, err := url.Parse(upstreamURL)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(path.Ext(parsed.Path)) {
+	case ".ts", ".m4s", ".aac":
+	default:
+		return ""
+	}
+	return stripHDNEAFromURL(upstreamURL)
+}
+
+// get returns a fresh cached segment and marks it as recently used
+func (r *relay) get(key string, now time.Time) (*relayedSegment, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[key]
+	if !ok {
+		return nil, false
+	}
+	segment := element.Value.(*relayedSegment)
+	if now.Sub(segment.storedAt) > segmentRelayTTL {
+		r.remove(element)
+		return nil, false
+	}
+	r.order.MoveToFront(element)
+	return segment, true
+}
+
+// add caches a segment, evicting the least recently used ones to stay within maxBytes
+func (r *relay) add(segment *relayedSegment) {
+	size := int64(len(segment.body))
+	if size > r.maxBytes {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, ok := r.entries[segment.key]; ok {
+		r.remove(element)
+	}
+	r.entries[segment.key] = r.order.PushFront(segment)
+	r.bytes += size
+	for r.bytes > r.maxBytes {
+		r.remove(r.order.Back())
+	}
+}
+
+// remove drops an entry. Callers must hold r.mu.
+func (r *relay) remove(element *list.Element) {
+	segment := element.Value.(*relayedSegment)
+	r.order.Remove(element)
+	delete(r.entries, segment.key)
+	r.bytes -= int64(len(segment.body))
+}
+
+// do returns the segment for key from the cache, from a fetch already in
+// flight for the same key, or by calling fetch. fetched reports whether this
+// caller ran fetch itself. Only successful responses are cached.
+func (r *relay) do(key string, fetch func() (*relayedSegment, error)) (segment *relayedSegment, fetched bool, err error) {
+	if segment, ok := r.get(key, time.Now()); ok {
+		r.hits.Add(1)
+		return segment, false, nil
+	}
+
+	value, err, _ := r.group.Do(key, func() (interface{}, error) {
+		fetched = true
+		segment, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		segment.key = key
+		segment.storedAt = time.Now()
+		if segment.statusCode == fiber.StatusOK {
+			r.add(segment)
+		}
+		return segment, nil
+	})
+	if fetched {
+		r.misses.Add(1)
+	} else if err == nil {
+		r.hits.Add(1)
+	}
+	if err != nil {
+		return nil, fetched, err
+	}
+	return value.(*relayedSegment), fetched, nil
+}
+
+// stats returns a snapshot of the relay counters
+func (r *relay) stats() SegmentRelayStats {
+	r.mu.Lock()
+	stats := SegmentRelayStats{
+		Entries:  len(r.entries),
+		Bytes:    r.bytes,
+		MaxBytes: r.maxBytes,
+	}
+	r.mu.Unlock()
+
+	stats.Hits = r.hits.Load()
+	stats.Misses = r.misses.Load()
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// relaySegmentResponse serves a segment proxy request through the shared relay.
+// proxyToClient performs the handler's usual upstream request into c; the
+// first viewer of a segment runs it and later viewers get a copy of its
+// response. Requests that are not for media segments are proxied directly.
+func relaySegmentResponse(c *fiber.Ctx, upstreamURL string, proxyToClient func() error) error {
+	key := segmentRelayKey(upstreamURL)
+	if key == "" || segmentRelay.maxBytes == 0 {
+		return proxyToClient()
+	}
+
+	segment, fetched, err := segmentRelay.do(key, func() (*relayedSegment, error) {
+		if err := proxyToClient(); err != nil {
+			return nil, err
+		}
+		response := c.Response()
+		return &relayedSegment{
+			body:        append([]byte(nil), response.Body()...),
+			contentType: string(response.Header.ContentType()),
+			statusCode:  response.StatusCode(),
+		}, nil
+	})
+	if err != nil {
+		return err
+	}
+	if fetched {
+		// The response is already in c
+		return nil
+	}
+	if segment.statusCode != fiber.StatusOK {
+		// Do not hand out another viewer's failure, try upstream ourselves
+		return proxyToClient()
+	}
+
+	c.Response().Header.Del(fiber.HeaderServer)
+	c.Set(fiber.HeaderContentType, segment.contentType)
+	return c.Status(fiber.StatusOK).Send(segment.body)
+}
+
+// SegmentRelayStatsHandler reports the hit and miss counters of the shared segment relay
+func SegmentRelayStatsHandler(c *fiber.Ctx) error {
+	return c.JSON(segmentRelay.stats())
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+)
+
+func TestSegmentRelayKey(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "TS segment", url: "https://cdn.example.com/live/seg1.ts", want: "https://cdn.example.com/live/seg1.ts"},
+		{name: "Token is left out", url: "https://cdn.example.com/live/seg1.ts?a=1&__hdnea__=st=1~exp=2", want: "https://cdn.example.com/live/seg1.ts?a=1"},
+		{name: "DASH segment", url: "https://cdn.example.com/dash/video-100.m4s", want: "https://cdn.example.com/dash/video-100.m4s"},
+		{name: "AAC segment", url: "https://cdn.example.com/live/audio1.AAC", want: "https://cdn.example.com/live/audio1.AAC"},
+		{name: "Playlist", url: "https://cdn.example.com/live/index.m3u8", want: ""},
+		{name: "Manifest", url: "https://cdn.example.com/dash/manifest.mpd", want: ""},
+		{name: "Key", url: "https://cdn.example.com/live/key.key", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := segmentRelayKey(tt.url); got != tt.want {
+				t.Errorf("segmentRelayKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentRelayMaxBytes(t *testing.T) {
+	originalCfg := config.Cfg
+	defer func() { config.Cfg = originalCfg }()
+
+	tests := []struct {
+		name   string
+		sizeMB int
+		want   int64
+	}{
+		{name: "Default", sizeMB: 0, want: segmentRelayDefaultSizeMB << 20},
+		{name: "Configured", sizeMB: 8, want: 8 << 20},
+		{name: "Disabled", sizeMB: -1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg.SegmentCacheSizeMB = tt.sizeMB
+			if got := segmentRelayMaxBytes(); got != tt.want {
+				t.Errorf("segmentRelayMaxBytes() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelayLRU(t *testing.T) {
+	r := newSegmentRelay(10)
+	now := time.Now()
+
+	r.add(&relayedSegment{key: "a", body: []byte("1234"), storedAt: now})
+	r.add(&relayedSegment{key: "b", body: []byte("1234"), storedAt: now})
+	// Use "a" so that "b" becomes the least recently used
+	if _, ok := r.get("a", now); !ok {
+		t.Fatal("a should be cached")
+	}
+	r.add(&relayedSegment{key: "c", body: []byte("1234"), storedAt: now})
+
+	if _, ok := r.get("b", now); ok {
+		t.Error("b should have been evicted")
+	}
+	if _, ok := r.get("a", now); !ok {
+		t.Error("a should still be cached")
+	}
+	if stats := r.stats(); stats.Bytes != 8 || stats.Entries != 2 {
+		t.Errorf("stats() = %+v, want 8 bytes in 2 entries", stats)
+	}
+
+	r.add(&relayedSegment{key: "huge", body: make([]byte, 11), storedAt: now})
+	if _, ok := r.get("huge", now); ok {
+		t.Error("segments larger than the cache should not be stored")
+	}
+
+	if _, ok := r.get("a", now.Add(segmentRelayTTL+time.Second)); ok {
+		t.Error("expired segments should not be served")
+	}
+}
+
+func TestRelayDo(t *testing.T) {
+	r := newSegmentRelay(1 << 20)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	fetch := func() (*relayedSegment, error) {
+		fetches.Add(1)
+		<-release
+		return &relayedSegment{body: []byte("segment"), statusCode: fiber.StatusOK}, nil
+	}
+
+	const viewers = 5
+	var wg sync.WaitGroup
+	results := make([]string, viewers)
+	for i := 0; i < viewers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			segment, _, err := r.do("seg", fetch)
+			if err == nil {
+				results[i] = string(segment.body)
+			}
+		}(i)
+	}
+	// Give every viewer time to join the fetch in flight
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// A viewer arriving later is served from the cache
+	if _, fetched, _ := r.do("seg", fetch); fetched {
+		t.Error("a later viewer should be served from the cache")
+	}
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("upstream fetched %d times, want 1", got)
+	}
+	for i, result := range results {
+		if result != "segment" {
+			t.Errorf("viewer %d got %q", i, result)
+		}
+	}
+	stats := r.stats()
+	if stats.Misses != 1 || stats.Hits != viewers {
+		t.Errorf("stats() = %+v, want 1 miss and %d hits", stats, viewers)
+	}
+
+	t.Run("Failures are not cached", func(t *testing.T) {
+		_, _, err := r.do("broken", func() (*relayedSegment, error) { return nil, errors.New("upstream down") })
+		if err == nil {
+			t.Fatal("do() expected an error")
+		}
+		r.do("forbidden", func() (*relayedSegment, error) {
+			return &relayedSegment{statusCode: fiber.StatusForbidden}, nil
+		})
+		if _, ok := r.get("forbidden", time.Now()); ok {
+			t.Error("non-200 responses should not be cached")
+		}
+	})
+}
+
+func TestRelaySegmentResponse(t *testing.T) {
+	originalRelay := segmentRelay
+	defer func() { segmentRelay = originalRelay }()
+	segmentRelay = newSegmentRelay(1 << 20)
+
+	var upstreamCalls int
+	app := fiber.New()
+	app.Get("/segment", func(c *fiber.Ctx) error {
+		return relaySegmentResponse(c, "https://cdn.example.com/live/"+c.Query("name"), func() error {
+			upstreamCalls++
+			c.Set(fiber.HeaderContentType, "video/mp2t")
+			return c.SendString("payload of " + c.Query("name"))
+		})
+	})
+
+	for i := 0; i < 3; i++ {
+		resp, err := app.Test(httptest.NewRequest("GET", "/segment?name=seg1.ts", nil))
+		if err != nil {
+			t.Fatalf("app.Test() error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusOK || string(body) != "payload of seg1.ts" {
+			t.Errorf("request %d = %d %q", i, resp.StatusCode, body)
+		}
+		if got := resp.Header.Get(fiber.HeaderContentType); got != "video/mp2t" {
+			t.Errorf("request %d Content-Type = %q", i, got)
+		}
+	}
+	if upstreamCalls != 1 {
+		t.Errorf("upstream called %d times for one segment, want 1", upstreamCalls)
+	}
+
+	// Playlists bypass the relay
+	for i := 0; i < 2; i++ {
+		if _, err := app.Test(httptest.NewRequest("GET", "/segment?name=index.m3u8", nil)); err != nil {
+			t.Fatalf("app.Test() error = %v", err)
+		}
+	}
+	if upstreamCalls != 3 {
+		t.Errorf("upstream called %d times, want 3", upstreamCalls)
+	}
+}
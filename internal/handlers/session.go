@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+// sessionExpiryWarning is how long before the session can no longer be
+// renewed SessionStatusFor starts warning about it
+const sessionExpiryWarning = 24 * time.Hour
+
+// SessionStatusFor reports the login session of a profile
+func SessionStatusFor(profile string) SessionStatus {
+	profile = utils.NormalizeProfile(profile)
+	now := time.Now()
+	status := SessionStatus{
+		Profile:          profile,
+		PremiumProviders: []television.PremiumProvider{},
+	}
+
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
+	if err != nil || credentials == nil {
+		status.Warnings = append(status.Warnings, "Not logged in")
+		return status
+	}
+	status.LoggedIn = true
+	status.CRM = maskID(credentials.CRM)
+	status.UniqueID = maskID(credentials.UniqueID)
+	status.LastTokenRefresh = parseUnixSeconds(credentials.LastTokenRefreshTime)
+	status.LastSSOTokenRefresh = parseUnixSeconds(credentials.LastSSOTokenRefreshTime)
+
+	if expiry, ok := parseJWTExpiry(credentials.AccessToken); ok {
+		status.AccessTokenExpiry = expiry
+		status.AccessTokenExpired = !expiry.After(now)
+	}
+	if expiry, ok := parseJWTExpiry(credentials.SSOToken); ok {
+		status.SSOTokenExpiry = expiry
+		status.SSOTokenExpired = !expiry.After(now)
+	}
+	status.RefreshTokenRejectedAt, status.RefreshTokenRejected = utils.RefreshTokenRejectedAtFor(profile)
+
+	if providers := premiumProvidersOf(profile); providers != nil {
+		status.PremiumProviders = providers
+	}
+
+	tokenRefreshStatusesMu.Lock()
+	if refreshStatus, ok := tokenRefreshStatuses[profile]; ok {
+		statusCopy := *refreshStatus
+		status.BackgroundRefresh = &statusCopy
+	}
+	tokenRefreshStatusesMu.Unlock()
+
+	status.Warnings = sessionWarnings(status, now)
+	return status
+}
+
+// sessionWarnings describes the problems of a session that is logged in
+func sessionWarnings(status SessionStatus, now time.Time) []string {
+	var warnings []string
+	if status.RefreshTokenRejected {
+		warnings = append(warnings, fmt.Sprintf("JioTV rejected the refresh token at %s. Log in again.", status.RefreshTokenRejectedAt.Format(time.RFC3339)))
+	}
+	if status.AccessTokenExpired {
+		warnings = append(warnings, "The access token expired")
+	}
+	if status.SSOTokenExpired {
+		warnings = append(warnings, "The SSO token expired")
+	} else if !status.SSOTokenExpiry.IsZero() && status.SSOTokenExpiry.Before(now.Add(sessionExpiryWarning)) {
+		warnings = append(warnings, fmt.Sprintf("The SSO token expires at %s", status.SSOTokenExpiry.Format(time.RFC3339)))
+	}
+	if refresh := status.BackgroundRefresh; refresh != nil && refresh.Failures > 0 {
+		warnings = append(warnings, fmt.Sprintf("The last %d background token refreshes failed: %s", refresh.Failures, refresh.LastError))
+	}
+	return warnings
+}
+
+// maskID hides all but the last 4 characters of an account identifier
+func maskID(id string) string {
+	const visible = 4
+	if len(id) <= visible {
+		return strings.Repeat("*", len(id))
+	}
+	return strings.Repeat("*", len(id)-visible) + id[len(id)-visible:]
+}
+
+// parseUnixSeconds parses a stored unix timestamp, returning the zero time
+// if it is missing or invalid
+func parseUnixSeconds(value string) time.Time {
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || unix <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
+
+// SessionHandler reports the login session of the profile of the request:
+// `GET /api/session`
+func SessionHandler(c *fiber.Ctx) error {
+	return c.JSON(SessionStatusFor(requestProfile(c)))
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func TestMaskID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "", want: ""},
+		{id: "123", want: "***"},
+		{id: "1234", want: "****"},
+		{id: "9876543210", want: "******3210"},
+	}
+	for _, tt := range tests {
+		if got := maskID(tt.id); got != tt.want {
+			t.Errorf("maskID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestSessionStatusFor(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	previousLog := utils.Log
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log = previousLog
+		profileProviders.Clear()
+	})
+
+	if status := SessionStatusFor("family"); status.LoggedIn || len(status.Warnings) == 0 {
+		t.Errorf("SessionStatusFor() of a missing profile = %+v", status)
+	}
+
+	now := time.Now()
+	accessExpiry := now.Add(-time.Minute).Truncate(time.Second)
+	ssoExpiry := now.Add(2 * time.Hour).Truncate(time.Second)
+	lastRefresh := now.Add(-3 * time.Hour).Truncate(time.Second)
+	if err := utils.WriteJIOTVCredentialsFor("family", &utils.JIOTV_CREDENTIALS{
+		SSOToken:                buildUnsignedJWT(ssoExpiry.Unix()),
+		CRM:                     "9876543210",
+		UniqueID:                "unique-abcd",
+		AccessToken:             buildUnsignedJWT(accessExpiry.Unix()),
+		RefreshToken:            "refresh",
+		LastTokenRefreshTime:    strconv.FormatInt(lastRefresh.Unix(), 10),
+		LastSSOTokenRefreshTime: strconv.FormatInt(lastRefresh.Unix(), 10),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.SetRefreshTokenRejectedFor("family", lastRefresh); err != nil {
+		t.Fatal(err)
+	}
+	profileProviders.Store("family", profileProvidersEntry{Providers: []television.PremiumProvider{{ID: "sonyliv", Name: "SonyLIV"}}, UpdatedAt: now})
+
+	status := SessionStatusFor("Family")
+	if !status.LoggedIn || status.Profile != "family" {
+		t.Fatalf("SessionStatusFor() = %+v, want the family profile logged in", status)
+	}
+	if status.CRM != "******3210" || status.UniqueID != "*******abcd" {
+		t.Errorf("masked IDs = %q, %q", status.CRM, status.UniqueID)
+	}
+	if !status.AccessTokenExpiry.Equal(accessExpiry) || !status.AccessTokenExpired {
+		t.Errorf("access token expiry = %v, expired %v", status.AccessTokenExpiry, status.AccessTokenExpired)
+	}
+	if !status.SSOTokenExpiry.Equal(ssoExpiry) || status.SSOTokenExpired {
+		t.Errorf("SSO token expiry = %v, expired %v", status.SSOTokenExpiry, status.SSOTokenExpired)
+	}
+	if !status.LastTokenRefresh.Equal(lastRefresh) || !status.LastSSOTokenRefresh.Equal(lastRefresh) {
+		t.Errorf("last refreshes = %v, %v, want %v", status.LastTokenRefresh, status.LastSSOTokenRefresh, lastRefresh)
+	}
+	if !status.RefreshTokenRejected || !status.RefreshTokenRejectedAt.Equal(lastRefresh) {
+		t.Errorf("refresh token rejection = %v at %v", status.RefreshTokenRejected, status.RefreshTokenRejectedAt)
+	}
+	if len(status.PremiumProviders) != 1 || status.PremiumProviders[0].Name != "SonyLIV" {
+		t.Errorf("premium providers = %+v", status.PremiumProviders)
+	}
+	// The rejection, the expired access token and the SSO token expiring within a day
+	if len(status.Warnings) != 3 {
+		t.Errorf("warnings = %q, want 3", status.Warnings)
+	}
+
+	if err := utils.ClearRefreshTokenRejectedFor("family"); err != nil {
+		t.Fatal(err)
+	}
+	if status := SessionStatusFor("family"); status.RefreshTokenRejected {
+		t.Error("refresh token still rejected after clearing the rejection")
+	}
+}
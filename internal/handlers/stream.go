@@ -0,0 +1,692 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// streamLiveEdgeSegments is how many segments behind the live edge a new stream starts
+	streamLiveEdgeSegments = 3
+	// streamRecentSegments bounds the segments remembered to avoid writing duplicates
+	streamRecentSegments = 128
+	// streamMaxFailures is how many consecutive playlist failures end a stream
+	streamMaxFailures = 10
+	// streamSegmentRetries is how many times a segment download is retried before it is skipped
+	streamSegmentRetries = 3
+	// streamMaxCachedKeys bounds the AES-128 keys remembered per stream
+	streamMaxCachedKeys = 16
+	// streamMaxVariantDepth bounds the master playlists followed to reach a
+	// media playlist, so that a playlist listing itself cannot loop forever
+	streamMaxVariantDepth = 3
+	streamMinReloadDelay  = 1 * time.Second
+	streamMaxReloadDelay  = 10 * time.Second
+)
+
+var (
+	errStreamPlaylistUnavailable = errors.New("media playlist unavailable")
+	errStreamNotFound            = errors.New("stream not found")
+	errStreamTooManyVariants     = errors.New("too many nested master playlists")
+)
+
+// hlsKey describes an #EXT-X-KEY tag
+type hlsKey struct {
+	Method string
+	URI    string
+	IV     []byte
+}
+
+// hlsSegment is a media segment of an HLS media playlist
+type hlsSegment struct {
+	Sequence int64
+	URI      string
+	Duration float64
+	Key      *hlsKey
+}
+
+// hlsVariant is a variant stream of an HLS master playlist
+type hlsVariant struct {
+	URI       string
+	Bandwidth int64
+}
+
+// hlsPlaylist is a parsed HLS master or media playlist. All URIs are absolute.
+type hlsPlaylist struct {
+	TargetDuration float64
+	Segments       []hlsSegment
+	Variants       []hlsVariant
+	Ended          bool
+}
+
+// parseHLSAttributes parses an attribute list such as `METHOD=AES-128,URI="a,b"`
+func parseHLSAttributes(list string) map[string]string {
+	attributes := make(map[string]string)
+	for list != "" {
+		eq := strings.IndexByte(list, '=')
+		if eq == -1 {
+			break
+		}
+		name := strings.TrimSpace(list[:eq])
+		list = list[eq+1:]
+
+		var value string
+		if strings.HasPrefix(list, `"`) {
+			end := strings.IndexByte(list[1:], '"')
+			if end == -1 {
+				value, list = list[1:], ""
+			} else {
+				value, list = list[1:end+1], list[end+2:]
+			}
+		} else if comma := strings.IndexByte(list, ','); comma != -1 {
+			value, list = list[:comma], list[comma:]
+		} else {
+			value, list = list, ""
+		}
+		attributes[name] = value
+		list = strings.TrimPrefix(list, ",")
+	}
+	return attributes
+}
+
+// resolveHLSURI resolves a playlist URI against the playlist URL. Relative
+// URIs inherit the query of the playlist URL, which carries the CDN
+// parameters, the same way RenderHandler appends them when rewriting.
+func resolveHLSURI(playlistURL *url.URL, uri string) string {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	resolved := playlistURL.ResolveReference(ref)
+	if !ref.IsAbs() && ref.RawQuery == "" {
+		query := playlistURL.Query()
+		query.Del("hdnea")
+		query.Del("__hdnea__")
+		resolved.RawQuery = query.Encode()
+	}
+	return resolved.String()
+}
+
+// parseHLSPlaylist parses a master or media playlist fetched from playlistURL
+func parseHLSPlaylist(body []byte, playlistURL string) (hlsPlaylist, error) {
+	var playlist hlsPlaylist
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		return playlist, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("#EXTM3U")) {
+		return playlist, fmt.Errorf("not an HLS playlist")
+	}
+
+	var (
+		sequence       int64
+		duration       float64
+		key            *hlsKey
+		pendingVariant *hlsVariant
+	)
+	for _, rawLine := range strings.Split(string(body), "\n") {
+		line := strings.TrimSpace(rawLine)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			playlist.TargetDuration, _ = strconv.ParseFloat(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"), 64)
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if comma := strings.IndexByte(value, ','); comma != -1 {
+				value = value[:comma]
+			}
+			duration, _ = strconv.ParseFloat(value, 64)
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attributes := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			if attributes["METHOD"] == "" || attributes["METHOD"] == "NONE" {
+				key = nil
+				continue
+			}
+			key = &hlsKey{Method: attributes["METHOD"], URI: resolveHLSURI(base, attributes["URI"])}
+			if iv := attributes["IV"]; iv != "" {
+				key.IV, _ = hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"))
+			}
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			bandwidth, _ := strconv.ParseInt(parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))["BANDWIDTH"], 10, 64)
+			pendingVariant = &hlsVariant{Bandwidth: bandwidth}
+		case line == "#EXT-X-ENDLIST":
+			playlist.Ended = true
+		case strings.HasPrefix(line, "#"):
+			continue
+		case pendingVariant != nil:
+			pendingVariant.URI = resolveHLSURI(base, line)
+			playlist.Variants = append(playlist.Variants, *pendingVariant)
+			pendingVariant = nil
+		default:
+			playlist.Segments = append(playlist.Segments, hlsSegment{
+				Sequence: sequence,
+				URI:      resolveHLSURI(base, line),
+				Duration: duration,
+				Key:      key,
+			})
+			sequence++
+			duration = 0
+		}
+	}
+	return playlist, nil
+}
+
+// selectHLSVariant returns the URI of the variant for the requested quality.
+// Auto and high pick the highest bandwidth, low the lowest and medium the one in between.
+func selectHLSVariant(variants []hlsVariant, quality string) string {
+	if len(variants) == 0 {
+		return ""
+	}
+	sorted := make([]hlsVariant, len(variants))
+	copy(sorted, variants)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Bandwidth < sorted[j].Bandwidth
+	})
+	highest := sorted[len(sorted)-1].URI
+	return internalUtils.SelectQuality(quality, highest, highest, sorted[len(sorted)/2].URI, sorted[0].URI)
+}
+
+// hlsSegmentIdentity identifies a segment independently of the tokens in its query
+func hlsSegmentIdentity(segmentURL string) string {
+	if i := strings.IndexByte(segmentURL, '?'); i != -1 {
+		return segmentURL[:i]
+	}
+	return segmentURL
+}
+
+// recentSegments remembers the most recently written segments in order
+type recentSegments struct {
+	order []string
+	seen  map[string]struct{}
+}
+
+func newRecentSegments() *recentSegments {
+	return &recentSegments{seen: make(map[string]struct{})}
+}
+
+func (r *recentSegments) contains(id string) bool {
+	_, ok := r.seen[id]
+	return ok
+}
+
+func (r *recentSegments) add(id string) {
+	if r.contains(id) {
+		return
+	}
+	r.order = append(r.order, id)
+	r.seen[id] = struct{}{}
+	if len(r.order) > streamRecentSegments {
+		delete(r.seen, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+// pendingSegments returns the segments of a live playlist that still have to
+// be written. Segments after the last one already written are pending. When
+// the playlist URL changed, for example after a token refresh moved the stream
+// to another rendition, segments are matched by media sequence number
+// instead. Without either match the stream (re)starts close to the live edge.
+func pendingSegments(segments []hlsSegment, written *recentSegments, lastSequence int64) []hlsSegment {
+	lastWritten := -1
+	for i, segment := range segments {
+		if written.contains(hlsSegmentIdentity(segment.URI)) {
+			lastWritten = i
+		}
+	}
+	if lastWritten != -1 {
+		return segments[lastWritten+1:]
+	}
+
+	if len(segments) > 0 && lastSequence >= 0 && lastSequence >= segments[0].Sequence-1 && lastSequence <= segments[len(segments)-1].Sequence {
+		return segments[lastSequence-segments[0].Sequence+1:]
+	}
+
+	start := len(segments) - streamLiveEdgeSegments
+	if start < 0 {
+		start = 0
+	}
+	return segments[start:]
+}
+
+// decryptHLSSegment decrypts an AES-128 segment. Without an explicit IV the
+// media sequence number is used, as the HLS specification requires.
+func decryptHLSSegment(data, key []byte, segment hlsSegment) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("segment size %d is not a multiple of the AES block size", len(data))
+	}
+
+	iv := segment.Key.IV
+	if len(iv) != aes.BlockSize {
+		iv = make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], uint64(segment.Sequence))
+	}
+
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	// Remove PKCS#7 padding
+	if n := len(decrypted); n > 0 {
+		padding := int(decrypted[n-1])
+		if padding > 0 && padding <= aes.BlockSize && padding <= n {
+			decrypted = decrypted[:n-padding]
+		}
+	}
+	return decrypted, nil
+}
+
+// tsStream relays an HLS channel as one continuous MPEG-TS stream
+type tsStream struct {
+	// profile is the account profile the stream plays with. Recordings and
+	// downloads leave it empty and use the default profile.
+	profile     string
+	channelID   string
+	quality     string
+	refreshable bool
+	playlistURL string
+	keys        map[string][]byte
+	keysMu      sync.Mutex
+	written     *recentSegments
+	// lastSequence is the media sequence number of the last segment handled, or -1
+	lastSequence int64
+	// done stops the stream when closed. A nil channel never stops it.
+	done <-chan struct{}
+	// bypassRelay keeps segments out of the shared segment relay. Downloads
+	// read every segment once and would only push live segments out.
+	bypassRelay bool
+	// clientIP is the address of the viewer, counted in the active streams.
+	// Recordings and downloads leave it empty.
+	clientIP string
+}
+
+func newTSStream(channelID, quality string) *tsStream {
+	return &tsStream{
+		channelID:    channelID,
+		quality:      quality,
+		refreshable:  !isCustomChannel(channelID),
+		keys:         make(map[string][]byte),
+		written:      newRecentSegments(),
+		lastSequence: -1,
+	}
+}
+
+// stopped reports whether the stream was stopped through done
+func (s *tsStream) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// hdneaKey returns the hdnea cache key of the stream
+func (s *tsStream) hdneaKey() string {
+	return profileCacheKey(s.profile, hdneaCacheKey(s.channelID, s.playlistURL))
+}
+
+// resolveLiveURL points the stream at a fresh playlist URL from a live result
+func (s *tsStream) resolveLiveURL(liveResult *television.LiveURLOutput) bool {
+	liveURL := selectBestLiveHLSURL(liveResult, s.quality)
+	if liveURL == "" {
+		return false
+	}
+	s.playlistURL = toAbsoluteStreamURL(liveURL, liveResult)
+	if token := extractLiveResultHDNEA(liveResult); token != "" {
+		setCachedHDNEA(s.hdneaKey(), token)
+	}
+	return true
+}
+
+// render fetches a playlist through Television.Render using the hdnea cache the same way RenderHandler does
+func (s *tsStream) render(playlistURL string) ([]byte, int) {
+	hdneaKey := s.hdneaKey()
+	token := getCachedHDNEA(hdneaKey)
+	renderURL := playlistURL
+	if token != "" {
+		renderURL = stripHDNEAFromURL(playlistURL)
+	}
+
+	body, statusCode, newHdnea := tvFor(s.profile).Render(renderURL, token)
+	if newHdnea != "" {
+		setCachedHDNEA(hdneaKey, newHdnea)
+	}
+	return body, statusCode
+}
+
+// loadPlaylist fetches the current media playlist. A master playlist is
+// followed to the variant matching the requested quality, at most
+// streamMaxVariantDepth times. On 401, 403 and 404 the channel token is
+// refreshed and, if needed, a fresh live URL is used.
+func (s *tsStream) loadPlaylist() (hlsPlaylist, error) {
+	for depth := 0; ; depth++ {
+		body, statusCode := s.render(s.playlistURL)
+
+		if (statusCode == fiber.StatusForbidden || statusCode == fiber.StatusUnauthorized || statusCode == fiber.StatusNotFound) && s.refreshable {
+			if statusCode != fiber.StatusNotFound {
+				renderHDNEACache.Delete(s.hdneaKey())
+			}
+			refreshedLiveResult, err := refreshChannelToken(s.profile, s.channelID)
+			if err != nil {
+				return hlsPlaylist{}, err
+			}
+			if token := extractLiveResultHDNEA(refreshedLiveResult); token != "" {
+				setCachedHDNEA(s.hdneaKey(), token)
+			}
+
+			body, statusCode = s.render(s.playlistURL)
+			// The playlist itself is gone, start over from the fresh live URL
+			if statusCode == fiber.StatusNotFound && s.resolveLiveURL(refreshedLiveResult) {
+				body, statusCode = s.render(s.playlistURL)
+			}
+		}
+		if statusCode != fiber.StatusOK {
+			return hlsPlaylist{}, fmt.Errorf("%w: status %d", errStreamPlaylistUnavailable, statusCode)
+		}
+
+		playlist, err := parseHLSPlaylist(body, s.playlistURL)
+		if err != nil || len(playlist.Variants) == 0 {
+			return playlist, err
+		}
+		if depth == streamMaxVariantDepth {
+			return hlsPlaylist{}, fmt.Errorf("%w at %s", errStreamTooManyVariants, s.playlistURL)
+		}
+		s.playlistURL = selectHLSVariant(playlist.Variants, s.quality)
+	}
+}
+
+// get performs an upstream GET for a segment or key and returns the body
+func (s *tsStream) get(requestURL string, setHeaders func(req *fasthttp.Request)) ([]byte, int, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(requestURL)
+	req.Header.SetMethod(fiber.MethodGet)
+	req.Header.Set("User-Agent", PLAYER_USER_AGENT)
+	if token := getCachedHDNEA(s.hdneaKey()); token != "" {
+		req.SetRequestURI(stripHDNEAFromURL(requestURL))
+		req.Header.SetCookie("__hdnea__", token)
+	} else if token := extractHDNEAFromURL(requestURL); token != "" {
+		req.Header.SetCookie("__hdnea__", token)
+	}
+	if setHeaders != nil {
+		setHeaders(req)
+	}
+
+	if err := tvFor(s.profile).Client.Do(req, resp); err != nil {
+		return nil, fasthttp.StatusBadGateway, err
+	}
+	return append([]byte(nil), resp.Body()...), resp.StatusCode(), nil
+}
+
+// fetch downloads a segment or key like RenderTSHandler, refreshing the
+// channel token and retrying once on 401 and 403.
+func (s *tsStream) fetch(requestURL string, setHeaders func(req *fasthttp.Request)) ([]byte, error) {
+	body, statusCode, err := s.get(requestURL, setHeaders)
+	if err != nil {
+		return nil, err
+	}
+	if (statusCode == fiber.StatusForbidden || statusCode == fiber.StatusUnauthorized) && s.refreshable {
+		renderHDNEACache.Delete(s.hdneaKey())
+		if refreshedResult, refreshErr := refreshChannelToken(s.profile, s.channelID); refreshErr == nil && refreshedResult != nil {
+			if refreshedHDNEA := extractLiveResultHDNEA(refreshedResult); refreshedHDNEA != "" {
+				setCachedHDNEA(s.hdneaKey(), refreshedHDNEA)
+			}
+		}
+		body, statusCode, err = s.get(stripHDNEAFromURL(requestURL), setHeaders)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if statusCode != fiber.StatusOK {
+		return nil, fmt.Errorf("upstream returned status %d", statusCode)
+	}
+	return body, nil
+}
+
+// relayedFetch fetches a segment through the shared segment relay so that
+// continuous streams and HLS players of the same channel share upstream fetches
+func (s *tsStream) relayedFetch(segmentURL string) ([]byte, error) {
+	key := segmentRelayKey(segmentURL)
+	if key == "" || segmentRelay.maxBytes == 0 || s.bypassRelay {
+		return s.fetch(segmentURL, nil)
+	}
+	segment, _, err := segmentRelay.do(key, func() (*relayedSegment, error) {
+		body, err := s.fetch(segmentURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &relayedSegment{body: body, contentType: "video/mp2t", statusCode: fiber.StatusOK}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return segment.body, nil
+}
+
+// segmentKey returns the AES-128 key of a segment, requested with the same headers as RenderKeyHandler
+func (s *tsStream) segmentKey(keyURL string) ([]byte, error) {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	if key, ok := s.keys[keyURL]; ok {
+		return key, nil
+	}
+	key, err := s.fetch(keyURL, func(req *fasthttp.Request) {
+		if parsedURL, parseErr := url.Parse(keyURL); parseErr == nil {
+			for name, values := range parsedURL.Query() {
+				if len(values) > 0 {
+					req.Header.SetCookie(name, values[0])
+				}
+			}
+		}
+		tv := tvFor(s.profile)
+		for name, value := range tv.Headers {
+			req.Header.Set(name, value)
+		}
+		req.Header.Set("srno", "230203144000")
+		req.Header.Set("ssotoken", tv.SsoToken)
+		req.Header.Set("channelId", s.channelID)
+		req.Header.Set("User-Agent", PLAYER_USER_AGENT)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid key length %d", len(key))
+	}
+	if len(s.keys) >= streamMaxCachedKeys {
+		// Keys rotate over time, only the recent ones are needed
+		s.keys = make(map[string][]byte)
+	}
+	s.keys[keyURL] = key
+	return key, nil
+}
+
+// segment downloads and, if needed, decrypts a segment, retrying transient failures
+func (s *tsStream) segment(segment hlsSegment) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt < streamSegmentRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		data, err := s.relayedFetch(segment.URI)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if segment.Key == nil {
+			return data, nil
+		}
+		if segment.Key.Method != "AES-128" {
+			return nil, fmt.Errorf("unsupported encryption method %s", segment.Key.Method)
+		}
+		key, err := s.segmentKey(segment.Key.URI)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return decryptHLSSegment(data, key, segment)
+	}
+	return nil, lastErr
+}
+
+// reloadDelay returns how long to wait before reloading the playlist
+func reloadDelay(playlist hlsPlaylist, wroteSegments bool) time.Duration {
+	delay := time.Duration(playlist.TargetDuration * float64(time.Second))
+	if !wroteSegments {
+		// Nothing new yet, check again sooner
+		delay /= 2
+	}
+	if delay < streamMinReloadDelay {
+		delay = streamMinReloadDelay
+	}
+	if delay > streamMaxReloadDelay {
+		delay = streamMaxReloadDelay
+	}
+	return delay
+}
+
+// run writes segments to w until the client disconnects, the playlist ends,
+// the playlist keeps failing or done is closed. The first playlist is passed in so that
+// errors before streaming starts can still be reported with a status code.
+func (s *tsStream) run(w *bufio.Writer, playlist hlsPlaylist) {
+	failures := 0
+	for {
+		pending := pendingSegments(playlist.Segments, s.written, s.lastSequence)
+		for _, segment := range pending {
+			if s.stopped() {
+				return
+			}
+			s.written.add(hlsSegmentIdentity(segment.URI))
+			s.lastSequence = segment.Sequence
+			data, err := s.segment(segment)
+			if err != nil {
+				utils.Log.Printf("Stream %s: skipping segment %d: %v", s.channelID, segment.Sequence, err)
+				continue
+			}
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			if err := w.Flush(); err != nil {
+				// Client disconnected
+				return
+			}
+			if s.clientIP != "" {
+				activeStreams.touch(s.channelID, s.clientIP, time.Now())
+			}
+		}
+		if playlist.Ended {
+			return
+		}
+
+		delay := reloadDelay(playlist, len(pending) > 0)
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-time.After(delay):
+			}
+			reloaded, err := s.loadPlaylist()
+			if err == nil {
+				playlist = reloaded
+				failures = 0
+				break
+			}
+			failures++
+			if failures >= streamMaxFailures {
+				utils.Log.Printf("Stream %s: giving up after %d playlist failures: %v", s.channelID, failures, err)
+				return
+			}
+			utils.Log.Printf("Stream %s: playlist reload failed (%d/%d): %v", s.channelID, failures, streamMaxFailures, err)
+			delay = time.Duration(failures) * streamMinReloadDelay
+		}
+	}
+}
+
+// connect points the stream at the current playlist URL of its channel,
+// requesting a fresh live URL through Television.Live for JioTV channels
+func (s *tsStream) connect() error {
+	if isCustomChannel(s.channelID) {
+		channel, exists := television.GetCustomChannelByID(s.channelID)
+		if !exists {
+			return fmt.Errorf("%w: custom channel with ID %s not found", errStreamNotFound, s.channelID)
+		}
+		s.playlistURL = channel.URL
+		return nil
+	}
+
+	if err := EnsureFreshTokensFor(s.profile); err != nil {
+		utils.Log.Printf("Failed to ensure fresh tokens: %v", err)
+		// Continue with the request - tokens might still work
+	}
+	liveResult, err := liveFor(tvFor(s.profile), s.channelID)
+	if err != nil {
+		return err
+	}
+	if !s.resolveLiveURL(liveResult) {
+		return fmt.Errorf("%w for channel id: %s Status: %s", errStreamNotFound, s.channelID, liveResult.Message)
+	}
+	return nil
+}
+
+// StreamHandler handles the continuous MPEG-TS route `/stream/:id.ts`.
+// It follows the live HLS playlist of the channel and writes every segment
+// back to back into one long-lived chunked response for clients such as
+// tvheadend and ffmpeg based DVRs. The quality can be chosen with `?q=`.
+func StreamHandler(c *fiber.Ctx) error {
+	id := strings.TrimSuffix(c.Params("id"), ".ts")
+	quality := c.Query("q")
+	if quality == "" {
+		quality = "auto"
+	}
+
+	stream := newTSStream(id, quality)
+	stream.profile = channelProfile(c, id)
+	stream.clientIP = c.IP()
+	if err := stream.connect(); err != nil {
+		utils.Log.Println(err)
+		if errors.Is(err, errStreamNotFound) {
+			return internalUtils.NotFoundError(c, err.Error())
+		}
+		return internalUtils.InternalServerError(c, err)
+	}
+
+	playlist, err := stream.loadPlaylist()
+	if err != nil {
+		utils.Log.Printf("Stream %s: %v", id, err)
+		return internalUtils.ErrorResponse(c, fiber.StatusBadGateway, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "video/mp2t")
+	c.Set(fiber.HeaderCacheControl, "no-cache, no-store")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		stream.run(w, playlist)
+	})
+	return nil
+}
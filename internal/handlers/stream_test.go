@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseHLSAttributes(t *testing.T) {
+	got := parseHLSAttributes(`METHOD=AES-128,URI="https://example.com/a,b.key",IV=0x0000000000000000000000000000000A`)
+	want := map[string]string{
+		"METHOD": "AES-128",
+		"URI":    "https://example.com/a,b.key",
+		"IV":     "0x0000000000000000000000000000000A",
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("parseHLSAttributes()[%s] = %q, want %q", name, got[name], value)
+		}
+	}
+}
+
+func TestParseHLSPlaylist(t *testing.T) {
+	t.Run("Master playlist", func(t *testing.T) {
+		body := []byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=3000000\nhttps://cdn.example.com/high/index.m3u8\n")
+		playlist, err := parseHLSPlaylist(body, "https://cdn.example.com/live/master.m3u8?minrate=1&hdnea=st=1~exp=2")
+		if err != nil {
+			t.Fatalf("parseHLSPlaylist() error = %v", err)
+		}
+		if len(playlist.Variants) != 2 || len(playlist.Segments) != 0 {
+			t.Fatalf("parseHLSPlaylist() = %d variants, %d segments, want 2 variants", len(playlist.Variants), len(playlist.Segments))
+		}
+		if got, want := playlist.Variants[0].URI, "https://cdn.example.com/live/low/index.m3u8?minrate=1"; got != want {
+			t.Errorf("relative variant URI = %q, want %q", got, want)
+		}
+		if got, want := playlist.Variants[1].URI, "https://cdn.example.com/high/index.m3u8"; got != want {
+			t.Errorf("absolute variant URI = %q, want %q", got, want)
+		}
+		if playlist.Variants[1].Bandwidth != 3000000 {
+			t.Errorf("Bandwidth = %d, want 3000000", playlist.Variants[1].Bandwidth)
+		}
+	})
+
+	t.Run("Media playlist", func(t *testing.T) {
+		body := []byte(strings.Join([]string{
+			"#EXTM3U",
+			"#EXT-X-TARGETDURATION:6",
+			"#EXT-X-MEDIA-SEQUENCE:100",
+			"#EXTINF:6.000,",
+			"seg100.ts",
+			`#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example.com/k1.key",IV=0x000102030405060708090A0B0C0D0E0F`,
+			"#EXTINF:5.5,",
+			"seg101.ts?v=1",
+			"#EXT-X-ENDLIST",
+		}, "\n"))
+		playlist, err := parseHLSPlaylist(body, "https://cdn.example.com/live/index.m3u8")
+		if err != nil {
+			t.Fatalf("parseHLSPlaylist() error = %v", err)
+		}
+		if !playlist.Ended || playlist.TargetDuration != 6 {
+			t.Errorf("Ended = %v, TargetDuration = %v", playlist.Ended, playlist.TargetDuration)
+		}
+		if len(playlist.Segments) != 2 {
+			t.Fatalf("parseHLSPlaylist() returned %d segments, want 2", len(playlist.Segments))
+		}
+		first, second := playlist.Segments[0], playlist.Segments[1]
+		if first.Sequence != 100 || first.Key != nil || first.URI != "https://cdn.example.com/live/seg100.ts" {
+			t.Errorf("first segment = %+v", first)
+		}
+		if second.Sequence != 101 || second.Duration != 5.5 || second.URI != "https://cdn.example.com/live/seg101.ts?v=1" {
+			t.Errorf("second segment = %+v", second)
+		}
+		if second.Key == nil || second.Key.URI != "https://keys.example.com/k1.key" || len(second.Key.IV) != 16 || second.Key.IV[15] != 0x0F {
+			t.Errorf("second segment key = %+v", second.Key)
+		}
+	})
+
+	t.Run("Not a playlist", func(t *testing.T) {
+		if _, err := parseHLSPlaylist([]byte("<html></html>"), "https://cdn.example.com/index.m3u8"); err == nil {
+			t.Error("parseHLSPlaylist() expected an error for non-HLS content")
+		}
+	})
+}
+
+func TestSelectHLSVariant(t *testing.T) {
+	variants := []hlsVariant{
+		{URI: "mid", Bandwidth: 1500000},
+		{URI: "high", Bandwidth: 3000000},
+		{URI: "low", Bandwidth: 400000},
+	}
+	tests := []struct {
+		quality string
+		want    string
+	}{
+		{quality: "auto", want: "high"},
+		{quality: "high", want: "high"},
+		{quality: "m", want: "mid"},
+		{quality: "low", want: "low"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.quality, func(t *testing.T) {
+			if got := selectHLSVariant(variants, tt.quality); got != tt.want {
+				t.Errorf("selectHLSVariant(%q) = %q, want %q", tt.quality, got, tt.want)
+			}
+		})
+	}
+	if got := selectHLSVariant(nil, "auto"); got != "" {
+		t.Errorf("selectHLSVariant(nil) = %q, want empty", got)
+	}
+}
+
+func segmentsFrom(base string, first int64, count int) []hlsSegment {
+	segments := make([]hlsSegment, 0, count)
+	for i := 0; i < count; i++ {
+		sequence := first + int64(i)
+		segments = append(segments, hlsSegment{
+			Sequence: sequence,
+			URI:      base + "/seg" + strconv.FormatInt(sequence, 10) + ".ts?token=" + base,
+		})
+	}
+	return segments
+}
+
+func TestPendingSegments(t *testing.T) {
+	segments := segmentsFrom("https://a", 10, 6)
+
+	t.Run("New stream starts at the live edge", func(t *testing.T) {
+		got := pendingSegments(segments, newRecentSegments(), -1)
+		if len(got) != streamLiveEdgeSegments || got[0].Sequence != 13 {
+			t.Errorf("pendingSegments() = %d segments from %d", len(got), got[0].Sequence)
+		}
+	})
+
+	t.Run("Continues after the last written segment", func(t *testing.T) {
+		written := newRecentSegments()
+		written.add(hlsSegmentIdentity(segments[3].URI))
+		got := pendingSegments(segments, written, 13)
+		if len(got) != 2 || got[0].Sequence != 14 {
+			t.Errorf("pendingSegments() = %+v", got)
+		}
+	})
+
+	t.Run("Nothing new", func(t *testing.T) {
+		written := newRecentSegments()
+		written.add(hlsSegmentIdentity(segments[5].URI))
+		if got := pendingSegments(segments, written, 15); len(got) != 0 {
+			t.Errorf("pendingSegments() = %d segments, want 0", len(got))
+		}
+	})
+
+	t.Run("Rendition change falls back to sequence numbers", func(t *testing.T) {
+		written := newRecentSegments()
+		written.add(hlsSegmentIdentity(segments[1].URI))
+		other := segmentsFrom("https://b", 10, 6)
+		got := pendingSegments(other, written, 11)
+		if len(got) != 4 || got[0].Sequence != 12 {
+			t.Errorf("pendingSegments() = %d segments", len(got))
+		}
+	})
+
+	t.Run("Media sequence starting at zero", func(t *testing.T) {
+		fromZero := segmentsFrom("https://c", 0, 5)
+		got := pendingSegments(fromZero, newRecentSegments(), -1)
+		if len(got) != streamLiveEdgeSegments {
+			t.Errorf("pendingSegments() = %d segments, want %d", len(got), streamLiveEdgeSegments)
+		}
+	})
+}
+
+func TestRecentSegments(t *testing.T) {
+	recent := newRecentSegments()
+	for i := 0; i < streamRecentSegments+1; i++ {
+		recent.add(strings.Repeat("s", i+1))
+	}
+	if recent.contains("s") {
+		t.Error("oldest segment should have been forgotten")
+	}
+	if !recent.contains(strings.Repeat("s", streamRecentSegments+1)) {
+		t.Error("newest segment should be remembered")
+	}
+}
+
+func encryptTestSegment(t *testing.T, plain, key, iv []byte) []byte {
+	t.Helper()
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte(nil), plain...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+	return encrypted
+}
+
+func TestDecryptHLSSegment(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	plain := []byte("mpeg-ts payload that is not block aligned")
+
+	t.Run("Explicit IV", func(t *testing.T) {
+		iv := []byte("fedcba9876543210")
+		segment := hlsSegment{Sequence: 7, Key: &hlsKey{Method: "AES-128", IV: iv}}
+		got, err := decryptHLSSegment(encryptTestSegment(t, plain, key, iv), key, segment)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("decryptHLSSegment() = %q, %v", got, err)
+		}
+	})
+
+	t.Run("IV from media sequence", func(t *testing.T) {
+		iv := make([]byte, aes.BlockSize)
+		iv[15] = 7
+		segment := hlsSegment{Sequence: 7, Key: &hlsKey{Method: "AES-128"}}
+		got, err := decryptHLSSegment(encryptTestSegment(t, plain, key, iv), key, segment)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("decryptHLSSegment() = %q, %v", got, err)
+		}
+	})
+
+	t.Run("Truncated segment", func(t *testing.T) {
+		segment := hlsSegment{Key: &hlsKey{Method: "AES-128"}}
+		if _, err := decryptHLSSegment([]byte("short"), key, segment); err == nil {
+			t.Error("decryptHLSSegment() expected an error for a truncated segment")
+		}
+	})
+}
+
+func TestReloadDelay(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        float64
+		wroteSegments bool
+		want          time.Duration
+	}{
+		{name: "Target duration after new segments", target: 6, wroteSegments: true, want: 6 * time.Second},
+		{name: "Half target duration when nothing is new", target: 6, wroteSegments: false, want: 3 * time.Second},
+		{name: "Minimum delay", target: 0, wroteSegments: true, want: streamMinReloadDelay},
+		{name: "Maximum delay", target: 60, wroteSegments: true, want: streamMaxReloadDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reloadDelay(hlsPlaylist{TargetDuration: tt.target}, tt.wroteSegments); got != tt.want {
+				t.Errorf("reloadDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTSStreamRun(t *testing.T) {
+	previousTV := TV
+	previousLog := utils.Log
+	TV = &television.Television{Client: &fasthttp.Client{}, Headers: map[string]string{}}
+	utils.Log = log.New(os.Stderr, "", 0)
+	defer func() {
+		TV = previousTV
+		utils.Log = previousLog
+	}()
+
+	key := []byte("0123456789abcdef")
+	iv := make([]byte, aes.BlockSize)
+	iv[15] = 2
+	encrypted := encryptTestSegment(t, []byte("segment-2"), key, iv)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=100\nmedia.m3u8\n"))
+		case "/media.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:1\n#EXTINF:1,\nseg1.ts\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.key\"\n#EXTINF:1,\nseg2.ts\n#EXTINF:1,\nmissing.ts\n#EXT-X-KEY:METHOD=NONE\n#EXTINF:1,\nseg4.ts\n#EXT-X-ENDLIST\n"))
+		case "/seg1.ts":
+			w.Write([]byte("segment-1|"))
+		case "/seg2.ts":
+			w.Write(encrypted)
+		case "/seg4.ts":
+			w.Write([]byte("|segment-4"))
+		case "/key.key":
+			w.Write(key)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	stream := &tsStream{
+		channelID:    "custom_test",
+		quality:      "auto",
+		playlistURL:  server.URL + "/master.m3u8",
+		keys:         make(map[string][]byte),
+		written:      newRecentSegments(),
+		lastSequence: -1,
+	}
+	playlist, err := stream.loadPlaylist()
+	if err != nil {
+		t.Fatalf("loadPlaylist() error = %v", err)
+	}
+	// Start from the beginning of the playlist instead of the live edge
+	stream.lastSequence = 0
+
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	stream.run(w, playlist)
+
+	if got, want := out.String(), "segment-1|segment-2|segment-4"; got != want {
+		t.Errorf("stream output = %q, want %q", got, want)
+	}
+}
+
+func TestTSStreamLoadPlaylistVariantLoop(t *testing.T) {
+	previousTV := TV
+	TV = &television.Television{Client: &fasthttp.Client{}, Headers: map[string]string{}}
+	defer func() { TV = previousTV }()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=100\nmaster.m3u8\n"))
+	}))
+	defer server.Close()
+
+	stream := newTSStream("custom_test", "auto")
+	stream.playlistURL = server.URL + "/master.m3u8"
+	if _, err := stream.loadPlaylist(); !errors.Is(err, errStreamTooManyVariants) {
+		t.Fatalf("loadPlaylist() error = %v, want %v", err, errStreamTooManyVariants)
+	}
+	if want := streamMaxVariantDepth + 1; requests != want {
+		t.Errorf("loadPlaylist() fetched %d playlists, want %d", requests, want)
+	}
+}
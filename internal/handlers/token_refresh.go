@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/constants/tasks"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/scheduler"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// tokenRefreshCheckInterval is how often the background token refresh
+	// looks for profiles that are due
+	tokenRefreshCheckInterval = time.Minute
+	// backgroundTokenRefreshLeadTime is how long before expiry the background
+	// refresh renews a token, well ahead of the lead time of requests
+	backgroundTokenRefreshLeadTime = 5 * time.Minute
+	// tokenRefreshMinBackoff and tokenRefreshMaxBackoff bound the exponential
+	// backoff after failed background refreshes
+	tokenRefreshMinBackoff = 30 * time.Second
+	tokenRefreshMaxBackoff = 30 * time.Minute
+
+	tokenRefreshOutcomeRefreshed = "refreshed"
+	tokenRefreshOutcomeFresh     = "fresh"
+	tokenRefreshOutcomeFailed    = "failed"
+)
+
+var (
+	// tokenRefreshStatuses holds the background token refresh status by profile
+	tokenRefreshStatuses   = make(map[string]*TokenRefreshStatus)
+	tokenRefreshStatusesMu sync.Mutex
+
+	// refreshProfileAccessToken and refreshProfileSSOToken renew the tokens of
+	// a profile. Tests replace them to avoid calling JioTV.
+	refreshProfileAccessToken = LoginRefreshAccessTokenFor
+	refreshProfileSSOToken    = LoginRefreshSSOTokenFor
+)
+
+// InitTokenRefresh schedules the background token refresh, so that the
+// first request after an idle period does not wait for expired tokens to be
+// renewed
+func InitTokenRefresh() {
+	go func() {
+		if err := checkTokenRefreshes(time.Now()); err != nil {
+			utils.Log.Printf("Background token refresh failed: %v", err)
+		}
+	}()
+	scheduler.Add(tasks.RefreshTokenTaskID, tokenRefreshCheckInterval, func() error {
+		return checkTokenRefreshes(time.Now())
+	})
+}
+
+// checkTokenRefreshes refreshes the tokens of every profile that is due
+func checkTokenRefreshes(now time.Time) error {
+	profiles := utils.ListProfiles()
+
+	// Forget profiles that logged out
+	tokenRefreshStatusesMu.Lock()
+	for profile := range tokenRefreshStatuses {
+		if !slices.Contains(profiles, profile) {
+			delete(tokenRefreshStatuses, profile)
+		}
+	}
+	tokenRefreshStatusesMu.Unlock()
+
+	var errs []error
+	for _, profile := range profiles {
+		tokenRefreshStatusesMu.Lock()
+		status, ok := tokenRefreshStatuses[profile]
+		if !ok {
+			status = &TokenRefreshStatus{Profile: profile}
+			tokenRefreshStatuses[profile] = status
+		}
+		due := !now.Before(status.NextRefresh)
+		tokenRefreshStatusesMu.Unlock()
+		if !due {
+			continue
+		}
+
+		outcome, credentials, err := refreshProfileTokens(profile, now)
+
+		tokenRefreshStatusesMu.Lock()
+		recordTokenRefresh(status, outcome, credentials, err, now)
+		tokenRefreshStatusesMu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("profile %s: %w", profile, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tokensDueForRefresh reports which tokens the background refresh renews
+// now: those that expire within backgroundTokenRefreshLeadTime and can be
+// refreshed
+func tokensDueForRefresh(credentials *utils.JIOTV_CREDENTIALS, now time.Time) (accessToken, ssoToken bool) {
+	accessToken = credentials.AccessToken != "" && credentials.RefreshToken != "" && shouldRefreshToken(
+		credentials.AccessToken,
+		credentials.LastTokenRefreshTime,
+		backgroundTokenRefreshLeadTime,
+		accessTokenFallbackTTL,
+		accessTokenFallbackLeadTime,
+		now,
+	)
+	ssoToken = credentials.SSOToken != "" && credentials.UniqueID != "" && shouldRefreshToken(
+		credentials.SSOToken,
+		credentials.LastSSOTokenRefreshTime,
+		backgroundTokenRefreshLeadTime,
+		ssoTokenFallbackTTL,
+		ssoTokenFallbackLeadTime,
+		now,
+	)
+	return accessToken, ssoToken
+}
+
+// refreshProfileTokens renews the tokens of a profile that expire within
+// backgroundTokenRefreshLeadTime. It returns the outcome and the credentials
+// after the refresh.
+func refreshProfileTokens(profile string, now time.Time) (string, *utils.JIOTV_CREDENTIALS, error) {
+	mutex := tokenRefreshMutex(profile)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
+	if err != nil {
+		return tokenRefreshOutcomeFailed, nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	if credentials == nil {
+		return tokenRefreshOutcomeFailed, nil, fmt.Errorf("failed to get credentials: credentials are incomplete")
+	}
+
+	refreshAccessToken, refreshSSOToken := tokensDueForRefresh(credentials, now)
+	if !refreshAccessToken && !refreshSSOToken {
+		return tokenRefreshOutcomeFresh, credentials, nil
+	}
+
+	if refreshAccessToken {
+		err := refreshProfileAccessToken(profile)
+		countTokenRefresh("access", "background", err)
+		if err != nil {
+			return tokenRefreshOutcomeFailed, credentials, fmt.Errorf("access token refresh failed: %w", err)
+		}
+	}
+	if refreshSSOToken {
+		err := refreshProfileSSOToken(profile)
+		countTokenRefresh("sso", "background", err)
+		if err != nil {
+			return tokenRefreshOutcomeFailed, credentials, fmt.Errorf("SSO token refresh failed: %w", err)
+		}
+	}
+
+	freshCredentials, err := utils.GetJIOTVCredentialsFor(profile)
+	if err != nil || freshCredentials == nil {
+		return tokenRefreshOutcomeFailed, credentials, fmt.Errorf("failed to get fresh credentials: %v", err)
+	}
+	setTV(profile, television.NewForProfile(profile, freshCredentials))
+	utils.Log.Printf("Refreshed tokens of profile %s in the background", profile)
+	return tokenRefreshOutcomeRefreshed, freshCredentials, nil
+}
+
+// recordTokenRefresh records the outcome of a background refresh and plans
+// the next one: shortly before the earliest token expiry after a success, and
+// with exponential backoff after a failure
+func recordTokenRefresh(status *TokenRefreshStatus, outcome string, credentials *utils.JIOTV_CREDENTIALS, err error, now time.Time) {
+	status.LastRun = now
+	status.LastOutcome = outcome
+	if credentials != nil {
+		status.AccessTokenExpiry, _ = parseJWTExpiry(credentials.AccessToken)
+		status.SSOTokenExpiry, _ = parseJWTExpiry(credentials.SSOToken)
+	}
+
+	if err != nil {
+		status.Failures++
+		status.LastError = err.Error()
+		status.LastErrorAt = now
+		status.NextRefresh = now.Add(tokenRefreshBackoff(status.Failures))
+		return
+	}
+
+	status.Failures = 0
+	status.LastSuccess = now
+	status.NextRefresh = nextBackgroundTokenRefresh(credentials, now)
+}
+
+// tokenRefreshBackoff returns the delay before retrying after failures
+// consecutive failed refreshes
+func tokenRefreshBackoff(failures int) time.Duration {
+	backoff := tokenRefreshMinBackoff
+	for i := 1; i < failures && backoff < tokenRefreshMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, tokenRefreshMaxBackoff)
+}
+
+// nextBackgroundTokenRefresh returns when the earliest token of the
+// credentials is due for a background refresh
+func nextBackgroundTokenRefresh(credentials *utils.JIOTV_CREDENTIALS, now time.Time) time.Time {
+	next := now.Add(tokenRefreshMaxBackoff)
+	if credentials == nil {
+		return next
+	}
+	if check, ok := nextTokenValidationCheck(
+		credentials.AccessToken,
+		credentials.LastTokenRefreshTime,
+		backgroundTokenRefreshLeadTime,
+		accessTokenFallbackTTL,
+		accessTokenFallbackLeadTime,
+		now,
+	); ok && check.Before(next) {
+		next = check
+	}
+	if check, ok := nextTokenValidationCheck(
+		credentials.SSOToken,
+		credentials.LastSSOTokenRefreshTime,
+		backgroundTokenRefreshLeadTime,
+		ssoTokenFallbackTTL,
+		ssoTokenFallbackLeadTime,
+		now,
+	); ok && check.Before(next) {
+		next = check
+	}
+	return next
+}
+
+// TokenRefreshStatuses returns the background token refresh status of every
+// profile that was checked, sorted by profile
+func TokenRefreshStatuses() []TokenRefreshStatus {
+	tokenRefreshStatusesMu.Lock()
+	defer tokenRefreshStatusesMu.Unlock()
+
+	statuses := make([]TokenRefreshStatus, 0, len(tokenRefreshStatuses))
+	for _, status := range tokenRefreshStatuses {
+		statuses = append(statuses, *status)
+	}
+	slices.SortFunc(statuses, func(a, b TokenRefreshStatus) int {
+		return strings.Compare(a.Profile, b.Profile)
+	})
+	return statuses
+}
+
+// TokenRefreshStatusHandler reports the background token refresh status of
+// every profile: `GET /api/token/status`
+func TokenRefreshStatusHandler(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"check_interval": tokenRefreshCheckInterval.String(),
+		"profiles":       TokenRefreshStatuses(),
+	})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func TestTokenRefreshBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 30 * time.Second},
+		{failures: 2, want: time.Minute},
+		{failures: 4, want: 4 * time.Minute},
+		{failures: 7, want: 30 * time.Minute},
+		{failures: 100, want: 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := tokenRefreshBackoff(tt.failures); got != tt.want {
+			t.Errorf("tokenRefreshBackoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTokenRefreshes(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	previousLog, previousAccess, previousSSO, previousTV := utils.Log, refreshProfileAccessToken, refreshProfileSSOToken, TV
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log, refreshProfileAccessToken, refreshProfileSSOToken, TV = previousLog, previousAccess, previousSSO, previousTV
+		tokenRefreshStatusesMu.Lock()
+		tokenRefreshStatuses = make(map[string]*TokenRefreshStatus)
+		tokenRefreshStatusesMu.Unlock()
+	})
+
+	now := time.Now()
+	ssoToken := buildUnsignedJWT(now.Add(12 * time.Hour).Unix())
+	if err := utils.WriteJIOTVCredentials(&utils.JIOTV_CREDENTIALS{
+		SSOToken:     ssoToken,
+		UniqueID:     "unique",
+		CRM:          "crm",
+		AccessToken:  buildUnsignedJWT(now.Add(2 * time.Minute).Unix()),
+		RefreshToken: "refresh",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var accessRefreshes, ssoRefreshes int
+	refreshError := errors.New("refresh token rejected")
+	refreshProfileSSOToken = func(string) error {
+		ssoRefreshes++
+		return nil
+	}
+
+	// The access token expires within the lead time, and the refresh fails
+	refreshProfileAccessToken = func(string) error {
+		accessRefreshes++
+		return refreshError
+	}
+	if err := checkTokenRefreshes(now); !errors.Is(err, refreshError) {
+		t.Fatalf("checkTokenRefreshes() error = %v, want %v", err, refreshError)
+	}
+	status := TokenRefreshStatuses()[0]
+	if status.Failures != 1 || status.LastOutcome != tokenRefreshOutcomeFailed || status.LastError == "" {
+		t.Errorf("status after failure = %+v", status)
+	}
+	if want := now.Add(tokenRefreshMinBackoff); !status.NextRefresh.Equal(want) {
+		t.Errorf("NextRefresh = %v, want %v", status.NextRefresh, want)
+	}
+
+	// Nothing happens until the backoff passed
+	if err := checkTokenRefreshes(now.Add(10 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if accessRefreshes != 1 {
+		t.Errorf("refreshed %d times during backoff, want 1", accessRefreshes)
+	}
+
+	// The second failure doubles the backoff
+	later := now.Add(tokenRefreshMinBackoff)
+	_ = checkTokenRefreshes(later)
+	if status := TokenRefreshStatuses()[0]; status.Failures != 2 || !status.NextRefresh.Equal(later.Add(2*tokenRefreshMinBackoff)) {
+		t.Errorf("status after second failure = %+v", status)
+	}
+
+	// A success resets the failures and plans the refresh before the access token expires
+	freshAccessToken := buildUnsignedJWT(now.Add(20 * time.Minute).Unix())
+	refreshProfileAccessToken = func(string) error {
+		accessRefreshes++
+		return utils.WriteJIOTVCredentials(&utils.JIOTV_CREDENTIALS{
+			SSOToken:     ssoToken,
+			UniqueID:     "unique",
+			CRM:          "crm",
+			AccessToken:  freshAccessToken,
+			RefreshToken: "refresh",
+		})
+	}
+	latest := later.Add(2 * tokenRefreshMinBackoff)
+	if err := checkTokenRefreshes(latest); err != nil {
+		t.Fatal(err)
+	}
+	status = TokenRefreshStatuses()[0]
+	if status.Failures != 0 || status.LastOutcome != tokenRefreshOutcomeRefreshed || !status.LastSuccess.Equal(latest) {
+		t.Errorf("status after success = %+v", status)
+	}
+	accessExpiry, _ := parseJWTExpiry(freshAccessToken)
+	if want := accessExpiry.Add(-backgroundTokenRefreshLeadTime); !status.NextRefresh.Equal(want) {
+		t.Errorf("NextRefresh = %v, want %v", status.NextRefresh, want)
+	}
+	if !status.AccessTokenExpiry.Equal(accessExpiry) {
+		t.Errorf("AccessTokenExpiry = %v, want %v", status.AccessTokenExpiry, accessExpiry)
+	}
+	if ssoRefreshes != 0 {
+		t.Errorf("refreshed the SSO token %d times, want 0", ssoRefreshes)
+	}
+}
@@ -1,5 +1,15 @@
 package handlers
 
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/epg"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/scheduler"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+)
+
 // LoginSendOTPRequestBodyData represents Request body for OTP based login request
 type LoginSendOTPRequestBodyData struct {
 	// Mobile number of Jio account
@@ -12,6 +22,8 @@ type LoginVerifyOTPRequestBodyData struct {
 	MobileNumber string `json:"number" xml:"number" form:"number"`
 	// OTP received on mobile number
 	OTP string `json:"otp" xml:"otp" form:"otp"`
+	// Profile to store the credentials in, empty for the default profile
+	Profile string `json:"profile" xml:"profile" form:"profile"`
 }
 
 // RefreshTokenResponse represents Response body for refresh token request
@@ -33,3 +45,366 @@ type DrmMpdOutput struct {
 	Tv_url_host string
 	Tv_url_path string
 }
+
+// XtreamUserInfo represents the account block of an Xtream Codes player_api.php response
+type XtreamUserInfo struct {
+	Username             string   `json:"username"`
+	Password             string   `json:"password"`
+	Message              string   `json:"message"`
+	Auth                 int      `json:"auth"`
+	Status               string   `json:"status"`
+	ExpDate              *string  `json:"exp_date"`
+	IsTrial              string   `json:"is_trial"`
+	ActiveCons           string   `json:"active_cons"`
+	CreatedAt            string   `json:"created_at"`
+	MaxConnections       string   `json:"max_connections"`
+	AllowedOutputFormats []string `json:"allowed_output_formats"`
+}
+
+// XtreamServerInfo represents the server block of an Xtream Codes player_api.php response
+type XtreamServerInfo struct {
+	URL            string `json:"url"`
+	Port           string `json:"port"`
+	HTTPSPort      string `json:"https_port"`
+	ServerProtocol string `json:"server_protocol"`
+	RTMPPort       string `json:"rtmp_port"`
+	Timezone       string `json:"timezone"`
+	TimestampNow   int64  `json:"timestamp_now"`
+	TimeNow        string `json:"time_now"`
+}
+
+// XtreamAccountResponse is returned by player_api.php when no action is given
+type XtreamAccountResponse struct {
+	UserInfo   XtreamUserInfo   `json:"user_info"`
+	ServerInfo XtreamServerInfo `json:"server_info"`
+}
+
+// XtreamCategory represents a live category in the Xtream Codes API
+type XtreamCategory struct {
+	CategoryID   string `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	ParentID     int    `json:"parent_id"`
+}
+
+// XtreamLiveStream represents a live channel in the Xtream Codes API
+type XtreamLiveStream struct {
+	Num               int         `json:"num"`
+	Name              string      `json:"name"`
+	StreamType        string      `json:"stream_type"`
+	StreamID          interface{} `json:"stream_id"` // numeric for JioTV channels, string for custom channels
+	StreamIcon        string      `json:"stream_icon"`
+	EPGChannelID      string      `json:"epg_channel_id"`
+	Added             string      `json:"added"`
+	CategoryID        string      `json:"category_id"`
+	CustomSID         string      `json:"custom_sid"`
+	TVArchive         int         `json:"tv_archive"`
+	DirectSource      string      `json:"direct_source"`
+	TVArchiveDuration int         `json:"tv_archive_duration"`
+}
+
+// XtreamEPGListing represents a programme in get_short_epg and get_simple_data_table responses.
+// Title and Description are base64 encoded as Xtream Codes clients expect.
+type XtreamEPGListing struct {
+	ID             string `json:"id"`
+	EPGID          string `json:"epg_id"`
+	Title          string `json:"title"`
+	Lang           string `json:"lang"`
+	Start          string `json:"start"`
+	End            string `json:"end"`
+	Description    string `json:"description"`
+	ChannelID      string `json:"channel_id"`
+	StartTimestamp string `json:"start_timestamp"`
+	StopTimestamp  string `json:"stop_timestamp"`
+	NowPlaying     int    `json:"now_playing"`
+	HasArchive     int    `json:"has_archive"`
+}
+
+// HDHomeRunDiscovery represents the discover.json response of an HDHomeRun tuner
+type HDHomeRunDiscovery struct {
+	FriendlyName    string `json:"FriendlyName"`
+	Manufacturer    string `json:"Manufacturer"`
+	ModelNumber     string `json:"ModelNumber"`
+	FirmwareName    string `json:"FirmwareName"`
+	FirmwareVersion string `json:"FirmwareVersion"`
+	DeviceID        string `json:"DeviceID"`
+	DeviceAuth      string `json:"DeviceAuth"`
+	TunerCount      int    `json:"TunerCount"`
+	BaseURL         string `json:"BaseURL"`
+	LineupURL       string `json:"LineupURL"`
+}
+
+// HDHomeRunLineupItem represents a channel in the lineup.json response of an HDHomeRun tuner
+type HDHomeRunLineupItem struct {
+	GuideNumber string `json:"GuideNumber"`
+	GuideName   string `json:"GuideName"`
+	URL         string `json:"URL"`
+	HD          int    `json:"HD,omitempty"`
+}
+
+// HDHomeRunLineupStatus represents the lineup_status.json response of an HDHomeRun tuner
+type HDHomeRunLineupStatus struct {
+	ScanInProgress int      `json:"ScanInProgress"`
+	ScanPossible   int      `json:"ScanPossible"`
+	Source         string   `json:"Source"`
+	SourceList     []string `json:"SourceList"`
+}
+
+// HDHomeRunDeviceXML represents the UPnP device description served at device.xml
+type HDHomeRunDeviceXML struct {
+	XMLName     xml.Name              `xml:"root"`
+	Xmlns       string                `xml:"xmlns,attr"`
+	URLBase     string                `xml:"URLBase"`
+	SpecVersion HDHomeRunSpecVersion  `xml:"specVersion"`
+	Device      HDHomeRunDeviceDetail `xml:"device"`
+}
+
+// HDHomeRunSpecVersion represents the UPnP spec version of device.xml
+type HDHomeRunSpecVersion struct {
+	Major int `xml:"major"`
+	Minor int `xml:"minor"`
+}
+
+// HDHomeRunDeviceDetail represents the device block of device.xml
+type HDHomeRunDeviceDetail struct {
+	DeviceType   string `xml:"deviceType"`
+	FriendlyName string `xml:"friendlyName"`
+	Manufacturer string `xml:"manufacturer"`
+	ModelName    string `xml:"modelName"`
+	ModelNumber  string `xml:"modelNumber"`
+	SerialNumber string `xml:"serialNumber"`
+	UDN          string `xml:"UDN"`
+}
+
+// RecordingStatus is the state of a scheduled recording
+type RecordingStatus string
+
+// RecordingSource is where a recording is taken from
+type RecordingSource string
+
+// Recording is a scheduled, running or finished recording of a channel
+type Recording struct {
+	ID        string          `json:"id"`
+	ChannelID string          `json:"channel_id"`
+	Title     string          `json:"title"`
+	Quality   string          `json:"quality"`
+	Start     time.Time       `json:"start"`
+	Stop      time.Time       `json:"stop"`
+	Status    RecordingStatus `json:"status"`
+	// Source is "live" for recordings of the live stream and "catchup" for
+	// programmes downloaded from catchup after they aired
+	Source RecordingSource `json:"source,omitempty"`
+	// Srno is the EPG serial number of the recorded programme, if known
+	Srno string `json:"srno,omitempty"`
+	// RuleID is the recording rule that scheduled the recording, if any
+	RuleID string `json:"rule_id,omitempty"`
+	// File is the path of the recorded MPEG-TS file
+	File      string    `json:"file"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecordingRequest represents Request body for creating a recording.
+// Either Start and Stop or Programme must be set. Times are RFC 3339,
+// "2006-01-02 15:04" or "15:04" in IST, or unix seconds. Programme is a time
+// during the EPG programme to record, "now" or "next".
+type RecordingRequest struct {
+	ChannelID string `json:"channel_id" form:"channel_id"`
+	Title     string `json:"title" form:"title"`
+	Quality   string `json:"quality" form:"quality"`
+	Start     string `json:"start" form:"start"`
+	Stop      string `json:"stop" form:"stop"`
+	Programme string `json:"programme" form:"programme"`
+}
+
+// RecordingRule schedules a recording of every EPG programme it matches
+type RecordingRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name" form:"name"`
+	// Title and Category match case-insensitively as substrings, or as regular expressions when Regex is set
+	Title    string `json:"title,omitempty" form:"title"`
+	Category string `json:"category,omitempty" form:"category"`
+	Regex    bool   `json:"regex,omitempty" form:"regex"`
+	// ChannelIDs limits the rule to these channels. Empty matches every channel.
+	ChannelIDs []string `json:"channel_ids,omitempty" form:"channel_ids"`
+	// ChannelCategories and Languages limit the rule to the channels of these
+	// categories and languages, such as Sports and Hindi
+	ChannelCategories []string `json:"channel_categories,omitempty" form:"channel_categories"`
+	Languages         []string `json:"languages,omitempty" form:"languages"`
+	// Action is "record" to record the live airing or "catchup" to download it from catchup after it aired
+	Action  string `json:"action" form:"action"`
+	Quality string `json:"quality,omitempty" form:"quality"`
+	// KeepLast keeps only the newest completed recordings of the rule. 0 keeps all.
+	KeepLast  int       `json:"keep_last,omitempty" form:"keep_last"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecordingRuleStatus is a recording rule as listed by the API. Error tells
+// why the rule is skipped, empty for valid rules.
+type RecordingRuleStatus struct {
+	RecordingRule
+	Error string `json:"error,omitempty"`
+}
+
+// CatchupDownloadRequest represents Request body for downloading a catchup
+// programme. The programme is identified by Srno, or by Start and End in
+// unix seconds or milliseconds as used in catchup URLs.
+type CatchupDownloadRequest struct {
+	ChannelID string `json:"channel_id" form:"channel_id"`
+	Srno      string `json:"srno" form:"srno"`
+	Start     string `json:"start" form:"start"`
+	End       string `json:"end" form:"end"`
+	Quality   string `json:"quality" form:"quality"`
+	Title     string `json:"title" form:"title"`
+	// Profile is the account profile to download with, taken from the path
+	// of the request. Empty uses the default profile.
+	Profile string `json:"-" form:"-"`
+}
+
+// CatchupDownloadProgress reports the progress of a catchup download
+type CatchupDownloadProgress struct {
+	ID            string    `json:"id,omitempty"`
+	ChannelID     string    `json:"channel_id"`
+	Title         string    `json:"title"`
+	File          string    `json:"file"`
+	Status        string    `json:"status"`
+	TotalSegments int       `json:"total_segments"`
+	DoneSegments  int       `json:"done_segments"`
+	Bytes         int64     `json:"bytes"`
+	Percent       float64   `json:"percent"`
+	Error         string    `json:"error,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at,omitzero"`
+}
+
+// TokenRefreshStatus reports the background token refresh of a profile
+type TokenRefreshStatus struct {
+	Profile string `json:"profile"`
+	// NextRefresh is when the tokens are checked next and refreshed if they
+	// expire soon
+	NextRefresh time.Time `json:"next_refresh"`
+	// LastRun is when the tokens were last checked, LastSuccess when that last succeeded
+	LastRun     time.Time `json:"last_run,omitzero"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	// LastOutcome is "refreshed", "fresh" when no token needed a refresh, or "failed"
+	LastOutcome string    `json:"last_outcome,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitzero"`
+	// Failures counts the failed refreshes since the last success
+	Failures          int       `json:"failures"`
+	AccessTokenExpiry time.Time `json:"access_token_expiry,omitzero"`
+	SSOTokenExpiry    time.Time `json:"sso_token_expiry,omitzero"`
+}
+
+// SessionStatus reports the JioTV login session of a profile
+type SessionStatus struct {
+	Profile  string `json:"profile"`
+	LoggedIn bool   `json:"logged_in"`
+	// CRM and UniqueID are masked except for their last characters
+	CRM                 string    `json:"crm,omitempty"`
+	UniqueID            string    `json:"unique_id,omitempty"`
+	AccessTokenExpiry   time.Time `json:"access_token_expiry,omitzero"`
+	AccessTokenExpired  bool      `json:"access_token_expired"`
+	SSOTokenExpiry      time.Time `json:"sso_token_expiry,omitzero"`
+	SSOTokenExpired     bool      `json:"sso_token_expired"`
+	LastTokenRefresh    time.Time `json:"last_token_refresh,omitzero"`
+	LastSSOTokenRefresh time.Time `json:"last_sso_token_refresh,omitzero"`
+	// RefreshTokenRejected is set when JioTV rejected the refresh token since
+	// it last worked. The session then ends when the access token expires.
+	RefreshTokenRejected   bool                         `json:"refresh_token_rejected"`
+	RefreshTokenRejectedAt time.Time                    `json:"refresh_token_rejected_at,omitzero"`
+	PremiumProviders       []television.PremiumProvider `json:"premium_providers"`
+	// BackgroundRefresh is the status of the background token refresh, if it ran
+	BackgroundRefresh *TokenRefreshStatus `json:"background_refresh,omitempty"`
+	// Warnings describe problems that end the session soon
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ReadinessCheck is the result of one check of Readiness
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// Readiness reports whether the server is ready to serve channels
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// UpstreamProbe reports whether an upstream domain could be reached
+type UpstreamProbe struct {
+	Domain    string `json:"domain"`
+	Reachable bool   `json:"reachable"`
+	// Status is the HTTP status code of the response, which may be an error
+	// status as the probe does not request a real API
+	Status    int    `json:"status,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DiagnosticsProxy describes the proxy upstream requests go through
+type DiagnosticsProxy struct {
+	// Kind is "direct", "socks5" or "http"
+	Kind string `json:"kind"`
+	// URL is the proxy URL with its password redacted
+	URL string `json:"url,omitempty"`
+}
+
+// Diagnostics describes the server for troubleshooting
+type Diagnostics struct {
+	Version   string    `json:"version"`
+	GoVersion string    `json:"go_version"`
+	StartedAt time.Time `json:"started_at"`
+	// Config is the configuration with passwords and tokens redacted
+	Config       config.JioTVConfig             `json:"config"`
+	Proxy        DiagnosticsProxy               `json:"proxy"`
+	Upstreams    []UpstreamProbe                `json:"upstreams"`
+	Tasks        []scheduler.TaskStatus         `json:"tasks"`
+	Readiness    Readiness                      `json:"readiness"`
+	Channels     television.ChannelsCacheStatus `json:"channels"`
+	TokenRefresh []TokenRefreshStatus           `json:"token_refresh"`
+	DRMChannels  int                            `json:"drm_channels"`
+}
+
+// Statuses of doctor checks
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	// CheckSkip marks checks that did not run because an earlier check failed
+	CheckSkip = "skip"
+)
+
+// DoctorCheck is the result of one check of the doctor command
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// Hint suggests how to fix a failed or warning check
+	Hint string `json:"hint,omitempty"`
+}
+
+// EPGLinks are the links to watch a programme of the EPG API
+type EPGLinks struct {
+	// Play and Stream are set while the programme airs
+	Play   string `json:"play,omitempty"`
+	Stream string `json:"stream,omitempty"`
+	// Catchup and CatchupStream are set once the programme has started on a
+	// channel offering catchup
+	Catchup       string `json:"catchup,omitempty"`
+	CatchupStream string `json:"catchup_stream,omitempty"`
+}
+
+// EPGAPIProgramme is a programme returned by the EPG API
+type EPGAPIProgramme struct {
+	epg.IndexedProgramme
+	Links EPGLinks `json:"links"`
+}
+
+// EPGAPIResponse is the response of the EPG API
+type EPGAPIResponse struct {
+	// Updated is when the EPG was generated
+	Updated    time.Time         `json:"updated"`
+	Programmes []EPGAPIProgramme `json:"programmes"`
+}
@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/internal/middleware"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// xtreamTimeshiftLayout is the start time format used in Xtream Codes timeshift URLs
+	xtreamTimeshiftLayout = "2006-01-02:15-04"
+	// xtreamEPGTimeLayout is the time format of start/end fields in Xtream Codes EPG listings
+	xtreamEPGTimeLayout = "2006-01-02 15:04:05"
+	// xtreamShortEPGLimit is the number of programmes returned by get_short_epg when no limit is given
+	xtreamShortEPGLimit = 4
+	// xtreamArchiveDays is the catchup window advertised to Xtream Codes clients
+	xtreamArchiveDays = 7
+)
+
+// indiaLocation returns the Asia/Kolkata time zone used by JioTV schedules.
+// It falls back to a fixed IST offset when tzdata is not available.
+func indiaLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		return time.FixedZone("IST", 5*3600+30*60)
+	}
+	return loc
+}
+
+// xtreamCredentialsValid checks Xtream Codes login credentials against the config.
+// When no credentials are configured, any username and password is accepted.
+func xtreamCredentialsValid(username, password string) bool {
+	if config.Cfg.XtreamUsername == "" && config.Cfg.XtreamPassword == "" {
+		return true
+	}
+	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(config.Cfg.XtreamUsername)) == 1
+	passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(config.Cfg.XtreamPassword)) == 1
+	return usernameMatch && passwordMatch
+}
+
+// xtreamPathCredentials returns the unescaped username and password path
+// parameters of Xtream Codes stream URLs.
+func xtreamPathCredentials(c *fiber.Ctx) (string, string) {
+	username, err := url.PathUnescape(c.Params("username"))
+	if err != nil {
+		username = c.Params("username")
+	}
+	password, err := url.PathUnescape(c.Params("password"))
+	if err != nil {
+		password = c.Params("password")
+	}
+	return username, password
+}
+
+// xtreamUnauthorized responds the way Xtream Codes clients expect for a failed login
+func xtreamUnauthorized(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"user_info": fiber.Map{"auth": 0},
+	})
+}
+
+// trimStreamExtension removes the .m3u8 or .ts suffix Xtream Codes clients append to stream IDs
+func trimStreamExtension(id string) string {
+	id = strings.TrimSuffix(id, ".m3u8")
+	return strings.TrimSuffix(id, ".ts")
+}
+
+// epgEpochMillis reads an epoch field from a programme returned by
+// getCatchupEPG. The API reports seconds for some channels and milliseconds
+// for others, so the value is normalised to milliseconds.
+func epgEpochMillis(programme map[string]interface{}, key string) (int64, bool) {
+	epoch, ok := programme[key].(int64)
+	if !ok {
+		return 0, false
+	}
+	if epoch < epochThreshold {
+		epoch *= 1000
+	}
+	return epoch, true
+}
+
+// xtreamServerInfo builds the server_info block from the incoming request
+func xtreamServerInfo(c *fiber.Ctx) XtreamServerInfo {
+	protocol := strings.ToLower(c.Protocol())
+	host := c.Hostname()
+	port := "80"
+	if protocol == "https" {
+		port = "443"
+	}
+	if splitHost, splitPort, err := net.SplitHostPort(host); err == nil {
+		host = splitHost
+		port = splitPort
+	}
+	now := time.Now().In(indiaLocation())
+	serverInfo := XtreamServerInfo{
+		URL:            host,
+		Port:           port,
+		HTTPSPort:      port,
+		ServerProtocol: protocol,
+		RTMPPort:       "",
+		Timezone:       now.Location().String(),
+		TimestampNow:   now.Unix(),
+		TimeNow:        now.Format(xtreamEPGTimeLayout),
+	}
+	return serverInfo
+}
+
+// xtreamLiveCategories maps television.CategoryMap to Xtream Codes live categories
+func xtreamLiveCategories() []XtreamCategory {
+	categoryIDs := make([]int, 0, len(television.CategoryMap))
+	for categoryID := range television.CategoryMap {
+		// 0 is the "All Categories" filter, not a real category
+		if categoryID == 0 {
+			continue
+		}
+		categoryIDs = append(categoryIDs, categoryID)
+	}
+	sort.Ints(categoryIDs)
+
+	categories := make([]XtreamCategory, 0, len(categoryIDs))
+	for _, categoryID := range categoryIDs {
+		categories = append(categories, XtreamCategory{
+			CategoryID:   strconv.Itoa(categoryID),
+			CategoryName: television.CategoryMap[categoryID],
+			ParentID:     0,
+		})
+	}
+	return categories
+}
+
+// xtreamLiveStreams maps channels to Xtream Codes live streams, optionally
+// restricted to a single category.
+func xtreamLiveStreams(channels []television.Channel, hostURL, categoryID string) []XtreamLiveStream {
+	streams := make([]XtreamLiveStream, 0, len(channels))
+	for _, channel := range channels {
+		channelCategoryID := strconv.Itoa(channel.Category)
+		if categoryID != "" && categoryID != channelCategoryID {
+			continue
+		}
+
+		var streamID interface{} = channel.ID
+		if numericID, err := strconv.Atoi(channel.ID); err == nil {
+			streamID = numericID
+		}
+
+		streamIcon := channel.LogoURL
+		if !strings.HasPrefix(streamIcon, "http://") && !strings.HasPrefix(streamIcon, "https://") {
+			streamIcon = hostURL + "/jtvimage/" + streamIcon
+		}
+
+		stream := XtreamLiveStream{
+			Num:          len(streams) + 1,
+			Name:         channel.Name,
+			StreamType:   "live",
+			StreamID:     streamID,
+			StreamIcon:   streamIcon,
+			EPGChannelID: channel.ID,
+			CategoryID:   channelCategoryID,
+		}
+		if channel.IsCatchupAvailable {
+			stream.TVArchive = 1
+			stream.TVArchiveDuration = xtreamArchiveDays
+		}
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// xtreamEPGListings converts catchup EPG programmes to Xtream Codes listings
+// sorted by start time. When upcomingOnly is set, programmes that have already
+// ended are skipped.
+func xtreamEPGListings(programmes []map[string]interface{}, channelID string, catchupAvailable, upcomingOnly bool, now time.Time) []XtreamEPGListing {
+	loc := indiaLocation()
+	nowMillis := now.UnixMilli()
+
+	listings := make([]XtreamEPGListing, 0, len(programmes))
+	for _, programme := range programmes {
+		if programme == nil {
+			continue
+		}
+		start, ok := epgEpochMillis(programme, "startEpoch")
+		if !ok {
+			continue
+		}
+		end, ok := epgEpochMillis(programme, "endEpoch")
+		if !ok {
+			continue
+		}
+		if upcomingOnly && end <= nowMillis {
+			continue
+		}
+
+		title, _ := programme["showname"].(string)
+		description, _ := programme["description"].(string)
+		srno, _ := programme["srno"].(string)
+
+		listing := XtreamEPGListing{
+			ID:             srno,
+			EPGID:          channelID,
+			Title:          base64.StdEncoding.EncodeToString([]byte(title)),
+			Lang:           "en",
+			Start:          time.UnixMilli(start).In(loc).Format(xtreamEPGTimeLayout),
+			End:            time.UnixMilli(end).In(loc).Format(xtreamEPGTimeLayout),
+			Description:    base64.StdEncoding.EncodeToString([]byte(description)),
+			ChannelID:      channelID,
+			StartTimestamp: strconv.FormatInt(start/1000, 10),
+			StopTimestamp:  strconv.FormatInt(end/1000, 10),
+		}
+		if start <= nowMillis && end > nowMillis {
+			listing.NowPlaying = 1
+		}
+		if catchupAvailable && end <= nowMillis {
+			listing.HasArchive = 1
+		}
+		listings = append(listings, listing)
+	}
+
+	sort.SliceStable(listings, func(i, j int) bool {
+		startI, _ := strconv.ParseInt(listings[i].StartTimestamp, 10, 64)
+		startJ, _ := strconv.ParseInt(listings[j].StartTimestamp, 10, 64)
+		return startI < startJ
+	})
+	return listings
+}
+
+// xtreamCatchupEPG fetches a day of the catchup EPG. Tests replace it.
+var xtreamCatchupEPG = getCatchupEPG
+
+// xtreamEPGOffsets returns the day offsets of the catchup EPG served to
+// Xtream Codes clients. get_short_epg only needs today, while
+// get_simple_data_table covers the advertised archive and tomorrow.
+func xtreamEPGOffsets(short bool) []int {
+	if short {
+		return []int{0}
+	}
+	offsets := make([]int, 0, xtreamArchiveDays+2)
+	for offset := -xtreamArchiveDays; offset <= 1; offset++ {
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+// xtreamEPGProgrammes fetches the catchup EPG of the days at offsets in
+// parallel. Days that fail to load are logged and left out, and an error is
+// only returned when none loaded.
+func xtreamEPGProgrammes(channelID string, offsets []int) ([]map[string]interface{}, error) {
+	days := make([][]map[string]interface{}, len(offsets))
+	errs := make([]error, len(offsets))
+	var wg sync.WaitGroup
+	for i, offset := range offsets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			days[i], errs[i] = xtreamCatchupEPG(channelID, offset)
+		}()
+	}
+	wg.Wait()
+
+	var programmes []map[string]interface{}
+	var lastErr error
+	loaded := false
+	for i, err := range errs {
+		if err != nil {
+			utils.Log.Printf("Error fetching EPG for Xtream stream %s at offset %d: %v", channelID, offsets[i], err)
+			lastErr = err
+			continue
+		}
+		loaded = true
+		programmes = append(programmes, days[i]...)
+	}
+	if !loaded {
+		return nil, lastErr
+	}
+	return programmes, nil
+}
+
+// xtreamEPGHandler serves get_short_epg and get_simple_data_table from the
+// same catchup EPG data used by CatchupHandler.
+func xtreamEPGHandler(c *fiber.Ctx, short bool) error {
+	channelID := c.FormValue("stream_id")
+	if err := internalUtils.CheckFieldExist(c, "stream_id", channelID != ""); err != nil {
+		return err
+	}
+
+	programmes, err := xtreamEPGProgrammes(channelID, xtreamEPGOffsets(short))
+	if err != nil {
+		return c.JSON(fiber.Map{"epg_listings": []XtreamEPGListing{}})
+	}
+
+	_, catchupAvailable, _ := catchupSupport(channelID)
+	listings := xtreamEPGListings(programmes, channelID, catchupAvailable, short, time.Now())
+
+	if short {
+		limit, err := strconv.Atoi(c.FormValue("limit"))
+		if err != nil || limit <= 0 {
+			limit = xtreamShortEPGLimit
+		}
+		if len(listings) > limit {
+			listings = listings[:limit]
+		}
+	}
+
+	return c.JSON(fiber.Map{"epg_listings": listings})
+}
+
+// XtreamPlayerAPIHandler handles the Xtream Codes `/player_api.php` route.
+// Live categories map to television.CategoryMap, live streams to
+// television.Channels() and EPG actions to the catchup EPG API.
+func XtreamPlayerAPIHandler(c *fiber.Ctx) error {
+	// Xtream Codes clients send parameters either in the query string or as a POST form
+	username := c.FormValue("username")
+	password := c.FormValue("password")
+	if !xtreamCredentialsValid(username, password) {
+		return xtreamUnauthorized(c)
+	}
+
+	hostURL := strings.ToLower(c.Protocol()) + "://" + c.Hostname()
+
+	action := c.FormValue("action")
+	switch action {
+	case "":
+		return c.JSON(XtreamAccountResponse{
+			UserInfo: XtreamUserInfo{
+				Username:             username,
+				Password:             password,
+				Auth:                 1,
+				Status:               "Active",
+				IsTrial:              "0",
+				ActiveCons:           "0",
+				CreatedAt:            "0",
+				MaxConnections:       "1",
+				AllowedOutputFormats: []string{"m3u8"},
+			},
+			ServerInfo: xtreamServerInfo(c),
+		})
+	case "get_live_categories":
+		return c.JSON(xtreamLiveCategories())
+	case "get_live_streams":
+		channels, err := television.Channels()
+		if err != nil {
+			return ErrorMessageHandler(c, err)
+		}
+		return c.JSON(xtreamLiveStreams(channels.Result, hostURL, c.FormValue("category_id")))
+	case "get_short_epg":
+		return xtreamEPGHandler(c, true)
+	case "get_simple_data_table":
+		return xtreamEPGHandler(c, false)
+	case "get_vod_categories", "get_vod_streams", "get_series_categories", "get_series":
+		// JioTV Go only serves live TV
+		return c.JSON([]interface{}{})
+	default:
+		return internalUtils.BadRequestError(c, "Unsupported action: "+action)
+	}
+}
+
+// generateXtreamM3UPlaylist generates an M3U playlist whose stream URLs use the Xtream Codes live URL shape
+func generateXtreamM3UPlaylist(channels []television.Channel, hostURL, username, password string) string {
+	var m3uContent strings.Builder
+	fmt.Fprintf(&m3uContent, "#EXTM3U x-tvg-url=\"%s/xmltv.php?username=%s&password=%s\"\n",
+		hostURL, url.QueryEscape(username), url.QueryEscape(password))
+
+	// Without a category filter every channel maps to exactly one stream, in order
+	streams := xtreamLiveStreams(channels, hostURL, "")
+	for i, stream := range streams {
+		fmt.Fprintf(&m3uContent, "#EXTINF:-1 tvg-id=%q tvg-name=%q tvg-logo=%q group-title=%q, %s\n%s/live/%s/%s/%s.m3u8\n",
+			stream.EPGChannelID, stream.Name, stream.StreamIcon, television.CategoryMap[channels[i].Category],
+			stream.Name, hostURL, url.PathEscape(username), url.PathEscape(password), url.PathEscape(channels[i].ID))
+	}
+
+	return m3uContent.String()
+}
+
+// XtreamPlaylistHandler handles the Xtream Codes `/get.php` playlist route
+func XtreamPlaylistHandler(c *fiber.Ctx) error {
+	username := c.FormValue("username")
+	password := c.FormValue("password")
+	if !xtreamCredentialsValid(username, password) {
+		return xtreamUnauthorized(c)
+	}
+
+	channels, err := television.Channels()
+	if err != nil {
+		return ErrorMessageHandler(c, err)
+	}
+	hostURL := strings.ToLower(c.Protocol()) + "://" + c.Hostname()
+
+	m3uContent := generateXtreamM3UPlaylist(channels.Result, hostURL, username, password)
+	c.Set("Content-Disposition", "attachment; filename=jiotv_playlist.m3u")
+	c.Set("Content-Type", "application/vnd.apple.mpegurl")
+	return c.SendStream(strings.NewReader(m3uContent))
+}
+
+// XtreamXMLTVHandler handles the Xtream Codes `/xmltv.php` route by serving the generated EPG
+func XtreamXMLTVHandler(c *fiber.Ctx) error {
+	if !xtreamCredentialsValid(c.FormValue("username"), c.FormValue("password")) {
+		return xtreamUnauthorized(c)
+	}
+	return EPGHandler(c)
+}
+
+// XtreamLiveHandler handles the Xtream Codes live route `/live/:username/:password/:id.m3u8`
+func XtreamLiveHandler(c *fiber.Ctx) error {
+	username, password := xtreamPathCredentials(c)
+	if !xtreamCredentialsValid(username, password) {
+		return xtreamUnauthorized(c)
+	}
+	return liveQualityRedirect(c, "auto", trimStreamExtension(c.Params("id")))
+}
+
+// parseXtreamTimeshiftStart parses the start time of an Xtream Codes timeshift
+// request, which is expressed in the server time zone advertised in server_info.
+func parseXtreamTimeshiftStart(start string) (time.Time, error) {
+	for _, layout := range []string{xtreamTimeshiftLayout, xtreamTimeshiftLayout + "-05", xtreamEPGTimeLayout} {
+		if parsed, err := time.ParseInLocation(layout, start, indiaLocation()); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timeshift start: %s", start)
+}
+
+// findCatchupProgramme returns the programme airing at startTime, or nil if none matches
+func findCatchupProgramme(programmes []map[string]interface{}, startTime time.Time) map[string]interface{} {
+	startMillis := startTime.UnixMilli()
+	for _, programme := range programmes {
+		if programme == nil {
+			continue
+		}
+		start, ok := epgEpochMillis(programme, "startEpoch")
+		if !ok {
+			continue
+		}
+		end, ok := epgEpochMillis(programme, "endEpoch")
+		if !ok {
+			continue
+		}
+		if start <= startMillis && startMillis < end {
+			return programme
+		}
+	}
+	return nil
+}
+
+// XtreamTimeshiftHandler handles Xtream Codes catchup requests in both the
+// `/timeshift/:username/:password/:duration/:start/:id.m3u8` and
+// `/streaming/timeshift.php` shapes. The programme serial number required by
+// the catchup API is looked up from the EPG, then the request is routed
+// through CatchupStreamHandler.
+func XtreamTimeshiftHandler(c *fiber.Ctx) error {
+	username, password := xtreamPathCredentials(c)
+	channelID := trimStreamExtension(c.Params("id"))
+	start := c.Params("start")
+	duration := c.Params("duration")
+	if channelID == "" {
+		username = c.FormValue("username")
+		password = c.FormValue("password")
+		channelID = trimStreamExtension(c.FormValue("stream"))
+		start = c.FormValue("start")
+		duration = c.FormValue("duration")
+	}
+	if !xtreamCredentialsValid(username, password) {
+		return xtreamUnauthorized(c)
+	}
+	if err := internalUtils.CheckFieldExist(c, "stream", channelID != ""); err != nil {
+		return err
+	}
+
+	startTime, err := parseXtreamTimeshiftStart(start)
+	if err != nil {
+		return internalUtils.BadRequestError(c, err.Error())
+	}
+	durationMinutes, err := strconv.Atoi(duration)
+	if err != nil || durationMinutes <= 0 {
+		return internalUtils.BadRequestError(c, "Invalid timeshift duration")
+	}
+
+	startMillis := startTime.UnixMilli()
+	endMillis := startTime.Add(time.Duration(durationMinutes) * time.Minute).UnixMilli()
+	srno := ""
+
+	// EPG offsets are whole days relative to today in IST
+	loc := indiaLocation()
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	startDay := time.Date(startTime.Year(), startTime.Month(), startTime.Day(), 0, 0, 0, 0, loc)
+	offset := int(startDay.Sub(today).Hours() / 24)
+
+	programmes, err := getCatchupEPG(channelID, offset)
+	if err != nil {
+		utils.Log.Printf("Error fetching EPG for Xtream timeshift on channel %s: %v", channelID, err)
+	} else if programme := findCatchupProgramme(programmes, startTime); programme != nil {
+		srno, _ = programme["srno"].(string)
+		if programmeStart, ok := epgEpochMillis(programme, "startEpoch"); ok {
+			startMillis = programmeStart
+		}
+		if programmeEnd, ok := epgEpochMillis(programme, "endEpoch"); ok {
+			endMillis = programmeEnd
+		}
+	}
+
+	redirectURL := fmt.Sprintf("/catchup/stream/%s.m3u8?start=%d&end=%d&srno=%s",
+		url.PathEscape(channelID), startMillis, endMillis, url.QueryEscape(srno))
+	return c.Redirect(middleware.WithURLQuery(c, redirectURL), fiber.StatusFound)
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func TestXtreamCredentialsValid(t *testing.T) {
+	originalCfg := config.Cfg
+	defer func() { config.Cfg = originalCfg }()
+
+	tests := []struct {
+		name         string
+		cfgUsername  string
+		cfgPassword  string
+		username     string
+		password     string
+		wantAccepted bool
+	}{
+		{
+			name:         "No credentials configured accepts anything",
+			username:     "any",
+			password:     "thing",
+			wantAccepted: true,
+		},
+		{
+			name:         "Matching credentials",
+			cfgUsername:  "user",
+			cfgPassword:  "pass",
+			username:     "user",
+			password:     "pass",
+			wantAccepted: true,
+		},
+		{
+			name:         "Wrong password",
+			cfgUsername:  "user",
+			cfgPassword:  "pass",
+			username:     "user",
+			password:     "wrong",
+			wantAccepted: false,
+		},
+		{
+			name:         "Password only configured",
+			cfgPassword:  "pass",
+			username:     "someone",
+			password:     "pass",
+			wantAccepted: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg.XtreamUsername = tt.cfgUsername
+			config.Cfg.XtreamPassword = tt.cfgPassword
+			if got := xtreamCredentialsValid(tt.username, tt.password); got != tt.wantAccepted {
+				t.Errorf("xtreamCredentialsValid() = %v, want %v", got, tt.wantAccepted)
+			}
+		})
+	}
+}
+
+func TestXtreamLiveCategories(t *testing.T) {
+	categories := xtreamLiveCategories()
+	if len(categories) == 0 {
+		t.Fatal("xtreamLiveCategories() returned no categories")
+	}
+	previousID := 0
+	for _, category := range categories {
+		categoryID, err := strconv.Atoi(category.CategoryID)
+		if err != nil {
+			t.Fatalf("CategoryID %q is not numeric", category.CategoryID)
+		}
+		if categoryID == 0 {
+			t.Errorf("xtreamLiveCategories() includes the \"All Categories\" entry")
+		}
+		if categoryID <= previousID {
+			t.Errorf("xtreamLiveCategories() not sorted: %d after %d", categoryID, previousID)
+		}
+		previousID = categoryID
+	}
+}
+
+func TestXtreamLiveStreams(t *testing.T) {
+	channels := []television.Channel{
+		{ID: "143", Name: "News One", LogoURL: "news.png", Category: 12, IsCatchupAvailable: true},
+		{ID: "custom_1", Name: "Custom", LogoURL: "https://example.com/logo.png", Category: 5},
+	}
+
+	tests := []struct {
+		name         string
+		categoryID   string
+		wantCount    int
+		wantStreamID interface{}
+		wantIcon     string
+		wantArchive  int
+	}{
+		{
+			name:         "All categories",
+			wantCount:    2,
+			wantStreamID: 143,
+			wantIcon:     "http://localhost:5001/jtvimage/news.png",
+			wantArchive:  1,
+		},
+		{
+			name:         "Filtered by category",
+			categoryID:   "5",
+			wantCount:    1,
+			wantStreamID: "custom_1",
+			wantIcon:     "https://example.com/logo.png",
+			wantArchive:  0,
+		},
+		{
+			name:       "Unknown category",
+			categoryID: "999",
+			wantCount:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := xtreamLiveStreams(channels, "http://localhost:5001", tt.categoryID)
+			if len(streams) != tt.wantCount {
+				t.Fatalf("xtreamLiveStreams() returned %d streams, want %d", len(streams), tt.wantCount)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			if streams[0].StreamID != tt.wantStreamID {
+				t.Errorf("StreamID = %v (%T), want %v (%T)", streams[0].StreamID, streams[0].StreamID, tt.wantStreamID, tt.wantStreamID)
+			}
+			if streams[0].StreamIcon != tt.wantIcon {
+				t.Errorf("StreamIcon = %v, want %v", streams[0].StreamIcon, tt.wantIcon)
+			}
+			if streams[0].TVArchive != tt.wantArchive {
+				t.Errorf("TVArchive = %v, want %v", streams[0].TVArchive, tt.wantArchive)
+			}
+			if streams[0].Num != 1 {
+				t.Errorf("Num = %v, want 1", streams[0].Num)
+			}
+		})
+	}
+}
+
+func TestXtreamEPGListings(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	hour := int64(time.Hour / time.Millisecond)
+	nowMillis := now.UnixMilli()
+
+	// Newest first, as returned by getCatchupEPG
+	programmes := []map[string]interface{}{
+		{"showname": "Upcoming", "description": "Later", "srno": "3", "startEpoch": nowMillis + hour, "endEpoch": nowMillis + 2*hour},
+		{"showname": "Current", "description": "Now", "srno": "2", "startEpoch": nowMillis - hour/2, "endEpoch": nowMillis + hour/2},
+		{"showname": "Past", "description": "Earlier", "srno": "1", "startEpoch": (nowMillis - 2*hour) / 1000, "endEpoch": (nowMillis - hour/2) / 1000},
+		nil,
+	}
+
+	tests := []struct {
+		name             string
+		upcomingOnly     bool
+		catchupAvailable bool
+		wantIDs          []string
+		wantNowPlaying   string
+		wantArchived     []string
+	}{
+		{
+			name:             "Full table with catchup",
+			catchupAvailable: true,
+			wantIDs:          []string{"1", "2", "3"},
+			wantNowPlaying:   "2",
+			wantArchived:     []string{"1"},
+		},
+		{
+			name:           "Short EPG skips finished programmes",
+			upcomingOnly:   true,
+			wantIDs:        []string{"2", "3"},
+			wantNowPlaying: "2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listings := xtreamEPGListings(programmes, "143", tt.catchupAvailable, tt.upcomingOnly, now)
+			if len(listings) != len(tt.wantIDs) {
+				t.Fatalf("xtreamEPGListings() returned %d listings, want %d", len(listings), len(tt.wantIDs))
+			}
+			var archived []string
+			for i, listing := range listings {
+				if listing.ID != tt.wantIDs[i] {
+					t.Errorf("listing[%d].ID = %v, want %v", i, listing.ID, tt.wantIDs[i])
+				}
+				if (listing.NowPlaying == 1) != (listing.ID == tt.wantNowPlaying) {
+					t.Errorf("listing %s NowPlaying = %d", listing.ID, listing.NowPlaying)
+				}
+				if listing.HasArchive == 1 {
+					archived = append(archived, listing.ID)
+				}
+				if _, err := base64.StdEncoding.DecodeString(listing.Title); err != nil {
+					t.Errorf("listing %s Title is not base64: %v", listing.ID, err)
+				}
+			}
+			if strings.Join(archived, ",") != strings.Join(tt.wantArchived, ",") {
+				t.Errorf("archived listings = %v, want %v", archived, tt.wantArchived)
+			}
+		})
+	}
+}
+
+func TestXtreamEPGProgrammes(t *testing.T) {
+	previousEPG, previousLog := xtreamCatchupEPG, utils.Log
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() {
+		xtreamCatchupEPG, utils.Log = previousEPG, previousLog
+	})
+
+	var mu sync.Mutex
+	var fetched []int
+	xtreamCatchupEPG = func(id string, offset int) ([]map[string]interface{}, error) {
+		mu.Lock()
+		fetched = append(fetched, offset)
+		mu.Unlock()
+		if offset == -3 {
+			return nil, errors.New("unavailable")
+		}
+		return []map[string]interface{}{{"srno": strconv.Itoa(offset)}}, nil
+	}
+
+	if offsets := xtreamEPGOffsets(true); !slices.Equal(offsets, []int{0}) {
+		t.Errorf("xtreamEPGOffsets(true) = %v, want [0]", offsets)
+	}
+	offsets := xtreamEPGOffsets(false)
+	if len(offsets) != xtreamArchiveDays+2 || offsets[0] != -xtreamArchiveDays || offsets[len(offsets)-1] != 1 {
+		t.Errorf("xtreamEPGOffsets(false) = %v, want -%d to 1", offsets, xtreamArchiveDays)
+	}
+
+	programmes, err := xtreamEPGProgrammes("143", offsets)
+	if err != nil {
+		t.Fatalf("xtreamEPGProgrammes() error = %v", err)
+	}
+	if len(fetched) != len(offsets) {
+		t.Errorf("fetched offsets %v, want %v", fetched, offsets)
+	}
+	// The failed day is left out
+	if len(programmes) != len(offsets)-1 {
+		t.Errorf("xtreamEPGProgrammes() returned %d programmes, want %d", len(programmes), len(offsets)-1)
+	}
+
+	if _, err := xtreamEPGProgrammes("143", []int{-3}); err == nil {
+		t.Error("xtreamEPGProgrammes() without any day loaded returned no error")
+	}
+}
+
+func TestParseXtreamTimeshiftStart(t *testing.T) {
+	want := time.Date(2024, 1, 15, 20, 30, 0, 0, indiaLocation())
+	tests := []struct {
+		name    string
+		start   string
+		wantErr bool
+	}{
+		{name: "Minute layout", start: "2024-01-15:20-30"},
+		{name: "Minute layout with seconds", start: "2024-01-15:20-30-00"},
+		{name: "EPG layout", start: "2024-01-15 20:30:00"},
+		{name: "Invalid", start: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseXtreamTimeshiftStart(tt.start)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseXtreamTimeshiftStart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(want) {
+				t.Errorf("parseXtreamTimeshiftStart() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFindCatchupProgramme(t *testing.T) {
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	programmes := []map[string]interface{}{
+		{"srno": "2", "startEpoch": base.Add(time.Hour).UnixMilli(), "endEpoch": base.Add(2 * time.Hour).UnixMilli()},
+		{"srno": "1", "startEpoch": base.Unix(), "endEpoch": base.Add(time.Hour).Unix()},
+	}
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		wantSrno string
+	}{
+		{name: "Programme start", start: base, wantSrno: "1"},
+		{name: "Programme boundary", start: base.Add(time.Hour), wantSrno: "2"},
+		{name: "No programme", start: base.Add(3 * time.Hour), wantSrno: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findCatchupProgramme(programmes, tt.start)
+			gotSrno := ""
+			if got != nil {
+				gotSrno, _ = got["srno"].(string)
+			}
+			if gotSrno != tt.wantSrno {
+				t.Errorf("findCatchupProgramme() srno = %q, want %q", gotSrno, tt.wantSrno)
+			}
+		})
+	}
+}
+
+func TestGenerateXtreamM3UPlaylist(t *testing.T) {
+	channels := []television.Channel{
+		{ID: "143", Name: "News One", LogoURL: "news.png", Category: 12},
+	}
+	got := generateXtreamM3UPlaylist(channels, "http://localhost:5001", "user", "p@ss")
+
+	wantParts := []string{
+		"#EXTM3U x-tvg-url=\"http://localhost:5001/xmltv.php?username=user&password=p%40ss\"",
+		"tvg-id=\"143\"",
+		"group-title=\"" + television.CategoryMap[12] + "\"",
+		"http://localhost:5001/live/user/p@ss/143.m3u8",
+	}
+	for _, part := range wantParts {
+		if !strings.Contains(got, part) {
+			t.Errorf("generateXtreamM3UPlaylist() missing %q in:\n%s", part, got)
+		}
+	}
+}
+
+func TestXtreamPlayerAPIHandler_Unauthorized(t *testing.T) {
+	originalCfg := config.Cfg
+	defer func() { config.Cfg = originalCfg }()
+	config.Cfg.XtreamUsername = "user"
+	config.Cfg.XtreamPassword = "pass"
+
+	app := fiber.New()
+	app.Get("/player_api.php", XtreamPlayerAPIHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/player_api.php?username=user&password=wrong", nil))
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,357 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+// Scopes of authenticated users
+const (
+	// ScopeWatch allows playing channels, catchup and premium content
+	ScopeWatch = "watch"
+	// ScopePlaylist allows fetching playlists and channel lists
+	ScopePlaylist = "playlist"
+	// ScopeEPG allows fetching the EPG
+	ScopeEPG = "epg"
+	// ScopeAdmin allows everything, including login, logout and the API
+	ScopeAdmin = "admin"
+)
+
+const (
+	// TokenQueryParam is the query parameter carrying the token of a user
+	TokenQueryParam = "token"
+	// tokenCookie carries the token of a user that logged in with the query
+	// parameter, so that links of the web interface keep working
+	tokenCookie = "jiotv_token"
+	// urlTokenLocal is the Locals key of the token to carry in generated URLs
+	urlTokenLocal = "auth_url_token"
+	// dashTokenPrefix embeds the token in DASH segment paths, as DASH players
+	// drop query parameters of BaseURL
+	dashTokenPrefix = "/render.dash/token/"
+	// signedTokenPrefix starts URL credentials signed for users without a token
+	signedTokenPrefix = "u."
+)
+
+// urlCredentialPurpose derives the keys signing the URL credentials of users
+// without a token from the URL encryption key
+const urlCredentialPurpose = "jiotv_go url credential"
+
+// fallbackURLCredentialKey signs URL credentials when the URL encryption key
+// is not loaded, which only happens in tests
+var fallbackURLCredentialKey = newURLCredentialKey()
+
+func newURLCredentialKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// urlCredentialKeys returns the keys URL credentials are signed with. They
+// are derived from the persisted URL encryption key, so that signed URLs keep
+// working across restarts. The first key signs new credentials.
+func urlCredentialKeys() [][]byte {
+	if keys := secureurl.DerivedKeys(urlCredentialPurpose); len(keys) > 0 {
+		return keys
+	}
+	return [][]byte{fallbackURLCredentialKey}
+}
+
+// defaultScopes are the scopes of users without scopes in the config
+var defaultScopes = []string{ScopeWatch, ScopePlaylist, ScopeEPG}
+
+// routeScopes maps path prefixes to the scope they require. Paths are
+// matched in order, and paths that match none require ScopeWatch. An empty
+// scope means the path is public.
+var routeScopes = []struct {
+	prefix string
+	scope  string
+}{
+	{"/static/", ""},
+	{"/favicon.ico", ""},
+	{"/jtvimage/", ""},
+	{"/jtvposter/", ""},
+	{"/dashtime", ""},
+	{"/healthz", ""},
+	{"/readyz", ""},
+	{"/login", ScopeAdmin},
+	{"/logout", ScopeAdmin},
+	{"/api/epg/", ScopeEPG},
+	{"/api/", ScopeAdmin},
+	{"/metrics", ScopeAdmin},
+	{"/playlist.m3u", ScopePlaylist},
+	{"/channels", ScopePlaylist},
+	{"/epg.xml.gz", ScopeEPG},
+	{"/epg/", ScopeEPG},
+	{"/get.php", ScopePlaylist},
+	{"/xmltv.php", ScopeEPG},
+	{"/player_api.php", ScopePlaylist},
+	{"/discover.json", ScopePlaylist},
+	{"/lineup.json", ScopePlaylist},
+	{"/lineup_status.json", ScopePlaylist},
+	{"/device.xml", ScopePlaylist},
+}
+
+// RouteScope returns the scope required for a path, or "" for public paths
+func RouteScope(path string) string {
+	path = strings.ToLower(path)
+	for _, route := range routeScopes {
+		if strings.HasPrefix(path, route.prefix) {
+			return route.scope
+		}
+	}
+	return ScopeWatch
+}
+
+// hasScope reports whether the user has access to scope
+func hasScope(user config.AuthUser, scope string) bool {
+	scopes := user.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+	return slices.Contains(scopes, ScopeAdmin) || slices.Contains(scopes, scope)
+}
+
+// secretEqual compares secrets in constant time. Empty secrets never match.
+func secretEqual(given, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(given), []byte(want)) == 1
+}
+
+// userByPassword returns the user with the given name and password
+func userByPassword(users []config.AuthUser, name, password string) *config.AuthUser {
+	for i := range users {
+		if secretEqual(name, users[i].Name) && secretEqual(password, users[i].Password) {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+// userByToken returns the user with the given token
+func userByToken(users []config.AuthUser, token string) *config.AuthUser {
+	for i := range users {
+		if secretEqual(token, users[i].Token) {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+// credentialSignature signs the name and password of a user with key, so that
+// the signature stops working when the password changes
+func credentialSignature(user config.AuthUser, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(user.Name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(user.Password))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// urlCredential returns the credential to carry in URLs generated for a user:
+// its token, or a signed credential for users that only have a password
+func urlCredential(user config.AuthUser) string {
+	if user.Token != "" {
+		return user.Token
+	}
+	return signedTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(user.Name)) + "." + credentialSignature(user, urlCredentialKeys()[0])
+}
+
+// userByURLCredential returns the user with the given token, or with the
+// given signed credential of urlCredential
+func userByURLCredential(users []config.AuthUser, credential string) *config.AuthUser {
+	if user := userByToken(users, credential); user != nil {
+		return user
+	}
+	signed, ok := strings.CutPrefix(credential, signedTokenPrefix)
+	if !ok {
+		return nil
+	}
+	encodedName, signature, _ := strings.Cut(signed, ".")
+	name, err := base64.RawURLEncoding.DecodeString(encodedName)
+	if err != nil {
+		return nil
+	}
+	keys := urlCredentialKeys()
+	for i := range users {
+		if users[i].Token != "" || users[i].Password == "" || !secretEqual(string(name), users[i].Name) {
+			continue
+		}
+		for _, key := range keys {
+			if secretEqual(signature, credentialSignature(users[i], key)) {
+				return &users[i]
+			}
+		}
+	}
+	return nil
+}
+
+// xtreamQueryRoutes are the Xtream Codes routes that take the username and
+// password as query parameters. Other routes never read them, so that
+// passwords are not put in URLs of the rest of the server.
+var xtreamQueryRoutes = []string{"/player_api.php", "/get.php", "/xmltv.php"}
+
+// xtreamPathCredentials returns the username and password of Xtream Codes
+// stream paths like /live/:username/:password/:id, and whether path is one
+func xtreamPathCredentials(path string) (string, string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch strings.ToLower(parts[0]) {
+	case "live":
+		if len(parts) != 4 {
+			return "", "", false
+		}
+	case "timeshift":
+		if len(parts) != 6 {
+			return "", "", false
+		}
+	default:
+		return "", "", false
+	}
+	username, _ := url.PathUnescape(parts[1])
+	password, _ := url.PathUnescape(parts[2])
+	return username, password, true
+}
+
+// xtreamCredentials returns the username and password Xtream Codes apps send
+// as query parameters of the Xtream routes or in stream paths
+func xtreamCredentials(c *fiber.Ctx) (string, string) {
+	if slices.Contains(xtreamQueryRoutes, strings.ToLower(c.Path())) {
+		if username := c.FormValue("username"); username != "" {
+			return username, c.FormValue("password")
+		}
+	}
+	if username, password, ok := xtreamPathCredentials(c.Path()); ok {
+		return username, password
+	}
+	return "", ""
+}
+
+// authenticate returns the user a request authenticates as, and whether the
+// credentials were part of the URL, so that generated URLs need them too.
+func authenticate(c *fiber.Ctx, users []config.AuthUser) (*config.AuthUser, bool) {
+	authorization := c.Get(fiber.HeaderAuthorization)
+	if encoded, ok := strings.CutPrefix(authorization, "Basic "); ok {
+		if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			name, password, _ := strings.Cut(string(decoded), ":")
+			if user := userByPassword(users, name, password); user != nil {
+				return user, false
+			}
+		}
+	}
+	if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		if user := userByToken(users, token); user != nil {
+			return user, false
+		}
+	}
+	if token := c.Get("X-API-Key"); token != "" {
+		if user := userByToken(users, token); user != nil {
+			return user, false
+		}
+	}
+	if token := c.Query(TokenQueryParam); token != "" {
+		if user := userByURLCredential(users, token); user != nil {
+			if c.Cookies(tokenCookie) != token {
+				c.Cookie(&fiber.Cookie{Name: tokenCookie, Value: token, Path: "/", HTTPOnly: true, SameSite: fiber.CookieSameSiteLaxMode})
+			}
+			return user, true
+		}
+	}
+	if token := c.Cookies(tokenCookie); token != "" {
+		if user := userByURLCredential(users, token); user != nil {
+			return user, false
+		}
+	}
+	if name, password := xtreamCredentials(c); name != "" {
+		if user := userByPassword(users, name, password); user != nil {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+// parseNetworks parses CIDR ranges, logging and skipping invalid ones
+func parseNetworks(cidrs []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			utils.SafeLogf("Invalid trusted network %q: %v", cidr, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// Auth middleware authenticates requests when users are configured. Users
+// log in with HTTP Basic authentication, an API key header or a token query
+// parameter for IPTV apps that can not send headers, and need the scope of
+// the route.
+func Auth() fiber.Handler {
+	users := config.Cfg.AuthUsers
+	trusted := parseNetworks(config.Cfg.AuthTrustedNetworks)
+
+	return func(c *fiber.Ctx) error {
+		if len(users) == 0 {
+			return c.Next()
+		}
+
+		// DASH segment paths carry the token as /render.dash/token/:token/...
+		var pathToken string
+		if rest, ok := strings.CutPrefix(c.Path(), dashTokenPrefix); ok {
+			// The path is overwritten below, so copy the token out of it
+			token, remainder, _ := strings.Cut(strings.Clone(rest), "/")
+			pathToken, _ = url.PathUnescape(token)
+			c.Path("/render.dash/" + remainder)
+		}
+
+		scope := RouteScope(c.Path())
+		if scope == "" {
+			return c.Next()
+		}
+		if ip := net.ParseIP(c.IP()); ip != nil {
+			for _, network := range trusted {
+				if network.Contains(ip) {
+					return c.Next()
+				}
+			}
+		}
+
+		user, fromURL := authenticate(c, users)
+		if user == nil && pathToken != "" {
+			user, fromURL = userByURLCredential(users, pathToken), true
+		}
+		if user == nil {
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="JioTV Go"`)
+			return internalUtils.ErrorResponse(c, fiber.StatusUnauthorized, "Authentication required")
+		}
+		if !hasScope(*user, scope) {
+			return internalUtils.ForbiddenError(c, "User "+user.Name+" does not have the "+scope+" scope")
+		}
+		if fromURL {
+			c.Locals(urlTokenLocal, urlCredential(*user))
+		}
+		return c.Next()
+	}
+}
+
+// URLToken returns the token to carry in URLs generated for a request. It is
+// empty unless the request authenticated with credentials in its URL. Users
+// without a token get a credential signed by the server instead.
+func URLToken(c *fiber.Ctx) string {
+	token, _ := c.Locals(urlTokenLocal).(string)
+	return token
+}
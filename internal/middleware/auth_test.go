@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+)
+
+func newAuthTestApp(t *testing.T, users []config.AuthUser, trusted []string) *fiber.App {
+	t.Helper()
+	previousUsers, previousTrusted := config.Cfg.AuthUsers, config.Cfg.AuthTrustedNetworks
+	config.Cfg.AuthUsers, config.Cfg.AuthTrustedNetworks = users, trusted
+	t.Cleanup(func() {
+		config.Cfg.AuthUsers, config.Cfg.AuthTrustedNetworks = previousUsers, previousTrusted
+	})
+
+	app := fiber.New()
+	app.Use(Auth())
+	// Handlers answer with the path they saw and the token to carry in URLs
+	app.Use(func(c *fiber.Ctx) error {
+		return c.SendString(c.Path() + " " + URLToken(c))
+	})
+	return app
+}
+
+func TestAuth(t *testing.T) {
+	users := []config.AuthUser{
+		{Name: "admin", Password: "secret", Scopes: []string{ScopeAdmin}},
+		{Name: "tv", Password: "tvpass", Token: "tv-token"},
+		{Name: "guest", Token: "guest-token", Scopes: []string{ScopePlaylist}},
+		{Name: "guide", Token: "guide-token", Scopes: []string{ScopeEPG}},
+	}
+	app := newAuthTestApp(t, users, nil)
+
+	tests := []struct {
+		name       string
+		path       string
+		header     map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "Public path", path: "/static/app.js", wantStatus: 200},
+		{name: "No credentials", path: "/live/143.m3u8", wantStatus: 401},
+		{name: "Basic auth", path: "/live/143.m3u8", header: map[string]string{"Authorization": "Basic dHY6dHZwYXNz"}, wantStatus: 200, wantBody: "/live/143.m3u8 "},
+		{name: "Wrong password", path: "/live/143.m3u8", header: map[string]string{"Authorization": "Basic dHY6d3Jvbmc="}, wantStatus: 401},
+		{name: "Bearer token", path: "/live/143.m3u8", header: map[string]string{"Authorization": "Bearer tv-token"}, wantStatus: 200},
+		{name: "API key", path: "/playlist.m3u", header: map[string]string{"X-API-Key": "tv-token"}, wantStatus: 200, wantBody: "/playlist.m3u "},
+		{name: "Query token is carried in URLs", path: "/playlist.m3u?token=tv-token", wantStatus: 200, wantBody: "/playlist.m3u tv-token"},
+		{name: "Cookie", path: "/live/143.m3u8", header: map[string]string{"Cookie": "jiotv_token=tv-token"}, wantStatus: 200},
+		{name: "Xtream path credentials", path: "/live/tv/tvpass/143.ts", wantStatus: 200, wantBody: "/live/tv/tvpass/143.ts tv-token"},
+		{name: "Xtream query credentials", path: "/player_api.php?username=tv&password=tvpass", wantStatus: 200},
+		{name: "Xtream query credentials ignored elsewhere", path: "/playlist.m3u?username=tv&password=tvpass", wantStatus: 401},
+		{name: "Xtream query credentials ignored on the API", path: "/api/recordings?username=admin&password=secret", wantStatus: 401},
+		{name: "Missing scope", path: "/live/143.m3u8?token=guest-token", wantStatus: 403},
+		{name: "Admin route needs admin", path: "/api/recordings?token=tv-token", wantStatus: 403},
+		{name: "EPG API needs epg", path: "/api/epg/now?token=guide-token", wantStatus: 200},
+		{name: "EPG needs epg", path: "/epg.xml.gz?token=guest-token", wantStatus: 403},
+		{name: "Xtream EPG needs epg", path: "/xmltv.php?token=guide-token", wantStatus: 200},
+		{name: "Playlist needs playlist", path: "/playlist.m3u?token=guide-token", wantStatus: 403},
+		{name: "Default scopes include epg", path: "/epg/0/1?token=tv-token", wantStatus: 200},
+		{name: "Admin route ignores case", path: "/API/recordings?token=tv-token", wantStatus: 403},
+		{name: "Admin has every scope", path: "/logout", header: map[string]string{"Authorization": "Basic YWRtaW46c2VjcmV0"}, wantStatus: 200},
+		{name: "Empty token never matches", path: "/live/143.m3u8", header: map[string]string{"X-API-Key": ""}, wantStatus: 401},
+		{name: "DASH path token", path: "/render.dash/token/tv-token/host/abc/path/def/seg.m4s", wantStatus: 200, wantBody: "/render.dash/host/abc/path/def/seg.m4s tv-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.StatusCode == 401 && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("401 response without WWW-Authenticate header")
+			}
+			if tt.wantBody != "" {
+				body, _ := io.ReadAll(resp.Body)
+				if string(body) != tt.wantBody {
+					t.Errorf("got body %q, want %q", body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthXtreamPasswordOnlyUser(t *testing.T) {
+	previousUsers := config.Cfg.AuthUsers
+	config.Cfg.AuthUsers = []config.AuthUser{{Name: "tv", Password: "tvpass"}}
+	t.Cleanup(func() { config.Cfg.AuthUsers = previousUsers })
+
+	app := fiber.New()
+	app.Use(Auth())
+	// Xtream stream paths redirect to the playlist with the URL credential,
+	// as liveQualityRedirect does
+	app.Get("/live/:username/:password/:id", func(c *fiber.Ctx) error {
+		return c.Redirect(WithURLQuery(c, "/render.m3u8?auth=x"), fiber.StatusFound)
+	})
+	app.Get("/render.m3u8", func(c *fiber.Ctx) error {
+		return c.SendString(WithURLQuery(c, "/render.ts?auth=y"))
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/live/tv/tvpass/143.m3u8", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != fiber.StatusFound || !strings.Contains(location, "token=u.") {
+		t.Fatalf("got status %d and location %q, want a redirect with a signed credential", resp.StatusCode, location)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, location, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), "token=u.") {
+		t.Fatalf("following the redirect got status %d and body %q, want 200 with the credential carried on", resp.StatusCode, body)
+	}
+
+	// The credential is bound to the password
+	config.Cfg.AuthUsers[0].Password = "changed"
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, location, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("after a password change got status %d, want 401", resp.StatusCode)
+	}
+	for _, forged := range []string{"u.dHY.forged", "u.", "u.!!!.x"} {
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/render.m3u8?token="+forged, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 401 {
+			t.Errorf("forged credential %q got status %d, want 401", forged, resp.StatusCode)
+		}
+	}
+}
+
+func TestURLCredentialSurvivesRestart(t *testing.T) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	users := []config.AuthUser{{Name: "tv", Password: "tvpass"}}
+	secureurl.Init()
+	credential := urlCredential(users[0])
+
+	// A restart loads the persisted URL encryption key again
+	secureurl.Init()
+	if user := userByURLCredential(users, credential); user == nil {
+		t.Error("signed credential stopped working after a restart")
+	}
+	if _, err := secureurl.RotateKey(0); err != nil {
+		t.Fatal(err)
+	}
+	if user := userByURLCredential(users, credential); user != nil {
+		t.Error("signed credential still works after the key was rotated")
+	}
+}
+
+func TestAuthDisabledAndTrustedNetworks(t *testing.T) {
+	app := newAuthTestApp(t, nil, nil)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/recordings", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("without users got status %d, want 200", resp.StatusCode)
+	}
+
+	// Test requests come from 0.0.0.0
+	app = newAuthTestApp(t, []config.AuthUser{{Name: "tv", Password: "tvpass"}}, []string{"invalid", "0.0.0.0/32"})
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/api/recordings", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("from a trusted network got status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	tests := []struct {
+		url   string
+		query string
+		want  string
+	}{
+		{url: "/live/143.m3u8", query: "", want: "/live/143.m3u8"},
+		{url: "/live/143.m3u8", query: "token=a+b", want: "/live/143.m3u8?token=a+b"},
+		{url: "/render.m3u8?auth=x", query: "token=t", want: "/render.m3u8?auth=x&token=t"},
+	}
+	for _, tt := range tests {
+		if got := AppendQuery(tt.url, tt.query); got != tt.want {
+			t.Errorf("AppendQuery(%q, %q) = %q, want %q", tt.url, tt.query, got, tt.want)
+		}
+	}
+}
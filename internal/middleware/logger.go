@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/valyala/fasthttp"
+)
+
+// redacted replaces credentials in access logs
+const redacted = "REDACTED"
+
+// redactedQueryParams are the query parameters carrying credentials
+var redactedQueryParams = []string{TokenQueryParam, "password"}
+
+// Logger middleware writes an access log line for every request to output.
+// Credentials in paths and query parameters are redacted.
+func Logger(output io.Writer) fiber.Handler {
+	return logger.New(logger.Config{
+		TimeZone: "Asia/Kolkata",
+		Format:   "[${time}] ${status} - ${latency} ${method} ${path} Params:[${queryParams}] ${error}\n",
+		Output:   output,
+		CustomTags: map[string]logger.LogFunc{
+			logger.TagPath: func(output logger.Buffer, c *fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+				return output.WriteString(RedactedPath(c.Path()))
+			},
+			logger.TagQueryStringParams: func(output logger.Buffer, c *fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+				return output.WriteString(RedactedQuery(c.Request().URI().QueryArgs()))
+			},
+		},
+	})
+}
+
+// RedactedPath returns path with the password of Xtream Codes stream paths
+// and the token of DASH segment paths redacted
+func RedactedPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, dashTokenPrefix); ok {
+		_, remainder, _ := strings.Cut(rest, "/")
+		return dashTokenPrefix + redacted + "/" + remainder
+	}
+	if _, _, ok := xtreamPathCredentials(path); ok {
+		parts := strings.Split(path, "/")
+		// The path starts with a slash, so the password is the fourth part
+		if len(parts) > 3 {
+			parts[3] = redacted
+			return strings.Join(parts, "/")
+		}
+	}
+	return path
+}
+
+// RedactedQuery returns the query string of args with the values of
+// credential parameters redacted
+func RedactedQuery(args *fasthttp.Args) string {
+	query := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(query)
+	args.VisitAll(func(key, value []byte) {
+		if slices.ContainsFunc(redactedQueryParams, func(name string) bool { return strings.EqualFold(string(key), name) }) {
+			value = []byte(redacted)
+		}
+		query.AddBytesKV(key, value)
+	})
+	return query.String()
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRedactedPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/live/143.m3u8", want: "/live/143.m3u8"},
+		{path: "/live/high/143.m3u8", want: "/live/high/143.m3u8"},
+		{path: "/live/tv/tvpass/143.ts", want: "/live/tv/REDACTED/143.ts"},
+		{path: "/timeshift/tv/tvpass/60/2024-01-15:18-00/143.ts", want: "/timeshift/tv/REDACTED/60/2024-01-15:18-00/143.ts"},
+		{path: "/render.dash/token/tv-token/host/abc/seg.m4s", want: "/render.dash/token/REDACTED/host/abc/seg.m4s"},
+	}
+	for _, tt := range tests {
+		if got := RedactedPath(tt.path); got != tt.want {
+			t.Errorf("RedactedPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRedactedQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: ""},
+		{query: "q=high&auth=abc", want: "q=high&auth=abc"},
+		{query: "token=u.dHY.sig&q=high", want: "token=REDACTED&q=high"},
+		{query: "username=tv&password=tvpass&action=get_live_streams", want: "username=tv&password=REDACTED&action=get_live_streams"},
+	}
+	for _, tt := range tests {
+		var args fasthttp.Args
+		args.Parse(tt.query)
+		if got := RedactedQuery(&args); got != tt.want {
+			t.Errorf("RedactedQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	app := fiber.New()
+	app.Use(Logger(&out))
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	if _, err := app.Test(httptest.NewRequest(http.MethodGet, "/live/tv/tvpass/143.ts?token=secret-token", nil)); err != nil {
+		t.Fatal(err)
+	}
+	line := out.String()
+	if strings.Contains(line, "tvpass") || strings.Contains(line, "secret-token") {
+		t.Errorf("access log contains credentials: %q", line)
+	}
+	if !strings.Contains(line, "/live/tv/REDACTED/143.ts") {
+		t.Errorf("access log = %q, want the redacted path", line)
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("jiotv_http_requests_total",
+		"HTTP requests by route family and status code.", "family", "code")
+	httpRequestDuration = metrics.NewHistogram("jiotv_http_request_duration_seconds",
+		"Time to handle HTTP requests by route family. Streamed bodies are not included.", metrics.DefaultBuckets, "family")
+)
+
+// routeFamilies maps path prefixes to the route family they are counted in.
+// Paths are matched in order, and paths that match none are counted as "web".
+var routeFamilies = []struct {
+	prefix string
+	family string
+}{
+	{"/live/mpd/", "drm"},
+	{"/live/key/", "drm"},
+	{"/mpd/", "drm"},
+	{"/render.mpd", "drm"},
+	{"/drm", "drm"},
+	{"/render.dash", "dash"},
+	{"/live/", "live"},
+	{"/stream/", "live"},
+	{"/out/", "live"},
+	{"/render.m3u8", "render.m3u8"},
+	{"/render.ts", "render.ts"},
+	{"/render.key", "render.key"},
+	{"/epg.xml.gz", "epg"},
+	{"/epg/", "epg"},
+	{"/xmltv.php", "epg"},
+	{"/api/epg/", "epg"},
+	{"/catchup/", "catchup"},
+	{"/api/catchup/", "catchup"},
+	{"/timeshift/", "catchup"},
+	{"/streaming/timeshift.php", "catchup"},
+	{"/premium/", "premium"},
+	{"/playlist.m3u", "playlist"},
+	{"/channels", "playlist"},
+	{"/get.php", "playlist"},
+	{"/player_api.php", "playlist"},
+	{"/discover.json", "playlist"},
+	{"/lineup.json", "playlist"},
+	{"/lineup_status.json", "playlist"},
+	{"/device.xml", "playlist"},
+	{"/api/", "api"},
+	{"/metrics", "api"},
+	{"/healthz", "api"},
+	{"/readyz", "api"},
+}
+
+// RouteFamily returns the route family a path is counted in
+func RouteFamily(path string) string {
+	path = strings.ToLower(path)
+	for _, route := range routeFamilies {
+		if strings.HasPrefix(path, route.prefix) {
+			return route.family
+		}
+	}
+	return "web"
+}
+
+// Metrics middleware counts requests and their latency by route family
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		// Handlers may rewrite the path, so pick the family first
+		family := RouteFamily(c.Path())
+		err := c.Next()
+
+		code := c.Response().StatusCode()
+		if err != nil {
+			// The error handler sets the status after the middleware returns
+			code = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				code = fiberErr.Code
+			}
+		}
+		httpRequests.Inc(family, strconv.Itoa(code))
+		httpRequestDuration.Observe(time.Since(start).Seconds(), family)
+		return err
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRouteFamily(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/live/143.m3u8", "live"},
+		{"/live/high/143.m3u8", "live"},
+		{"/stream/143.ts", "live"},
+		{"/live/mpd/143", "drm"},
+		{"/live/key/143", "drm"},
+		{"/mpd/143", "drm"},
+		{"/render.mpd", "drm"},
+		{"/drm", "drm"},
+		{"/render.dash/host/cdn/path/seg.m4s", "dash"},
+		{"/render.m3u8", "render.m3u8"},
+		{"/Render.TS", "render.ts"},
+		{"/epg.xml.gz", "epg"},
+		{"/epg/143/0", "epg"},
+		{"/xmltv.php", "epg"},
+		{"/catchup/143", "catchup"},
+		{"/api/catchup/downloads", "catchup"},
+		{"/api/epg/now", "epg"},
+		{"/timeshift/u/p/60/2024-01-01:10-00/143.ts", "catchup"},
+		{"/premium/providers", "premium"},
+		{"/playlist.m3u", "playlist"},
+		{"/api/session", "api"},
+		{"/metrics", "api"},
+		{"/", "web"},
+		{"/play/143", "web"},
+	}
+	for _, tt := range tests {
+		if got := RouteFamily(tt.path); got != tt.want {
+			t.Errorf("RouteFamily(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	app := fiber.New()
+	app.Use(Metrics())
+	app.Get("/render.m3u8", func(c *fiber.Ctx) error {
+		return c.SendString("#EXTM3U")
+	})
+	app.Get("/render.ts", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusBadGateway, "upstream failed")
+	})
+
+	before := map[string]float64{
+		"200": httpRequests.Value("render.m3u8", "200"),
+		"502": httpRequests.Value("render.ts", "502"),
+		"404": httpRequests.Value("web", "404"),
+	}
+	for _, path := range []string{"/render.m3u8", "/render.ts", "/missing"} {
+		if _, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := httpRequests.Value("render.m3u8", "200") - before["200"]; got != 1 {
+		t.Errorf("render.m3u8 200 count increased by %v, want 1", got)
+	}
+	if got := httpRequests.Value("render.ts", "502") - before["502"]; got != 1 {
+		t.Errorf("render.ts 502 count increased by %v, want 1", got)
+	}
+	if got := httpRequests.Value("web", "404") - before["404"]; got != 1 {
+		t.Errorf("web 404 count increased by %v, want 1", got)
+	}
+}
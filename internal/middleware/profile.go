@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	internalUtils "github.com/jiotv-go/jiotv_go/v3/internal/utils"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// ProfileQueryParam is the query parameter selecting the profile of a request
+	ProfileQueryParam = "profile"
+	// profileLocal is the Locals key of the profile of a request
+	profileLocal = "profile"
+	// profilePathPrefix selects the profile of a request as /p/:profile/...
+	profilePathPrefix = "/p/"
+)
+
+// Profile middleware selects the account profile of a request from a
+// /p/:profile/ path prefix or the profile query parameter. The prefix is
+// removed from the path, so routes match as they do without it. Requests
+// without a profile use the default profile. The profile is checked by
+// CheckProfile once the request is authenticated.
+func Profile() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var profile string
+		if path := c.Path(); strings.HasPrefix(strings.ToLower(path), profilePathPrefix) {
+			// The path is overwritten below, so copy the profile out of it
+			name, remainder, _ := strings.Cut(strings.Clone(path[len(profilePathPrefix):]), "/")
+			profile, _ = url.PathUnescape(name)
+			c.Path("/" + remainder)
+		} else {
+			profile = c.Query(ProfileQueryParam)
+		}
+		if profile != "" {
+			c.Locals(profileLocal, utils.NormalizeProfile(profile))
+		}
+		return c.Next()
+	}
+}
+
+// CheckProfile middleware rejects requests for profiles that do not exist.
+// It runs after Auth, so that only authenticated users learn which
+// profiles exist. Public paths do not use profiles and are not checked.
+func CheckProfile() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if RouteScope(c.Path()) == "" {
+			return c.Next()
+		}
+		profile := ProfileName(c)
+		if profile != utils.DefaultProfile && profile != utils.AutoProfile && !utils.ProfileExists(profile) {
+			return internalUtils.NotFoundError(c, "Profile "+profile+" not found")
+		}
+		return c.Next()
+	}
+}
+
+// ProfileName returns the profile selected for a request. Requests for
+// utils.AutoProfile keep it until handlers resolve it with SetProfile.
+func ProfileName(c *fiber.Ctx) string {
+	if profile, _ := c.Locals(profileLocal).(string); profile != "" {
+		return profile
+	}
+	return utils.DefaultProfile
+}
+
+// SetProfile sets the profile of a request, so that URLs generated for it
+// use the profile too
+func SetProfile(c *fiber.Ctx, profile string) {
+	c.Locals(profileLocal, utils.NormalizeProfile(profile))
+}
+
+// URLQuery returns the encoded query parameters to carry in URLs generated for
+// a request: the token of the user if it authenticated with credentials in
+// its URL, and the profile unless it is the default one.
+func URLQuery(c *fiber.Ctx) string {
+	query := url.Values{}
+	if token := URLToken(c); token != "" {
+		query.Set(TokenQueryParam, token)
+	}
+	if profile := ProfileName(c); profile != utils.DefaultProfile {
+		query.Set(ProfileQueryParam, profile)
+	}
+	return query.Encode()
+}
+
+// WithURLQuery adds the query parameters to carry for a request to a
+// generated URL
+func WithURLQuery(c *fiber.Ctx, rawURL string) string {
+	return AppendQuery(rawURL, URLQuery(c))
+}
+
+// AppendQuery adds encoded query parameters to rawURL. An empty rawURL is
+// returned as is.
+func AppendQuery(rawURL, query string) string {
+	if query == "" || rawURL == "" {
+		return rawURL
+	}
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	return rawURL + separator + query
+}
+
+// DASHBasePath returns the path prefix of DASH segment URLs for a request.
+// DASH players drop the query of BaseURL, so the profile and token are part
+// of the path.
+func DASHBasePath(c *fiber.Ctx) string {
+	basePath := "/render.dash"
+	if profile := ProfileName(c); profile != utils.DefaultProfile {
+		basePath = profilePathPrefix + url.PathEscape(profile) + basePath
+	}
+	if token := URLToken(c); token != "" {
+		basePath += "/token/" + url.PathEscape(token)
+	}
+	return basePath
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func newProfileTestApp(t *testing.T, profiles ...string) *fiber.App {
+	t.Helper()
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, profile := range profiles {
+		if err := utils.WriteJIOTVCredentialsFor(profile, &utils.JIOTV_CREDENTIALS{SSOToken: "sso-" + profile}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previousUsers := config.Cfg.AuthUsers
+	config.Cfg.AuthUsers = []config.AuthUser{{Name: "tv", Token: "tv-token"}}
+	t.Cleanup(func() { config.Cfg.AuthUsers = previousUsers })
+
+	app := fiber.New()
+	app.Use(Profile())
+	app.Use(Auth())
+	app.Use(CheckProfile())
+	// Handlers answer with the path and profile they saw, and the query and
+	// DASH base path to carry in URLs
+	app.Use(func(c *fiber.Ctx) error {
+		return c.SendString(c.Path() + " " + ProfileName(c) + " " + URLQuery(c) + " " + DASHBasePath(c))
+	})
+	return app
+}
+
+func TestProfile(t *testing.T) {
+	app := newProfileTestApp(t, "family")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "No profile", path: "/live/143.m3u8?token=tv-token", wantStatus: 200, wantBody: "/live/143.m3u8 default token=tv-token /render.dash/token/tv-token"},
+		{name: "Path prefix", path: "/p/family/live/143.m3u8?token=tv-token", wantStatus: 200, wantBody: "/live/143.m3u8 family profile=family&token=tv-token /p/family/render.dash/token/tv-token"},
+		{name: "Path prefix ignores case", path: "/P/Family/playlist.m3u?token=tv-token", wantStatus: 200, wantBody: "/playlist.m3u family profile=family&token=tv-token /p/family/render.dash/token/tv-token"},
+		{name: "Query parameter", path: "/live/143.m3u8?token=tv-token&profile=family", wantStatus: 200, wantBody: "/live/143.m3u8 family profile=family&token=tv-token /p/family/render.dash/token/tv-token"},
+		{name: "Auto profile", path: "/p/auto/playlist.m3u?token=tv-token", wantStatus: 200, wantBody: "/playlist.m3u auto profile=auto&token=tv-token /p/auto/render.dash/token/tv-token"},
+		{name: "Unknown profile", path: "/p/stranger/live/143.m3u8?token=tv-token", wantStatus: 404},
+		{name: "Prefixed DASH path token", path: "/p/family/render.dash/token/tv-token/host/abc/seg.m4s", wantStatus: 200, wantBody: "/render.dash/host/abc/seg.m4s family profile=family&token=tv-token /p/family/render.dash/token/tv-token"},
+		{name: "Prefix still needs credentials", path: "/p/family/live/143.m3u8", wantStatus: 401},
+		{name: "Unknown profile needs credentials", path: "/p/stranger/live/143.m3u8", wantStatus: 401},
+		{name: "Unknown profile query needs credentials", path: "/live/143.m3u8?profile=stranger", wantStatus: 401},
+		{name: "Public paths do not check profiles", path: "/p/stranger/static/app.js", wantStatus: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" {
+				body, _ := io.ReadAll(resp.Body)
+				if string(body) != tt.wantBody {
+					t.Errorf("got body %q, want %q", body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
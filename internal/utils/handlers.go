@@ -5,6 +5,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
 	"github.com/valyala/fasthttp"
@@ -58,13 +59,35 @@ func SetPlayerHeaders(c *fiber.Ctx, userAgent string) {
 	c.Request().Header.Del("Referer")
 }
 
-// DecryptURLParam decrypts a URL parameter and handles errors
-func DecryptURLParam(paramName, encryptedURL string) (string, error) {
+// URLTokenError sends a 403 error response with the reason code of a
+// rejected encrypted URL, or a 400 error response for other errors
+func URLTokenError(c *fiber.Ctx, err error) error {
+	reason := secureurl.Reason(err)
+	if reason == "" {
+		return BadRequestError(c, err.Error())
+	}
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"message": err.Error(),
+		"reason":  reason,
+	})
+}
+
+// URLClient returns the client encrypted URLs are bound to, which is empty
+// unless client binding is enabled in the config
+func URLClient(c *fiber.Ctx) string {
+	if !config.Cfg.URLClientBinding {
+		return ""
+	}
+	return c.IP()
+}
+
+// DecryptURLParam decrypts a URL parameter of a request from client and handles errors
+func DecryptURLParam(paramName, encryptedURL, client string) (string, error) {
 	if encryptedURL == "" {
 		return "", fmt.Errorf("%s not provided", paramName)
 	}
 
-	decoded, err := secureurl.DecryptURL(encryptedURL)
+	decoded, err := secureurl.DecryptURLFor(encryptedURL, client)
 	if err != nil {
 		utils.SafeLogf("Error decrypting %s: %v", paramName, err)
 		return "", err
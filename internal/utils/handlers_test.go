@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -73,11 +76,11 @@ func TestValidateRequiredParam(t *testing.T) {
 
 func TestDecryptURLParam(t *testing.T) {
 	// Test empty parameter
-	_, err := DecryptURLParam("test", "")
+	_, err := DecryptURLParam("test", "", "")
 	assert.Error(t, err, "Expected error for empty URL")
 
 	// Test invalid encrypted URL
-	_, err = DecryptURLParam("test", "invalid")
+	_, err = DecryptURLParam("test", "invalid", "")
 	assert.Error(t, err, "Expected error for invalid encrypted URL")
 }
 func TestSetPlayerHeadersStripsBrowserHeaders(t *testing.T) {
@@ -110,3 +113,32 @@ func TestSetPlayerHeadersStripsBrowserHeaders(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 }
+
+func TestURLTokenError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantReason string
+	}{
+		{name: "Expired URL", err: &secureurl.TokenError{Reason: secureurl.ReasonExpired, Message: "URL expired"}, wantStatus: fiber.StatusForbidden, wantReason: secureurl.ReasonExpired},
+		{name: "Missing parameter", err: errors.New("auth not provided"), wantStatus: fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/test", func(c *fiber.Ctx) error {
+				return URLTokenError(c, tt.err)
+			})
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/test", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			var body map[string]string
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.err.Error(), body["message"])
+			assert.Equal(t, tt.wantReason, body["reason"])
+		})
+	}
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	_ "embed"
+	"fmt"
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/jiotv-go/jiotv_go/v3/cmd"
 	"github.com/jiotv-go/jiotv_go/v3/internal/constants"
+	"github.com/jiotv-go/jiotv_go/v3/internal/handlers"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
@@ -35,6 +38,12 @@ func main() {
 			utils.BoolFlag("skip-update-check", "Skip checking for update on startup", "skip-update"),
 		},
 		Before: func(c *cli.Context) error {
+			// The doctor command loads the config and the store itself, so that
+			// it reports their errors instead of stopping on them
+			if slices.Contains(cmd.DoctorCommandNames, c.Args().First()) {
+				return nil
+			}
+
 			configPath := c.String("config")
 			// Load the config file first
 			if err := cmd.LoadConfig(configPath); err != nil {
@@ -126,28 +135,350 @@ func main() {
 					}),
 				},
 			}),
+			utils.NewCommand(utils.CommandConfig{
+				Name:        "record",
+				Aliases:     []string{"rec"},
+				Usage:       "Manage recordings",
+				Description: "The record command schedules, lists and cancels recordings of live channels. Recordings are made by the running JioTV Go server and saved as .ts files in the recordings folder of the path prefix.",
+				Subcommands: []*cli.Command{
+					utils.NewCommand(utils.CommandConfig{
+						Name:        "add",
+						Aliases:     []string{"a"},
+						Usage:       "Schedule a recording",
+						Description: "The add command schedules a recording of a channel, either between --start and --stop or for an EPG programme with --programme. Times are in IST and can be given as \"2006-01-02 15:04\", \"15:04\" for today, RFC 3339 or unix seconds. --programme takes a time during the programme, \"now\" or \"next\".",
+						Action: func(c *cli.Context) error {
+							return cmd.RecordAdd(handlers.RecordingRequest{
+								ChannelID: c.String("channel"),
+								Title:     c.String("title"),
+								Quality:   c.String("quality"),
+								Start:     c.String("start"),
+								Stop:      c.String("stop"),
+								Programme: c.String("programme"),
+							})
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "channel",
+								Aliases:  []string{"c"},
+								Usage:    "Channel ID to record",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "start",
+								Usage: "Start time of the recording",
+							},
+							&cli.StringFlag{
+								Name:  "stop",
+								Usage: "Stop time of the recording",
+							},
+							&cli.StringFlag{
+								Name:    "programme",
+								Aliases: []string{"p"},
+								Usage:   "Record the EPG programme airing at this time, \"now\" or \"next\"",
+							},
+							&cli.StringFlag{
+								Name:    "title",
+								Aliases: []string{"t"},
+								Usage:   "Title of the recording, defaults to the programme title",
+							},
+							&cli.StringFlag{
+								Name:    "quality",
+								Aliases: []string{"q"},
+								Value:   "auto",
+								Usage:   "Quality to record: auto, high, medium or low",
+							},
+						},
+					}),
+					utils.NewCommand(utils.CommandConfig{
+						Name:        "list",
+						Aliases:     []string{"ls"},
+						Usage:       "List recordings",
+						Description: "The list command lists scheduled, running and finished recordings.",
+						Action: func(c *cli.Context) error {
+							return cmd.RecordList()
+						},
+					}),
+					utils.NewCommand(utils.CommandConfig{
+						Name:        "cancel",
+						Aliases:     []string{"rm"},
+						Usage:       "Cancel a recording",
+						Description: "The cancel command cancels a scheduled or running recording by its ID. A running recording is stopped by the server within a few seconds and keeps what was recorded so far.",
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								return fmt.Errorf("usage: jiotv_go record cancel <id>")
+							}
+							return cmd.RecordCancel(c.Args().First())
+						},
+					}),
+					utils.NewCommand(utils.CommandConfig{
+						Name:        "rules",
+						Usage:       "Manage series recording rules",
+						Description: "The rules command manages rules that schedule a recording of every EPG programme they match, such as every episode of a show on a channel. Rules are applied after every EPG update and when the server starts.",
+						Subcommands: []*cli.Command{
+							utils.NewCommand(utils.CommandConfig{
+								Name:        "add",
+								Aliases:     []string{"a"},
+								Usage:       "Add a recording rule",
+								Description: "The add command adds a rule. A programme matches when it matches every given criterion. Title and category match case-insensitively as substrings, or as regular expressions with --regex.",
+								Action: func(c *cli.Context) error {
+									return cmd.RecordRuleAdd(handlers.RecordingRule{
+										Name:              c.String("name"),
+										Title:             c.String("title"),
+										Category:          c.String("category"),
+										Regex:             c.Bool("regex"),
+										ChannelIDs:        c.StringSlice("channel"),
+										ChannelCategories: c.StringSlice("channel-category"),
+										Languages:         c.StringSlice("language"),
+										Action:            c.String("action"),
+										Quality:           c.String("quality"),
+										KeepLast:          c.Int("keep"),
+									})
+								},
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:    "name",
+										Aliases: []string{"n"},
+										Usage:   "Name of the rule",
+									},
+									&cli.StringFlag{
+										Name:    "title",
+										Aliases: []string{"t"},
+										Usage:   "Match programme titles",
+									},
+									&cli.StringFlag{
+										Name:  "category",
+										Usage: "Match programme categories, such as Sports",
+									},
+									&cli.StringSliceFlag{
+										Name:    "channel",
+										Aliases: []string{"c"},
+										Usage:   "Only match programmes of this channel ID. Can be repeated",
+									},
+									&cli.StringSliceFlag{
+										Name:  "channel-category",
+										Usage: "Only match programmes of channels in this category, such as Sports. Can be repeated",
+									},
+									&cli.StringSliceFlag{
+										Name:  "language",
+										Usage: "Only match programmes of channels in this language, such as Hindi. Can be repeated",
+									},
+									&cli.BoolFlag{
+										Name:    "regex",
+										Aliases: []string{"r"},
+										Usage:   "Treat title and category as regular expressions",
+									},
+									&cli.StringFlag{
+										Name:  "action",
+										Value: "record",
+										Usage: "\"record\" to record the live airing or \"catchup\" to download it from catchup after it aired",
+									},
+									&cli.StringFlag{
+										Name:    "quality",
+										Aliases: []string{"q"},
+										Value:   "auto",
+										Usage:   "Quality to record: auto, high, medium or low",
+									},
+									&cli.IntFlag{
+										Name:    "keep",
+										Aliases: []string{"k"},
+										Usage:   "Keep only the newest N completed recordings of the rule. 0 keeps all",
+									},
+								},
+							}),
+							utils.NewCommand(utils.CommandConfig{
+								Name:        "list",
+								Aliases:     []string{"ls"},
+								Usage:       "List recording rules",
+								Description: "The list command lists all recording rules.",
+								Action: func(c *cli.Context) error {
+									return cmd.RecordRuleList()
+								},
+							}),
+							utils.NewCommand(utils.CommandConfig{
+								Name:        "delete",
+								Aliases:     []string{"del", "rm"},
+								Usage:       "Delete a recording rule",
+								Description: "The delete command deletes a recording rule by its ID. Recordings it already scheduled are kept.",
+								Action: func(c *cli.Context) error {
+									if c.Args().Len() != 1 {
+										return fmt.Errorf("usage: jiotv_go record rules delete <id>")
+									}
+									return cmd.RecordRuleDelete(c.Args().First())
+								},
+							}),
+							utils.NewCommand(utils.CommandConfig{
+								Name:        "preview",
+								Aliases:     []string{"dry-run"},
+								Usage:       "List upcoming programmes the rules match",
+								Description: "The preview command fetches the EPG and lists the recordings the rules would schedule, without scheduling them.",
+								Action: func(c *cli.Context) error {
+									return cmd.RecordRulePreview()
+								},
+							}),
+						},
+					}),
+				},
+			}),
+			utils.NewCommand(utils.CommandConfig{
+				Name:        "catchup",
+				Usage:       "Manage catchup",
+				Description: "The catchup command works with programmes that already aired and are available from catchup for seven days.",
+				Subcommands: []*cli.Command{
+					utils.NewCommand(utils.CommandConfig{
+						Name:        "download",
+						Aliases:     []string{"dl", "d"},
+						Usage:       "Download a catchup programme",
+						Description: "The download command downloads a catchup programme to a .ts file in the recordings folder. The programme is given as <channel> <srno|start-end>, where srno is the serial number from the EPG and start and end are unix epochs in seconds or milliseconds, as in catchup URLs.",
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 2 {
+								return fmt.Errorf("usage: jiotv_go catchup download <channel> <srno|start-end>")
+							}
+							return cmd.CatchupDownload(c.Args().Get(0), c.Args().Get(1), c.String("quality"))
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "quality",
+								Aliases: []string{"q"},
+								Value:   "auto",
+								Usage:   "Quality to download: auto, high, medium or low",
+							},
+						},
+					}),
+				},
+			}),
+			utils.NewCommand(utils.CommandConfig{
+				Name:        "channels",
+				Aliases:     []string{"ch"},
+				Usage:       "Manage channels",
+				Description: "The channels command works with the channel list of JioTV.",
+				Subcommands: []*cli.Command{
+					utils.NewCommand(utils.CommandConfig{
+						Name:        "probe",
+						Aliases:     []string{"p"},
+						Usage:       "Detect which channels stream with DRM",
+						Description: "The probe command fetches the live stream of every channel to learn which of them stream with DRM, and saves the result in the drm_channels.json file in the path prefix. The server also learns this whenever a channel is played; probing updates all channels at once. A running server picks up the result the next time it saves what it learned, or when it restarts.",
+						Action: func(c *cli.Context) error {
+							return cmd.ChannelsProbe(c.Int("concurrency"))
+						},
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:    "concurrency",
+								Aliases: []string{"c"},
+								Value:   4,
+								Usage:   "Number of channels to probe at the same time",
+							},
+						},
+					}),
+				},
+			}),
+			utils.NewCommand(utils.CommandConfig{
+				Name:        "secureurl",
+				Usage:       "Manage the URL encryption key",
+				Description: "The secureurl command manages the key used to encrypt stream URLs. The key is kept in the secureurl.key file in the path prefix, so encrypted URLs in playlists keep working across restarts.",
+				Subcommands: []*cli.Command{
+					utils.NewCommand(utils.CommandConfig{
+						Name:        "rotate",
+						Usage:       "Replace the URL encryption key",
+						Description: "The rotate command replaces the URL encryption key with a new one. URLs encrypted with the previous key keep working for the grace period. Restart the server to start using the new key.",
+						Action: func(c *cli.Context) error {
+							return cmd.SecureURLRotate(c.Duration("grace"))
+						},
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:    "grace",
+								Aliases: []string{"g"},
+								Value:   24 * time.Hour,
+								Usage:   "How long URLs encrypted with the previous key keep working, 0 to stop them immediately",
+							},
+						},
+					}),
+				},
+			}),
+			utils.NewCommand(utils.CommandConfig{
+				Name:        cmd.DoctorCommandNames[0],
+				Aliases:     cmd.DoctorCommandNames[1:],
+				Usage:       "Diagnose why channels do not play",
+				Description: "The doctor command checks the config file, the path prefix, the store, the login tokens, and plays a channel with and without DRM. It prints what passed, warned or failed, with hints to fix the failures. Attach the --json output to bug reports.",
+				Action: func(c *cli.Context) error {
+					return cmd.Doctor(c.String("config"), c.Bool("json"))
+				},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Value: false,
+						Usage: "Print the report as JSON",
+					},
+				},
+			}),
 			{
 				Name:        "login",
 				Aliases:     []string{"l"},
 				Usage:       "Manage login",
-				Description: "The login command manages login. It can be used to login, logout.",
+				Description: "The login command manages login. It can be used to login, logout and list the logged in profiles.",
 				Subcommands: []*cli.Command{
 					{
 						Name:        "otp",
 						Aliases:     []string{"o"},
 						Usage:       "Login using OTP",
-						Description: "The otp command logs you in using OTP. It will send OTP to your mobile number, and you have to enter the OTP to login.",
+						Description: "The otp command logs you in using OTP. It will send OTP to your mobile number, and you have to enter the OTP to login. Use --profile to log in another account as a named profile.",
 						Action: func(c *cli.Context) error {
-							return cmd.LoginOTP()
+							return cmd.LoginOTP(c.String("profile"))
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "profile",
+								Aliases: []string{"p"},
+								Value:   "",
+								Usage:   "Name of the account profile, the default profile if empty",
+							},
 						},
 					},
 					{
 						Name:        "reset",
 						Aliases:     []string{"lo", "logout"},
 						Usage:       "Logout",
-						Description: "The logout command logs you out. It will delete the login file.",
+						Description: "The logout command logs you out. It will delete the login credentials of the profile.",
+						Action: func(c *cli.Context) error {
+							return cmd.Logout(c.String("profile"))
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "profile",
+								Aliases: []string{"p"},
+								Value:   "",
+								Usage:   "Name of the account profile, the default profile if empty",
+							},
+						},
+					},
+					{
+						Name:        "status",
+						Aliases:     []string{"s"},
+						Usage:       "Show the login session",
+						Description: "The status command shows the login session of a profile: the masked account IDs, when the tokens expire and were last refreshed, the premium providers, and whether JioTV rejected the refresh token.",
+						Action: func(c *cli.Context) error {
+							return cmd.LoginStatus(c.String("profile"), c.Bool("json"))
+						},
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "profile",
+								Aliases: []string{"p"},
+								Value:   "",
+								Usage:   "Name of the account profile, the default profile if empty",
+							},
+							&cli.BoolFlag{
+								Name:  "json",
+								Value: false,
+								Usage: "Print the session as JSON",
+							},
+						},
+					},
+					{
+						Name:        "profiles",
+						Aliases:     []string{"p"},
+						Usage:       "List logged in profiles",
+						Description: "The profiles command lists the profiles with saved login credentials.",
 						Action: func(c *cli.Context) error {
-							return cmd.Logout()
+							return cmd.LoginProfiles()
 						},
 					},
 				},
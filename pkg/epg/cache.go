@@ -0,0 +1,230 @@
+package epg
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// maxEPGDays is how many days before and after today the JioTV EPG API serves
+	maxEPGDays = 7
+	// defaultFutureDays is the number of days after today fetched by default
+	defaultFutureDays = 1
+	// cacheMaxAge is how long the programmes of a day that has not ended are
+	// reused before they are fetched again
+	cacheMaxAge = 12 * time.Hour
+	// cacheVersion is changed whenever cached days miss fields the EPG needs
+	cacheVersion = 2
+	// cacheDateFormat names the directory of each day in the cache
+	cacheDateFormat = "2006-01-02"
+)
+
+// ErrNoCachedProgrammes is returned by CachedProgrammes when no day of the
+// EPG is cached yet
+var ErrNoCachedProgrammes = errors.New("no EPG programmes are cached yet")
+
+// ErrProgrammeNotFound is returned by FindProgramme when nothing airs at the given time
+var ErrProgrammeNotFound = errors.New("no programme found")
+
+// ist is the time zone of the days served by the JioTV EPG API
+var ist = time.FixedZone("IST", 5*3600+30*60)
+
+// cachedDay is the programmes of a channel on a day, as saved in the cache
+type cachedDay struct {
+	Version   int         `json:"version"`
+	FetchedAt time.Time   `json:"fetched_at"`
+	EPG       []EPGObject `json:"epg"`
+}
+
+// dayOffsets returns the days to fetch, as offsets from today, from the
+// epg_past_days and epg_future_days config
+func dayOffsets() []int {
+	past := min(max(config.Cfg.EPGPastDays, 0), maxEPGDays)
+	future := config.Cfg.EPGFutureDays
+	switch {
+	case future < 0:
+		future = 0
+	case future == 0:
+		future = defaultFutureDays
+	}
+	future = min(future, maxEPGDays)
+
+	offsets := make([]int, 0, past+future+1)
+	for offset := -past; offset <= future; offset++ {
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+// istDay returns the start of the day of t in IST
+func istDay(t time.Time) time.Time {
+	t = t.In(ist)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, ist)
+}
+
+// cacheDir returns the directory of the EPG cache
+func cacheDir() string {
+	return filepath.Join(utils.GetPathPrefix(), "epg_cache")
+}
+
+// cacheFile returns the cache file of a channel on a day
+func cacheFile(day time.Time, channelID int) string {
+	return filepath.Join(cacheDir(), day.Format(cacheDateFormat), strconv.Itoa(channelID)+".json")
+}
+
+// fresh reports whether the programmes of day can be reused at now. Days
+// fetched after they ended do not change anymore.
+func (c cachedDay) fresh(day, now time.Time) bool {
+	if c.Version != cacheVersion {
+		return false
+	}
+	return !c.FetchedAt.Before(day.AddDate(0, 0, 1)) || now.Sub(c.FetchedAt) < cacheMaxAge
+}
+
+// readCachedDay reads the cached programmes of a channel on a day
+func readCachedDay(day time.Time, channelID int) (cachedDay, bool) {
+	data, err := os.ReadFile(cacheFile(day, channelID))
+	if err != nil {
+		return cachedDay{}, false
+	}
+	var cached cachedDay
+	if err := json.Unmarshal(data, &cached); err != nil {
+		utils.Log.Printf("Ignoring damaged EPG cache of channel %d on %s: %v", channelID, day.Format(cacheDateFormat), err)
+		return cachedDay{}, false
+	}
+	return cached, true
+}
+
+// writeCachedDay saves the programmes of a channel on a day
+func writeCachedDay(day time.Time, channelID int, cached cachedDay) error {
+	filename := cacheFile(day, channelID)
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filename, 0o644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(cached)
+	})
+}
+
+// channelDay returns the programmes of a channel on a day, from the cache if
+// they are fresh. When fetching fails, stale cached programmes are returned.
+func channelDay(client *fasthttp.Client, channelID int, day, now time.Time) ([]EPGObject, error) {
+	cached, ok := readCachedDay(day, channelID)
+	if ok && cached.fresh(day, now) {
+		cachedDays.Inc("cache")
+		return cached.EPG, nil
+	}
+
+	offset := int(day.Sub(istDay(now)).Hours() / 24)
+	programmes, err := fetchChannelEPG(client, channelID, offset)
+	if err != nil {
+		if ok {
+			cachedDays.Inc("stale")
+			utils.Log.Printf("Using cached EPG of channel %d on %s: %v", channelID, day.Format(cacheDateFormat), err)
+			return cached.EPG, nil
+		}
+		cachedDays.Inc("failed")
+		return nil, err
+	}
+	cachedDays.Inc("fetched")
+
+	if err := writeCachedDay(day, channelID, cachedDay{Version: cacheVersion, FetchedAt: now, EPG: programmes}); err != nil {
+		utils.Log.Printf("Failed to cache EPG of channel %d on %s: %v", channelID, day.Format(cacheDateFormat), err)
+	}
+	return programmes, nil
+}
+
+// pruneCache removes the cached days before oldest
+func pruneCache(oldest time.Time) {
+	entries, err := os.ReadDir(cacheDir())
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			utils.Log.Printf("Failed to read EPG cache: %v", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		day, err := time.ParseInLocation(cacheDateFormat, entry.Name(), ist)
+		if err != nil || !day.Before(oldest) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(cacheDir(), entry.Name())); err != nil {
+			utils.Log.Printf("Failed to remove EPG cache of %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+// CachedProgrammes returns the programmes keep selects from the EPG cache,
+// for the days set by the epg_past_days and epg_future_days config, without
+// fetching anything. Only the channels in channelIDs are read, unless it is
+// empty. Channels are read one at a time, so only the selected programmes
+// are held in memory.
+func CachedProgrammes(channelIDs []int, keep func(EPGObject) bool) ([]EPGObject, error) {
+	today := istDay(time.Now())
+	var programmes []EPGObject
+	found := false
+	seen := make(map[[2]int64]bool)
+	for _, offset := range dayOffsets() {
+		day := today.AddDate(0, 0, offset)
+		ids := channelIDs
+		if len(ids) == 0 {
+			ids = cachedChannelIDs(day)
+		}
+		for _, channelID := range ids {
+			cached, ok := readCachedDay(day, channelID)
+			if !ok {
+				continue
+			}
+			found = true
+			for _, programme := range cached.EPG {
+				// Programmes running past midnight are cached on both days
+				key := [2]int64{int64(channelID), programme.StartEpoch}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				programme.ChannelID = uint16(channelID)
+				if keep(programme) {
+					programmes = append(programmes, programme)
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, ErrNoCachedProgrammes
+	}
+	return programmes, nil
+}
+
+// cachedChannelIDs returns the channels cached on a day
+func cachedChannelIDs(day time.Time) []int {
+	entries, err := os.ReadDir(filepath.Dir(cacheFile(day, 0)))
+	if err != nil {
+		return nil
+	}
+	var ids []int
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,182 @@
+package epg
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func setupCacheTest(t *testing.T) {
+	t.Helper()
+	previousLog, previousCfg := utils.Log, config.Cfg
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log, config.Cfg = previousLog, previousCfg
+	})
+}
+
+func TestDayOffsets(t *testing.T) {
+	previousCfg := config.Cfg
+	t.Cleanup(func() { config.Cfg = previousCfg })
+
+	tests := []struct {
+		name   string
+		past   int
+		future int
+		want   []int
+	}{
+		{name: "Default", want: []int{0, 1}},
+		{name: "Today only", future: -1, want: []int{0}},
+		{name: "Catchup week", past: 7, future: 2, want: []int{-7, -6, -5, -4, -3, -2, -1, 0, 1, 2}},
+		{name: "Clamped", past: 10, future: 10, want: []int{-7, -6, -5, -4, -3, -2, -1, 0, 1, 2, 3, 4, 5, 6, 7}},
+		{name: "Negative past", past: -3, want: []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg.EPGPastDays, config.Cfg.EPGFutureDays = tt.past, tt.future
+			if got := dayOffsets(); !slices.Equal(got, tt.want) {
+				t.Errorf("dayOffsets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCachedDayFresh(t *testing.T) {
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, ist)
+	tests := []struct {
+		name   string
+		cached cachedDay
+		now    time.Time
+		want   bool
+	}{
+		{name: "Recent", cached: cachedDay{Version: cacheVersion, FetchedAt: day.Add(2 * time.Hour)}, now: day.Add(10 * time.Hour), want: true},
+		{name: "Old", cached: cachedDay{Version: cacheVersion, FetchedAt: day.Add(-time.Hour)}, now: day.Add(12 * time.Hour), want: false},
+		{name: "Fetched before the day ended", cached: cachedDay{Version: cacheVersion, FetchedAt: day.Add(2 * time.Hour)}, now: day.AddDate(0, 0, 3), want: false},
+		{name: "Fetched after the day ended", cached: cachedDay{Version: cacheVersion, FetchedAt: day.AddDate(0, 0, 1)}, now: day.AddDate(0, 0, 5), want: true},
+		{name: "Other version", cached: cachedDay{Version: cacheVersion - 1, FetchedAt: day.Add(2 * time.Hour)}, now: day.Add(3 * time.Hour), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cached.fresh(day, tt.now); got != tt.want {
+				t.Errorf("fresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelDayFromCache(t *testing.T) {
+	setupCacheTest(t)
+
+	now := time.Now()
+	day := istDay(now).AddDate(0, 0, -2)
+	programmes := []EPGObject{{StartEpoch: day.UnixMilli(), EndEpoch: day.Add(time.Hour).UnixMilli(), Title: "News", Srno: "240115000000"}}
+	if err := writeCachedDay(day, 143, cachedDay{Version: cacheVersion, FetchedAt: day.AddDate(0, 0, 1), EPG: programmes}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh day is served without a client
+	got, err := channelDay(nil, 143, day, now)
+	if err != nil {
+		t.Fatalf("channelDay() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Title != "News" || got[0].Srno != "240115000000" {
+		t.Errorf("channelDay() = %+v, want the cached programmes", got)
+	}
+}
+
+func TestCachedProgrammes(t *testing.T) {
+	setupCacheTest(t)
+	config.Cfg.EPGPastDays, config.Cfg.EPGFutureDays = 0, 1
+
+	if _, err := CachedProgrammes(nil, func(EPGObject) bool { return true }); !errors.Is(err, ErrNoCachedProgrammes) {
+		t.Fatalf("CachedProgrammes() of an empty cache error = %v, want %v", err, ErrNoCachedProgrammes)
+	}
+
+	today := istDay(time.Now())
+	programme := func(start time.Time, title string) EPGObject {
+		return EPGObject{StartEpoch: start.UnixMilli(), EndEpoch: start.Add(time.Hour).UnixMilli(), Title: title}
+	}
+	// The late programme runs past midnight and is cached on both days
+	late := programme(today.Add(23*time.Hour+30*time.Minute), "Late Show")
+	days := map[int]map[int][]EPGObject{
+		0: {143: {programme(today.Add(20*time.Hour), "News"), late}, 154: {programme(today.Add(20*time.Hour), "Cricket")}},
+		1: {143: {late, programme(today.Add(26*time.Hour), "Morning News")}},
+		// Outside of the configured days
+		2: {143: {programme(today.Add(50*time.Hour), "Old News")}},
+	}
+	for offset, channels := range days {
+		for channelID, programmes := range channels {
+			if err := writeCachedDay(today.AddDate(0, 0, offset), channelID, cachedDay{Version: cacheVersion, EPG: programmes}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name       string
+		channelIDs []int
+		keep       func(EPGObject) bool
+		want       []string
+	}{
+		{name: "Every channel", keep: func(EPGObject) bool { return true }, want: []string{"News", "Late Show", "Cricket", "Morning News"}},
+		{name: "Channels", channelIDs: []int{154}, keep: func(EPGObject) bool { return true }, want: []string{"Cricket"}},
+		{name: "Selected", keep: func(programme EPGObject) bool { return programme.ChannelID == 143 && programme.Title != "Late Show" }, want: []string{"News", "Morning News"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			programmes, err := CachedProgrammes(tt.channelIDs, tt.keep)
+			if err != nil {
+				t.Fatalf("CachedProgrammes() error = %v", err)
+			}
+			var got []string
+			for _, programme := range programmes {
+				got = append(got, programme.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("CachedProgrammes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	setupCacheTest(t)
+
+	today := istDay(time.Now())
+	for _, offset := range []int{-3, -1, 0} {
+		if err := writeCachedDay(today.AddDate(0, 0, offset), 143, cachedDay{Version: cacheVersion}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(cacheDir(), "unrelated"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	pruneCache(today.AddDate(0, 0, -1))
+
+	entries, err := os.ReadDir(cacheDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{today.AddDate(0, 0, -1).Format(cacheDateFormat), today.Format(cacheDateFormat), "unrelated"}
+	if !slices.Equal(names, want) {
+		t.Errorf("cache after pruneCache() = %v, want %v", names, want)
+	}
+}
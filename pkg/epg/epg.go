@@ -1,14 +1,16 @@
 package epg
 
 import (
+	"cmp"
 	"compress/gzip"
 	"crypto/rand"
 	"encoding/json"
-	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
-
 	"os"
+	"slices"
 	"sync"
 	"time"
 
@@ -33,11 +35,35 @@ const (
 	// Default values for random scheduling when crypto/rand fails
 	defaultRandomHour   = 2
 	defaultRandomMinute = 30
+	// numWorkers is the number of channels whose EPG is fetched concurrently
+	numWorkers = 20
+	// fetchWindow is the number of channels whose EPG is held in memory
+	// while an earlier channel is still being fetched
+	fetchWindow = 4 * numWorkers
+)
+
+// errNoProgrammes is returned when no programme of any channel was fetched
+var errNoProgrammes = errors.New("no EPG programmes were fetched")
+
+var (
+	generatedHooks   []generatedHook
+	generatedHooksMu sync.Mutex
 )
 
+// generatedHook is a function registered with OnGenerated
+type generatedHook struct {
+	selector func() func(EPGObject) bool
+	run      func(programmes []EPGObject)
+}
+
+// FilePath returns the path of the generated EPG file
+func FilePath() string {
+	return utils.GetPathPrefix() + "epg.xml.gz"
+}
+
 // Init initializes EPG generation and schedules it for the next day.
 func Init() {
-	epgFile := utils.GetPathPrefix() + "epg.xml.gz"
+	epgFile := FilePath()
 	var lastModTime time.Time
 	flag := false
 	utils.Log.Println("Checking EPG file")
@@ -77,6 +103,12 @@ func Init() {
 	if flag {
 		genepg()
 	}
+	// Serve the EPG API from the existing file when it was not generated
+	if CurrentIndex() == nil && fileResult.Exists {
+		if err := LoadIndex(epgFile); err != nil {
+			utils.Log.Printf("Failed to load EPG index: %v", err)
+		}
+	}
 	// setup random time to avoid server load
 	random_hour_bigint, err := rand.Int(rand.Reader, big.NewInt(3))
 	if err != nil {
@@ -123,62 +155,38 @@ func NewProgramme(channelID int, start, stop, title, desc, category, iconSrc str
 	}
 }
 
-// genXML generates XML EPG from JioTV API and returns it as a byte slice.
-func genXML() ([]byte, error) {
+// FetchProgrammes fetches the channels and their programmes for the days set
+// by the epg_past_days and epg_future_days config from JioTV API. Days in the
+// EPG cache are only fetched again when they are stale. Only the channels in
+// channelIDs are fetched, unless it is empty.
+func FetchProgrammes(channelIDs []int) ([]Channel, []EPGObject, error) {
 	// Create a reusable fasthttp client with common headers
 	client := utils.GetRequestClient()
+	channels, err := fetchChannels(client, channelIDs)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Create channels and programmes slices with initial capacity
-	var channels []Channel
-	var programmes []Programme
-	var programmesMu sync.Mutex
-
-	// Define a worker function for fetching EPG data
-	fetchEPG := func(channel Channel, bar *progressbar.ProgressBar) {
-		var channelProgrammes []Programme
-		req := fasthttp.AcquireRequest()
-		req.Header.SetUserAgent(headers.UserAgentOkHttp)
-		defer fasthttp.ReleaseRequest(req)
-
-		resp := fasthttp.AcquireResponse()
-
-		for offset := 0; offset < 2; offset++ {
-			reqUrl := fmt.Sprintf(EPG_URL, offset, channel.ID)
-			req.SetRequestURI(reqUrl)
-
-			if err := client.Do(req, resp); err != nil {
-				// Handle error
-				utils.Log.Printf("Error fetching EPG for channel %d, offset %d: %v", channel.ID, offset, err)
-				continue
-			}
-			if status := resp.StatusCode(); status != fasthttp.StatusOK {
-				utils.Log.Printf("Error fetching EPG for channel %d, offset %d: HTTP status %d", channel.ID, offset, status)
-				continue
-			}
-
-			var epgResponse EPGResponse
-			if err := json.Unmarshal(resp.Body(), &epgResponse); err != nil {
-				// Handle error
-				utils.Log.Printf("Error unmarshaling EPG response for channel %d, offset %d: %v", channel.ID, offset, err)
-				// Print response body for debugging
-				utils.Log.Printf("Response body: %s", resp.Body())
-				continue
-			}
-
-			for _, programme := range epgResponse.EPG {
-				startTime := formatTime(time.UnixMilli(programme.StartEpoch))
-				endTime := formatTime(time.UnixMilli(programme.EndEpoch))
-				channelProgrammes = append(channelProgrammes, NewProgramme(channel.ID, startTime, endTime, programme.Title, programme.Description, programme.ShowCategory, programme.Poster))
-			}
-		}
-		programmesMu.Lock()
+	var programmes []EPGObject
+	fetcher := newDayFetcher(client)
+	err = fetchInOrder(channels, fetcher.fetch, func(_ Channel, channelProgrammes []EPGObject) error {
 		programmes = append(programmes, channelProgrammes...)
-		programmesMu.Unlock()
-		bar.Add(1)
-		fasthttp.ReleaseResponse(resp)
+		return nil
+	})
+	fetcher.prune()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(programmes) == 0 {
+		return nil, nil, errNoProgrammes
 	}
+	utils.Log.Println("Fetched programmes")
+	return channels, programmes, nil
+}
 
-	// Fetch channels data
+// fetchChannels fetches the channels from JioTV API, sorted by ID. Only the
+// channels in channelIDs are returned, unless it is empty.
+func fetchChannels(client *fasthttp.Client, channelIDs []int) ([]Channel, error) {
 	utils.Log.Println("Fetching channels")
 	resp, err := utils.MakeHTTPRequest(utils.HTTPRequestConfig{
 		URL:    CHANNEL_URL,
@@ -194,85 +202,346 @@ func genXML() ([]byte, error) {
 		return nil, utils.LogAndReturnError(err, "Failed to parse channels response")
 	}
 
+	var channels []Channel
 	for _, channel := range channelsResponse.Channels {
-		channels = append(channels, Channel{
-			ID:      channel.ChannelID,
-			Display: channel.ChannelName,
-		})
+		if len(channelIDs) > 0 && !slices.Contains(channelIDs, channel.ChannelID) {
+			continue
+		}
+		channels = append(channels, newChannel(channel))
 	}
+	// Keep the order stable between runs so that EPG files can be diffed
+	slices.SortStableFunc(channels, func(a, b Channel) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
 	utils.Log.Println("Fetched", len(channels), "channels")
-	// Use a worker pool to fetch EPG data concurrently
-	const numWorkers = 20 // Adjust the number of workers based on your needs
-	channelQueue := make(chan Channel, len(channels))
-	var wg sync.WaitGroup
+	return channels, nil
+}
+
+// dayFetcher fetches the programmes of channels for the configured days
+type dayFetcher struct {
+	client  *fasthttp.Client
+	now     time.Time
+	today   time.Time
+	offsets []int
+}
+
+func newDayFetcher(client *fasthttp.Client) *dayFetcher {
+	now := time.Now()
+	return &dayFetcher{client: client, now: now, today: istDay(now), offsets: dayOffsets()}
+}
 
-	// Create a progress bar
-	totalChannels := len(channels) // Replace with the actual number of channels
-	bar := progressbar.Default(int64(totalChannels))
+// fetch returns the programmes of a channel on all days, sorted by start time
+func (f *dayFetcher) fetch(channel Channel) []EPGObject {
+	var programmes []EPGObject
+	seen := make(map[int64]bool)
+	for _, offset := range f.offsets {
+		epgObjects, err := channelDay(f.client, channel.ID, f.today.AddDate(0, 0, offset), f.now)
+		if err != nil {
+			utils.Log.Printf("Error fetching EPG for channel %d, offset %d: %v", channel.ID, offset, err)
+			continue
+		}
 
+		for _, programme := range epgObjects {
+			// Programmes running past midnight are served on both days
+			if seen[programme.StartEpoch] {
+				continue
+			}
+			seen[programme.StartEpoch] = true
+			programme.ChannelID = uint16(channel.ID)
+			programme.ChannelName = channel.Display
+			programmes = append(programmes, programme)
+		}
+	}
+	slices.SortStableFunc(programmes, func(a, b EPGObject) int {
+		return cmp.Compare(a.StartEpoch, b.StartEpoch)
+	})
+	return programmes
+}
+
+// from returns the start of the first day fetched
+func (f *dayFetcher) from() time.Time {
+	return f.today.AddDate(0, 0, f.offsets[0])
+}
+
+// to returns the end of the last day fetched
+func (f *dayFetcher) to() time.Time {
+	return f.today.AddDate(0, 0, f.offsets[len(f.offsets)-1]+1)
+}
+
+// prune removes the cached days before the first day fetched
+func (f *dayFetcher) prune() {
+	pruneCache(f.from())
+}
+
+// fetchInOrder fetches the programmes of channels concurrently and passes
+// them to write in the order of channels, as soon as all earlier channels
+// were written. At most fetchWindow channels are fetched ahead of the
+// channel being written, which bounds the programmes held in memory.
+// Fetching stops at the first error returned by write.
+func fetchInOrder(channels []Channel, fetch func(Channel) []EPGObject, write func(Channel, []EPGObject) error) error {
+	type result struct {
+		index      int
+		programmes []EPGObject
+	}
+
+	bar := progressbar.Default(int64(len(channels)))
 	utils.Log.Println("Fetching EPG for channels")
-	for i := 0; i < numWorkers; i++ {
+
+	jobs := make(chan int)
+	results := make(chan result)
+	// A slot is taken for each channel queued, and freed once it is written
+	slots := make(chan struct{}, fetchWindow)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(jobs)
+		for i := range channels {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range numWorkers {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for channel := range channelQueue {
-				fetchEPG(channel, bar)
+			for i := range jobs {
+				programmes := fetch(channels[i])
+				bar.Add(1)
+				select {
+				case results <- result{index: i, programmes: programmes}:
+				case <-done:
+					return
+				}
 			}
 		}()
 	}
-	// Queue channels for processing
-	for _, channel := range channels {
-		channelQueue <- channel
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int][]EPGObject, fetchWindow)
+	next := 0
+	for r := range results {
+		pending[r.index] = r.programmes
+		for {
+			programmes, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if err := write(channels[next], programmes); err != nil {
+				return err
+			}
+			next++
+			<-slots
+		}
 	}
-	close(channelQueue)
-	wg.Wait()
-	if len(programmes) == 0 {
-		return nil, fmt.Errorf("no EPG programmes were fetched")
+	return nil
+}
+
+// fetchChannelEPG fetches the programmes of a channel for the day at offset from today
+func fetchChannelEPG(client *fasthttp.Client, channelID, offset int) ([]EPGObject, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(fmt.Sprintf(EPG_URL, offset, channelID))
+	req.Header.SetUserAgent(headers.UserAgentOkHttp)
+
+	if err := client.Do(req, resp); err != nil {
+		return nil, err
+	}
+	if status := resp.StatusCode(); status != fasthttp.StatusOK {
+		return nil, fmt.Errorf("HTTP status %d", status)
 	}
 
-	utils.Log.Println("Fetched programmes")
-	// Create EPG and marshal it to XML
-	epg := EPG{
-		Channel:   channels,
-		Programme: programmes,
+	var epgResponse EPGResponse
+	if err := json.Unmarshal(resp.Body(), &epgResponse); err != nil {
+		return nil, fmt.Errorf("unmarshaling EPG response: %w", err)
 	}
-	xml, err := xml.Marshal(epg)
+	return epgResponse.EPG, nil
+}
+
+// FindProgramme returns the programme airing on a channel at the given time.
+// The JioTV EPG API serves one day per request, counted from today in IST.
+func FindProgramme(channelID int, at time.Time) (*EPGObject, error) {
+	at = at.In(ist)
+	programmes, err := channelDay(utils.GetRequestClient(), channelID, istDay(at), time.Now())
 	if err != nil {
 		return nil, err
 	}
-	return xml, nil
+	programme, ok := programmeAt(programmes, at)
+	if !ok {
+		return nil, fmt.Errorf("%w on channel %d at %s", ErrProgrammeNotFound, channelID, at.Format(time.RFC3339))
+	}
+	return &programme, nil
+}
+
+// programmeAt returns the programme whose time range contains at
+func programmeAt(programmes []EPGObject, at time.Time) (EPGObject, bool) {
+	atMilli := at.UnixMilli()
+	for _, programme := range programmes {
+		if programme.StartEpoch <= atMilli && atMilli < programme.EndEpoch {
+			return programme, true
+		}
+	}
+	return EPGObject{}, false
 }
 
 // formatTime formats the given time to the string representation "20060102150405 -0700".
 func formatTime(t time.Time) string {
-	return t.Format("20060102150405 -0700")
+	return t.Format(xmltvTimeLayout)
 }
 
 // GenXMLGz generates XML EPG from JioTV API and writes it to a compressed gzip file.
 func GenXMLGz(filename string) error {
-	utils.Log.Println("Generating XML")
-	xml, err := genXML()
-	if err != nil {
-		return err
+	generatedHooksMu.Lock()
+	hooks := slices.Clone(generatedHooks)
+	generatedHooksMu.Unlock()
+
+	// Programmes are only kept in memory for the hooks that select some
+	selectors := make([]func(EPGObject) bool, len(hooks))
+	var keep func(EPGObject) bool
+	for i, hook := range hooks {
+		selectors[i] = hook.selector()
+	}
+	if slices.ContainsFunc(selectors, func(selector func(EPGObject) bool) bool { return selector != nil }) {
+		keep = func(programme EPGObject) bool {
+			return slices.ContainsFunc(selectors, func(selector func(EPGObject) bool) bool {
+				return selector != nil && selector(programme)
+			})
+		}
 	}
-	// Add XML header
-	xmlHeader := `<?xml version="1.0" encoding="UTF-8"?>
-	<!DOCTYPE tv SYSTEM "http://www.w3.org/2006/05/tv">`
-	xml = append([]byte(xmlHeader), xml...)
-	// write to file
-	f, err := os.Create(filename)
+
+	start := time.Now()
+	result, err := genXMLGz(filename, keep)
+	recordGeneration(time.Since(start), result, err)
 	if err != nil {
 		return err
 	}
-	defer f.Close() // skipcq: GO-S2307
+	currentIndex.Store(result.index.build(time.Now()))
 
-	utils.Log.Println("Writing XML to gzip file")
-	gz := gzip.NewWriter(f)
-	defer gz.Close()
+	for i, hook := range hooks {
+		if selectors[i] == nil {
+			continue
+		}
+		var programmes []EPGObject
+		for _, programme := range result.programmes {
+			if selectors[i](programme) {
+				programmes = append(programmes, programme)
+			}
+		}
+		hook.run(programmes)
+	}
+	return nil
+}
 
-	if _, err := gz.Write(xml); err != nil {
-		return err
+// generation is the result of an EPG generation
+type generation struct {
+	// channelCount is the number of channels with programmes
+	channelCount   int
+	programmeCount int
+	// programmes are the fetched programmes selected to be kept
+	programmes []EPGObject
+	// index is built from the programmes as they are written
+	index *indexBuilder
+}
+
+// genXMLGz streams the EPG to filename while it is fetched. Only the
+// programmes selected by keep are kept in the result, none when it is nil.
+func genXMLGz(filename string, keep func(EPGObject) bool) (generation, error) {
+	client := utils.GetRequestClient()
+	channels, err := fetchChannels(client, nil)
+	if err != nil {
+		return generation{}, err
+	}
+
+	// Write to a temporary file renamed over filename, so that the EPG
+	// served is never half-written
+	utils.Log.Println("Generating XML")
+	fetcher := newDayFetcher(client)
+	custom := loadCustomEPG(client, fetcher.from(), fetcher.to())
+	var result generation
+	err = utils.WriteFileAtomic(filename, 0o644, func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		result, err = writeEPG(gz, channels, custom, fetcher.fetch, keep)
+		if err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+	fetcher.prune()
+	if err != nil {
+		return generation{}, err
 	}
 	fmt.Println("\tEPG file generated successfully")
-	return nil
+	return result, nil
+}
+
+// writeEPG writes the XMLTV document of channels to w, fetching the
+// programmes of each channel with fetch while earlier channels are written.
+// The custom channels and their programmes follow the JioTV ones. Only the
+// programmes selected by keep are kept in the result.
+func writeEPG(w io.Writer, channels []Channel, custom customEPG, fetch func(Channel) []EPGObject, keep func(EPGObject) bool) (generation, error) {
+	result := generation{index: newIndexBuilder()}
+	xmltv, err := newXMLTVWriter(w, channels, custom.channels)
+	if err != nil {
+		return result, err
+	}
+	err = fetchInOrder(channels, fetch, func(channel Channel, programmes []EPGObject) error {
+		if len(programmes) > 0 {
+			result.channelCount++
+		}
+		result.programmeCount += len(programmes)
+		for _, programme := range programmes {
+			if keep != nil && keep(programme) {
+				result.programmes = append(result.programmes, programme)
+			}
+			result.index.add(newIndexedProgramme(programme, channel.Display))
+		}
+		return xmltv.writeProgrammes(programmes)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if err := xmltv.writeCustomProgrammes(custom.programmes); err != nil {
+		return result, err
+	}
+	customChannels := make(map[string]bool)
+	for _, programme := range custom.programmes {
+		customChannels[programme.indexed.ChannelID] = true
+		result.index.add(programme.indexed)
+	}
+	result.channelCount += len(customChannels)
+	result.programmeCount += len(custom.programmes)
+
+	// Keep the previous EPG file rather than replacing it with an empty one
+	if result.programmeCount == 0 {
+		return result, errNoProgrammes
+	}
+	return result, xmltv.close()
+}
+
+// OnGenerated registers a hook that is called after every successful
+// GenXMLGz run with the fetched programmes selector selects. selector is
+// called when a run starts and returns nil when the hook needs no programmes
+// in that run, so that the programmes are not kept in memory for it.
+func OnGenerated(selector func() func(EPGObject) bool, hook func(programmes []EPGObject)) {
+	generatedHooksMu.Lock()
+	defer generatedHooksMu.Unlock()
+	generatedHooks = append(generatedHooks, generatedHook{selector: selector, run: hook})
 }
@@ -271,3 +271,57 @@ func TestEpochString_String(t *testing.T) {
 		})
 	}
 }
+
+func TestProgrammeAt(t *testing.T) {
+	base := time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC)
+	programmes := []EPGObject{
+		{StartEpoch: base.UnixMilli(), EndEpoch: base.Add(30 * time.Minute).UnixMilli(), Title: "First"},
+		{StartEpoch: base.Add(30 * time.Minute).UnixMilli(), EndEpoch: base.Add(time.Hour).UnixMilli(), Title: "Second"},
+	}
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		want   string
+		wantOK bool
+	}{
+		{name: "At start", at: base, want: "First", wantOK: true},
+		{name: "During", at: base.Add(45 * time.Minute), want: "Second", wantOK: true},
+		{name: "At end of previous", at: base.Add(30 * time.Minute), want: "Second", wantOK: true},
+		{name: "After last", at: base.Add(time.Hour), wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := programmeAt(programmes, tt.at)
+			if ok != tt.wantOK || got.Title != tt.want {
+				t.Errorf("programmeAt() = %q, %v, want %q, %v", got.Title, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSerialNumber_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    SerialNumber
+		wantErr bool
+	}{
+		{name: "Number", data: "240115210000", want: "240115210000"},
+		{name: "String", data: `"240115210000"`, want: "240115210000"},
+		{name: "Empty string", data: `""`, want: ""},
+		{name: "Invalid", data: "{", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got SerialNumber
+			err := got.UnmarshalJSON([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
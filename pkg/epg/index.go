@@ -0,0 +1,365 @@
+package epg
+
+import (
+	"cmp"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// xmltvTimeLayout is the layout of programme times in XMLTV
+const xmltvTimeLayout = "20060102150405 -0700"
+
+// IndexedProgramme is a programme of the EPG index, as served by the EPG API
+type IndexedProgramme struct {
+	ChannelID   string    `json:"channel_id"`
+	ChannelName string    `json:"channel_name"`
+	Title       string    `json:"title"`
+	SubTitle    string    `json:"sub_title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Category    string    `json:"category,omitempty"`
+	Start       time.Time `json:"start"`
+	Stop        time.Time `json:"stop"`
+	Episode     string    `json:"episode,omitempty"` // Episode number as shown on screen
+	Rating      string    `json:"rating,omitempty"`
+	Poster      string    `json:"poster,omitempty"`
+	Thumbnail   string    `json:"thumbnail,omitempty"`
+	CatchupID   string    `json:"catchup_id,omitempty"` // Serial number to play the programme from catchup
+}
+
+// Index holds the programmes of the last generated EPG by channel
+type Index struct {
+	updated    time.Time
+	channelIDs []string
+	schedules  map[string][]IndexedProgramme // Sorted by start
+}
+
+// SearchQuery filters the programmes returned by Index.Search
+type SearchQuery struct {
+	Text     string    // Matched against the title, sub-title and description
+	Category string    // Matched against the whole category
+	From     time.Time // Programmes ending after From
+	To       time.Time // Programmes starting before To
+	Limit    int       // Maximum number of programmes, unlimited if 0
+}
+
+// currentIndex is the index of the EPG file, nil until one is built or loaded
+var currentIndex atomic.Pointer[Index]
+
+// CurrentIndex returns the index of the EPG file, or nil if there is none yet
+func CurrentIndex() *Index {
+	return currentIndex.Load()
+}
+
+// indexBuilder builds an Index one programme at a time, as the EPG is
+// generated or read, without holding the programmes twice
+type indexBuilder struct {
+	schedules map[string][]IndexedProgramme
+	// interned shares the strings repeated across programmes, like the
+	// descriptions of shows that air every day
+	interned map[string]string
+}
+
+func newIndexBuilder() *indexBuilder {
+	return &indexBuilder{schedules: make(map[string][]IndexedProgramme), interned: make(map[string]string)}
+}
+
+// intern returns the first string added equal to value
+func (builder *indexBuilder) intern(value string) string {
+	if interned, ok := builder.interned[value]; ok {
+		return interned
+	}
+	builder.interned[value] = value
+	return value
+}
+
+// add adds a programme to the schedule of its channel
+func (builder *indexBuilder) add(programme IndexedProgramme) {
+	programme.ChannelName = builder.intern(programme.ChannelName)
+	programme.Title = builder.intern(programme.Title)
+	programme.SubTitle = builder.intern(programme.SubTitle)
+	programme.Description = builder.intern(programme.Description)
+	programme.Category = builder.intern(programme.Category)
+	builder.schedules[programme.ChannelID] = append(builder.schedules[programme.ChannelID], programme)
+}
+
+// build returns the index of the added programmes, generated at updated
+func (builder *indexBuilder) build(updated time.Time) *Index {
+	index := &Index{updated: updated, schedules: builder.schedules}
+	for id, schedule := range index.schedules {
+		slices.SortStableFunc(schedule, func(a, b IndexedProgramme) int { return a.Start.Compare(b.Start) })
+		// Drop the spare capacity left by append
+		index.schedules[id] = slices.Clip(schedule)
+		index.channelIDs = append(index.channelIDs, id)
+	}
+	slices.SortFunc(index.channelIDs, compareChannelIDs)
+	builder.schedules, builder.interned = nil, nil
+	return index
+}
+
+// newIndex builds an index of programmes generated at updated
+func newIndex(programmes []IndexedProgramme, updated time.Time) *Index {
+	builder := newIndexBuilder()
+	for _, programme := range programmes {
+		builder.add(programme)
+	}
+	return builder.build(updated)
+}
+
+// compareChannelIDs orders JioTV channels by number, before custom channels
+func compareChannelIDs(a, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(x, y)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// Updated returns when the EPG of the index was generated
+func (index *Index) Updated() time.Time {
+	return index.updated
+}
+
+// Len returns the number of programmes in the index
+func (index *Index) Len() int {
+	count := 0
+	for _, schedule := range index.schedules {
+		count += len(schedule)
+	}
+	return count
+}
+
+// Now returns the programme airing at at on every channel
+func (index *Index) Now(at time.Time) []IndexedProgramme {
+	var programmes []IndexedProgramme
+	for _, id := range index.channelIDs {
+		schedule := index.schedules[id]
+		i := sort.Search(len(schedule), func(i int) bool { return schedule[i].Stop.After(at) })
+		if i < len(schedule) && !schedule[i].Start.After(at) {
+			programmes = append(programmes, schedule[i])
+		}
+	}
+	return programmes
+}
+
+// Next returns the first programme starting after at on every channel
+func (index *Index) Next(at time.Time) []IndexedProgramme {
+	var programmes []IndexedProgramme
+	for _, id := range index.channelIDs {
+		schedule := index.schedules[id]
+		i := sort.Search(len(schedule), func(i int) bool { return schedule[i].Start.After(at) })
+		if i < len(schedule) {
+			programmes = append(programmes, schedule[i])
+		}
+	}
+	return programmes
+}
+
+// Channel returns the programmes of a channel airing between from and to.
+// It reports false if the channel is not in the index.
+func (index *Index) Channel(channelID string, from, to time.Time) ([]IndexedProgramme, bool) {
+	schedule, ok := index.schedules[channelID]
+	if !ok {
+		return nil, false
+	}
+	first := sort.Search(len(schedule), func(i int) bool { return schedule[i].Stop.After(from) })
+	last := sort.Search(len(schedule), func(i int) bool { return !schedule[i].Start.Before(to) })
+	if first >= last {
+		return []IndexedProgramme{}, true
+	}
+	return slices.Clone(schedule[first:last]), true
+}
+
+// Search returns the programmes matching query, sorted by start and channel
+func (index *Index) Search(query SearchQuery) []IndexedProgramme {
+	text := strings.ToLower(strings.TrimSpace(query.Text))
+	category := strings.TrimSpace(query.Category)
+	var programmes []IndexedProgramme
+	for _, id := range index.channelIDs {
+		for _, programme := range index.schedules[id] {
+			switch {
+			case !query.From.IsZero() && !programme.Stop.After(query.From):
+				continue
+			case !query.To.IsZero() && !programme.Start.Before(query.To):
+				continue
+			case category != "" && !strings.EqualFold(programme.Category, category):
+				continue
+			case text != "" && !programme.matches(text):
+				continue
+			}
+			programmes = append(programmes, programme)
+		}
+	}
+	slices.SortStableFunc(programmes, func(a, b IndexedProgramme) int {
+		return cmp.Or(a.Start.Compare(b.Start), compareChannelIDs(a.ChannelID, b.ChannelID))
+	})
+	if query.Limit > 0 && len(programmes) > query.Limit {
+		programmes = programmes[:query.Limit]
+	}
+	return programmes
+}
+
+// matches reports whether the lower case text is in the title, sub-title or
+// description of the programme
+func (programme IndexedProgramme) matches(text string) bool {
+	for _, field := range []string{programme.Title, programme.SubTitle, programme.Description} {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// newIndexedProgramme converts a programme from JioTV EPG API for the index
+func newIndexedProgramme(programme EPGObject, channelName string) IndexedProgramme {
+	indexed := IndexedProgramme{
+		ChannelID:   strconv.Itoa(int(programme.ChannelID)),
+		ChannelName: channelName,
+		Title:       programme.Title,
+		Description: programme.Description,
+		Category:    programme.ShowCategory,
+		Start:       time.UnixMilli(programme.StartEpoch),
+		Stop:        time.UnixMilli(programme.EndEpoch),
+		Rating:      programme.Rating,
+		CatchupID:   string(programme.Srno),
+	}
+	if programme.EpisodeDesc != "" && programme.EpisodeDesc != programme.Description {
+		indexed.SubTitle = programme.EpisodeDesc
+	}
+	for _, episode := range newEpisodeNums(programme.EpisodeNum) {
+		if episode.System == "onscreen" {
+			indexed.Episode = episode.Value
+		}
+	}
+	if programme.Poster != "" {
+		indexed.Poster = EPG_POSTER_URL + "/" + programme.Poster
+	}
+	if programme.Thumbnail != "" {
+		indexed.Thumbnail = EPG_POSTER_URL + "/" + programme.Thumbnail
+	}
+	return indexed
+}
+
+// indexXMLTVProgramme converts a programme read from an XMLTV file for the index
+func indexXMLTVProgramme(programme Programme, channelNames map[string]string) (IndexedProgramme, error) {
+	start, err := time.Parse(xmltvTimeLayout, programme.Start)
+	if err != nil {
+		return IndexedProgramme{}, err
+	}
+	stop, err := time.Parse(xmltvTimeLayout, programme.Stop)
+	if err != nil {
+		return IndexedProgramme{}, err
+	}
+	indexed := IndexedProgramme{
+		ChannelID:   programme.Channel,
+		ChannelName: channelNames[programme.Channel],
+		Title:       programme.Title.Value,
+		Description: programme.Desc.Value,
+		Category:    programme.Category.Value,
+		Start:       start,
+		Stop:        stop,
+		CatchupID:   programme.CatchupID,
+	}
+	if programme.SubTitle != nil {
+		indexed.SubTitle = programme.SubTitle.Value
+	}
+	for _, episode := range programme.EpisodeNums {
+		if episode.System == "onscreen" {
+			indexed.Episode = episode.Value
+		}
+	}
+	if programme.Rating != nil {
+		indexed.Rating = programme.Rating.Value
+	}
+	for _, image := range programme.Images {
+		switch image.Type {
+		case "poster":
+			indexed.Poster = image.Value
+		case "still":
+			indexed.Thumbnail = image.Value
+		}
+	}
+	return indexed, nil
+}
+
+// LoadIndex builds the EPG index from a gzip XMLTV file written by GenXMLGz
+func LoadIndex(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	index, err := readXMLTVIndex(gz, stat.ModTime())
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	currentIndex.Store(index)
+	return nil
+}
+
+// readXMLTVIndex indexes the programmes of an XMLTV document generated at
+// updated, reading them one at a time
+func readXMLTVIndex(r io.Reader, updated time.Time) (*Index, error) {
+	decoder := xml.NewDecoder(r)
+	channelNames := make(map[string]string)
+	builder := newIndexBuilder()
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return builder.build(updated), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch element.Name.Local {
+		case "channel":
+			// The IDs of custom channels are not numbers
+			var channel CustomChannel
+			if err := decoder.DecodeElement(&channel, &element); err != nil {
+				return nil, err
+			}
+			if len(channel.DisplayNames) > 0 {
+				channelNames[channel.ID] = channel.DisplayNames[0].Value
+			}
+		case "programme":
+			var programme Programme
+			if err := decoder.DecodeElement(&programme, &element); err != nil {
+				return nil, err
+			}
+			indexed, err := indexXMLTVProgramme(programme, channelNames)
+			if err != nil {
+				return nil, err
+			}
+			builder.add(indexed)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package epg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// indexTestProgrammes returns a schedule of two channels around start
+func indexTestProgrammes(start time.Time) []IndexedProgramme {
+	at := func(hours float64) time.Time { return start.Add(time.Duration(hours * float64(time.Hour))) }
+	return []IndexedProgramme{
+		{ChannelID: "2", ChannelName: "Two", Title: "Cricket Live", Category: "Sports", Start: at(0), Stop: at(3)},
+		{ChannelID: "1", ChannelName: "One", Title: "Evening News", Category: "News", Start: at(1), Stop: at(2)},
+		{ChannelID: "1", ChannelName: "One", Title: "Morning News", Category: "News", Start: at(0), Stop: at(1)},
+		// A gap before the last programme
+		{ChannelID: "1", ChannelName: "One", Title: "Movie", Description: "A cricket drama", Category: "Film", Start: at(2.5), Stop: at(4)},
+	}
+}
+
+func titles(programmes []IndexedProgramme) []string {
+	var names []string
+	for _, programme := range programmes {
+		names = append(names, programme.Title)
+	}
+	return names
+}
+
+func TestIndexNowAndNext(t *testing.T) {
+	start := time.Date(2024, 1, 15, 18, 0, 0, 0, ist)
+	index := newIndex(indexTestProgrammes(start), start)
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		wantNow  []string
+		wantNext []string
+	}{
+		{name: "Start", at: start, wantNow: []string{"Morning News", "Cricket Live"}, wantNext: []string{"Evening News"}},
+		{name: "Programme boundary", at: start.Add(time.Hour), wantNow: []string{"Evening News", "Cricket Live"}, wantNext: []string{"Movie"}},
+		{name: "Gap", at: start.Add(135 * time.Minute), wantNow: []string{"Cricket Live"}, wantNext: []string{"Movie"}},
+		{name: "Before the EPG", at: start.Add(-time.Hour), wantNow: nil, wantNext: []string{"Morning News", "Cricket Live"}},
+		{name: "After the EPG", at: start.Add(5 * time.Hour), wantNow: nil, wantNext: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titles(index.Now(tt.at)); !slices.Equal(got, tt.wantNow) {
+				t.Errorf("Now() = %v, want %v", got, tt.wantNow)
+			}
+			if got := titles(index.Next(tt.at)); !slices.Equal(got, tt.wantNext) {
+				t.Errorf("Next() = %v, want %v", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestIndexChannel(t *testing.T) {
+	start := time.Date(2024, 1, 15, 18, 0, 0, 0, ist)
+	index := newIndex(indexTestProgrammes(start), start)
+
+	got, ok := index.Channel("1", start.Add(30*time.Minute), start.Add(150*time.Minute))
+	if want := []string{"Morning News", "Evening News"}; !ok || !slices.Equal(titles(got), want) {
+		t.Errorf("Channel() = %v, %v, want %v", titles(got), ok, want)
+	}
+	if got, ok := index.Channel("1", start.Add(5*time.Hour), start.Add(6*time.Hour)); !ok || got == nil || len(got) != 0 {
+		t.Errorf("Channel() after the EPG = %v, %v, want an empty schedule", got, ok)
+	}
+	if _, ok := index.Channel("3", start, start.Add(time.Hour)); ok {
+		t.Error("Channel() of a channel without EPG reported it")
+	}
+	if index.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", index.Len())
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	start := time.Date(2024, 1, 15, 18, 0, 0, 0, ist)
+	index := newIndex(indexTestProgrammes(start), start)
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []string
+	}{
+		{name: "Title and description", query: SearchQuery{Text: "CRICKET"}, want: []string{"Cricket Live", "Movie"}},
+		{name: "Category", query: SearchQuery{Category: "news"}, want: []string{"Morning News", "Evening News"}},
+		{name: "Text and category", query: SearchQuery{Text: "news", Category: "News"}, want: []string{"Morning News", "Evening News"}},
+		{name: "From", query: SearchQuery{Text: "news", From: start.Add(time.Hour)}, want: []string{"Evening News"}},
+		{name: "To", query: SearchQuery{Text: "cricket", To: start.Add(2 * time.Hour)}, want: []string{"Cricket Live"}},
+		{name: "Limit", query: SearchQuery{Text: "e", Limit: 2}, want: []string{"Morning News", "Cricket Live"}},
+		{name: "No match", query: SearchQuery{Text: "weather"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titles(index.Search(tt.query)); !slices.Equal(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	setupWriterTest(t)
+	t.Cleanup(func() { currentIndex.Store(nil) })
+
+	channels := []Channel{newChannel(ChannelObject{ChannelID: 1, ChannelName: "One"}), newChannel(ChannelObject{ChannelID: 2, ChannelName: "Two"})}
+	start := time.Date(2024, 1, 15, 18, 0, 0, 0, ist)
+	fetch := func(channel Channel) []EPGObject {
+		return []EPGObject{{
+			ChannelID:    uint16(channel.ID),
+			ChannelName:  channel.Display,
+			StartEpoch:   start.UnixMilli(),
+			EndEpoch:     start.Add(time.Hour).UnixMilli(),
+			Title:        "Show",
+			Description:  "About the show",
+			ShowCategory: "Drama",
+			Poster:       "poster.jpg",
+			Thumbnail:    "thumb.jpg",
+			Srno:         "240115180000",
+			EpisodeNum:   "3",
+			EpisodeDesc:  "Episode three",
+			Rating:       "U",
+		}}
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	result, err := writeEPG(gz, channels, customEPG{}, fetch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "epg.xml.gz")
+	if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadIndex(filename); err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	loaded := CurrentIndex().Now(start)
+	generated := result.index.build(time.Now()).Now(start)
+	if len(loaded) != 2 || len(generated) != 2 {
+		t.Fatalf("Now() = %d loaded and %d generated programmes, want 2", len(loaded), len(generated))
+	}
+	// The index loaded from the file matches the one built while generating it
+	for i := range loaded {
+		got, want := loaded[i], generated[i]
+		if !got.Start.Equal(want.Start) || !got.Stop.Equal(want.Stop) {
+			t.Errorf("loaded programme times %v-%v, want %v-%v", got.Start, got.Stop, want.Start, want.Stop)
+		}
+		got.Start, got.Stop, want.Start, want.Stop = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+		if got != want {
+			t.Errorf("loaded programme =\n%+v\nwant\n%+v", got, want)
+		}
+	}
+	if loaded[0].ChannelName != "One" || loaded[0].Episode != "E3" || loaded[0].SubTitle != "Episode three" || loaded[0].CatchupID != "240115180000" {
+		t.Errorf("loaded programme misses details: %+v", loaded[0])
+	}
+
+	if err := LoadIndex(filepath.Join(t.TempDir(), "missing.xml.gz")); err == nil {
+		t.Error("LoadIndex() of a missing file succeeded")
+	}
+}
+
+func TestIndexBuilderInternsStrings(t *testing.T) {
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	builder := newIndexBuilder()
+	// Decoded programmes hold copies of the same description
+	for day := range 2 {
+		builder.add(IndexedProgramme{
+			ChannelID:   "1",
+			Title:       "Show",
+			Description: strings.Repeat("Daily ", 3),
+			Start:       start.AddDate(0, 0, day),
+			Stop:        start.AddDate(0, 0, day).Add(time.Hour),
+		})
+	}
+	index := builder.build(start)
+	programmes, _ := index.Channel("1", start, start.AddDate(0, 0, 2))
+	if len(programmes) != 2 {
+		t.Fatalf("Channel() = %d programmes, want 2", len(programmes))
+	}
+	if unsafe.StringData(programmes[0].Description) != unsafe.StringData(programmes[1].Description) {
+		t.Error("the repeated description is not shared")
+	}
+}
@@ -0,0 +1,37 @@
+package epg
+
+import (
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/metrics"
+)
+
+var (
+	generations = metrics.NewCounter("jiotv_epg_generations_total",
+		"EPG generations by outcome.", "outcome")
+	generationDuration = metrics.NewGauge("jiotv_epg_generation_duration_seconds",
+		"Duration of the last EPG generation.")
+	generationTimestamp = metrics.NewGauge("jiotv_epg_last_success_timestamp_seconds",
+		"Unix time of the last successful EPG generation.")
+	generatedProgrammes = metrics.NewGauge("jiotv_epg_programmes",
+		"Programmes in the last generated EPG.")
+	generatedChannels = metrics.NewGauge("jiotv_epg_channels",
+		"Channels with programmes in the last generated EPG.")
+	cachedDays = metrics.NewCounter("jiotv_epg_channel_days_total",
+		"Programmes of a channel on a day by source: cache, fetched, stale cache after a failed fetch, or failed.", "source")
+	sourceReads = metrics.NewCounter("jiotv_epg_source_reads_total",
+		"Reads of the XMLTV sources of custom channels by outcome.", "outcome")
+)
+
+// recordGeneration records the duration and size of an EPG generation
+func recordGeneration(duration time.Duration, result generation, err error) {
+	generationDuration.Set(duration.Seconds())
+	if err != nil {
+		generations.Inc("failure")
+		return
+	}
+	generations.Inc("success")
+	generationTimestamp.Set(float64(time.Now().Unix()))
+	generatedProgrammes.Set(float64(result.programmeCount))
+	generatedChannels.Set(float64(result.channelCount))
+}
@@ -0,0 +1,368 @@
+package epg
+
+import (
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/html/charset"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+// sourceTimeout is how long an XMLTV source URL may take to download
+const sourceTimeout = 2 * time.Minute
+
+// maxSourceSize caps the size of an XMLTV source URL as downloaded
+const maxSourceSize = 256 << 20
+
+// sourceBufferSize is the size up to which source bodies are read at once.
+// Larger ones are streamed.
+const sourceBufferSize = 1 << 20
+
+var errSourceTooLarge = fmt.Errorf("source is larger than %d MiB", maxSourceSize>>20)
+
+// sourceProgramme is a programme read from an XMLTV source. Its elements are
+// kept as they are, and the ones the EPG API needs are decoded.
+type sourceProgramme struct {
+	XMLName  xml.Name   `xml:"programme"`
+	Channel  string     `xml:"channel,attr"`
+	Start    string     `xml:"start,attr"`
+	Stop     string     `xml:"stop,attr"`
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXML string     `xml:",innerxml"`
+
+	Titles     []Title    `xml:"title"`
+	SubTitles  []SubTitle `xml:"sub-title"`
+	Descs      []Desc     `xml:"desc"`
+	Categories []Category `xml:"category"`
+}
+
+// rawProgramme is a programme of a source as written to the EPG, moved to a
+// custom channel with its times normalised
+type rawProgramme struct {
+	XMLName  xml.Name   `xml:"programme"`
+	Channel  string     `xml:"channel,attr"`
+	Start    string     `xml:"start,attr"`
+	Stop     string     `xml:"stop,attr"`
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXML string     `xml:",innerxml"`
+}
+
+// customProgramme is a programme of a custom channel, as written to the EPG
+// and to the index
+type customProgramme struct {
+	programme rawProgramme
+	indexed   IndexedProgramme
+}
+
+// customEPG is the guide of the custom channels, merged from the XMLTV
+// sources in the epg_sources config
+type customEPG struct {
+	channels []CustomChannel
+	// programmes are sorted by channel, in the order of channels, and start
+	programmes []customProgramme
+}
+
+// loadCustomEPG reads the programmes airing between from and to of the custom
+// channels with an epg_id from the epg_sources config. A source that cannot
+// be read is skipped. When sources have a programme of a channel starting at
+// the same time, the one of the first source is kept.
+func loadCustomEPG(client *fasthttp.Client, from, to time.Time) customEPG {
+	if len(config.Cfg.EPGSources) == 0 || config.Cfg.CustomChannelsFile == "" {
+		return customEPG{}
+	}
+	channels, err := television.LoadCustomChannels(config.Cfg.CustomChannelsFile)
+	if err != nil {
+		utils.Log.Printf("Failed to load custom channels for the EPG: %v", err)
+		return customEPG{}
+	}
+
+	var custom customEPG
+	// Several custom channels may show the same source channel, such as the
+	// HD and SD streams of a channel
+	wanted := make(map[string][]television.Channel)
+	for _, channel := range channels {
+		if channel.EPGID == "" {
+			continue
+		}
+		wanted[channel.EPGID] = append(wanted[channel.EPGID], channel)
+		custom.channels = append(custom.channels, newCustomChannel(channel))
+	}
+	if len(wanted) == 0 {
+		return customEPG{}
+	}
+
+	type key struct {
+		channel string
+		start   int64
+	}
+	seen := make(map[key]bool)
+	for _, source := range config.Cfg.EPGSources {
+		programmes, err := readSource(client, source, wanted, from, to)
+		if err != nil {
+			sourceReads.Inc("failure")
+			utils.Log.Printf("Skipping EPG source %s: %v", config.RedactURL(source), err)
+			continue
+		}
+		sourceReads.Inc("success")
+		added := 0
+		for _, programme := range programmes {
+			k := key{programme.indexed.ChannelID, programme.indexed.Start.Unix()}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			custom.programmes = append(custom.programmes, programme)
+			added++
+		}
+		utils.Log.Printf("Merged %d programmes from EPG source %s", added, config.RedactURL(source))
+	}
+
+	order := make(map[string]int, len(custom.channels))
+	for i, channel := range custom.channels {
+		order[channel.ID] = i
+	}
+	slices.SortStableFunc(custom.programmes, func(a, b customProgramme) int {
+		return cmp.Or(
+			cmp.Compare(order[a.indexed.ChannelID], order[b.indexed.ChannelID]),
+			a.indexed.Start.Compare(b.indexed.Start),
+		)
+	})
+	return custom
+}
+
+// newCustomChannel converts a custom channel to XMLTV
+func newCustomChannel(channel television.Channel) CustomChannel {
+	xmltvChannel := CustomChannel{
+		ID:           channel.ID,
+		DisplayNames: []DisplayName{{Value: channel.Name, Lang: languageCodes[channel.Language]}},
+	}
+	if strings.HasPrefix(channel.LogoURL, "http://") || strings.HasPrefix(channel.LogoURL, "https://") {
+		xmltvChannel.Icon = &Icon{Src: channel.LogoURL}
+	}
+	return xmltvChannel
+}
+
+// readSource reads the programmes of the wanted channels from an XMLTV URL or
+// file, gzip or plain
+func readSource(client *fasthttp.Client, source string, wanted map[string][]television.Channel, from, to time.Time) ([]customProgramme, error) {
+	var r io.Reader
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		body, err := downloadSource(client, source)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		r = body
+	} else {
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	buffered := bufio.NewReader(r)
+	// gzip files start with 0x1f 0x8b, whatever their name or content type
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return parseSource(gz, wanted, from, to)
+	}
+	return parseSource(buffered, wanted, from, to)
+}
+
+// downloadSource downloads an XMLTV source URL. The body is streamed, so that
+// large sources are parsed without holding them in memory, and is closed by
+// the caller.
+func downloadSource(client *fasthttp.Client, source string) (io.ReadCloser, error) {
+	// fasthttp reads bodies with a length at once unless they are larger
+	// than MaxResponseBodySize, which is set for the whole client
+	streaming := &fasthttp.Client{
+		Dial:                client.Dial,
+		Transport:           client.Transport,
+		MaxResponseBodySize: sourceBufferSize,
+		StreamResponseBody:  true,
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+
+	req.SetRequestURI(source)
+	req.Header.SetMethod(fasthttp.MethodGet)
+	if err := streaming.DoTimeout(req, resp, sourceTimeout); err != nil {
+		fasthttp.ReleaseResponse(resp)
+		return nil, err
+	}
+	body := &sourceBody{resp: resp, remaining: maxSourceSize}
+	if status := resp.StatusCode(); status != fasthttp.StatusOK {
+		body.Close()
+		return nil, fmt.Errorf("HTTP status %d", status)
+	}
+	if resp.Header.ContentLength() > maxSourceSize {
+		body.Close()
+		return nil, errSourceTooLarge
+	}
+	return body, nil
+}
+
+// sourceBody reads the streamed body of a downloaded source, up to
+// maxSourceSize bytes
+type sourceBody struct {
+	resp      *fasthttp.Response
+	remaining int64
+}
+
+func (body *sourceBody) Read(p []byte) (int, error) {
+	if body.remaining <= 0 {
+		return 0, errSourceTooLarge
+	}
+	if int64(len(p)) > body.remaining {
+		p = p[:body.remaining]
+	}
+	n, err := body.resp.BodyStream().Read(p)
+	body.remaining -= int64(n)
+	return n, err
+}
+
+// Close releases the response and its connection
+func (body *sourceBody) Close() error {
+	err := body.resp.CloseBodyStream()
+	fasthttp.ReleaseResponse(body.resp)
+	return err
+}
+
+// parseSource reads the programmes of the wanted channels airing between from
+// and to from an XMLTV document, moved to the custom channels
+func parseSource(r io.Reader, wanted map[string][]television.Channel, from, to time.Time) ([]customProgramme, error) {
+	decoder := xml.NewDecoder(r)
+	// Sources in encodings other than UTF-8, like ISO-8859-1 and
+	// Windows-1252, are converted to UTF-8
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	var programmes []customProgramme
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return programmes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Local != "programme" {
+			continue
+		}
+		channels := wanted[sourceChannel(element)]
+		if len(channels) == 0 {
+			if err := decoder.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var programme sourceProgramme
+		if err := decoder.DecodeElement(&programme, &element); err != nil {
+			return nil, err
+		}
+		start, err := parseXMLTVTime(programme.Start)
+		if err != nil {
+			return nil, err
+		}
+		stop, err := parseXMLTVTime(programme.Stop)
+		if err != nil {
+			return nil, err
+		}
+		if !stop.After(start) || !stop.After(from) || !start.Before(to) {
+			continue
+		}
+		for _, channel := range channels {
+			programmes = append(programmes, newCustomProgramme(programme, channel, start, stop))
+		}
+	}
+}
+
+// sourceChannel returns the channel attribute of a programme element
+func sourceChannel(element xml.StartElement) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == "channel" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// newCustomProgramme moves a programme of a source to a custom channel, with
+// its times in the time zone of the other programmes of the EPG
+func newCustomProgramme(programme sourceProgramme, channel television.Channel, start, stop time.Time) customProgramme {
+	custom := customProgramme{
+		programme: rawProgramme{
+			Channel:  channel.ID,
+			Start:    formatTime(start.Local()),
+			Stop:     formatTime(stop.Local()),
+			Attrs:    programme.Attrs,
+			InnerXML: programme.InnerXML,
+		},
+		indexed: IndexedProgramme{
+			ChannelID:   channel.ID,
+			ChannelName: channel.Name,
+			Start:       start.Local(),
+			Stop:        stop.Local(),
+		},
+	}
+	if len(programme.Titles) > 0 {
+		custom.indexed.Title = programme.Titles[0].Value
+	}
+	if len(programme.SubTitles) > 0 {
+		custom.indexed.SubTitle = programme.SubTitles[0].Value
+	}
+	if len(programme.Descs) > 0 {
+		custom.indexed.Description = programme.Descs[0].Value
+	}
+	if len(programme.Categories) > 0 {
+		custom.indexed.Category = programme.Categories[0].Value
+	}
+	return custom
+}
+
+// parseXMLTVTime parses an XMLTV time such as "20240115210000 +0530". The
+// seconds, minutes and hours may be left out. Times without a zone are in
+// UTC, as the XMLTV format defines.
+func parseXMLTVTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	digits := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+	if digits < 0 {
+		digits = len(value)
+	}
+	date, zone := value[:digits], strings.TrimSpace(value[digits:])
+
+	layouts := map[int]string{8: "20060102", 10: "2006010215", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(date)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	if zone == "" {
+		return time.ParseInLocation(layout, date, time.UTC)
+	}
+	t, err := time.Parse(layout+" -0700", date+" "+zone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	return t, nil
+}
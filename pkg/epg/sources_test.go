@@ -0,0 +1,253 @@
+package epg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/television"
+)
+
+func TestParseXMLTVTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "20240115210000 +0530", want: time.Date(2024, 1, 15, 15, 30, 0, 0, time.UTC)},
+		{value: "20240115210000 -0500", want: time.Date(2024, 1, 16, 2, 0, 0, 0, time.UTC)},
+		{value: "20240115210000+0000", want: time.Date(2024, 1, 15, 21, 0, 0, 0, time.UTC)},
+		{value: "20240115210000", want: time.Date(2024, 1, 15, 21, 0, 0, 0, time.UTC)},
+		{value: "202401152100 +0100", want: time.Date(2024, 1, 15, 20, 0, 0, 0, time.UTC)},
+		{value: "20240115", want: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{value: "", wantErr: true},
+		{value: "2024011521", want: time.Date(2024, 1, 15, 21, 0, 0, 0, time.UTC)},
+		{value: "20240115210000 IST", wantErr: true},
+		{value: "2024-01-15 21:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseXMLTVTime(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseXMLTVTime(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !got.Equal(tt.want) {
+			t.Errorf("parseXMLTVTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// sourceDocument is an XMLTV source with two channels, in another time zone
+const sourceDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE tv SYSTEM "xmltv.dtd">
+<tv generator-info-name="test">
+<channel id="news.example"><display-name>Example News</display-name></channel>
+<programme start="20240115120000 +0000" stop="20240115130000 +0000" channel="news.example" catchup-id="42">
+  <title lang="en">Noon &amp; News</title>
+  <desc lang="en">Midday bulletin</desc>
+  <category lang="en">News</category>
+  <episode-num system="onscreen">E7</episode-num>
+</programme>
+<programme start="20240115130000 +0000" stop="20240115140000 +0000" channel="sports.example">
+  <title>Match</title>
+</programme>
+<programme start="20240110120000 +0000" stop="20240110130000 +0000" channel="news.example">
+  <title>Old News</title>
+</programme>
+<programme start="20240115130000" stop="20240115133000" channel="news.example">
+  <title>Afternoon Brief</title>
+</programme>
+</tv>`
+
+func TestParseSource(t *testing.T) {
+	wanted := map[string][]television.Channel{
+		"news.example": {{ID: "cc_news", Name: "News"}, {ID: "cc_news_hd", Name: "News HD"}},
+	}
+	from := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	programmes, err := parseSource(strings.NewReader(sourceDocument), wanted, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("parseSource() error = %v", err)
+	}
+	// Two programmes of the day, for both custom channels
+	if len(programmes) != 4 {
+		t.Fatalf("parseSource() = %d programmes, want 4", len(programmes))
+	}
+
+	first := programmes[0]
+	if first.indexed.ChannelID != "cc_news" || first.indexed.ChannelName != "News" || first.indexed.Title != "Noon & News" ||
+		first.indexed.Description != "Midday bulletin" || first.indexed.Category != "News" {
+		t.Errorf("parseSource() indexed %+v", first.indexed)
+	}
+	if !first.indexed.Start.Equal(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseSource() start = %v", first.indexed.Start)
+	}
+	if programmes[1].indexed.ChannelID != "cc_news_hd" || programmes[2].indexed.Title != "Afternoon Brief" {
+		t.Errorf("parseSource() programmes out of order: %+v", programmes)
+	}
+
+	got, err := xml.Marshal(first.programme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStart := formatTime(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC).Local())
+	for _, want := range []string{`channel="cc_news"`, `start="` + wantStart + `"`, `catchup-id="42"`, `<title lang="en">Noon &amp; News</title>`, `<episode-num system="onscreen">E7</episode-num>`} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("parseSource() programme %s does not contain %s", got, want)
+		}
+	}
+
+	if _, err := parseSource(strings.NewReader("<tv><programme channel=\"news.example\" start=\"x\"></programme></tv>"), wanted, from, from); err == nil {
+		t.Error("parseSource() of an invalid time succeeded")
+	}
+}
+
+func TestParseSourceCharsets(t *testing.T) {
+	wanted := map[string][]television.Channel{"news.example": {{ID: "cc_news", Name: "News"}}}
+	from := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	for _, encoding := range []string{"ISO-8859-1", "windows-1252"} {
+		// "Café" with é as the single byte 0xe9
+		document := `<?xml version="1.0" encoding="` + encoding + `"?>
+<tv><programme start="20240115120000 +0000" stop="20240115130000 +0000" channel="news.example"><title>Caf` + "\xe9" + `</title></programme></tv>`
+		programmes, err := parseSource(strings.NewReader(document), wanted, from, from.AddDate(0, 0, 1))
+		if err != nil {
+			t.Errorf("parseSource() of %s error = %v", encoding, err)
+			continue
+		}
+		if len(programmes) != 1 || programmes[0].indexed.Title != "Café" {
+			t.Errorf("parseSource() of %s = %+v, want Café", encoding, programmes)
+		}
+	}
+}
+
+func TestDownloadSource(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 2*sourceBufferSize)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/huge.xml" {
+			w.Header().Set("Content-Length", strconv.Itoa(maxSourceSize+1))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Write(large)
+	}))
+	t.Cleanup(server.Close)
+
+	// Bodies larger than the buffer are streamed whole
+	body, err := downloadSource(&fasthttp.Client{}, server.URL+"/large.xml")
+	if err != nil {
+		t.Fatalf("downloadSource() error = %v", err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || !bytes.Equal(data, large) {
+		t.Errorf("downloadSource() read %d bytes, %v, want %d bytes", len(data), err, len(large))
+	}
+
+	if body, err := downloadSource(&fasthttp.Client{}, server.URL+"/huge.xml"); !errors.Is(err, errSourceTooLarge) {
+		if body != nil {
+			body.Close()
+		}
+		t.Errorf("downloadSource() of a too large source error = %v, want %v", err, errSourceTooLarge)
+	}
+
+	// Sources without a length stop being read at the limit
+	limited := &sourceBody{resp: fasthttp.AcquireResponse(), remaining: 4}
+	limited.resp.SetBodyStream(strings.NewReader("<tv></tv>"), -1)
+	data, err = io.ReadAll(limited)
+	if !errors.Is(err, errSourceTooLarge) || string(data) != "<tv>" {
+		t.Errorf("reading past the limit = %q, %v, want %q, %v", data, err, "<tv>", errSourceTooLarge)
+	}
+	limited.Close()
+}
+
+func TestLoadCustomEPG(t *testing.T) {
+	setupCacheTest(t)
+	dir := t.TempDir()
+
+	channelsFile := filepath.Join(dir, "custom-channels.json")
+	channels := `{"channels": [
+		{"id": "news", "name": "News", "url": "https://example.com/news.m3u8", "logo_url": "https://example.com/news.png", "epg_id": "news.example"},
+		{"id": "music", "name": "Music", "url": "https://example.com/music.m3u8"}
+	]}`
+	if err := os.WriteFile(channelsFile, []byte(channels), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := filepath.Join(dir, "guide.xml")
+	if err := os.WriteFile(plain, []byte(sourceDocument), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The second source repeats a programme of the first and adds one
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`<tv>
+<programme start="20240115120000 +0000" stop="20240115130000 +0000" channel="news.example"><title>Duplicate</title></programme>
+<programme start="20240115180000 +0000" stop="20240115190000 +0000" channel="news.example"><title>Evening</title></programme>
+</tv>`))
+	gz.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/guide.xml.gz" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write(compressed.Bytes())
+	}))
+	t.Cleanup(server.Close)
+
+	config.Cfg.CustomChannelsFile = channelsFile
+	config.Cfg.EPGSources = []string{
+		filepath.Join(dir, "missing.xml"),
+		plain,
+		server.URL + "/broken.xml",
+		server.URL + "/guide.xml.gz",
+	}
+
+	from := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	custom := loadCustomEPG(&fasthttp.Client{}, from, from.AddDate(0, 0, 1))
+	if len(custom.channels) != 1 || custom.channels[0].ID != "cc_news" || custom.channels[0].Icon == nil {
+		t.Fatalf("loadCustomEPG() channels = %+v, want cc_news with its logo", custom.channels)
+	}
+	var got []string
+	for _, programme := range custom.programmes {
+		got = append(got, programme.indexed.Title)
+	}
+	if want := "Noon & News,Afternoon Brief,Evening"; strings.Join(got, ",") != want {
+		t.Errorf("loadCustomEPG() programmes = %v, want %s", got, want)
+	}
+
+	// The custom programmes follow the JioTV ones in the EPG and its index
+	var buf bytes.Buffer
+	result, err := writeEPG(&buf, []Channel{{ID: 1, Display: "One"}}, custom, testProgrammes, nil)
+	if err != nil {
+		t.Fatalf("writeEPG() error = %v", err)
+	}
+	if indexed := result.index.build(time.Now()).Len(); result.channelCount != 2 || result.programmeCount != 5 || indexed != 5 {
+		t.Errorf("writeEPG() = %d channels, %d programmes, %d indexed, want 2, 5, 5", result.channelCount, result.programmeCount, indexed)
+	}
+	index, err := readXMLTVIndex(&buf, time.Now())
+	if err != nil {
+		t.Fatalf("writeEPG() wrote an EPG that cannot be read back: %v", err)
+	}
+	programmes, _ := index.Channel("cc_news", from, from.AddDate(0, 0, 1))
+	if index.Len() != 5 || len(programmes) == 0 || programmes[0].ChannelName != "News" || programmes[0].Title != "Noon & News" {
+		t.Errorf("EPG index of cc_news = %+v", programmes)
+	}
+
+	// Without sources the custom channels are left out
+	config.Cfg.EPGSources = nil
+	if custom := loadCustomEPG(&fasthttp.Client{}, from, from.AddDate(0, 0, 1)); len(custom.channels) != 0 || len(custom.programmes) != 0 {
+		t.Errorf("loadCustomEPG() without sources = %+v", custom)
+	}
+}
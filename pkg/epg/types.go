@@ -8,9 +8,27 @@ import (
 
 // Channel XML tag structure for the EPG
 type Channel struct {
-	XMLName xml.Name `xml:"channel"`      // XML tag name
-	ID      int      `xml:"id,attr"`      // ID is attribute of channel tag
-	Display string   `xml:"display-name"` // Display name of the channel
+	XMLName      xml.Name      `xml:"channel"`        // XML tag name
+	ID           int           `xml:"id,attr"`        // ID is attribute of channel tag
+	Display      string        `xml:"-"`              // Display name of the channel
+	DisplayNames []DisplayName `xml:"display-name"`   // Names players match channels by
+	Icon         *Icon         `xml:"icon,omitempty"` // Logo of the channel
+}
+
+// CustomChannel XML tag structure for custom channels in the EPG, whose IDs
+// are not JioTV channel numbers
+type CustomChannel struct {
+	XMLName      xml.Name      `xml:"channel"`
+	ID           string        `xml:"id,attr"`
+	DisplayNames []DisplayName `xml:"display-name"`
+	Icon         *Icon         `xml:"icon,omitempty"`
+}
+
+// DisplayName XML tag for Channel XML tag in EPG
+type DisplayName struct {
+	XMLName xml.Name `xml:"display-name"`
+	Value   string   `xml:",chardata"`           // Name of the channel
+	Lang    string   `xml:"lang,attr,omitempty"` // Language of the name
 }
 
 // Icon XML tag for Programme XML tag in EPG
@@ -35,6 +53,41 @@ type Category struct {
 	Lang    string   `xml:"lang,attr"` // Language of the category
 }
 
+// SubTitle XML tag for Programme XML tag in EPG
+// SubTitle describes the episode of the programme
+type SubTitle struct {
+	XMLName xml.Name `xml:"sub-title"`
+	Value   string   `xml:",chardata"` // Title of the episode
+	Lang    string   `xml:"lang,attr"` // Language of the title
+}
+
+// Credits XML tag for Programme XML tag in EPG
+type Credits struct {
+	XMLName   xml.Name `xml:"credits"`
+	Directors []string `xml:"director"` // Directors of the programme
+	Actors    []string `xml:"actor"`    // Cast of the programme
+}
+
+// EpisodeNum XML tag for Programme XML tag in EPG
+type EpisodeNum struct {
+	XMLName xml.Name `xml:"episode-num"`
+	System  string   `xml:"system,attr"` // Numbering system, xmltv_ns or onscreen
+	Value   string   `xml:",chardata"`   // Episode number in the system
+}
+
+// Rating XML tag for Programme XML tag in EPG
+type Rating struct {
+	XMLName xml.Name `xml:"rating"`
+	Value   string   `xml:"value"` // Age rating of the programme
+}
+
+// Image XML tag for Programme XML tag in EPG
+type Image struct {
+	XMLName xml.Name `xml:"image"`
+	Type    string   `xml:"type,attr"` // Type of the image, poster or still
+	Value   string   `xml:",chardata"` // URL of the image
+}
+
 // Desc represents Description XML tag for Programme XML tag in EPG
 type Desc struct {
 	XMLName xml.Name `xml:"desc"`
@@ -45,14 +98,20 @@ type Desc struct {
 // Programme XML tag structure for EPG
 // Each programme tag represents a show being aired on a channel
 type Programme struct {
-	XMLName  xml.Name `xml:"programme"`    // XML tag name
-	Channel  string   `xml:"channel,attr"` // Channel is attribute of programme tag
-	Start    string   `xml:"start,attr"`   // Start time of the programme
-	Stop     string   `xml:"stop,attr"`    // Stop time of the programme
-	Title    Title    `xml:"title"`        // Title of the programme
-	Desc     Desc     `xml:"desc"`         // Description of the programme
-	Category Category `xml:"category"`     // Category of the programme
-	Icon     Icon     `xml:"icon"`         // Icon of the programme
+	XMLName     xml.Name     `xml:"programme"`                 // XML tag name
+	Channel     string       `xml:"channel,attr"`              // Channel is attribute of programme tag
+	Start       string       `xml:"start,attr"`                // Start time of the programme
+	Stop        string       `xml:"stop,attr"`                 // Stop time of the programme
+	CatchupID   string       `xml:"catchup-id,attr,omitempty"` // Serial number to play the programme from catchup
+	Title       Title        `xml:"title"`                     // Title of the programme
+	SubTitle    *SubTitle    `xml:"sub-title,omitempty"`       // Title of the episode
+	Desc        Desc         `xml:"desc"`                      // Description of the programme
+	Credits     *Credits     `xml:"credits,omitempty"`         // Directors and cast of the programme
+	Category    Category     `xml:"category"`                  // Category of the programme
+	Icon        Icon         `xml:"icon"`                      // Icon of the programme
+	EpisodeNums []EpisodeNum `xml:"episode-num"`               // Episode number in several systems
+	Rating      *Rating      `xml:"rating,omitempty"`          // Age rating of the programme
+	Images      []Image      `xml:"image"`                     // Poster and thumbnail of the programme
 }
 
 // EPG XML tag structure
@@ -66,9 +125,10 @@ type EPG struct {
 
 // ChannelObject represents Individual channel detail from JioTV API response
 type ChannelObject struct {
-	ChannelID   int    `json:"channel_id"`   // Channel ID
-	ChannelName string `json:"channel_name"` // Channel name
-	LogoURL     string `json:"logoUrl"`      // Channel logo URL
+	ChannelID   int    `json:"channel_id"`        // Channel ID
+	ChannelName string `json:"channel_name"`      // Channel name
+	LogoURL     string `json:"logoUrl"`           // Channel logo URL
+	Language    int    `json:"channelLanguageId"` // Language ID of the channel
 }
 
 // ChannelsResponse represents Channel details from JioTV API response
@@ -80,15 +140,21 @@ type ChannelsResponse struct {
 
 // EPGObject represents Individual EPG detail from JioTV EPG API response
 type EPGObject struct {
-	StartEpoch   int64  `json:"startEpoch"`       // Start time of the programme
-	EndEpoch     int64  `json:"endEpoch"`         // End time of the programme
-	ChannelID    uint16 `json:"channel_id"`       // Channel ID
-	ChannelName  string `json:"channel_name"`     // Channel name
-	ShowCategory string `json:"showCategory"`     // Category of the show
-	Description  string `json:"description"`      // Description of the show
-	Title        string `json:"showname"`         // Title of the show
-	Thumbnail    string `json:"episodeThumbnail"` // Thumbnail of the show
-	Poster       string `json:"episodePoster"`    // Poster of the show
+	StartEpoch   int64         `json:"startEpoch"`       // Start time of the programme
+	EndEpoch     int64         `json:"endEpoch"`         // End time of the programme
+	ChannelID    uint16        `json:"channel_id"`       // Channel ID
+	ChannelName  string        `json:"channel_name"`     // Channel name
+	ShowCategory string        `json:"showCategory"`     // Category of the show
+	Description  string        `json:"description"`      // Description of the show
+	Title        string        `json:"showname"`         // Title of the show
+	Thumbnail    string        `json:"episodeThumbnail"` // Thumbnail of the show
+	Poster       string        `json:"episodePoster"`    // Poster of the show
+	Srno         SerialNumber  `json:"srno"`             // Serial number of the airing, used for catchup
+	EpisodeNum   EpisodeNumber `json:"episode_num"`      // Episode number of the show, 0 if unknown
+	EpisodeDesc  string        `json:"episode_desc"`     // Description of the episode
+	Director     string        `json:"director"`         // Director of the show
+	StarCast     string        `json:"starCast"`         // Comma separated cast of the show
+	Rating       string        `json:"rating"`           // Age rating of the show, if any
 }
 
 // EPGResponse represents EPG details from JioTV EPG API response
@@ -121,3 +187,29 @@ func (id *EpochString) UnmarshalJSON(data []byte) error {
 func (id *EpochString) String() string {
 	return string(*id)
 }
+
+// SerialNumber is a programme serial number that JioTV EPG API sends either as a number or as a string
+type SerialNumber string
+
+// UnmarshalJSON unmarshals serial numbers from JioTV EPG API without losing digits
+func (srno *SerialNumber) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err == nil {
+		*srno = SerialNumber(number.String())
+		return nil
+	}
+	var stringValue string
+	if err := json.Unmarshal(data, &stringValue); err != nil {
+		return err
+	}
+	*srno = SerialNumber(stringValue)
+	return nil
+}
+
+// EpisodeNumber is an episode number that JioTV EPG API sends either as a number or as a string
+type EpisodeNumber string
+
+// UnmarshalJSON unmarshals episode numbers from JioTV EPG API
+func (n *EpisodeNumber) UnmarshalJSON(data []byte) error {
+	return (*SerialNumber)(n).UnmarshalJSON(data)
+}
@@ -0,0 +1,176 @@
+package epg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/constants/urls"
+)
+
+// xmltvHeader starts every EPG file
+const xmltvHeader = xml.Header + `<!DOCTYPE tv SYSTEM "http://www.w3.org/2006/05/tv">` + "\n"
+
+// xmltvWriter streams an XMLTV document: the channels first, then the
+// programmes of each channel as they are written
+type xmltvWriter struct {
+	encoder *xml.Encoder
+	tv      xml.StartElement
+}
+
+// newXMLTVWriter writes the header and the channels of an XMLTV document to w
+func newXMLTVWriter(w io.Writer, channels []Channel, customChannels []CustomChannel) (*xmltvWriter, error) {
+	if _, err := io.WriteString(w, xmltvHeader); err != nil {
+		return nil, err
+	}
+	x := &xmltvWriter{
+		encoder: xml.NewEncoder(w),
+		tv:      xml.StartElement{Name: xml.Name{Local: "tv"}},
+	}
+	if err := x.encoder.EncodeToken(x.tv); err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if err := x.encoder.Encode(channel); err != nil {
+			return nil, err
+		}
+	}
+	for _, channel := range customChannels {
+		if err := x.encoder.Encode(channel); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// writeProgrammes writes programmes to the document
+func (x *xmltvWriter) writeProgrammes(programmes []EPGObject) error {
+	for _, programme := range programmes {
+		if err := x.encoder.Encode(newXMLTVProgramme(programme)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCustomProgrammes writes the programmes of custom channels to the document
+func (x *xmltvWriter) writeCustomProgrammes(programmes []customProgramme) error {
+	for _, programme := range programmes {
+		if err := x.encoder.Encode(programme.programme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close ends the document and flushes it to the underlying writer
+func (x *xmltvWriter) close() error {
+	if err := x.encoder.EncodeToken(x.tv.End()); err != nil {
+		return err
+	}
+	return x.encoder.Close()
+}
+
+// languageCodes are the ISO 639 codes of the channel languages of JioTV
+var languageCodes = map[int]string{
+	1:  "hi",
+	2:  "mr",
+	3:  "pa",
+	4:  "ur",
+	5:  "bn",
+	6:  "en",
+	7:  "ml",
+	8:  "ta",
+	9:  "gu",
+	10: "or",
+	11: "te",
+	12: "bho",
+	13: "kn",
+	14: "as",
+	15: "ne",
+	16: "fr",
+}
+
+// newChannel converts a channel from JioTV API to XMLTV. Players match
+// channels by name or by number, so both are display names.
+func newChannel(channel ChannelObject) Channel {
+	xmltvChannel := Channel{
+		ID:      channel.ChannelID,
+		Display: channel.ChannelName,
+		DisplayNames: []DisplayName{
+			{Value: channel.ChannelName, Lang: languageCodes[channel.Language]},
+			{Value: strconv.Itoa(channel.ChannelID)},
+		},
+	}
+	switch {
+	case channel.LogoURL == "":
+	case strings.HasPrefix(channel.LogoURL, "http://") || strings.HasPrefix(channel.LogoURL, "https://"):
+		xmltvChannel.Icon = &Icon{Src: channel.LogoURL}
+	default:
+		xmltvChannel.Icon = &Icon{Src: urls.ChannelLogoURL + "/" + channel.LogoURL}
+	}
+	return xmltvChannel
+}
+
+// newXMLTVProgramme converts a programme from JioTV EPG API to XMLTV
+func newXMLTVProgramme(programme EPGObject) Programme {
+	startTime := formatTime(time.UnixMilli(programme.StartEpoch))
+	endTime := formatTime(time.UnixMilli(programme.EndEpoch))
+	xmltvProgramme := NewProgramme(int(programme.ChannelID), startTime, endTime, programme.Title, programme.Description, programme.ShowCategory, programme.Poster)
+
+	xmltvProgramme.CatchupID = string(programme.Srno)
+	if programme.EpisodeDesc != "" && programme.EpisodeDesc != programme.Description {
+		xmltvProgramme.SubTitle = &SubTitle{Value: programme.EpisodeDesc, Lang: "en"}
+	}
+	xmltvProgramme.Credits = newCredits(programme)
+	xmltvProgramme.EpisodeNums = newEpisodeNums(programme.EpisodeNum)
+	if programme.Rating != "" {
+		xmltvProgramme.Rating = &Rating{Value: programme.Rating}
+	}
+	if programme.Poster != "" {
+		xmltvProgramme.Images = append(xmltvProgramme.Images, Image{Type: "poster", Value: EPG_POSTER_URL + "/" + programme.Poster})
+	}
+	if programme.Thumbnail != "" {
+		xmltvProgramme.Images = append(xmltvProgramme.Images, Image{Type: "still", Value: EPG_POSTER_URL + "/" + programme.Thumbnail})
+	}
+	return xmltvProgramme
+}
+
+// newCredits returns the director and cast of a programme, nil if unknown
+func newCredits(programme EPGObject) *Credits {
+	var credits Credits
+	if director := strings.TrimSpace(programme.Director); director != "" {
+		credits.Directors = []string{director}
+	}
+	for actor := range strings.SplitSeq(programme.StarCast, ",") {
+		if actor = strings.TrimSpace(actor); actor != "" {
+			credits.Actors = append(credits.Actors, actor)
+		}
+	}
+	if len(credits.Directors) == 0 && len(credits.Actors) == 0 {
+		return nil
+	}
+	return &credits
+}
+
+// newEpisodeNums returns the episode number in the xmltv_ns system, counted
+// from 0, and as shown on screen. JioTV sends 0 when it is unknown.
+func newEpisodeNums(episode EpisodeNumber) []EpisodeNum {
+	value := strings.TrimSpace(string(episode))
+	number, err := strconv.Atoi(value)
+	switch {
+	case value == "" || value == "0":
+		return nil
+	case err != nil:
+		return []EpisodeNum{{System: "onscreen", Value: value}}
+	case number < 0:
+		return nil
+	}
+	return []EpisodeNum{
+		{System: "xmltv_ns", Value: fmt.Sprintf(".%d.", number-1)},
+		{System: "onscreen", Value: fmt.Sprintf("E%d", number)},
+	}
+}
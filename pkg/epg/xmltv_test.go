@@ -0,0 +1,265 @@
+package epg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+func setupWriterTest(t *testing.T) {
+	t.Helper()
+	previousLog := utils.Log
+	utils.Log = log.New(os.Stderr, "", 0)
+	t.Cleanup(func() { utils.Log = previousLog })
+}
+
+// testProgrammes returns two programmes of a channel, or none for channel 3
+func testProgrammes(channel Channel) []EPGObject {
+	if channel.ID == 3 {
+		return nil
+	}
+	start := time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC)
+	var programmes []EPGObject
+	for i := range 2 {
+		programmes = append(programmes, EPGObject{
+			ChannelID:  uint16(channel.ID),
+			StartEpoch: start.Add(time.Duration(i) * time.Hour).UnixMilli(),
+			EndEpoch:   start.Add(time.Duration(i+1) * time.Hour).UnixMilli(),
+			Title:      channel.Display + " & Show",
+		})
+	}
+	return programmes
+}
+
+func TestWriteEPG(t *testing.T) {
+	setupWriterTest(t)
+	channels := []Channel{{ID: 1, Display: "One"}, {ID: 2, Display: "Two"}, {ID: 3, Display: "Three"}}
+	// Channels finish in random order
+	fetch := func(channel Channel) []EPGObject {
+		time.Sleep(time.Duration(rand.IntN(3)) * time.Millisecond)
+		return testProgrammes(channel)
+	}
+
+	var first bytes.Buffer
+	// Only the programmes of channel 2 are kept
+	keep := func(programme EPGObject) bool { return programme.ChannelID == 2 }
+	result, err := writeEPG(&first, channels, customEPG{}, fetch, keep)
+	if err != nil {
+		t.Fatalf("writeEPG() error = %v", err)
+	}
+	if indexed := result.index.build(time.Now()).Len(); result.channelCount != 2 || result.programmeCount != 4 || len(result.programmes) != 2 || indexed != 4 {
+		t.Errorf("writeEPG() = %d channels, %d programmes, %d kept, %d indexed, want 2, 4, 2, 4", result.channelCount, result.programmeCount, len(result.programmes), indexed)
+	}
+
+	out := first.String()
+	if !strings.HasPrefix(out, xmltvHeader+"<tv>") || !strings.HasSuffix(out, "</tv>") {
+		t.Errorf("writeEPG() document is not wrapped in the header and <tv>: %s", out)
+	}
+	var doc struct {
+		Channels   []Channel   `xml:"channel"`
+		Programmes []Programme `xml:"programme"`
+	}
+	if err := xml.Unmarshal(first.Bytes(), &doc); err != nil {
+		t.Fatalf("writeEPG() wrote invalid XML: %v", err)
+	}
+	if len(doc.Channels) != 3 || len(doc.Programmes) != 4 {
+		t.Fatalf("writeEPG() wrote %d channels and %d programmes, want 3 and 4", len(doc.Channels), len(doc.Programmes))
+	}
+	if doc.Programmes[0].Channel != "1" || doc.Programmes[2].Channel != "2" || doc.Programmes[0].Title.Value != "One & Show" {
+		t.Errorf("writeEPG() programmes are out of order: %+v", doc.Programmes)
+	}
+	if strings.LastIndex(out, "<channel ") > strings.Index(out, "<programme ") {
+		t.Error("writeEPG() wrote a channel after a programme")
+	}
+
+	// The output does not depend on the order channels are fetched in
+	for range 5 {
+		var again bytes.Buffer
+		if _, err := writeEPG(&again, channels, customEPG{}, fetch, nil); err != nil {
+			t.Fatal(err)
+		}
+		if again.String() != out {
+			t.Fatalf("writeEPG() output changed between runs:\n%s\n%s", out, again.String())
+		}
+	}
+}
+
+func TestWriteEPGWithoutProgrammes(t *testing.T) {
+	setupWriterTest(t)
+	channels := []Channel{{ID: 3, Display: "Three"}}
+	if _, err := writeEPG(&bytes.Buffer{}, channels, customEPG{}, testProgrammes, nil); !errors.Is(err, errNoProgrammes) {
+		t.Errorf("writeEPG() error = %v, want %v", err, errNoProgrammes)
+	}
+}
+
+func TestFetchInOrder(t *testing.T) {
+	setupWriterTest(t)
+	channels := make([]Channel, 10*fetchWindow)
+	for i := range channels {
+		channels[i] = Channel{ID: i}
+	}
+
+	var mu sync.Mutex
+	fetched, written, maxAhead := 0, 0, 0
+	fetch := func(channel Channel) []EPGObject {
+		// The first channel is slow, so later channels wait for it
+		if channel.ID == 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		fetched++
+		maxAhead = max(maxAhead, fetched-written)
+		mu.Unlock()
+		return []EPGObject{{ChannelID: uint16(channel.ID)}}
+	}
+	var order []int
+	write := func(channel Channel, programmes []EPGObject) error {
+		mu.Lock()
+		written++
+		mu.Unlock()
+		order = append(order, int(programmes[0].ChannelID))
+		return nil
+	}
+
+	if err := fetchInOrder(channels, fetch, write); err != nil {
+		t.Fatalf("fetchInOrder() error = %v", err)
+	}
+	for i, id := range order {
+		if id != i {
+			t.Fatalf("fetchInOrder() wrote channel %d at position %d", id, i)
+		}
+	}
+	if len(order) != len(channels) {
+		t.Errorf("fetchInOrder() wrote %d channels, want %d", len(order), len(channels))
+	}
+	if maxAhead > fetchWindow {
+		t.Errorf("fetchInOrder() fetched %d channels ahead of the written ones, want at most %d", maxAhead, fetchWindow)
+	}
+
+	// A write error stops fetching
+	errWrite := errors.New("disk full")
+	if err := fetchInOrder(channels, fetch, func(Channel, []EPGObject) error { return errWrite }); !errors.Is(err, errWrite) {
+		t.Errorf("fetchInOrder() error = %v, want %v", err, errWrite)
+	}
+}
+
+func TestNewXMLTVProgramme(t *testing.T) {
+	start := time.Date(2024, 1, 15, 21, 0, 0, 0, time.UTC)
+	programme := EPGObject{
+		ChannelID:    143,
+		StartEpoch:   start.UnixMilli(),
+		EndEpoch:     start.Add(30 * time.Minute).UnixMilli(),
+		Title:        "Show",
+		Description:  "About the show",
+		ShowCategory: "Drama",
+		Poster:       "poster.jpg",
+		Thumbnail:    "thumb.jpg",
+		Srno:         "240115210000",
+		EpisodeNum:   "12",
+		EpisodeDesc:  "The one with the twist",
+		Director:     "Director",
+		StarCast:     "Actor One, Actor Two,",
+		Rating:       "U/A 13+",
+	}
+	got, err := xml.Marshal(newXMLTVProgramme(programme))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<programme channel="143" start="20240115210000 +0000" stop="20240115213000 +0000" catchup-id="240115210000">` +
+		`<title lang="en">Show</title>` +
+		`<sub-title lang="en">The one with the twist</sub-title>` +
+		`<desc lang="en">About the show</desc>` +
+		`<credits><director>Director</director><actor>Actor One</actor><actor>Actor Two</actor></credits>` +
+		`<category lang="en">Drama</category>` +
+		`<icon src="https://jiotv.catchup.cdn.jio.com/dare_images/shows/poster.jpg"></icon>` +
+		`<episode-num system="xmltv_ns">.11.</episode-num>` +
+		`<episode-num system="onscreen">E12</episode-num>` +
+		`<rating><value>U/A 13+</value></rating>` +
+		`<image type="poster">https://jiotv.catchup.cdn.jio.com/dare_images/shows/poster.jpg</image>` +
+		`<image type="still">https://jiotv.catchup.cdn.jio.com/dare_images/shows/thumb.jpg</image>` +
+		`</programme>`
+	if string(got) != want {
+		t.Errorf("newXMLTVProgramme() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Optional elements are left out when JioTV does not send them
+	programme = EPGObject{ChannelID: 143, Title: "News", Description: "Headlines", EpisodeNum: "0", EpisodeDesc: "Headlines"}
+	got, err = xml.Marshal(newXMLTVProgramme(programme))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, element := range []string{"catchup-id", "<sub-title", "<credits", "<episode-num", "<rating", "<image"} {
+		if strings.Contains(string(got), element) {
+			t.Errorf("newXMLTVProgramme() without details contains %s: %s", element, got)
+		}
+	}
+}
+
+func TestNewEpisodeNums(t *testing.T) {
+	tests := []struct {
+		episode EpisodeNumber
+		want    []EpisodeNum
+	}{
+		{episode: "", want: nil},
+		{episode: "0", want: nil},
+		{episode: "-1", want: nil},
+		{episode: "1", want: []EpisodeNum{{System: "xmltv_ns", Value: ".0."}, {System: "onscreen", Value: "E1"}}},
+		{episode: "S2 E5", want: []EpisodeNum{{System: "onscreen", Value: "S2 E5"}}},
+	}
+	for _, tt := range tests {
+		got := newEpisodeNums(tt.episode)
+		if len(got) != len(tt.want) {
+			t.Errorf("newEpisodeNums(%q) = %+v, want %+v", tt.episode, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].System != tt.want[i].System || got[i].Value != tt.want[i].Value {
+				t.Errorf("newEpisodeNums(%q) = %+v, want %+v", tt.episode, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestNewChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel ChannelObject
+		want    string
+	}{
+		{
+			name:    "Relative logo",
+			channel: ChannelObject{ChannelID: 143, ChannelName: "Aaj Tak", LogoURL: "Aaj_Tak.png", Language: 1},
+			want:    `<channel id="143"><display-name lang="hi">Aaj Tak</display-name><display-name>143</display-name><icon src="https://jiotv.catchup.cdn.jio.com/dare_images/images/Aaj_Tak.png"></icon></channel>`,
+		},
+		{
+			name:    "Absolute logo",
+			channel: ChannelObject{ChannelID: 1, ChannelName: "One", LogoURL: "https://example.com/one.png", Language: 6},
+			want:    `<channel id="1"><display-name lang="en">One</display-name><display-name>1</display-name><icon src="https://example.com/one.png"></icon></channel>`,
+		},
+		{
+			name:    "No logo or language",
+			channel: ChannelObject{ChannelID: 2, ChannelName: "Two"},
+			want:    `<channel id="2"><display-name>Two</display-name><display-name>2</display-name></channel>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xml.Marshal(newChannel(tt.channel))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("newChannel() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+// Package metrics is a minimal registry of counters, gauges and histograms
+// exposed in the Prometheus text format, without client library dependencies.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency buckets in seconds for request durations
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is a metric family that can write itself in the text format
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+var (
+	registry   []metric
+	registryMu sync.Mutex
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, registered := range registry {
+		if registered.name() == m.name() {
+			panic("metrics: duplicate metric " + m.name())
+		}
+	}
+	registry = append(registry, m)
+}
+
+// WriteTo writes all registered metrics, sorted by name, in the Prometheus
+// text format
+func WriteTo(w io.Writer) error {
+	registryMu.Lock()
+	metrics := slices.Clone(registry)
+	registryMu.Unlock()
+	slices.SortFunc(metrics, func(a, b metric) int {
+		return strings.Compare(a.name(), b.name())
+	})
+
+	buffered := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// desc holds what all metric families share
+type desc struct {
+	metricName string
+	help       string
+	typ        string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, d.typ)
+}
+
+// checkValues panics when a metric is used with the wrong number of label values
+func (d *desc) checkValues(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+}
+
+// formatLabels formats label pairs as {a="1",b="2"}, with extra pairs appended
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	pair := func(name, value string) {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(value))
+		b.WriteByte('"')
+	}
+	for i, name := range names {
+		pair(name, values[i])
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pair(extra[i], extra[i+1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// seriesKey joins label values into a map key
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// valueVec is a set of float values by label values, shared by counters and gauges
+type valueVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*labeledValue
+}
+
+type labeledValue struct {
+	labels []string
+	value  float64
+}
+
+func newValueVec(name, help, typ string, labels []string) *valueVec {
+	return &valueVec{
+		desc:   desc{metricName: name, help: help, typ: typ, labels: labels},
+		values: make(map[string]*labeledValue),
+	}
+}
+
+func (v *valueVec) update(values []string, fn func(float64) float64) {
+	v.checkValues(values)
+	key := seriesKey(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	series, ok := v.values[key]
+	if !ok {
+		series = &labeledValue{labels: slices.Clone(values)}
+		v.values[key] = series
+	}
+	series.value = fn(series.value)
+}
+
+func (v *valueVec) get(values []string) float64 {
+	v.checkValues(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if series, ok := v.values[seriesKey(values)]; ok {
+		return series.value
+	}
+	return 0
+}
+
+func (v *valueVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	series := make([]labeledValue, 0, len(v.values))
+	for _, value := range v.values {
+		series = append(series, *value)
+	}
+	v.mu.Unlock()
+	slices.SortFunc(series, func(a, b labeledValue) int {
+		return slices.Compare(a.labels, b.labels)
+	})
+
+	v.writeHeader(w)
+	if len(series) == 0 && len(v.labels) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.metricName)
+	}
+	for _, s := range series {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, formatLabels(v.labels, s.labels), formatValue(s.value))
+	}
+}
+
+// Counter is a value that only goes up, by label values
+type Counter struct {
+	vec *valueVec
+}
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newValueVec(name, help, "counter", labels)}
+	register(c.vec)
+	return c
+}
+
+// Inc adds 1 to the counter of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the counter of the label values
+func (c *Counter) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.vec.metricName + " cannot decrease")
+	}
+	c.vec.update(values, func(value float64) float64 { return value + delta })
+}
+
+// Value returns the counter of the label values
+func (c *Counter) Value(values ...string) float64 {
+	return c.vec.get(values)
+}
+
+// Gauge is a value that goes up and down, by label values
+type Gauge struct {
+	vec *valueVec
+}
+
+// NewGauge registers a gauge with the given label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newValueVec(name, help, "gauge", labels)}
+	register(g.vec)
+	return g
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(value float64, values ...string) {
+	g.vec.update(values, func(float64) float64 { return value })
+}
+
+// Add adds delta to the gauge of the label values
+func (g *Gauge) Add(delta float64, values ...string) {
+	g.vec.update(values, func(value float64) float64 { return value + delta })
+}
+
+// Value returns the gauge of the label values
+func (g *Gauge) Value(values ...string) float64 {
+	return g.vec.get(values)
+}
+
+// Histogram counts observations in buckets, by label values
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given upper bounds of its
+// buckets and label names
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{metricName: name, help: help, typ: "histogram", labels: labels},
+		buckets: slices.Sorted(slices.Values(buckets)),
+		series:  make(map[string]*histogramSeries),
+	}
+	register(h)
+	return h
+}
+
+// Observe adds an observation to the histogram of the label values
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.checkValues(values)
+	key := seriesKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{labels: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	series := make([]histogramSeries, 0, len(h.series))
+	for _, s := range h.series {
+		series = append(series, histogramSeries{labels: s.labels, counts: slices.Clone(s.counts), count: s.count, sum: s.sum})
+	}
+	h.mu.Unlock()
+	slices.SortFunc(series, func(a, b histogramSeries) int {
+		return slices.Compare(a.labels, b.labels)
+	})
+
+	h.writeHeader(w)
+	for _, s := range series {
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, s.labels, "le", formatValue(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, s.labels), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, s.labels), s.count)
+	}
+}
+
+// funcMetric is a metric whose values are collected when it is written
+type funcMetric struct {
+	desc
+	collect func(emit func(value float64, values ...string))
+}
+
+// NewGaugeFunc registers a gauge whose values collect emits, by label values,
+// whenever the metrics are written
+func NewGaugeFunc(name, help string, labels []string, collect func(emit func(value float64, values ...string))) {
+	register(&funcMetric{desc: desc{metricName: name, help: help, typ: "gauge", labels: labels}, collect: collect})
+}
+
+// NewCounterFunc registers a counter whose values collect emits, by label
+// values, whenever the metrics are written
+func NewCounterFunc(name, help string, labels []string, collect func(emit func(value float64, values ...string))) {
+	register(&funcMetric{desc: desc{metricName: name, help: help, typ: "counter", labels: labels}, collect: collect})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	var series []labeledValue
+	f.collect(func(value float64, values ...string) {
+		f.checkValues(values)
+		series = append(series, labeledValue{labels: slices.Clone(values), value: value})
+	})
+	slices.SortFunc(series, func(a, b labeledValue) int {
+		return slices.Compare(a.labels, b.labels)
+	})
+
+	f.writeHeader(w)
+	for _, s := range series {
+		fmt.Fprintf(w, "%s%s %s\n", f.metricName, formatLabels(f.labels, s.labels), formatValue(s.value))
+	}
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// setupRegistry empties the registry for the test
+func setupRegistry(t *testing.T) {
+	t.Helper()
+	registryMu.Lock()
+	previous := registry
+	registry = nil
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = previous
+		registryMu.Unlock()
+	})
+}
+
+func TestWriteTo(t *testing.T) {
+	setupRegistry(t)
+
+	requests := NewCounter("test_requests_total", "Requests by family.", "family", "code")
+	requests.Inc("live", "200")
+	requests.Add(2, "epg", "500")
+	requests.Inc("live", "200")
+
+	temperature := NewGauge("test_temperature", "Temperature with a \"quoted\"\nhelp.")
+	temperature.Set(21.5)
+
+	latency := NewHistogram("test_latency_seconds", "Latency.", []float64{1, 0.1}, "family")
+	latency.Observe(0.05, "live")
+	latency.Observe(0.5, "live")
+	latency.Observe(5, "live")
+
+	NewGaugeFunc("test_streams", "Active streams.", []string{"channel"}, func(emit func(float64, ...string)) {
+		emit(2, `say "hi"`)
+		emit(1, "143")
+	})
+	NewCounter("test_unused_total", "Never incremented.")
+
+	var b strings.Builder
+	if err := WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{family="live",le="0.1"} 1
+test_latency_seconds_bucket{family="live",le="1"} 2
+test_latency_seconds_bucket{family="live",le="+Inf"} 3
+test_latency_seconds_sum{family="live"} 5.55
+test_latency_seconds_count{family="live"} 3
+# HELP test_requests_total Requests by family.
+# TYPE test_requests_total counter
+test_requests_total{family="epg",code="500"} 2
+test_requests_total{family="live",code="200"} 2
+# HELP test_streams Active streams.
+# TYPE test_streams gauge
+test_streams{channel="143"} 1
+test_streams{channel="say \"hi\""} 2
+# HELP test_temperature Temperature with a "quoted"\nhelp.
+# TYPE test_temperature gauge
+test_temperature 21.5
+# HELP test_unused_total Never incremented.
+# TYPE test_unused_total counter
+test_unused_total 0
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCounterConcurrent(t *testing.T) {
+	setupRegistry(t)
+	counter := NewCounter("test_concurrent_total", "Concurrent increments.", "worker")
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				counter.Inc("a")
+			}
+		}()
+	}
+	wg.Wait()
+	if got := counter.Value("a"); got != 8000 {
+		t.Errorf("Value() = %v, want 8000", got)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	setupRegistry(t)
+	counter := NewCounter("test_misuse_total", "Misuse.", "family")
+
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Wrong label count", func() { counter.Inc() }},
+		{"Negative counter delta", func() { counter.Add(-1, "live") }},
+		{"Duplicate name", func() { NewGauge("test_misuse_total", "Duplicate.") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{1.5, "1.5"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatValue(tt.value); got != tt.want {
+			t.Errorf("formatValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package scheduler
 
 import (
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
@@ -10,8 +13,22 @@ import (
 var (
 	// Scheduler is the task scheduler
 	Scheduler *tasks.Scheduler
+
+	// taskStatuses tracks the runs of the tasks added with Add
+	taskStatuses   = make(map[string]*TaskStatus)
+	taskStatusesMu sync.Mutex
 )
 
+// TaskStatus describes a scheduled task and its last run
+type TaskStatus struct {
+	ID        string    `json:"id"`
+	Interval  string    `json:"interval"`
+	NextRun   time.Time `json:"next_run"`
+	LastRun   time.Time `json:"last_run,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+	Runs      int       `json:"runs"`
+}
+
 func Init() {
 	// Create a new task scheduler
 	Scheduler = tasks.New()
@@ -28,7 +45,11 @@ func Add(id string, interval time.Duration, task func() error) {
 	// Add a task
 	err := Scheduler.AddWithID(id, &tasks.Task{
 		Interval: interval,
-		TaskFunc: task,
+		TaskFunc: func() error {
+			err := task()
+			recordRun(id, interval, time.Now(), err)
+			return err
+		},
 		ErrFunc: func(err error) {
 			utils.Log.Printf("Task failed: %v\n", err)
 		},
@@ -37,5 +58,42 @@ func Add(id string, interval time.Duration, task func() error) {
 		utils.Log.Printf("Failed to add task: %v\n", err)
 		return
 	}
+
+	taskStatusesMu.Lock()
+	taskStatuses[id] = &TaskStatus{ID: id, Interval: interval.String(), NextRun: time.Now().Add(interval)}
+	taskStatusesMu.Unlock()
 	utils.Log.Printf("Task added with ID: %v\n", id)
 }
+
+// Tasks returns the tasks added with Add, sorted by ID
+func Tasks() []TaskStatus {
+	taskStatusesMu.Lock()
+	defer taskStatusesMu.Unlock()
+
+	statuses := make([]TaskStatus, 0, len(taskStatuses))
+	for _, status := range taskStatuses {
+		statuses = append(statuses, *status)
+	}
+	slices.SortFunc(statuses, func(a, b TaskStatus) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return statuses
+}
+
+// recordRun records that a task finished running at now
+func recordRun(id string, interval time.Duration, now time.Time, err error) {
+	taskStatusesMu.Lock()
+	defer taskStatusesMu.Unlock()
+
+	status, ok := taskStatuses[id]
+	if !ok {
+		return
+	}
+	status.Runs++
+	status.LastRun = now
+	status.NextRun = now.Add(interval)
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+}
@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"errors"
 	"log"
 	"os"
 	"testing"
@@ -132,3 +133,40 @@ func TestAdd(t *testing.T) {
 		Add("nil_task", 1*time.Second, nil)
 	})
 }
+
+func TestTasks(t *testing.T) {
+	Init()
+	defer Stop()
+
+	find := func(id string) (TaskStatus, bool) {
+		for _, status := range Tasks() {
+			if status.ID == id {
+				return status, true
+			}
+		}
+		return TaskStatus{}, false
+	}
+
+	Add("test_tasks_failing", 20*time.Millisecond, func() error {
+		return errors.New("upstream down")
+	})
+	status, ok := find("test_tasks_failing")
+	if !ok || status.Interval != "20ms" || status.Runs != 0 || status.NextRun.IsZero() {
+		t.Fatalf("Tasks() status after Add = %+v, %v", status, ok)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for status.Runs == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		status, _ = find("test_tasks_failing")
+	}
+	if status.Runs == 0 || status.LastRun.IsZero() || status.LastError != "upstream down" {
+		t.Errorf("Tasks() status after a run = %+v", status)
+	}
+
+	// Adding a task again resets its status
+	Add("test_tasks_failing", time.Hour, func() error { return nil })
+	if status, _ := find("test_tasks_failing"); status.Runs != 0 || status.LastError != "" || status.Interval != "1h0m0s" {
+		t.Errorf("Tasks() status after replacing the task = %+v", status)
+	}
+}
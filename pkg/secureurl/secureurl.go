@@ -1,24 +1,51 @@
 package secureurl
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
 )
 
+// KeyFileName is the name of the file in the path prefix that keeps the URL
+// encryption keys, so that encrypted URLs stay valid across restarts.
+const KeyFileName = "secureurl.key"
+
 var (
 	key                  []byte
+	previousKey          []byte
+	previousKeyExpiry    time.Time
 	disableUrlEncryption bool
 )
 
+// keyFile is the JSON structure of the key file
+type keyFile struct {
+	Key                string    `json:"key"`
+	PreviousKey        string    `json:"previous_key,omitempty"`
+	PreviousKeyExpires time.Time `json:"previous_key_expires,omitempty"`
+}
+
 func generateKey() []byte {
 	key := make([]byte, 32) // 32 bytes for AES-256
 	_, err := rand.Read(key)
@@ -28,65 +55,404 @@ func generateKey() []byte {
 	return key
 }
 
+// tokenVersion is the first byte of every encrypted URL token
+const tokenVersion = 1
+
+// clientHashSize is the size of the client binding hash in a token
+const clientHashSize = 8
+
+// defaultExpiry is how long encrypted URLs stay valid when url_expiry_hours is not set
+const defaultExpiry = 24 * time.Hour
+
+// defaultAllowedHosts are the upstream host suffixes encrypted URLs may point to
+var defaultAllowedHosts = []string{
+	"jio.com",
+	"jiocinema.com",
+	"fancode.com",
+	"slivcdn.com",
+	"dai.google.com",
+	"googlevideo.com",
+	"akamaized.net",
+}
+
+// Reason codes of rejected URL tokens
+const (
+	ReasonMalformed      = "malformed"
+	ReasonInvalid        = "invalid_signature"
+	ReasonExpired        = "expired"
+	ReasonClientMismatch = "client_mismatch"
+	ReasonHostNotAllowed = "host_not_allowed"
+)
+
+// TokenError is returned when an encrypted URL is rejected. Reason is one of
+// the Reason codes, so clients can tell a tampered URL from an expired one.
+type TokenError struct {
+	Reason  string
+	Message string
+}
+
+func (e *TokenError) Error() string {
+	return e.Message
+}
+
+// Reason returns the reason code of a TokenError, or "" for other errors
+func Reason(err error) string {
+	var tokenErr *TokenError
+	if errors.As(err, &tokenErr) {
+		return tokenErr.Reason
+	}
+	return ""
+}
+
+// now returns the current time, replaced in tests
+var now = time.Now
+
+// urlScheme matches values starting with a URL scheme, in any case
+var urlScheme = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://`)
+
+var (
+	allowedHostsMu sync.RWMutex
+	allowedHosts   []string
+)
+
+// AllowHosts adds upstream hosts that encrypted URLs may point to, along with
+// their subdomains. It is used for hosts of custom channels.
+func AllowHosts(hosts ...string) {
+	allowedHostsMu.Lock()
+	defer allowedHostsMu.Unlock()
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(host), "."))
+		if host != "" && !slices.Contains(allowedHosts, host) {
+			allowedHosts = append(allowedHosts, host)
+		}
+	}
+}
+
+// CheckHost returns a TokenError if host is not an allowed upstream host
+func CheckHost(host string) error {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	allowedHostsMu.RLock()
+	defer allowedHostsMu.RUnlock()
+	for _, allowed := range allowedHosts {
+		if allowed == "*" || host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+	return &TokenError{Reason: ReasonHostNotAllowed, Message: fmt.Sprintf("host %s is not allowed, add it to url_allowed_hosts to allow it", host)}
+}
+
+// upstreamHost returns the host a decrypted value points to. Values are full
+// URLs, hosts with or without a port and path, paths or query parameters;
+// only the first two carry a host. ok is false when a value should carry a
+// host but none can be determined.
+func upstreamHost(value string) (host string, ok bool) {
+	if value == "" || (strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//")) {
+		return "", true
+	}
+	if strings.HasPrefix(value, "//") || urlScheme.MatchString(value) {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Host == "" {
+			return "", false
+		}
+		if scheme := strings.ToLower(parsed.Scheme); scheme != "" && scheme != "http" && scheme != "https" {
+			return "", false
+		}
+		return parsed.Host, true
+	}
+	first, _, _ := strings.Cut(value, "/")
+	first, _, _ = strings.Cut(first, "?")
+	first, _, _ = strings.Cut(first, "#")
+	if first == "" || strings.Contains(first, "=") {
+		return "", true
+	}
+	return first, true
+}
+
+// checkUpstream returns an error if value points to a host that is not
+// allowed, or to no host it can determine
+func checkUpstream(value string) error {
+	host, ok := upstreamHost(value)
+	if !ok {
+		return &TokenError{Reason: ReasonHostNotAllowed, Message: "URL has no upstream host"}
+	}
+	if host != "" {
+		return CheckHost(host)
+	}
+	return nil
+}
+
+// urlExpiry returns how long encrypted URLs are valid, 0 if they never expire
+func urlExpiry() time.Duration {
+	switch hours := config.Cfg.URLExpiryHours; {
+	case hours < 0:
+		return 0
+	case hours == 0:
+		return defaultExpiry
+	default:
+		return time.Duration(hours) * time.Hour
+	}
+}
+
+// clientHash returns the binding of a token to a client, zeros when unbound
+func clientHash(client string) []byte {
+	hash := make([]byte, clientHashSize)
+	if client != "" {
+		sum := sha256.Sum256([]byte(client))
+		copy(hash, sum[:])
+	}
+	return hash
+}
+
+// EncryptURL encrypts and signs a URL, so that it can be passed to clients
+// and back without them being able to read or modify it.
 func EncryptURL(inputURL string) (string, error) {
+	return EncryptURLFor(inputURL, "")
+}
+
+// EncryptURLFor is EncryptURL for URLs only accepted from the given client,
+// such as its IP address. An empty client gives a URL usable by anyone.
+func EncryptURLFor(inputURL, client string) (string, error) {
 	if disableUrlEncryption {
 		return url.QueryEscape(inputURL), nil
 	}
 
-	block, err := aes.NewCipher(key)
+	gcm, err := newGCM(key)
 	if err != nil {
 		return "", err
 	}
 
-	ciphertext := make([]byte, aes.BlockSize+len(inputURL))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return "", err
+	// Payload: expiry in unix seconds (0 for never) | client hash | URL
+	payload := make([]byte, 8+clientHashSize, 8+clientHashSize+len(inputURL))
+	if expiry := urlExpiry(); expiry > 0 {
+		binary.BigEndian.PutUint64(payload, uint64(now().Add(expiry).Unix()))
 	}
+	copy(payload[8:], clientHash(client))
+	payload = append(payload, inputURL...)
 
-	stream := cipher.NewCTR(block, iv)
-	stream.XORKeyStream(ciphertext[aes.BlockSize:], []byte(inputURL))
+	token := make([]byte, 1+gcm.NonceSize(), 1+gcm.NonceSize()+len(payload)+gcm.Overhead())
+	token[0] = tokenVersion
+	if _, err := io.ReadFull(rand.Reader, token[1:]); err != nil {
+		return "", err
+	}
+	token = gcm.Seal(token, token[1:], payload, token[:1])
 
-	encryptedURL := base64.URLEncoding.EncodeToString(ciphertext)
-	return encryptedURL, nil
+	return base64.URLEncoding.EncodeToString(token), nil
 }
 
+// DecryptURL decrypts a URL encrypted with EncryptURL. It returns a
+// TokenError if the URL was modified, expired, bound to a client or points
+// to a host that is not allowed.
 func DecryptURL(encryptedURL string) (string, error) {
+	return DecryptURLFor(encryptedURL, "")
+}
+
+// DecryptURLFor is DecryptURL for a request from the given client
+func DecryptURLFor(encryptedURL, client string) (string, error) {
 	if disableUrlEncryption {
 		decoded_url, err := url.QueryUnescape(encryptedURL)
-		return decoded_url, err
+		if err != nil {
+			return "", err
+		}
+		return decoded_url, checkUpstream(decoded_url)
 	}
 
-	ciphertext, err := base64.URLEncoding.DecodeString(encryptedURL)
+	token, err := base64.URLEncoding.DecodeString(encryptedURL)
 	if err != nil {
-		return "", err
+		return "", &TokenError{Reason: ReasonMalformed, Message: "malformed URL token"}
 	}
 
-	block, err := aes.NewCipher(key)
+	payload, err := open(key, token)
+	// URLs of the previous key keep working during its grace period
+	if err != nil && previousKey != nil && now().Before(previousKeyExpiry) {
+		if previousPayload, previousErr := open(previousKey, token); previousErr == nil {
+			payload, err = previousPayload, nil
+		}
+	}
 	if err != nil {
 		return "", err
 	}
 
-	if len(ciphertext) < aes.BlockSize {
-		return "", errors.New("ciphertext too short")
+	if expiry := binary.BigEndian.Uint64(payload); expiry != 0 && now().Unix() > int64(expiry) {
+		return "", &TokenError{Reason: ReasonExpired, Message: "URL expired, reload the playlist"}
+	}
+	if bound := payload[8 : 8+clientHashSize]; !bytes.Equal(bound, clientHash("")) && !bytes.Equal(bound, clientHash(client)) {
+		return "", &TokenError{Reason: ReasonClientMismatch, Message: "URL was issued to another client"}
 	}
 
-	iv := ciphertext[:aes.BlockSize]
-	ciphertext = ciphertext[aes.BlockSize:]
-
-	stream := cipher.NewCTR(block, iv)
-	stream.XORKeyStream(ciphertext, ciphertext)
+	decryptedURL := string(payload[8+clientHashSize:])
+	if err := checkUpstream(decryptedURL); err != nil {
+		return "", err
+	}
+	return decryptedURL, nil
+}
 
-	decryptedURL := string(ciphertext)
+// newGCM returns AES-GCM for the given key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
 
-	return decryptedURL, nil
+// open verifies and decrypts a token, returning its payload
+func open(key, token []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(token) < 1+gcm.NonceSize()+gcm.Overhead()+8+clientHashSize || token[0] != tokenVersion {
+		return nil, &TokenError{Reason: ReasonMalformed, Message: "malformed URL token"}
+	}
+	nonce := token[1 : 1+gcm.NonceSize()]
+	payload, err := gcm.Open(nil, nonce, token[1+gcm.NonceSize():], token[:1])
+	if err != nil {
+		return nil, &TokenError{Reason: ReasonInvalid, Message: "URL token was modified or signed with another key"}
+	}
+	return payload, nil
 }
 
 func Init() {
+	allowedHostsMu.Lock()
+	allowedHosts = nil
+	allowedHostsMu.Unlock()
+	AllowHosts(defaultAllowedHosts...)
+	AllowHosts(config.Cfg.URLAllowedHosts...)
+
 	disableUrlEncryption = config.Cfg.DisableURLEncryption
 	if disableUrlEncryption {
 		fmt.Println("Warning! URL encryption is disabled. Anyone can pass modified URLs to your server.")
-		return
 	}
-	key = generateKey()
+	// The key is loaded even without URL encryption, as DerivedKeys signs
+	// other credentials with it
+	if err := loadKeys(); err != nil {
+		// Encrypted URLs will not survive a restart, but the server still works
+		fmt.Println("Warning! Could not persist the URL encryption key:", err)
+		key = generateKey()
+		previousKey = nil
+	}
+}
+
+// keyFilePath returns the path of the key file in the path prefix
+func keyFilePath() string {
+	return filepath.Join(store.GetPathPrefix(), KeyFileName)
+}
+
+// readKeyFile reads the key file. It returns a nil keyFile if it does not exist.
+func readKeyFile() (*keyFile, error) {
+	data, err := os.ReadFile(keyFilePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys keyFile
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", keyFilePath(), err)
+	}
+	return &keys, nil
+}
+
+// writeKeyFile writes the key file readable only by the owner
+func writeKeyFile(keys keyFile) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(keyFilePath(), 0600, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// decodeKey decodes a base64 encoded AES-256 key
+func decodeKey(encoded string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != 32 {
+		return nil, fmt.Errorf("key is %d bytes, want 32", len(decoded))
+	}
+	return decoded, nil
+}
+
+// loadKeys loads the keys from the key file, and creates it with a new key
+// on the first run.
+func loadKeys() error {
+	keys, err := readKeyFile()
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		newKey := generateKey()
+		if err := writeKeyFile(keyFile{Key: base64.StdEncoding.EncodeToString(newKey)}); err != nil {
+			return err
+		}
+		key, previousKey, previousKeyExpiry = newKey, nil, time.Time{}
+		return nil
+	}
+
+	currentKey, err := decodeKey(keys.Key)
+	if err != nil {
+		return fmt.Errorf("invalid key in %s: %w", keyFilePath(), err)
+	}
+	key, previousKey, previousKeyExpiry = currentKey, nil, time.Time{}
+	if keys.PreviousKey != "" && now().Before(keys.PreviousKeyExpires) {
+		if previousKey, err = decodeKey(keys.PreviousKey); err != nil {
+			return fmt.Errorf("invalid previous key in %s: %w", keyFilePath(), err)
+		}
+		previousKeyExpiry = keys.PreviousKeyExpires
+	}
+	return nil
+}
+
+// DerivedKeys returns keys derived from the URL encryption key for the given
+// purpose, so that other credentials survive restarts like encrypted URLs do.
+// The key of the current URL key comes first, followed by the key of the
+// previous URL key during its grace period. It returns nil before Init.
+func DerivedKeys(purpose string) [][]byte {
+	derive := func(from []byte) []byte {
+		mac := hmac.New(sha256.New, from)
+		mac.Write([]byte(purpose))
+		return mac.Sum(nil)
+	}
+	if key == nil {
+		return nil
+	}
+	keys := [][]byte{derive(key)}
+	if previousKey != nil && now().Before(previousKeyExpiry) {
+		keys = append(keys, derive(previousKey))
+	}
+	return keys
+}
+
+// RotateKey replaces the URL encryption key with a new one. URLs encrypted
+// with the current key are still accepted for the grace period, after which
+// they stop working. It returns when the grace period ends. A running server
+// uses the new key after it is restarted.
+func RotateKey(grace time.Duration) (time.Time, error) {
+	if grace < 0 {
+		return time.Time{}, fmt.Errorf("grace period must not be negative")
+	}
+	keys, err := readKeyFile()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	rotated := keyFile{Key: base64.StdEncoding.EncodeToString(generateKey())}
+	if keys != nil && grace > 0 {
+		rotated.PreviousKey = keys.Key
+		rotated.PreviousKeyExpires = now().Add(grace)
+	}
+	if err := writeKeyFile(rotated); err != nil {
+		return time.Time{}, err
+	}
+	return rotated.PreviousKeyExpires, loadKeys()
 }
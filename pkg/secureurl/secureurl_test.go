@@ -1,9 +1,28 @@
 package secureurl
 
 import (
+	"encoding/base64"
+	"net/url"
+	"os"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
 )
 
+func TestMain(m *testing.M) {
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		panic(err)
+	}
+	config.Cfg.URLAllowedHosts = []string{"example.com"}
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
+
 func TestGenerateKey(t *testing.T) {
 	tests := []struct {
 		name string
@@ -140,3 +159,210 @@ func TestInit(t *testing.T) {
 		})
 	}
 }
+
+func TestInitPersistsKey(t *testing.T) {
+	if err := os.Remove(keyFilePath()); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	Init()
+
+	info, err := os.Stat(keyFilePath())
+	if err != nil {
+		t.Fatalf("Init() did not write the key file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key file permissions = %o, want 600", perm)
+	}
+
+	encrypted, err := EncryptURL("https://example.com/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart loads the same key
+	key = nil
+	Init()
+	if decrypted, err := DecryptURL(encrypted); err != nil || decrypted != "https://example.com/test" {
+		t.Errorf("DecryptURL() after restart = %q, %v", decrypted, err)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	defer func() { now = time.Now }()
+	Init()
+	const testURL = "https://example.com/test?param1=value1"
+	encrypted, err := EncryptURL(testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiry, err := RotateKey(time.Hour)
+	if err != nil {
+		t.Fatalf("RotateKey() error = %v", err)
+	}
+	if time.Until(expiry) < 59*time.Minute {
+		t.Errorf("RotateKey() grace period ends at %v, want in an hour", expiry)
+	}
+
+	// URLs of the previous key keep working during the grace period
+	Init()
+	if decrypted, err := DecryptURL(encrypted); err != nil || decrypted != testURL {
+		t.Errorf("DecryptURL() of a previous key URL = %q, %v", decrypted, err)
+	}
+	current, err := EncryptURL(testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err := DecryptURL(current); err != nil || decrypted != testURL {
+		t.Errorf("DecryptURL() of a new key URL = %q, %v", decrypted, err)
+	}
+
+	// After the grace period the previous key is no longer accepted
+	now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if decrypted, _ := DecryptURL(encrypted); decrypted == testURL {
+		t.Error("DecryptURL() accepted a previous key URL after the grace period")
+	}
+	now = time.Now
+
+	// Without a grace period the previous key is dropped immediately
+	if _, err := RotateKey(0); err != nil {
+		t.Fatalf("RotateKey() error = %v", err)
+	}
+	Init()
+	if decrypted, _ := DecryptURL(current); decrypted == testURL {
+		t.Error("DecryptURL() accepted a URL of a rotated key without a grace period")
+	}
+
+	if _, err := RotateKey(-time.Hour); err == nil {
+		t.Error("RotateKey() accepted a negative grace period")
+	}
+}
+
+func TestDecryptURLRejects(t *testing.T) {
+	Init()
+	defer func() { now = time.Now }()
+
+	const testURL = "https://example.com/live/index.m3u8"
+	encrypt := func(value, client string) string {
+		t.Helper()
+		token, err := EncryptURLFor(value, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	tampered := func(token string) string {
+		raw, _ := base64.URLEncoding.DecodeString(token)
+		raw[len(raw)-20] ^= 1
+		return base64.URLEncoding.EncodeToString(raw)
+	}
+
+	tests := []struct {
+		name       string
+		token      func() string
+		client     string
+		later      time.Duration
+		wantReason string
+	}{
+		{name: "Valid", token: func() string { return encrypt(testURL, "") }},
+		{name: "Bound to the client", token: func() string { return encrypt(testURL, "10.0.0.2") }, client: "10.0.0.2"},
+		{name: "Malformed", token: func() string { return "not base64!" }, wantReason: ReasonMalformed},
+		{name: "Tampered", token: func() string { return tampered(encrypt(testURL, "")) }, wantReason: ReasonInvalid},
+		{name: "Expired", token: func() string { return encrypt(testURL, "") }, later: 25 * time.Hour, wantReason: ReasonExpired},
+		{name: "Bound to another client", token: func() string { return encrypt(testURL, "10.0.0.2") }, client: "10.0.0.3", wantReason: ReasonClientMismatch},
+		{name: "Host not allowed", token: func() string { return encrypt("http://192.168.1.1/admin", "") }, wantReason: ReasonHostNotAllowed},
+		{name: "Bare host not allowed", token: func() string { return encrypt("internal.example.net", "") }, wantReason: ReasonHostNotAllowed},
+		{name: "Subdomain of an allowed host", token: func() string { return encrypt("https://cdn.example.com/a.ts", "") }},
+		{name: "Path", token: func() string { return encrypt("/bpk-tv/channel/", "") }},
+		{name: "Uppercase scheme", token: func() string { return encrypt("HTTPS://evil.example/x", "") }, wantReason: ReasonHostNotAllowed},
+		{name: "Host with a port", token: func() string { return encrypt("localhost:6379/x", "") }, wantReason: ReasonHostNotAllowed},
+		{name: "IPv6 literal", token: func() string { return encrypt("[::1]:6379/x", "") }, wantReason: ReasonHostNotAllowed},
+		{name: "Other scheme", token: func() string { return encrypt("file:///etc/passwd", "") }, wantReason: ReasonHostNotAllowed},
+		{name: "Query parameters", token: func() string { return encrypt("__hdnea__=st=1~acl=/*~hmac=ab", "") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = time.Now
+			token := tt.token()
+			now = func() time.Time { return time.Now().Add(tt.later) }
+
+			_, err := DecryptURLFor(token, tt.client)
+			if got := Reason(err); got != tt.wantReason {
+				t.Errorf("DecryptURLFor() error = %v, want reason %q", err, tt.wantReason)
+			}
+			if tt.wantReason == "" && err != nil {
+				t.Errorf("DecryptURLFor() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestUpstreamHost(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{value: "", want: "", wantOK: true},
+		{value: "/bpk-tv/channel/", want: "", wantOK: true},
+		{value: "__hdnea__=st=1~acl=/*~hmac=ab", want: "", wantOK: true},
+		{value: "https://jiotvmblive.cdn.jio.com/a.m3u8", want: "jiotvmblive.cdn.jio.com", wantOK: true},
+		{value: "HTTPS://evil.example/x", want: "evil.example", wantOK: true},
+		{value: "//evil.example/x", want: "evil.example", wantOK: true},
+		{value: "jiotvmblive.cdn.jio.com", want: "jiotvmblive.cdn.jio.com", wantOK: true},
+		{value: "localhost:8080", want: "localhost:8080", wantOK: true},
+		{value: "localhost", want: "localhost", wantOK: true},
+		{value: "[::1]:6379/x", want: "[::1]:6379", wantOK: true},
+		{value: "evil.example?x=1", want: "evil.example", wantOK: true},
+		{value: "http:///path", want: "", wantOK: false},
+		{value: "ftp://evil.example/x", want: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := upstreamHost(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("upstreamHost(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCheckHostIPv6(t *testing.T) {
+	AllowHosts("::1")
+	defer func() {
+		allowedHostsMu.Lock()
+		allowedHosts = slices.DeleteFunc(allowedHosts, func(host string) bool { return host == "::1" })
+		allowedHostsMu.Unlock()
+	}()
+	for _, host := range []string{"[::1]", "[::1]:6379", "::1"} {
+		if err := CheckHost(host); err != nil {
+			t.Errorf("CheckHost(%q) error = %v", host, err)
+		}
+	}
+}
+
+func TestDecryptURLDisabledEncryption(t *testing.T) {
+	disableUrlEncryption = true
+	defer func() { disableUrlEncryption = false }()
+	for _, value := range []string{"HTTPS://evil.example/x", "localhost:6379/x", "[::1]:6379/x"} {
+		if _, err := DecryptURL(url.QueryEscape(value)); Reason(err) != ReasonHostNotAllowed {
+			t.Errorf("DecryptURL(%q) error = %v, want reason %q", value, err, ReasonHostNotAllowed)
+		}
+	}
+}
+
+func TestURLExpiry(t *testing.T) {
+	defer func() { config.Cfg.URLExpiryHours = 0 }()
+	tests := []struct {
+		hours int
+		want  time.Duration
+	}{
+		{hours: 0, want: defaultExpiry},
+		{hours: 2, want: 2 * time.Hour},
+		{hours: -1, want: 0},
+	}
+	for _, tt := range tests {
+		config.Cfg.URLExpiryHours = tt.hours
+		if got := urlExpiry(); got != tt.want {
+			t.Errorf("urlExpiry() with %d hours = %v, want %v", tt.hours, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"golang.org/x/term"
+)
+
+// StoreKeyEnv is the environment variable holding the store passphrase
+const StoreKeyEnv = "JIOTV_STORE_KEY"
+
+const (
+	// encryptedValuePrefix marks encrypted values in the store file
+	encryptedValuePrefix = "enc:v1:"
+	// keyDerivationIterations is the PBKDF2 iteration count for new stores
+	keyDerivationIterations = 600000
+	// encryptionCheckValue is encrypted into the store file to detect a wrong passphrase
+	encryptionCheckValue = "jiotv_go"
+)
+
+// sensitiveKeys are the keys whose values are encrypted. Keys of profiles,
+// such as profile.family.ssoToken, are matched by their last part.
+var sensitiveKeys = []string{
+	"ssoToken",
+	"accessToken",
+	"refreshToken",
+	"crm",
+	"uniqueId",
+}
+
+// Errors of store encryption
+var (
+	ErrStoreKeyMissing = errors.New("no store passphrase: set " + StoreKeyEnv + " or store_key_file, or start in a terminal")
+	ErrWrongStoreKey   = errors.New("wrong store passphrase")
+)
+
+// promptPassphrase asks for the store passphrase. Tests replace it.
+var promptPassphrase = promptTerminalPassphrase
+
+// encryptionHeader is stored in the store file when it is encrypted
+type encryptionHeader struct {
+	Salt       string `toml:"salt"`
+	Iterations int    `toml:"iterations"`
+	// Check is encryptionCheckValue encrypted, to detect a wrong passphrase
+	Check string `toml:"check"`
+}
+
+// isSensitiveKey reports whether the value of key is encrypted
+func isSensitiveKey(key string) bool {
+	return slices.Contains(sensitiveKeys, key[strings.LastIndex(key, ".")+1:])
+}
+
+// setupEncryption decrypts the values of an encrypted store file and sets up
+// the cipher for writing it. Plaintext stores are encrypted on their next
+// write when store_encryption is enabled, and encrypted stores are written in
+// plaintext again when it is disabled.
+func (s *TomlStore) setupEncryption() error {
+	encrypted := s.config.Encryption != nil
+	if !config.Cfg.StoreEncryption && !encrypted {
+		return nil
+	}
+
+	passphrase, err := storePassphrase(!encrypted)
+	if err != nil {
+		if encrypted {
+			return fmt.Errorf("%s is encrypted: %w", s.filename, err)
+		}
+		return err
+	}
+
+	header := s.config.Encryption
+	if header == nil {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		header = &encryptionHeader{Salt: base64.StdEncoding.EncodeToString(salt), Iterations: keyDerivationIterations}
+	}
+	aead, err := deriveCipher(passphrase, header)
+	if err != nil {
+		return err
+	}
+
+	if header.Check == "" {
+		if header.Check, err = encryptValue(aead, encryptionCheckValue); err != nil {
+			return err
+		}
+	} else if check, err := decryptValue(aead, header.Check); err != nil || check != encryptionCheckValue {
+		return ErrWrongStoreKey
+	}
+
+	for key, value := range s.config.Data {
+		if !strings.HasPrefix(value, encryptedValuePrefix) {
+			continue
+		}
+		plaintext, err := decryptValue(aead, value)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", key, err)
+		}
+		s.config.Data[key] = plaintext
+	}
+
+	if config.Cfg.StoreEncryption {
+		s.aead = aead
+		s.config.Encryption = header
+	} else {
+		s.aead = nil
+		s.config.Encryption = nil
+	}
+	return nil
+}
+
+// encryptedConfig returns the configuration to write, with the sensitive
+// values encrypted if the store is encrypted
+func (s *TomlStore) encryptedConfig() (Config, error) {
+	if s.aead == nil {
+		return Config{Data: s.config.Data}, nil
+	}
+	data := make(map[string]string, len(s.config.Data))
+	for key, value := range s.config.Data {
+		if isSensitiveKey(key) {
+			encrypted, err := encryptValue(s.aead, value)
+			if err != nil {
+				return Config{}, err
+			}
+			value = encrypted
+		}
+		data[key] = value
+	}
+	return Config{Encryption: s.config.Encryption, Data: data}, nil
+}
+
+// deriveCipher derives the AES-256-GCM cipher of the store from the passphrase
+func deriveCipher(passphrase string, header *encryptionHeader) (cipher.AEAD, error) {
+	salt, err := base64.StdEncoding.DecodeString(header.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store salt: %w", err)
+	}
+	if header.Iterations <= 0 {
+		return nil, fmt.Errorf("invalid store key iterations %d", header.Iterations)
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, header.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptValue encrypts a value as encryptedValuePrefix followed by the
+// base64 encoded nonce and ciphertext
+func encryptValue(aead cipher.AEAD, value string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedValuePrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptValue decrypts a value encrypted by encryptValue
+func decryptValue(aead cipher.AEAD, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedValuePrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// storePassphrase returns the store passphrase from StoreKeyEnv, the key
+// file or a prompt. The key file is created with a random passphrase if it
+// does not exist. A new passphrase is prompted twice.
+func storePassphrase(isNew bool) (string, error) {
+	if passphrase := os.Getenv(StoreKeyEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if keyFile := config.Cfg.StoreKeyFile; keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if os.IsNotExist(err) {
+			random := make([]byte, 32)
+			if _, err := rand.Read(random); err != nil {
+				return "", err
+			}
+			passphrase := base64.StdEncoding.EncodeToString(random)
+			err := WriteFileAtomic(keyFile, 0600, func(w io.Writer) error {
+				_, err := io.WriteString(w, passphrase+"\n")
+				return err
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to create store key file: %w", err)
+			}
+			return passphrase, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read store key file: %w", err)
+		}
+		passphrase := strings.TrimSpace(string(data))
+		if passphrase == "" {
+			return "", fmt.Errorf("store key file %s is empty", keyFile)
+		}
+		return passphrase, nil
+	}
+
+	return promptPassphrase(isNew)
+}
+
+// promptTerminalPassphrase reads the store passphrase from the terminal
+func promptTerminalPassphrase(isNew bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", ErrStoreKeyMissing
+	}
+
+	fmt.Fprint(os.Stderr, "Enter store passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", ErrStoreKeyMissing
+	}
+	if isNew {
+		fmt.Fprint(os.Stderr, "Repeat store passphrase: ")
+		repeated, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(repeated) != string(passphrase) {
+			return "", errors.New("store passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+)
+
+// setupEncryptedStore enables store encryption with passphrase for the test
+func setupEncryptedStore(t *testing.T, passphrase string) {
+	t.Helper()
+	cleanup, err := SetupTestPathPrefix()
+	if err != nil {
+		t.Fatalf("Failed to setup test environment: %v", err)
+	}
+	previousEncryption, previousKeyFile, previousPrompt := config.Cfg.StoreEncryption, config.Cfg.StoreKeyFile, promptPassphrase
+	t.Cleanup(func() {
+		cleanup()
+		config.Cfg.StoreEncryption, config.Cfg.StoreKeyFile, promptPassphrase = previousEncryption, previousKeyFile, previousPrompt
+	})
+	config.Cfg.StoreEncryption = true
+	config.Cfg.StoreKeyFile = ""
+	promptPassphrase = func(bool) (string, error) {
+		return "", ErrStoreKeyMissing
+	}
+	t.Setenv(StoreKeyEnv, passphrase)
+}
+
+func readStoreFile(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(KVS.(*TomlStore).filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "ssoToken", want: true},
+		{key: "profile.family.accessToken", want: true},
+		{key: "crm", want: true},
+		{key: "lastTokenRefreshTime", want: false},
+		{key: "profile.family.deviceId", want: false},
+		{key: "accessTokenSuffix", want: false},
+	}
+	for _, tt := range tests {
+		if got := isSensitiveKey(tt.key); got != tt.want {
+			t.Errorf("isSensitiveKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	setupEncryptedStore(t, "correct horse")
+
+	if err := Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := Set("profile.family.ssoToken", "secret-sso"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set("lastTokenRefreshTime", "1700000000"); err != nil {
+		t.Fatal(err)
+	}
+
+	contents := readStoreFile(t)
+	if strings.Contains(contents, "secret-sso") || !strings.Contains(contents, encryptedValuePrefix) {
+		t.Errorf("store file does not encrypt the token:\n%s", contents)
+	}
+	if !strings.Contains(contents, "1700000000") {
+		t.Errorf("store file encrypts a value that is not sensitive:\n%s", contents)
+	}
+	info, err := os.Stat(KVS.(*TomlStore).filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("store file permissions = %o, want 600", perm)
+	}
+
+	// Reopening with the passphrase decrypts the values
+	if err := Init(); err != nil {
+		t.Fatalf("Init() of an encrypted store error = %v", err)
+	}
+	if value, err := Get("profile.family.ssoToken"); err != nil || value != "secret-sso" {
+		t.Errorf("Get() = %q, %v, want secret-sso", value, err)
+	}
+
+	// A wrong passphrase is rejected
+	t.Setenv(StoreKeyEnv, "wrong")
+	if err := Init(); !errors.Is(err, ErrWrongStoreKey) {
+		t.Errorf("Init() with a wrong passphrase error = %v, want %v", err, ErrWrongStoreKey)
+	}
+
+	// No passphrase at all is an error even with encryption disabled
+	t.Setenv(StoreKeyEnv, "")
+	config.Cfg.StoreEncryption = false
+	if err := Init(); !errors.Is(err, ErrStoreKeyMissing) {
+		t.Errorf("Init() without a passphrase error = %v, want %v", err, ErrStoreKeyMissing)
+	}
+
+	// Disabling encryption writes the values in plaintext again
+	t.Setenv(StoreKeyEnv, "correct horse")
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set("accessToken", "secret-access"); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readStoreFile(t); !strings.Contains(contents, "secret-sso") || strings.Contains(contents, encryptedValuePrefix) {
+		t.Errorf("store file is still encrypted:\n%s", contents)
+	}
+}
+
+func TestEncryptedStoreMigration(t *testing.T) {
+	setupEncryptedStore(t, "")
+	config.Cfg.StoreEncryption = false
+
+	// A plaintext store written before encryption was enabled
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set("refreshToken", "secret-refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Cfg.StoreEncryption = true
+	config.Cfg.StoreKeyFile = filepath.Join(t.TempDir(), "store.key")
+	if err := Init(); err != nil {
+		t.Fatalf("Init() of a plaintext store error = %v", err)
+	}
+	if value, err := Get("refreshToken"); err != nil || value != "secret-refresh" {
+		t.Errorf("Get() = %q, %v, want secret-refresh", value, err)
+	}
+	if err := Set("deviceId", "device"); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readStoreFile(t); strings.Contains(contents, "secret-refresh") {
+		t.Errorf("store file was not encrypted on write:\n%s", contents)
+	}
+
+	// The generated key file opens the store again
+	info, err := os.Stat(config.Cfg.StoreKeyFile)
+	if err != nil {
+		t.Fatalf("key file was not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key file permissions = %o, want 600", perm)
+	}
+	if err := Init(); err != nil {
+		t.Fatalf("Init() with the key file error = %v", err)
+	}
+	if value, err := Get("refreshToken"); err != nil || value != "secret-refresh" {
+		t.Errorf("Get() after reopening = %q, %v, want secret-refresh", value, err)
+	}
+}
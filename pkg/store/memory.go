@@ -0,0 +1,91 @@
+package store
+
+import (
+	"strings"
+	"sync"
+)
+
+// EnvStorePrefix is the prefix of the environment variables read by the env
+// backend. The rest of the name is the key, with "__" standing for ".", so
+// JIOTV_STORE_DATA_profile__family__ssoToken sets profile.family.ssoToken.
+const EnvStorePrefix = "JIOTV_STORE_DATA_"
+
+// MemoryStore is a store that only lives in memory.
+type MemoryStore struct {
+	data map[string]string
+	mu   sync.Mutex
+}
+
+// NewMemoryStore returns a memory store holding a copy of data.
+func NewMemoryStore(data map[string]string) *MemoryStore {
+	s := &MemoryStore{data: make(map[string]string, len(data))}
+	for key, value := range data {
+		s.data[key] = value
+	}
+	return s
+}
+
+// NewEnvStore returns a memory store seeded from the EnvStorePrefix variables
+// of environ, as returned by os.Environ. Changes such as refreshed tokens are
+// kept in memory only, so the variables must hold credentials that can be
+// refreshed again after a restart.
+func NewEnvStore(environ []string) *MemoryStore {
+	data := make(map[string]string)
+	for _, variable := range environ {
+		name, value, ok := strings.Cut(variable, "=")
+		if !ok {
+			continue
+		}
+		if key, ok := strings.CutPrefix(name, EnvStorePrefix); ok && key != "" {
+			data[strings.ReplaceAll(key, "__", ".")] = value
+		}
+	}
+	return NewMemoryStore(data)
+}
+
+// Get retrieves the value for the specified key from the memory store.
+func (s *MemoryStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return newTx(s.data).Get(key)
+}
+
+// Set sets the value for the specified key in the memory store.
+func (s *MemoryStore) Set(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = value
+	return nil
+}
+
+// Delete removes the entry for the specified key from the memory store.
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
+
+// Keys returns the keys of the memory store in sorted order.
+func (s *MemoryStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedKeys(s.data)
+}
+
+// Update runs fn in a transaction and applies its changes at once.
+func (s *MemoryStore) Update(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := newTx(s.data)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.data, _ = tx.apply()
+	return nil
+}
@@ -3,93 +3,166 @@ package store
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
-	"sync"
 
-	"github.com/BurntSushi/toml"
 	"github.com/jiotv-go/jiotv_go/v3/internal/config"
 	"github.com/jiotv-go/jiotv_go/v3/internal/constants"
 )
 
-// Config represents the structure of the TOML file.
-type Config struct {
-	Data map[string]string `toml:"data"`
-}
+// Store backends selected by the store_backend config
+const (
+	// BackendTOML keeps the store in a TOML file under the path prefix
+	BackendTOML = "toml"
+	// BackendMemory keeps the store in memory, losing it on exit
+	BackendMemory = "memory"
+	// BackendEnv reads the store from EnvStorePrefix environment variables
+	// and keeps changes in memory, for read-only filesystems
+	BackendEnv = "env"
+)
 
-// TomlStore represents the TOML storage.
-type TomlStore struct {
-	filename string
-	config   Config
-	mu       sync.Mutex
+// TomlStoreFile is the file under the path prefix holding the TOML store.
+// Its version is changed whenever a new version requires logging in again.
+const TomlStoreFile = "store_v4.toml"
+
+// Store is a key-value store of credentials and settings.
+type Store interface {
+	// Get returns the value of key, or ErrKeyNotFound.
+	Get(key string) (string, error)
+	// Set sets the value of key.
+	Set(key, value string) error
+	// Delete removes key.
+	Delete(key string) error
+	// Keys returns the keys in sorted order.
+	Keys() []string
+	// Update runs fn in a transaction. The changes of fn are applied
+	// atomically if it returns nil and discarded otherwise. fn must not use
+	// the store other than through tx.
+	Update(fn func(tx *Tx) error) error
 }
 
 // KVS represents global key-value store.
-var KVS *TomlStore
+var KVS Store
 
-// Init initializes the TOML file, creates if not exist, otherwise reads and decodes to struct.
+// Init initializes the store backend selected in the config.
 func Init() error {
-	KVS = &TomlStore{}
-	// store_vX.toml, where X is changed whenever new version requires re-login
-	filename := filepath.Join(GetPathPrefix(), "store_v4.toml")
-
-	KVS.mu.Lock()
-	defer KVS.mu.Unlock()
-
-	KVS.filename = filename
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		// Create a new file with an empty configuration.
-		KVS.config = Config{
-			Data: make(map[string]string),
-		}
-		return saveConfig()
+	KVS = nil
+	var (
+		kvs Store
+		err error
+	)
+	switch config.Cfg.StoreBackend {
+	case "", BackendTOML:
+		kvs, err = NewTomlStore(filepath.Join(GetPathPrefix(), TomlStoreFile))
+	case BackendMemory:
+		kvs = NewMemoryStore(nil)
+	case BackendEnv:
+		kvs = NewEnvStore(os.Environ())
+	default:
+		err = fmt.Errorf("unknown store backend %q: use %s, %s or %s", config.Cfg.StoreBackend, BackendTOML, BackendMemory, BackendEnv)
 	}
-
-	// Read and decode existing configuration from the file.
-	_, err := toml.DecodeFile(filename, &KVS.config)
-	return err
+	if err != nil {
+		return err
+	}
+	KVS = kvs
+	return nil
 }
 
-// Get retrieves the value for the specified key from the TOML store.
+// Get retrieves the value for the specified key from the store.
 func Get(key string) (string, error) {
-	KVS.mu.Lock()
-	defer KVS.mu.Unlock()
+	return KVS.Get(key)
+}
+
+// Set sets the value for the specified key in the store.
+func Set(key, value string) error {
+	return KVS.Set(key, value)
+}
+
+// Delete removes the entry for the specified key from the store.
+func Delete(key string) error {
+	return KVS.Delete(key)
+}
+
+// Keys returns the keys of the store in sorted order.
+func Keys() []string {
+	return KVS.Keys()
+}
+
+// Update runs fn in a transaction of the store.
+func Update(fn func(tx *Tx) error) error {
+	return KVS.Update(fn)
+}
 
-	value, ok := KVS.config.Data[key]
+// Batch sets and deletes several keys atomically. Deletes win over sets of
+// the same key.
+func Batch(sets map[string]string, deletes []string) error {
+	return KVS.Update(func(tx *Tx) error {
+		for key, value := range sets {
+			tx.Set(key, value)
+		}
+		for _, key := range deletes {
+			tx.Delete(key)
+		}
+		return nil
+	})
+}
+
+// Tx is a transaction of a store. Its changes are not visible outside of it
+// until it is applied.
+type Tx struct {
+	data map[string]string
+	// changes holds the new values of changed keys, nil for deleted keys
+	changes map[string]*string
+}
+
+func newTx(data map[string]string) *Tx {
+	return &Tx{data: data, changes: make(map[string]*string)}
+}
+
+// Get returns the value of key in the transaction, or ErrKeyNotFound.
+func (tx *Tx) Get(key string) (string, error) {
+	value, ok := tx.data[key]
+	if change, changed := tx.changes[key]; changed {
+		value, ok = "", change != nil
+		if ok {
+			value = *change
+		}
+	}
 	if !ok {
 		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
 	}
 	return value, nil
 }
 
-// Set sets the value for the specified key in the TOML store.
-func Set(key, value string) error {
-	KVS.mu.Lock()
-	defer KVS.mu.Unlock()
-
-	KVS.config.Data[key] = value
-	return saveConfig()
+// Set sets the value of key in the transaction.
+func (tx *Tx) Set(key, value string) {
+	tx.changes[key] = &value
 }
 
-// Delete removes the entry for the specified key from the TOML store.
-func Delete(key string) error {
-	KVS.mu.Lock()
-	defer KVS.mu.Unlock()
-
-	delete(KVS.config.Data, key)
-	return saveConfig()
+// Delete removes key in the transaction.
+func (tx *Tx) Delete(key string) {
+	tx.changes[key] = nil
 }
 
-// saveConfig saves the current configuration to the TOML file.
-func saveConfig() error {
-	file, err := os.Create(KVS.filename)
-	if err != nil {
-		return err
+// apply returns a copy of the data of the transaction with its changes, and
+// whether there were any
+func (tx *Tx) apply() (map[string]string, bool) {
+	if len(tx.changes) == 0 {
+		return tx.data, false
 	}
-	defer file.Close()
-
-	encoder := toml.NewEncoder(file)
-	return encoder.Encode(KVS.config)
+	data := maps.Clone(tx.data)
+	if data == nil {
+		data = make(map[string]string)
+	}
+	for key, change := range tx.changes {
+		if change == nil {
+			delete(data, key)
+		} else {
+			data[key] = *change
+		}
+	}
+	return data, true
 }
 
 // Errors
@@ -1,6 +1,14 @@
 package store
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+)
 
 func TestInit(t *testing.T) {
 	tests := []struct {
@@ -246,7 +254,7 @@ func TestSaveConfig(t *testing.T) {
 				t.Fatalf("Failed to initialize store: %v", err)
 			}
 
-			if err := saveConfig(); (err != nil) != tt.wantErr {
+			if err := KVS.(*TomlStore).saveConfig(); (err != nil) != tt.wantErr {
 				t.Errorf("saveConfig() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -282,3 +290,113 @@ func TestGetPathPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestInitBackends(t *testing.T) {
+	tests := []struct {
+		backend string
+		want    string
+		wantErr bool
+	}{
+		{backend: "", want: "*store.TomlStore"},
+		{backend: BackendTOML, want: "*store.TomlStore"},
+		{backend: BackendMemory, want: "*store.MemoryStore"},
+		{backend: BackendEnv, want: "*store.MemoryStore"},
+		{backend: "redis", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			cleanup, err := SetupTestPathPrefix()
+			if err != nil {
+				t.Fatalf("Failed to setup test environment: %v", err)
+			}
+			previousBackend := config.Cfg.StoreBackend
+			defer func() {
+				cleanup()
+				config.Cfg.StoreBackend = previousBackend
+			}()
+			config.Cfg.StoreBackend = tt.backend
+
+			err = Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if KVS != nil {
+					t.Errorf("KVS = %T after a failed Init(), want nil", KVS)
+				}
+				return
+			}
+			if got := fmt.Sprintf("%T", KVS); got != tt.want {
+				t.Errorf("KVS = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEnvStore(t *testing.T) {
+	s := NewEnvStore([]string{
+		"HOME=/root",
+		EnvStorePrefix + "ssoToken=sso=with=equals",
+		EnvStorePrefix + "profile__family__crm=123",
+		EnvStorePrefix + "=ignored",
+	})
+	if got, want := s.Keys(), []string{"profile.family.crm", "ssoToken"}; !slices.Equal(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+	if value, err := s.Get("ssoToken"); err != nil || value != "sso=with=equals" {
+		t.Errorf("Get(ssoToken) = %q, %v", value, err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	cleanup, err := SetupTestPathPrefix()
+	if err != nil {
+		t.Fatalf("Failed to setup test environment: %v", err)
+	}
+	defer cleanup()
+	tomlStore, err := NewTomlStore(filepath.Join(config.Cfg.PathPrefix, "store.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []Store{tomlStore, NewMemoryStore(map[string]string{})} {
+		t.Run(fmt.Sprintf("%T", s), func(t *testing.T) {
+			if err := s.Set("kept", "1"); err != nil {
+				t.Fatal(err)
+			}
+
+			// A failing transaction changes nothing
+			failure := errors.New("failure")
+			err := s.Update(func(tx *Tx) error {
+				tx.Set("discarded", "1")
+				tx.Delete("kept")
+				return failure
+			})
+			if !errors.Is(err, failure) {
+				t.Fatalf("Update() error = %v, want %v", err, failure)
+			}
+			if got := s.Keys(); !slices.Equal(got, []string{"kept"}) {
+				t.Errorf("Keys() after a failed Update() = %q", got)
+			}
+
+			// A transaction sees its own changes and applies them together
+			err = s.Update(func(tx *Tx) error {
+				tx.Set("a", "1")
+				tx.Delete("kept")
+				if value, err := tx.Get("a"); err != nil || value != "1" {
+					t.Errorf("tx.Get(a) = %q, %v", value, err)
+				}
+				if _, err := tx.Get("kept"); !errors.Is(err, ErrKeyNotFound) {
+					t.Errorf("tx.Get(kept) error = %v, want %v", err, ErrKeyNotFound)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Keys(); !slices.Equal(got, []string{"a"}) {
+				t.Errorf("Keys() after Update() = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"crypto/cipher"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config represents the structure of the TOML file.
+type Config struct {
+	// Encryption is set when the sensitive values of Data are encrypted
+	Encryption *encryptionHeader `toml:"encryption,omitempty"`
+	Data       map[string]string `toml:"data"`
+}
+
+// TomlStore represents the TOML storage.
+type TomlStore struct {
+	filename string
+	config   Config
+	// aead encrypts the sensitive values when store encryption is enabled
+	aead cipher.AEAD
+	mu   sync.Mutex
+}
+
+// NewTomlStore opens the TOML store in filename, creating it if it does not exist.
+func NewTomlStore(filename string) (*TomlStore, error) {
+	s := &TomlStore{filename: filename}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		// Create a new file with an empty configuration.
+		s.config = Config{
+			Data: make(map[string]string),
+		}
+		if err := s.setupEncryption(); err != nil {
+			return nil, err
+		}
+		return s, s.saveConfig()
+	}
+
+	// Read and decode existing configuration from the file.
+	if _, err := toml.DecodeFile(filename, &s.config); err != nil {
+		return nil, err
+	}
+	if s.config.Data == nil {
+		s.config.Data = make(map[string]string)
+	}
+	if err := s.setupEncryption(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get retrieves the value for the specified key from the TOML store.
+func (s *TomlStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return newTx(s.config.Data).Get(key)
+}
+
+// Set sets the value for the specified key in the TOML store.
+func (s *TomlStore) Set(key, value string) error {
+	return s.Update(func(tx *Tx) error {
+		tx.Set(key, value)
+		return nil
+	})
+}
+
+// Delete removes the entry for the specified key from the TOML store.
+func (s *TomlStore) Delete(key string) error {
+	return s.Update(func(tx *Tx) error {
+		tx.Delete(key)
+		return nil
+	})
+}
+
+// Keys returns the keys of the TOML store in sorted order.
+func (s *TomlStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedKeys(s.config.Data)
+}
+
+// Update runs fn in a transaction and writes its changes to the file at
+// once. The store is left unchanged if writing fails.
+func (s *TomlStore) Update(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := newTx(s.config.Data)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	data, changed := tx.apply()
+	if !changed {
+		return nil
+	}
+
+	previous := s.config.Data
+	s.config.Data = data
+	if err := s.saveConfig(); err != nil {
+		s.config.Data = previous
+		return err
+	}
+	return nil
+}
+
+// saveConfig saves the current configuration to the TOML file, encrypting
+// the sensitive values if store encryption is enabled.
+func (s *TomlStore) saveConfig() error {
+	config, err := s.encryptedConfig()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(s.filename, 0600, func(w io.Writer) error {
+		return toml.NewEncoder(w).Encode(config)
+	})
+}
+
+// WriteFileAtomic writes a file with the given permissions. It writes a
+// temporary file in the same directory and renames it over the file, so that
+// readers never see a partially written file. The data is synced to disk
+// before the rename, so that a crash never leaves a truncated file behind.
+func WriteFileAtomic(filename string, perm os.FileMode, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// sortedKeys returns the keys of data in sorted order
+func sortedKeys(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
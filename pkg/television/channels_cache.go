@@ -0,0 +1,258 @@
+package television
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+const (
+	// defaultChannelsCacheTTL is how long the channel list is fresh unless
+	// channels_cache_ttl_minutes is set
+	defaultChannelsCacheTTL = 30 * time.Minute
+	// channelsRevalidateInterval is the least time between background
+	// refreshes of an expired channel list, so that an unreachable JioTV is
+	// not asked on every request
+	channelsRevalidateInterval = time.Minute
+	// channelsSnapshotFile is the file under the path prefix holding the last
+	// channel list fetched from JioTV
+	channelsSnapshotFile = "channels_snapshot.json"
+)
+
+var (
+	// channelsCache holds the last channel list fetched from JioTV, without
+	// custom channels
+	channelsCache   *channelsSnapshot
+	channelsCacheMu sync.Mutex
+	// channelsLastAttempt is when the channel list was last fetched
+	channelsLastAttempt time.Time
+	channelsGroup       singleflight.Group
+
+	// fetchChannels fetches the channel list from JioTV. Tests replace it.
+	fetchChannels = fetchUpstreamChannels
+)
+
+// channelsSnapshot is a channel list fetched from JioTV, as cached in memory
+// and on disk
+type channelsSnapshot struct {
+	FetchedAt time.Time         `json:"fetched_at"`
+	Code      int               `json:"code"`
+	Message   string            `json:"message"`
+	Channels  []snapshotChannel `json:"channels"`
+}
+
+// snapshotChannel is a Channel without the custom JSON decoding of API
+// responses, so that snapshots read back what was written
+type snapshotChannel Channel
+
+// ChannelsCacheStatus describes the cached channel list
+type ChannelsCacheStatus struct {
+	Channels  int       `json:"channels"`
+	FetchedAt time.Time `json:"fetched_at,omitzero"`
+	Stale     bool      `json:"stale"`
+	TTL       string    `json:"ttl"`
+}
+
+// Channels returns the channels of JioTV merged with custom channels.
+//
+// The JioTV channel list is cached for channels_cache_ttl_minutes. An expired
+// list is served while it is refreshed in the background, and the last list
+// saved under the path prefix is served when JioTV cannot be reached.
+func Channels() (ChannelsResponse, error) {
+	snapshot, err := cachedChannels(time.Now())
+	if err != nil {
+		return ChannelsResponse{}, err
+	}
+
+	apiResponse := snapshot.response()
+	// Load and append custom channels if configured
+	if config.Cfg.CustomChannelsFile != "" {
+		customChannels := getCustomChannels()
+		apiResponse.Result = append(apiResponse.Result, customChannels...)
+	}
+	return apiResponse, nil
+}
+
+// RefreshChannels fetches the channel list from JioTV now, replacing the
+// cached list
+func RefreshChannels() (ChannelsCacheStatus, error) {
+	if _, err := refreshChannels(); err != nil {
+		return ChannelsCacheStatus{}, err
+	}
+	return CachedChannelsStatus(), nil
+}
+
+// CachedChannelsStatus describes the cached channel list
+func CachedChannelsStatus() ChannelsCacheStatus {
+	channelsCacheMu.Lock()
+	defer channelsCacheMu.Unlock()
+
+	ttl := channelsCacheTTL()
+	status := ChannelsCacheStatus{TTL: ttl.String()}
+	if channelsCache != nil {
+		status.Channels = len(channelsCache.Channels)
+		status.FetchedAt = channelsCache.FetchedAt
+		status.Stale = time.Since(channelsCache.FetchedAt) >= ttl
+	}
+	return status
+}
+
+// cachedChannels returns the cached channel list if it is fresh. Otherwise it
+// fetches the list, serving an expired list while it is refreshed in the
+// background and falling back to the disk snapshot.
+func cachedChannels(now time.Time) (*channelsSnapshot, error) {
+	ttl := channelsCacheTTL()
+
+	channelsCacheMu.Lock()
+	cached := channelsCache
+	fresh := cached != nil && ttl > 0 && now.Sub(cached.FetchedAt) < ttl
+	revalidate := !fresh && cached != nil && ttl > 0 && now.Sub(channelsLastAttempt) >= channelsRevalidateInterval
+	if revalidate {
+		channelsLastAttempt = now
+	}
+	channelsCacheMu.Unlock()
+
+	if fresh {
+		return cached, nil
+	}
+	if cached != nil && ttl > 0 {
+		if revalidate {
+			go func() {
+				if _, err := refreshChannels(); err != nil {
+					utils.Log.Printf("Error refreshing channels in the background: %v", err)
+				}
+			}()
+		}
+		return cached, nil
+	}
+
+	snapshot, err := refreshChannels()
+	if err == nil {
+		return snapshot, nil
+	}
+	if cached != nil {
+		utils.Log.Printf("Serving channels fetched at %s: %v", cached.FetchedAt.Format(time.RFC3339), err)
+		return cached, nil
+	}
+
+	saved, loadErr := loadChannelsSnapshot()
+	if loadErr != nil {
+		if !errors.Is(loadErr, os.ErrNotExist) {
+			utils.Log.Printf("Error loading channels snapshot: %v", loadErr)
+		}
+		return nil, err
+	}
+	utils.Log.Printf("Serving channels saved at %s: %v", saved.FetchedAt.Format(time.RFC3339), err)
+	channelsCacheMu.Lock()
+	if channelsCache == nil {
+		channelsCache = saved
+		channelsLastAttempt = now
+	}
+	channelsCacheMu.Unlock()
+	return saved, nil
+}
+
+// refreshChannels fetches the channel list from JioTV, caches it and saves
+// it to disk. Concurrent refreshes share one fetch.
+func refreshChannels() (*channelsSnapshot, error) {
+	result, err, _ := channelsGroup.Do("channels", func() (any, error) {
+		channelsCacheMu.Lock()
+		channelsLastAttempt = time.Now()
+		channelsCacheMu.Unlock()
+
+		apiResponse, err := fetchChannels()
+		if err != nil {
+			return nil, err
+		}
+		snapshot := newChannelsSnapshot(apiResponse, time.Now())
+
+		channelsCacheMu.Lock()
+		channelsCache = snapshot
+		channelsCacheMu.Unlock()
+
+		if err := saveChannelsSnapshot(snapshot); err != nil {
+			utils.Log.Printf("Error saving channels snapshot: %v", err)
+		}
+		return snapshot, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*channelsSnapshot), nil
+}
+
+// channelsCacheTTL returns how long the channel list is fresh, 0 if it is
+// not cached
+func channelsCacheTTL() time.Duration {
+	switch minutes := config.Cfg.ChannelsCacheTTLMinutes; {
+	case minutes < 0:
+		return 0
+	case minutes == 0:
+		return defaultChannelsCacheTTL
+	default:
+		return time.Duration(minutes) * time.Minute
+	}
+}
+
+func newChannelsSnapshot(apiResponse ChannelsResponse, fetchedAt time.Time) *channelsSnapshot {
+	channels := make([]snapshotChannel, len(apiResponse.Result))
+	for i, channel := range apiResponse.Result {
+		channels[i] = snapshotChannel(channel)
+	}
+	return &channelsSnapshot{
+		FetchedAt: fetchedAt,
+		Code:      apiResponse.Code,
+		Message:   apiResponse.Message,
+		Channels:  channels,
+	}
+}
+
+// response returns the snapshot as a channels response that callers may modify
+func (snapshot *channelsSnapshot) response() ChannelsResponse {
+	channels := make([]Channel, len(snapshot.Channels))
+	for i, channel := range snapshot.Channels {
+		channels[i] = Channel(channel)
+	}
+	return ChannelsResponse{
+		Code:    snapshot.Code,
+		Message: snapshot.Message,
+		Result:  channels,
+	}
+}
+
+func channelsSnapshotPath() string {
+	return filepath.Join(store.GetPathPrefix(), channelsSnapshotFile)
+}
+
+// saveChannelsSnapshot saves the channel list under the path prefix
+func saveChannelsSnapshot(snapshot *channelsSnapshot) error {
+	return store.WriteFileAtomic(channelsSnapshotPath(), 0644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(snapshot)
+	})
+}
+
+// loadChannelsSnapshot loads the channel list saved under the path prefix
+func loadChannelsSnapshot() (*channelsSnapshot, error) {
+	data, err := os.ReadFile(channelsSnapshotPath())
+	if err != nil {
+		return nil, err
+	}
+	var snapshot channelsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	if len(snapshot.Channels) == 0 {
+		return nil, errors.New("channels snapshot is empty")
+	}
+	return &snapshot, nil
+}
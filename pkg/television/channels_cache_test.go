@@ -0,0 +1,122 @@
+package television
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jiotv-go/jiotv_go/v3/internal/config"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
+)
+
+// setupChannelsCache resets the channel cache and replaces the JioTV fetch
+// with fetch for the test
+func setupChannelsCache(t *testing.T, fetch func() (ChannelsResponse, error)) {
+	t.Helper()
+	cleanup, err := store.SetupTestPathPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousLog, previousFetch, previousTTL := utils.Log, fetchChannels, config.Cfg.ChannelsCacheTTLMinutes
+	utils.Log = log.New(os.Stderr, "", 0)
+	resetChannelsCache := func() {
+		channelsCacheMu.Lock()
+		channelsCache = nil
+		channelsLastAttempt = time.Time{}
+		channelsCacheMu.Unlock()
+	}
+	resetChannelsCache()
+	fetchChannels = fetch
+	t.Cleanup(func() {
+		cleanup()
+		utils.Log, fetchChannels, config.Cfg.ChannelsCacheTTLMinutes = previousLog, previousFetch, previousTTL
+		resetChannelsCache()
+	})
+}
+
+func TestChannelsCache(t *testing.T) {
+	var fetches atomic.Int32
+	var fail atomic.Bool
+	setupChannelsCache(t, func() (ChannelsResponse, error) {
+		fetches.Add(1)
+		if fail.Load() {
+			return ChannelsResponse{}, errors.New("JioTV is down")
+		}
+		return ChannelsResponse{Code: 200, Result: []Channel{{ID: "143", Name: "Sports", RequiresSubscription: true}}}, nil
+	})
+
+	now := time.Now()
+	first, err := cachedChannels(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Channels) != 1 || fetches.Load() != 1 {
+		t.Fatalf("cachedChannels() = %+v after %d fetches", first, fetches.Load())
+	}
+
+	// A fresh list is served from memory, and callers may modify their copy
+	response := first.response()
+	response.Result[0].Name = "Changed"
+	if second, err := cachedChannels(now.Add(time.Minute)); err != nil || second.Channels[0].Name != "Sports" || fetches.Load() != 1 {
+		t.Errorf("cachedChannels() of a fresh list = %+v, %v after %d fetches", second, err, fetches.Load())
+	}
+
+	// An expired list is served while it is refreshed in the background
+	fail.Store(true)
+	if stale, err := cachedChannels(now.Add(defaultChannelsCacheTTL + time.Minute)); err != nil || stale != first {
+		t.Errorf("cachedChannels() of an expired list = %+v, %v", stale, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for fetches.Load() != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fetches.Load() != 2 {
+		t.Errorf("expired list fetched %d times, want 2", fetches.Load())
+	}
+
+	// A restart without JioTV serves the snapshot saved on disk
+	channelsCacheMu.Lock()
+	channelsCache = nil
+	channelsCacheMu.Unlock()
+	saved, err := cachedChannels(now)
+	if err != nil {
+		t.Fatalf("cachedChannels() without JioTV error = %v, want the snapshot", err)
+	}
+	if len(saved.Channels) != 1 || saved.Channels[0].ID != "143" || !saved.Channels[0].RequiresSubscription || !saved.FetchedAt.Equal(first.FetchedAt) {
+		t.Errorf("snapshot = %+v, want %+v", saved, first)
+	}
+
+	// Without a snapshot the error is returned
+	if err := os.Remove(channelsSnapshotPath()); err != nil {
+		t.Fatal(err)
+	}
+	channelsCacheMu.Lock()
+	channelsCache = nil
+	channelsCacheMu.Unlock()
+	if _, err := cachedChannels(now); err == nil {
+		t.Error("cachedChannels() without JioTV and snapshot succeeded")
+	}
+}
+
+func TestChannelsCacheDisabled(t *testing.T) {
+	var fetches atomic.Int32
+	setupChannelsCache(t, func() (ChannelsResponse, error) {
+		fetches.Add(1)
+		return ChannelsResponse{Result: []Channel{{ID: "1"}}}, nil
+	})
+	config.Cfg.ChannelsCacheTTLMinutes = -1
+
+	now := time.Now()
+	for range 3 {
+		if _, err := cachedChannels(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches.Load() != 3 {
+		t.Errorf("fetched %d times without a cache, want 3", fetches.Load())
+	}
+}
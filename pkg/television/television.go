@@ -19,6 +19,7 @@ import (
 	"github.com/jiotv-go/jiotv_go/v3/internal/constants"
 	"github.com/jiotv-go/jiotv_go/v3/internal/constants/headers"
 	"github.com/jiotv-go/jiotv_go/v3/internal/constants/urls"
+	"github.com/jiotv-go/jiotv_go/v3/pkg/secureurl"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
 	"github.com/jiotv-go/jiotv_go/v3/pkg/utils"
 )
@@ -109,6 +110,13 @@ var premiumProviderByKeyword = []struct {
 
 // New function creates a new Television instance with the provided credentials
 func New(credentials *utils.JIOTV_CREDENTIALS) *Television {
+	return NewForProfile(utils.DefaultProfile, credentials)
+}
+
+// NewForProfile creates a new Television instance for the given profile with
+// the provided credentials
+func NewForProfile(profile string, credentials *utils.JIOTV_CREDENTIALS) *Television {
+	profile = utils.NormalizeProfile(profile)
 	// Check if credentials are provided
 	if credentials == nil {
 		// If credentials are not provided, set them to empty strings
@@ -125,7 +133,7 @@ func New(credentials *utils.JIOTV_CREDENTIALS) *Television {
 		"channel_id":      "",
 		"crmid":           credentials.CRM,
 		"userId":          credentials.CRM,
-		"deviceId":        utils.GetDeviceID(),
+		"deviceId":        utils.GetDeviceIDFor(profile),
 		"devicetype":      "phone",
 		"isott":           "false",
 		"languageId":      "6",
@@ -144,6 +152,7 @@ func New(credentials *utils.JIOTV_CREDENTIALS) *Television {
 
 	// Return a new Television instance
 	return &Television{
+		Profile:     profile,
 		AccessToken: credentials.AccessToken,
 		SsoToken:    credentials.SSOToken,
 		Crm:         credentials.CRM,
@@ -193,6 +202,10 @@ func loadAndCacheCustomChannels() {
 	} else {
 		for _, channel := range channels {
 			next[channel.ID] = channel
+			// Encrypted URLs of custom channels point to their own hosts
+			if parsed, parseErr := neturl.Parse(channel.URL); parseErr == nil && parsed.Hostname() != "" {
+				secureurl.AllowHosts(parsed.Hostname())
+			}
 		}
 
 		logExcessiveChannelsWarning(len(channels), "Cached")
@@ -527,6 +540,7 @@ func convertCustomConfigToChannels(customConfig CustomChannelsConfig) []Channel
 			Category: customChannel.Category,
 			Language: customChannel.Language,
 			IsHD:     customChannel.IsHD,
+			EPGID:    customChannel.EPGID,
 		}
 		channels = append(channels, channel)
 	}
@@ -986,11 +1000,17 @@ func extractPremiumProvidersFromAccessToken(accessToken string) []PremiumProvide
 
 // PremiumProviders fetches premium providers enabled for the logged-in account.
 func PremiumProviders() ([]PremiumProvider, error) {
+	return PremiumProvidersFor(utils.DefaultProfile)
+}
+
+// PremiumProvidersFor fetches premium providers enabled for the account of
+// the given profile.
+func PremiumProvidersFor(profile string) ([]PremiumProvider, error) {
 	if store.KVS == nil {
 		return []PremiumProvider{}, nil
 	}
 
-	credentials, err := utils.GetJIOTVCredentials()
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
 	if err != nil {
 		if errors.Is(err, store.ErrKeyNotFound) {
 			return []PremiumProvider{}, nil
@@ -1537,6 +1557,12 @@ func mapPremiumProviderCatalogItems(rawItems []map[string]interface{}, providerI
 
 // PremiumProviderCatalog fetches provider catalog items available for a premium provider.
 func PremiumProviderCatalog(providerIdentifier string, page, limit int) (PremiumProviderCatalogResult, error) {
+	return PremiumProviderCatalogFor(utils.DefaultProfile, providerIdentifier, page, limit)
+}
+
+// PremiumProviderCatalogFor fetches the catalog of a premium provider with the
+// account of the given profile.
+func PremiumProviderCatalogFor(profile, providerIdentifier string, page, limit int) (PremiumProviderCatalogResult, error) {
 	result := PremiumProviderCatalogResult{
 		Result: make([]PremiumProviderCatalogItem, 0),
 	}
@@ -1552,7 +1578,7 @@ func PremiumProviderCatalog(providerIdentifier string, page, limit int) (Premium
 		return result, errors.New("not logged in")
 	}
 
-	credentials, err := utils.GetJIOTVCredentials()
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
 	if err != nil {
 		return result, err
 	}
@@ -1721,11 +1747,17 @@ var ErrPremiumUpstreamUnavailable = errors.New("JioTV's playback service is temp
 
 // PremiumProviderPlayback resolves playback URL for a premium provider item.
 func PremiumProviderPlayback(providerIdentifier string, playRequest PremiumProviderPlayRequest) (*LiveURLOutput, error) {
+	return PremiumProviderPlaybackFor(utils.DefaultProfile, providerIdentifier, playRequest)
+}
+
+// PremiumProviderPlaybackFor resolves the playback URL of a premium provider
+// item with the account of the given profile.
+func PremiumProviderPlaybackFor(profile, providerIdentifier string, playRequest PremiumProviderPlayRequest) (*LiveURLOutput, error) {
 	if store.KVS == nil {
 		return nil, errors.New("not logged in")
 	}
 
-	credentials, err := utils.GetJIOTVCredentials()
+	credentials, err := utils.GetJIOTVCredentialsFor(profile)
 	if err != nil {
 		return nil, err
 	}
@@ -1876,8 +1908,8 @@ func mergeChannels(primary, secondary []Channel) []Channel {
 	return mergedChannels
 }
 
-// Channels fetch channels from JioTV API and merge with custom channels
-func Channels() (ChannelsResponse, error) {
+// fetchUpstreamChannels fetches the channel list from JioTV API
+func fetchUpstreamChannels() (ChannelsResponse, error) {
 	// Create a fasthttp.Client
 	client := utils.GetRequestClient()
 
@@ -1935,12 +1967,6 @@ func Channels() (ChannelsResponse, error) {
 	// disable sony channels temporarily
 	// apiResponse.Result = append(apiResponse.Result, SONY_CHANNELS_API...)
 
-	// Load and append custom channels if configured
-	if config.Cfg.CustomChannelsFile != "" {
-		customChannels := getCustomChannels()
-		apiResponse.Result = append(apiResponse.Result, customChannels...)
-	}
-
 	return apiResponse, nil
 }
 
@@ -2011,7 +2037,7 @@ func FilterChannelsByDefaults(channels []Channel, categories, languages []int) [
 	return filteredChannels
 }
 
-func ReplaceM3U8(baseUrl, match []byte, params, channel_id string, quality string) []byte {
+func ReplaceM3U8(baseUrl, match []byte, params, channel_id string, quality string, opts RewriteOptions) []byte {
 	config := EncryptedURLConfig{
 		BaseURL:     string(baseUrl),
 		Match:       string(match),
@@ -2019,6 +2045,8 @@ func ReplaceM3U8(baseUrl, match []byte, params, channel_id string, quality strin
 		ChannelID:   channel_id,
 		EndpointURL: "/render.m3u8",
 		Quality:     quality,
+		Client:      opts.Client,
+		Query:       opts.Query,
 	}
 
 	result, err := CreateEncryptedURL(config)
@@ -2028,7 +2056,7 @@ func ReplaceM3U8(baseUrl, match []byte, params, channel_id string, quality strin
 	return result
 }
 
-func ReplaceTS(baseUrl, match []byte, params, channelID string) []byte {
+func ReplaceTS(baseUrl, match []byte, params, channelID string, opts RewriteOptions) []byte {
 	if config.Cfg.DisableTSHandler {
 		return []byte(string(baseUrl) + string(match) + "?" + params)
 	}
@@ -2039,6 +2067,8 @@ func ReplaceTS(baseUrl, match []byte, params, channelID string) []byte {
 		Params:      params,
 		ChannelID:   channelID,
 		EndpointURL: "/render.ts",
+		Client:      opts.Client,
+		Query:       opts.Query,
 	}
 
 	result, err := CreateEncryptedURL(config)
@@ -2048,7 +2078,7 @@ func ReplaceTS(baseUrl, match []byte, params, channelID string) []byte {
 	return result
 }
 
-func ReplaceAAC(baseUrl, match []byte, params, channelID string) []byte {
+func ReplaceAAC(baseUrl, match []byte, params, channelID string, opts RewriteOptions) []byte {
 	if config.Cfg.DisableTSHandler {
 		return []byte(string(baseUrl) + string(match) + "?" + params)
 	}
@@ -2059,6 +2089,8 @@ func ReplaceAAC(baseUrl, match []byte, params, channelID string) []byte {
 		Params:      params,
 		ChannelID:   channelID,
 		EndpointURL: "/render.ts",
+		Client:      opts.Client,
+		Query:       opts.Query,
 	}
 
 	result, err := CreateEncryptedURL(config)
@@ -2068,13 +2100,15 @@ func ReplaceAAC(baseUrl, match []byte, params, channelID string) []byte {
 	return result
 }
 
-func ReplaceKey(match []byte, params, channel_id string) []byte {
+func ReplaceKey(match []byte, params, channel_id string, opts RewriteOptions) []byte {
 	config := EncryptedURLConfig{
 		BaseURL:     "",
 		Match:       string(match),
 		Params:      params,
 		ChannelID:   channel_id,
 		EndpointURL: "/render.key",
+		Client:      opts.Client,
+		Query:       opts.Query,
 	}
 
 	result, err := CreateEncryptedURL(config)
@@ -179,7 +179,7 @@ func TestReplaceM3U8(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ReplaceM3U8(tt.args.baseUrl, tt.args.match, tt.args.params, tt.args.channel_id, tt.args.quality)
+			got := ReplaceM3U8(tt.args.baseUrl, tt.args.match, tt.args.params, tt.args.channel_id, tt.args.quality, RewriteOptions{})
 			// The function encrypts URLs, so we check that it produces some output
 			if len(got) == 0 {
 				t.Errorf("ReplaceM3U8() returned empty result")
@@ -234,7 +234,7 @@ func TestReplaceTS(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ReplaceTS(tt.args.baseUrl, tt.args.match, tt.args.params, "123")
+			got := ReplaceTS(tt.args.baseUrl, tt.args.match, tt.args.params, "123", RewriteOptions{})
 			// The function encrypts URLs, so we check that it produces some output
 			if len(got) == 0 {
 				t.Errorf("ReplaceTS() returned empty result")
@@ -285,7 +285,7 @@ func TestReplaceAAC(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ReplaceAAC(tt.args.baseUrl, tt.args.match, tt.args.params, "123")
+			got := ReplaceAAC(tt.args.baseUrl, tt.args.match, tt.args.params, "123", RewriteOptions{})
 			// The function encrypts URLs, so we check that it produces some output
 			if len(got) == 0 {
 				t.Errorf("ReplaceAAC() returned empty result")
@@ -336,7 +336,7 @@ func TestReplaceKey(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ReplaceKey(tt.args.match, tt.args.params, tt.args.channel_id)
+			got := ReplaceKey(tt.args.match, tt.args.params, tt.args.channel_id, RewriteOptions{})
 			// The function encrypts URLs, so we check that it produces some output
 			if len(got) == 0 {
 				t.Errorf("ReplaceKey() returned empty result")
@@ -10,6 +10,7 @@ import (
 
 // Television struct to store credentials and client required for making requests to JioTV API
 type Television struct {
+	Profile     string // Profile whose credentials the instance uses
 	AccessToken string
 	SsoToken    string
 	Crm         string
@@ -37,6 +38,9 @@ type Channel struct {
 	// roughly one in five, while business_type=="premium" matched playability
 	// exactly across a sample covering all four business types.
 	RequiresSubscription bool `json:"requiresSubscription"`
+
+	// EPGID is the channel ID of a custom channel in external XMLTV sources
+	EPGID string `json:"epg_id,omitempty"`
 }
 
 // businessTypePremium is the business_type value marking channels that need a
@@ -371,6 +375,7 @@ type CustomChannel struct {
 	Category int    `json:"category" yaml:"category"`
 	Language int    `json:"language" yaml:"language"`
 	IsHD     bool   `json:"is_hd" yaml:"is_hd"`
+	EPGID    string `json:"epg_id" yaml:"epg_id"` // Channel ID in the XMLTV files of the epg_sources config
 }
 
 // CustomChannelsConfig represents the structure of custom channels configuration file
@@ -17,6 +17,14 @@ type EncryptedURLConfig struct {
 	EndpointURL string // The endpoint URL pattern (e.g., "/render.m3u8", "/render.ts")
 	Quality     string // Quality parameter for live streams
 	Hdnea       string // Akamai token value to be appended as query param hdnea
+	Client      string // Client the encrypted URL is bound to, empty for any client
+	Query       string // Encoded query parameters carried to the URL, like the token of the user
+}
+
+// RewriteOptions are the options of URLs rewritten for a client
+type RewriteOptions struct {
+	Client string // Client the encrypted URLs are bound to, empty for any client
+	Query  string // Encoded query parameters carried to the URLs, like the token of the user
 }
 
 // CreateEncryptedURL creates an encrypted URL with auth parameters for various endpoints
@@ -30,7 +38,7 @@ func CreateEncryptedURL(config EncryptedURLConfig) ([]byte, error) {
 		fullURL += sep + config.Params
 	}
 
-	encryptedURL, err := secureurl.EncryptURL(fullURL)
+	encryptedURL, err := secureurl.EncryptURLFor(fullURL, config.Client)
 	if err != nil {
 		utils.Log.Println(err)
 		return nil, err
@@ -51,5 +59,9 @@ func CreateEncryptedURL(config EncryptedURLConfig) ([]byte, error) {
 		result += "&hdnea=" + config.Hdnea
 	}
 
+	if config.Query != "" {
+		result += "&" + config.Query
+	}
+
 	return []byte(result), nil
 }
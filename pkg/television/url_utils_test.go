@@ -18,6 +18,7 @@ func setupURLUtilsTest() {
 	store.Init()
 	// Initialize secureurl for URL encryption/decryption
 	secureurl.Init()
+	secureurl.AllowHosts("example.com")
 }
 
 func TestCreateEncryptedURL(t *testing.T) {
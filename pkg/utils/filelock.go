@@ -0,0 +1,24 @@
+package utils
+
+import "os"
+
+// LockFile takes an exclusive lock on the file at path, creating it if
+// needed, and waits until the lock is held. It guards read-modify-write
+// cycles of files shared by several processes, such as the server and the
+// CLI. The returned function releases the lock.
+func LockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		if err := unlockFile(file); err != nil {
+			Log.Println(err)
+		}
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json.lock")
+	unlock, err := LockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan func())
+	go func() {
+		unlockSecond, err := LockFile(path)
+		if err != nil {
+			t.Error(err)
+			close(locked)
+			return
+		}
+		locked <- unlockSecond
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("second lock was taken while the first was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case unlockSecond := <-locked:
+		if unlockSecond != nil {
+			unlockSecond()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock was not taken after the first was released")
+	}
+}
@@ -90,22 +90,12 @@ type BatchStoreOperations struct {
 	Deletes []string
 }
 
-// ExecuteBatchStoreOperations executes multiple store operations in sequence
+// ExecuteBatchStoreOperations executes multiple store operations atomically,
+// writing the store once. Deletes win over sets of the same key.
 func ExecuteBatchStoreOperations(ops BatchStoreOperations) error {
-	// Execute all Set operations
-	for key, value := range ops.Sets {
-		if err := store.Set(key, value); err != nil {
-			return fmt.Errorf("failed to set %s: %w", key, err)
-		}
+	if err := store.Batch(ops.Sets, ops.Deletes); err != nil {
+		return fmt.Errorf("failed to update store: %w", err)
 	}
-
-	// Execute all Delete operations
-	for _, key := range ops.Deletes {
-		if err := store.Delete(key); err != nil {
-			return fmt.Errorf("failed to delete %s: %w", key, err)
-		}
-	}
-
 	return nil
 }
 
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/store"
+)
+
+const (
+	// DefaultProfile is the profile whose credentials are stored without a
+	// key prefix, as they were before profiles existed
+	DefaultProfile = "default"
+	// AutoProfile is not a stored profile. Requests for it are routed to a
+	// profile entitled to the requested channel.
+	AutoProfile = "auto"
+	// profileKeyPrefix prefixes the store keys of named profiles as
+	// profile.<name>.<key>
+	profileKeyPrefix = "profile."
+)
+
+// credentialStoreKeys are the store keys holding the credentials of a profile
+var credentialStoreKeys = []string{
+	"ssoToken",
+	"crm",
+	"uniqueId",
+	"accessToken",
+	"refreshToken",
+	"lastTokenRefreshTime",
+	"lastSSOTokenRefreshTime",
+	refreshTokenRejectedAtKey,
+}
+
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// NormalizeProfile lowercases a profile name, and returns DefaultProfile for
+// an empty one
+func NormalizeProfile(profile string) string {
+	profile = strings.ToLower(strings.TrimSpace(profile))
+	if profile == "" {
+		return DefaultProfile
+	}
+	return profile
+}
+
+// ValidateProfileName checks that a profile can be stored under name
+func ValidateProfileName(name string) error {
+	if name == AutoProfile {
+		return fmt.Errorf("profile name %q is reserved", name)
+	}
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use up to 32 lowercase letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// ProfileStoreKey returns the store key of key in the given profile
+func ProfileStoreKey(profile, key string) string {
+	profile = NormalizeProfile(profile)
+	if profile == DefaultProfile {
+		return key
+	}
+	return profileKeyPrefix + profile + "." + key
+}
+
+// ListProfiles returns the profiles with credentials in the store, starting
+// with the default profile
+func ListProfiles() []string {
+	if store.KVS == nil {
+		return nil
+	}
+	var profiles []string
+	for _, key := range store.Keys() {
+		if key == "ssoToken" {
+			profiles = append(profiles, DefaultProfile)
+			continue
+		}
+		rest, ok := strings.CutPrefix(key, profileKeyPrefix)
+		if !ok {
+			continue
+		}
+		if name, ok := strings.CutSuffix(rest, ".ssoToken"); ok && name != DefaultProfile {
+			profiles = append(profiles, name)
+		}
+	}
+	slices.SortStableFunc(profiles, func(a, b string) int {
+		switch {
+		case a == b:
+			return 0
+		case a == DefaultProfile:
+			return -1
+		case b == DefaultProfile:
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	return profiles
+}
+
+// ProfileExists reports whether the profile has credentials in the store.
+// The default profile always exists.
+func ProfileExists(profile string) bool {
+	profile = NormalizeProfile(profile)
+	return profile == DefaultProfile || slices.Contains(ListProfiles(), profile)
+}
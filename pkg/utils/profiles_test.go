@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateProfileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		wantErr bool
+	}{
+		{name: "Simple name", profile: "family", wantErr: false},
+		{name: "Digits, dash and underscore", profile: "tv-2_b", wantErr: false},
+		{name: "Reserved auto", profile: AutoProfile, wantErr: true},
+		{name: "Empty", profile: "", wantErr: true},
+		{name: "Uppercase", profile: "Family", wantErr: true},
+		{name: "Path separator", profile: "a/b", wantErr: true},
+		{name: "Leading dash", profile: "-a", wantErr: true},
+		{name: "Too long", profile: "abcdefghijklmnopqrstuvwxyz0123456", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateProfileName(tt.profile); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateProfileName(%q) error = %v, wantErr %v", tt.profile, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileStoreKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		key     string
+		want    string
+	}{
+		{name: "Default profile keeps key", profile: DefaultProfile, key: "ssoToken", want: "ssoToken"},
+		{name: "Empty profile is default", profile: "", key: "crm", want: "crm"},
+		{name: "Named profile", profile: "family", key: "ssoToken", want: "profile.family.ssoToken"},
+		{name: "Named profile is normalized", profile: " Family ", key: "crm", want: "profile.family.crm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProfileStoreKey(tt.profile, tt.key); got != tt.want {
+				t.Errorf("ProfileStoreKey(%q, %q) = %q, want %q", tt.profile, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileCredentials(t *testing.T) {
+	setupTest()
+
+	credentials := &JIOTV_CREDENTIALS{
+		SSOToken:     "sso-profiletest",
+		CRM:          "crm-profiletest",
+		UniqueID:     "unique-profiletest",
+		AccessToken:  "access-profiletest",
+		RefreshToken: "refresh-profiletest",
+	}
+	if err := WriteJIOTVCredentialsFor("profiletest", credentials); err != nil {
+		t.Fatalf("WriteJIOTVCredentialsFor() error = %v", err)
+	}
+
+	got, err := GetJIOTVCredentialsFor("profiletest")
+	if err != nil || got == nil {
+		t.Fatalf("GetJIOTVCredentialsFor() = %v, %v", got, err)
+	}
+	if got.SSOToken != credentials.SSOToken || got.AccessToken != credentials.AccessToken {
+		t.Errorf("GetJIOTVCredentialsFor() = %+v, want the written credentials", got)
+	}
+	if defaultCredentials, _ := GetJIOTVCredentials(); defaultCredentials != nil && defaultCredentials.SSOToken == credentials.SSOToken {
+		t.Error("credentials of a named profile leaked into the default profile")
+	}
+
+	if !ProfileExists("profiletest") {
+		t.Error("ProfileExists() = false after writing credentials")
+	}
+	if ProfileExists("missingprofile") {
+		t.Error("ProfileExists() = true for a profile without credentials")
+	}
+	if !ProfileExists(DefaultProfile) {
+		t.Error("ProfileExists() = false for the default profile")
+	}
+
+	if err := LogoutProfile("profiletest"); err != nil {
+		t.Fatalf("LogoutProfile() error = %v", err)
+	}
+	if ProfileExists("profiletest") {
+		t.Error("ProfileExists() = true after logging out")
+	}
+}
+
+func TestListProfiles(t *testing.T) {
+	setupTest()
+
+	for _, profile := range []string{"zeta", DefaultProfile, "alpha"} {
+		if err := WriteJIOTVCredentialsFor(profile, &JIOTV_CREDENTIALS{SSOToken: "sso-" + profile}); err != nil {
+			t.Fatalf("WriteJIOTVCredentialsFor(%q) error = %v", profile, err)
+		}
+	}
+	t.Cleanup(func() {
+		var deletes []string
+		for _, profile := range []string{"zeta", DefaultProfile, "alpha"} {
+			for _, key := range credentialStoreKeys {
+				deletes = append(deletes, ProfileStoreKey(profile, key))
+			}
+		}
+		_ = ExecuteBatchStoreOperations(BatchStoreOperations{Deletes: deletes})
+	})
+
+	want := []string{DefaultProfile, "alpha", "zeta"}
+	if got := ListProfiles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListProfiles() = %v, want %v", got, want)
+	}
+}
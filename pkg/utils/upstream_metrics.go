@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/jiotv-go/jiotv_go/v3/pkg/metrics"
+)
+
+// upstreamResponses counts responses of upstream servers by host and status
+// code, "error" if the request failed
+var upstreamResponses = metrics.NewCounter("jiotv_upstream_responses_total",
+	"Responses of upstream servers by host and status code.", "host", "code")
+
+// metricsTransport is a fasthttp transport that counts upstream responses
+type metricsTransport struct{}
+
+// RoundTrip performs the request with the default transport and counts its response
+func (metricsTransport) RoundTrip(hc *fasthttp.HostClient, req *fasthttp.Request, resp *fasthttp.Response) (bool, error) {
+	retry, err := fasthttp.DefaultTransport.RoundTrip(hc, req, resp)
+	host := string(req.URI().Host())
+	if h, _, splitErr := net.SplitHostPort(host); splitErr == nil {
+		host = h
+	}
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode())
+	}
+	upstreamResponses.Inc(host, code)
+	return retry, err
+}
@@ -140,6 +140,11 @@ func LoginSendOTP(number string) (bool, error) {
 
 // LoginVerifyOTP verifies OTP for login
 func LoginVerifyOTP(number, otp string) (map[string]string, error) {
+	return LoginVerifyOTPFor(DefaultProfile, number, otp)
+}
+
+// LoginVerifyOTPFor verifies OTP and stores the credentials in the given profile
+func LoginVerifyOTPFor(profile, number, otp string) (map[string]string, error) {
 	// convert number string to base64
 	encoded_number := base64.StdEncoding.EncodeToString([]byte(number))
 
@@ -154,7 +159,7 @@ func LoginVerifyOTP(number, otp string) (map[string]string, error) {
 				Platform: LoginPayloadDeviceInfoInfoPlatform{
 					Name: "SM-G930F",
 				},
-				AndroidID: GetDeviceID(),
+				AndroidID: GetDeviceIDFor(profile),
 			},
 		},
 	}
@@ -193,7 +198,7 @@ func LoginVerifyOTP(number, otp string) (map[string]string, error) {
 		crm := result.SessionAttributes.User.SubscriberID
 		uniqueId := result.SessionAttributes.User.Unique
 
-		WriteJIOTVCredentials(&JIOTV_CREDENTIALS{
+		WriteJIOTVCredentialsFor(profile, &JIOTV_CREDENTIALS{
 			SSOToken:             ssoToken,
 			CRM:                  crm,
 			UniqueID:             uniqueId,
@@ -201,6 +206,8 @@ func LoginVerifyOTP(number, otp string) (map[string]string, error) {
 			RefreshToken:         refreshToken,
 			LastTokenRefreshTime: strconv.FormatInt(time.Now().Unix(), 10),
 		})
+		// The new login comes with a new refresh token
+		ClearRefreshTokenRejectedFor(profile)
 		return map[string]string{
 			"status":       "success",
 			"accessToken":  accessToken,
@@ -222,17 +229,28 @@ func GetPathPrefix() string {
 	return store.GetPathPrefix()
 }
 
+// WriteFileAtomic alias for store.WriteFileAtomic
+func WriteFileAtomic(filename string, perm os.FileMode, write func(io.Writer) error) error {
+	return store.WriteFileAtomic(filename, perm, write)
+}
+
 // GetDeviceID returns the device ID
 func GetDeviceID() string {
-	deviceID, err := store.Get("deviceId")
+	return GetDeviceIDFor(DefaultProfile)
+}
+
+// GetDeviceIDFor returns the device ID of the given profile
+func GetDeviceIDFor(profile string) string {
+	key := ProfileStoreKey(profile, "deviceId")
+	deviceID, err := store.Get(key)
 	if err != nil {
 		Log.Println(err)
-		err = GenerateRandomString()
+		err = generateDeviceID(key)
 		if err != nil {
 			Log.Println(err)
 			return ""
 		}
-		deviceID, err = store.Get("deviceId")
+		deviceID, err = store.Get(key)
 		if deviceID == "" {
 			Log.Println("Device ID is empty")
 			return ""
@@ -247,40 +265,45 @@ func GetDeviceID() string {
 // GetJIOTVCredentials return credentials from environment variables or credentials file
 // Important note: If credentials are provided from environment variables, they will be used instead of credentials file
 func GetJIOTVCredentials() (*JIOTV_CREDENTIALS, error) {
-	ssoToken, err := store.Get("ssoToken")
+	return GetJIOTVCredentialsFor(DefaultProfile)
+}
+
+// GetJIOTVCredentialsFor returns the credentials of the given profile
+func GetJIOTVCredentialsFor(profile string) (*JIOTV_CREDENTIALS, error) {
+	ssoToken, err := store.Get(ProfileStoreKey(profile, "ssoToken"))
 	if err != nil {
 		return nil, err
 	}
 
-	crm, err := store.Get("crm")
+	crm, err := store.Get(ProfileStoreKey(profile, "crm"))
 	if err != nil {
 		return nil, err
 	}
 
-	uniqueId, err := store.Get("uniqueId")
+	uniqueId, err := store.Get(ProfileStoreKey(profile, "uniqueId"))
 	if err != nil {
 		return nil, err
 	}
 
 	// Required for OTP login
-	accessToken, err := store.Get("accessToken")
+	accessToken, err := store.Get(ProfileStoreKey(profile, "accessToken"))
 	if err != nil {
 		return nil, nil
 	}
 
 	// Required for OTP login
-	refreshToken, err := store.Get("refreshToken")
+	refreshToken, err := store.Get(ProfileStoreKey(profile, "refreshToken"))
 	if err != nil {
 		return nil, nil
 	}
 
 	// Required for OTP login
-	lastTokenRefreshTime, err := store.Get("lastTokenRefreshTime")
+	lastTokenRefreshTime, err := store.Get(ProfileStoreKey(profile, "lastTokenRefreshTime"))
 	if err != nil {
 		return nil, nil
 	}
 
-	lastSSOTokenRefreshTime, err := store.Get("lastSSOTokenRefreshTime")
+	lastSSOTokenRefreshTime, err := store.Get(ProfileStoreKey(profile, "lastSSOTokenRefreshTime"))
 	if err != nil {
 		return nil, nil
 	}
@@ -298,6 +321,11 @@ func GetJIOTVCredentials() (*JIOTV_CREDENTIALS, error) {
 
 // WriteJIOTVCredentials writes credentials data to file
 func WriteJIOTVCredentials(credentials *JIOTV_CREDENTIALS) error {
+	return WriteJIOTVCredentialsFor(DefaultProfile, credentials)
+}
+
+// WriteJIOTVCredentialsFor writes credentials data of the given profile to file
+func WriteJIOTVCredentialsFor(profile string, credentials *JIOTV_CREDENTIALS) error {
 	// Prepare batch operations
 	sets := map[string]string{
 		"ssoToken":     credentials.SSOToken,
@@ -320,12 +348,53 @@ func WriteJIOTVCredentials(credentials *JIOTV_CREDENTIALS) error {
 		sets["lastSSOTokenRefreshTime"] = strconv.FormatInt(time.Now().Unix(), 10)
 	}
 
+	// Store the credentials under the keys of the profile
+	profileSets := make(map[string]string, len(sets))
+	for key, value := range sets {
+		profileSets[ProfileStoreKey(profile, key)] = value
+	}
+
 	// Execute batch operations
 	return ExecuteBatchStoreOperations(BatchStoreOperations{
-		Sets: sets,
+		Sets: profileSets,
 	})
 }
 
+// refreshTokenRejectedAtKey stores when JioTV last rejected the refresh token
+// of a profile, in unix seconds
+const refreshTokenRejectedAtKey = "refreshTokenRejectedAt"
+
+// SetRefreshTokenRejectedFor records that JioTV rejected the refresh token of
+// the given profile at the given time
+func SetRefreshTokenRejectedFor(profile string, at time.Time) error {
+	return store.Set(ProfileStoreKey(profile, refreshTokenRejectedAtKey), strconv.FormatInt(at.Unix(), 10))
+}
+
+// ClearRefreshTokenRejectedFor forgets a rejection of the refresh token of the
+// given profile after it worked again or the profile logged in again
+func ClearRefreshTokenRejectedFor(profile string) error {
+	key := ProfileStoreKey(profile, refreshTokenRejectedAtKey)
+	if _, err := store.Get(key); err != nil {
+		return nil
+	}
+	return store.Delete(key)
+}
+
+// RefreshTokenRejectedAtFor returns when JioTV last rejected the refresh token
+// of the given profile. It returns false if the refresh token was not rejected
+// since it last worked.
+func RefreshTokenRejectedAtFor(profile string) (time.Time, bool) {
+	value, err := store.Get(ProfileStoreKey(profile, refreshTokenRejectedAtKey))
+	if err != nil {
+		return time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(unix, 0), true
+}
+
 // CheckLoggedIn function checks if user is logged in
 func CheckLoggedIn() bool {
 	// Check if credentials.json exists
@@ -340,31 +409,37 @@ func CheckLoggedIn() bool {
 
 // Logout function deletes credentials file
 func Logout() error {
+	return LogoutProfile(DefaultProfile)
+}
+
+// LogoutProfile logs out of the given profile and deletes its credentials
+func LogoutProfile(profile string) error {
 	// Perform server-side logout first
-	if err := PerformServerLogout(); err != nil {
+	if err := PerformServerLogoutFor(profile); err != nil {
 		// Log the error but continue with local logout
 		Log.Printf("PerformServerLogout failed: %v", err)
 	}
 
 	// Delete all key-value pairs from the store using batch operations
+	deletes := make([]string, 0, len(credentialStoreKeys))
+	for _, key := range credentialStoreKeys {
+		deletes = append(deletes, ProfileStoreKey(profile, key))
+	}
 	return ExecuteBatchStoreOperations(BatchStoreOperations{
-		Deletes: []string{
-			"ssoToken",
-			"crm",
-			"uniqueId",
-			"accessToken",
-			"refreshToken",
-			"lastTokenRefreshTime",
-			"lastSSOTokenRefreshTime",
-		},
+		Deletes: deletes,
 	})
 }
 
 // PerformServerLogout attempts to log out the user from the JioTV servers.
 func PerformServerLogout() error {
+	return PerformServerLogoutFor(DefaultProfile)
+}
+
+// PerformServerLogoutFor attempts to log the given profile out from the JioTV servers.
+func PerformServerLogoutFor(profile string) error {
 	Log.Println("Attempting server-side logout...")
 
-	creds, err := GetJIOTVCredentials()
+	creds, err := GetJIOTVCredentialsFor(profile)
 	if err != nil {
 		Log.Printf("Error getting credentials for server logout: %v\n", err)
 		// Depending on the error, we might still proceed if critical info like refreshToken is available
@@ -374,7 +449,7 @@ func PerformServerLogout() error {
 		}
 	}
 
-	deviceID := GetDeviceID()
+	deviceID := GetDeviceIDFor(profile)
 	if deviceID == "" {
 		Log.Println("Device ID is empty, cannot perform server logout.")
 		return fmt.Errorf("deviceId is empty")
@@ -436,6 +511,27 @@ func PerformServerLogout() error {
 	return fmt.Errorf("server logout API request failed with status code: %d", resp.StatusCode())
 }
 
+// Kinds of proxies returned by RequestProxy
+const (
+	ProxyDirect = "direct"
+	ProxySOCKS5 = "socks5"
+	ProxyHTTP   = "http"
+)
+
+// RequestProxy returns the kind of proxy GetRequestClient uses and its URL
+// with the password redacted, or ProxyDirect and "" without a proxy
+func RequestProxy() (kind, proxyURL string) {
+	proxy := config.Cfg.Proxy
+	switch {
+	case proxy == "":
+		return ProxyDirect, ""
+	case strings.HasPrefix(proxy, "socks5://"):
+		return ProxySOCKS5, config.RedactURL(proxy)
+	default:
+		return ProxyHTTP, config.RedactURL(proxy)
+	}
+}
+
 // GetRequestClient create a HTTP client with proxy if given
 // Otherwise create a HTTP client without proxy
 // Returns a fasthttp.Client
@@ -443,25 +539,25 @@ func GetRequestClient() *fasthttp.Client {
 	// The function shall return a fasthttp.client with proxy if given
 	proxy := config.Cfg.Proxy
 
-	if proxy != "" {
-		Log.Println("Using proxy: " + proxy)
-		// check if given proxy is socks5 or http
-		if strings.HasPrefix(proxy, "socks5://") {
-			// socks5 proxy
-			return &fasthttp.Client{
-				Dial: fasthttpproxy.FasthttpSocksDialerDualStack(proxy),
-			}
-		} else {
-			// http proxy
-			return &fasthttp.Client{
-				Dial: fasthttpproxy.FasthttpHTTPDialerDualStackTimeout(proxy, 10*time.Second),
-			}
+	switch kind, redactedProxy := RequestProxy(); kind {
+	case ProxySOCKS5:
+		Log.Println("Using proxy: " + redactedProxy)
+		return &fasthttp.Client{
+			Dial:      fasthttpproxy.FasthttpSocksDialerDualStack(proxy),
+			Transport: metricsTransport{},
+		}
+	case ProxyHTTP:
+		Log.Println("Using proxy: " + redactedProxy)
+		return &fasthttp.Client{
+			Dial:      fasthttpproxy.FasthttpHTTPDialerDualStackTimeout(proxy, 10*time.Second),
+			Transport: metricsTransport{},
 		}
 	}
 	return &fasthttp.Client{
 		Dial: fasthttp.DialFunc(func(addr string) (netConn net.Conn, err error) {
 			return fasthttp.DialDualStackTimeout(addr, 5*time.Second)
 		}),
+		Transport: metricsTransport{},
 	}
 }
 
@@ -501,14 +597,22 @@ func ContainsString(item string, slice []string) bool {
 
 // GenerateRandomString generates a random 16-character hexadecimal string.
 func GenerateRandomString() error {
+	return generateDeviceID("deviceId")
+}
+
+// generateDeviceID stores a random 16-character hexadecimal device ID in key
+// unless it is already set.
+func generateDeviceID(key string) error {
 	bytes := make([]byte, 8) // 8 bytes will result in a 16-character hex string
 	if _, err := rand.Read(bytes); err != nil {
 		return err
 	}
-	if _, err := store.Get("deviceId"); err != nil {
-		store.Set("deviceId", hex.EncodeToString(bytes))
-	}
-	return nil
+	return store.Update(func(tx *store.Tx) error {
+		if _, err := tx.Get(key); err != nil {
+			tx.Set(key, hex.EncodeToString(bytes))
+		}
+		return nil
+	})
 }
 
 func BuildHLSPlayURL(quality, channelID string) string {